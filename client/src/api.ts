@@ -1 +1,26 @@
 export const API_URL = import.meta.env.VITE_API_URL;
+
+// The largest page the movie listings serve
+const MOVIES_PAGE_LIMIT = 100;
+
+// fetchAllMovies follows next_cursor until the listing is exhausted, so pages that sort and search
+// on the client see the whole catalog rather than its first page
+export async function fetchAllMovies(path: string, init?: RequestInit): Promise<any[]> {
+  const movies: any[] = [];
+  const separator = path.includes("?") ? "&" : "?";
+  let cursor = "";
+
+  do {
+    let url = `${API_URL}${path}${separator}limit=${MOVIES_PAGE_LIMIT}`;
+    if (cursor) url += `&cursor=${encodeURIComponent(cursor)}`;
+
+    const response = await fetch(url, init);
+    if (!response.ok) throw new Error("Failed to fetch movies");
+
+    const data: { movies: any[]; next_cursor?: string } = await response.json();
+    movies.push(...data.movies);
+    cursor = data.next_cursor ?? "";
+  } while (cursor);
+
+  return movies;
+}
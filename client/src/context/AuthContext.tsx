@@ -8,6 +8,7 @@ interface UserDetails {
   lastName: string;
   email: string;
   pictureUrl?: string;
+  role?: "user" | "editor" | "admin";
 }
 
 interface AuthContextType {
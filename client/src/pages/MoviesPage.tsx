@@ -3,7 +3,7 @@ import { FaSortAmountDown, FaSortAmountUp } from "react-icons/fa";
 import { FaHeart, FaRegHeart } from "react-icons/fa6";
 import { Link } from "react-router";
 
-import { API_URL } from "../api";
+import { API_URL, fetchAllMovies } from "../api";
 import GenreTag from "../components/GenreTag";
 import UserRatingStar from "../components/UserRatingStar";
 import { useAlert } from "../context/AlertContext";
@@ -29,13 +29,10 @@ export default function MoviesPage() {
       if (fetchError) return;
       setIsLoading(true);
 
-      const apiEndpoint = userDetails ? `${API_URL}/api/movies` : `${API_URL}/api/public/movies`;
+      const apiPath = userDetails ? "/api/movies" : "/api/public/movies";
 
       try {
-        const response = await fetch(apiEndpoint, { credentials: "include" });
-        if (!response.ok) throw new Error("Failed to fetch movies");
-
-        const data = await response.json();
+        const data = await fetchAllMovies(apiPath, { credentials: "include" });
 
         const movies = data.map(
           (movie: any) =>
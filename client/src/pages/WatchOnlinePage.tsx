@@ -2,7 +2,7 @@ import { useEffect, useState } from "react";
 import { FaPlay } from "react-icons/fa";
 import { Link } from "react-router";
 
-import { API_URL } from "../api";
+import { fetchAllMovies } from "../api";
 import GenreTag from "../components/GenreTag";
 import UserRatingStar from "../components/UserRatingStar";
 import { useAlert } from "../context/AlertContext";
@@ -16,12 +16,8 @@ export default function WatchOnlinePage() {
 
   useEffect(() => {
     if (fetchError) return;
-    fetch(`${API_URL}/api/public/movies`)
-      .then((res) => {
-        if (!res.ok) throw new Error("Failed to fetch movies");
-        return res.json();
-      })
-      .then((data: any[]) => {
+    fetchAllMovies("/api/public/movies")
+      .then((data) => {
         const movies = data.map(
           (movie) =>
             new Movie(
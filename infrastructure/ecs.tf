@@ -102,6 +102,50 @@ resource "aws_ecs_task_definition" "app_task" {
         {
           name  = "LOGS_PATH"
           value = var.environment_variables["LOGS_PATH"]
+        },
+        {
+          name  = "TRUST_FORWARDED_FOR"
+          value = var.environment_variables["TRUST_FORWARDED_FOR"]
+        },
+        {
+          name  = "LOGIN_MAX_FAILURES_PER_ACCOUNT"
+          value = var.environment_variables["LOGIN_MAX_FAILURES_PER_ACCOUNT"]
+        },
+        {
+          name  = "LOGIN_MAX_FAILURES_PER_IP"
+          value = var.environment_variables["LOGIN_MAX_FAILURES_PER_IP"]
+        },
+        {
+          name  = "LOGIN_FAILURE_WINDOW"
+          value = var.environment_variables["LOGIN_FAILURE_WINDOW"]
+        },
+        {
+          name  = "LOGIN_LOCKOUT_DURATION"
+          value = var.environment_variables["LOGIN_LOCKOUT_DURATION"]
+        },
+        {
+          name  = "LOGIN_DELAY_BASE"
+          value = var.environment_variables["LOGIN_DELAY_BASE"]
+        },
+        {
+          name  = "LOGIN_DELAY_MAX"
+          value = var.environment_variables["LOGIN_DELAY_MAX"]
+        },
+        {
+          name  = "MAILER_BACKEND"
+          value = var.environment_variables["MAILER_BACKEND"]
+        },
+        {
+          name  = "MAIL_FROM"
+          value = var.environment_variables["MAIL_FROM"]
+        },
+        {
+          name  = "SMTP_HOST"
+          value = var.environment_variables["SMTP_HOST"]
+        },
+        {
+          name  = "SMTP_PORT"
+          value = var.environment_variables["SMTP_PORT"]
         }
       ],
       secrets = [
@@ -128,6 +172,14 @@ resource "aws_ecs_task_definition" "app_task" {
         {
           name      = "ALLOY_PASSWORD"
           valueFrom = "${data.aws_secretsmanager_secret_version.movie_search_secrets_version.arn}:ALLOY_PASSWORD::"
+        },
+        {
+          name      = "SMTP_USERNAME"
+          valueFrom = "${data.aws_secretsmanager_secret_version.movie_search_secrets_version.arn}:SMTP_USERNAME::"
+        },
+        {
+          name      = "SMTP_PASSWORD"
+          valueFrom = "${data.aws_secretsmanager_secret_version.movie_search_secrets_version.arn}:SMTP_PASSWORD::"
         }
       ],
       mountPoints = [
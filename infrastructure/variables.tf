@@ -36,6 +36,18 @@ variable "environment_variables" {
     ENV                   = "production"
     ALLOY_HOST            = "127.0.0.1:8100"
     LOGS_PATH             = "/var/log/movie-search.log"
+    # The ALB appends the client address to X-Forwarded-For; without it every login comes from the balancer
+    TRUST_FORWARDED_FOR            = "true"
+    LOGIN_MAX_FAILURES_PER_ACCOUNT = "5"
+    LOGIN_MAX_FAILURES_PER_IP      = "20"
+    LOGIN_FAILURE_WINDOW           = "15m"
+    LOGIN_LOCKOUT_DURATION         = "15m"
+    LOGIN_DELAY_BASE               = "250ms"
+    LOGIN_DELAY_MAX                = "4s"
+    MAILER_BACKEND                 = "smtp"
+    MAIL_FROM                      = "Movie Search <no-reply@martishin.com>"
+    SMTP_HOST                      = "email-smtp.us-east-1.amazonaws.com"
+    SMTP_PORT                      = "587"
   }
 }
 
@@ -14,6 +14,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/martishin/movie-search-service/internal/adapter"
 	"github.com/martishin/movie-search-service/internal/db"
+	"github.com/martishin/movie-search-service/internal/mailer"
 	"github.com/martishin/movie-search-service/internal/model/config"
 	"github.com/martishin/movie-search-service/internal/server"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -36,7 +37,13 @@ func setupLogger(config *config.ObservabilityConfig) *slog.Logger {
 	return slog.New(multiWriter)
 }
 
-func gracefulShutdown(logger *slog.Logger, apiServer *http.Server, pool *pgxpool.Pool, done chan struct{}) {
+func gracefulShutdown(
+	logger *slog.Logger,
+	apiServer *http.Server,
+	stopBackground context.CancelFunc,
+	pool *pgxpool.Pool,
+	done chan struct{},
+) {
 	// Create context that listens for the interrupt signal from the OS.
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -49,6 +56,9 @@ func gracefulShutdown(logger *slog.Logger, apiServer *http.Server, pool *pgxpool
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	logger.Info("Stopping background jobs...")
+	stopBackground()
+
 	logger.Info("Closing database connection pool...")
 	pool.Close()
 
@@ -104,12 +114,13 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Connect to Redis
-	redisClient, err := db.NewRedisClient(redisConfig)
+	// Connect to the cache backend
+	cacheBackend, redisClient, err := db.NewCacheBackend(redisConfig)
 	if err != nil {
 		logger.Error("Failed to connect to Redis", slog.Any("error", err))
 		os.Exit(1)
 	}
+	logger.Info("Cache backend configured", slog.String("backend", redisConfig.CacheBackend))
 
 	// Read server config
 	serverConfig, err := adapter.ReadServerConfig()
@@ -125,13 +136,73 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Create the server
+	// Read OAuth providers config
+	oauthProvidersConfig, err := adapter.ReadOAuthProvidersConfig()
+	if err != nil {
+		logger.Error("Failed to read OAuth providers config", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Read search config
+	searchConfig, err := adapter.ReadSearchConfig()
+	if err != nil {
+		logger.Error("Failed to read search config", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Read RBAC config
+	rbacConfig, err := adapter.ReadRBACConfig()
+	if err != nil {
+		logger.Error("Failed to read RBAC config", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Read mailer config
+	mailerConfig, err := adapter.ReadMailerConfig()
+	if err != nil {
+		logger.Error("Failed to read mailer config", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Read two-factor config
+	twoFactorConfig, err := adapter.ReadTwoFactorConfig()
+	if err != nil {
+		logger.Error("Failed to read two-factor config", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Read login protection config
+	loginConfig, err := adapter.ReadLoginProtectionConfig()
+	if err != nil {
+		logger.Error("Failed to read login protection config", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Create the mailer
+	accountMailer, err := mailer.New(mailerConfig, logger)
+	if err != nil {
+		logger.Error("Failed to create mailer", slog.Any("error", err))
+		os.Exit(1)
+	}
+	logger.Info("Mailer configured", slog.String("backend", mailerConfig.Backend))
+
+	// Create the server; its background jobs stop on shutdown
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
 	serv := server.NewServer(
+		backgroundCtx,
 		logger,
 		postgresPool,
 		redisClient,
+		cacheBackend,
+		accountMailer,
 		serverConfig,
 		oauthConfig,
+		oauthProvidersConfig,
+		searchConfig,
+		rbacConfig,
+		mailerConfig,
+		twoFactorConfig,
+		loginConfig,
 		observabilityConfig,
 	)
 
@@ -139,7 +210,7 @@ func main() {
 	done := make(chan struct{})
 
 	// Run graceful shutdown in a separate goroutine
-	go gracefulShutdown(logger, serv, postgresPool, done)
+	go gracefulShutdown(logger, serv, stopBackground, postgresPool, done)
 
 	logger.Info("Starting server", slog.String("address", "http://localhost"+serv.Addr))
 	err = serv.ListenAndServe()
@@ -1,9 +1,14 @@
 package adapter
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"net/mail"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/martishin/movie-search-service/internal/model/config"
@@ -53,6 +58,59 @@ func ReadGoogleOauthConfig() (*config.OAuthConfig, error) {
 	}, nil
 }
 
+// ReadOAuthProvidersConfig reads the optional GitHub and OpenID Connect providers. Each is configured by all of its
+// variables or none.
+func ReadOAuthProvidersConfig() (*config.OAuthProvidersConfig, error) {
+	providersConfig := &config.OAuthProvidersConfig{}
+
+	gitHub, err := readOAuthProviderConfig("GITHUB")
+	if err != nil {
+		return nil, err
+	}
+	providersConfig.GitHub = gitHub
+
+	oidc, err := readOAuthProviderConfig("OIDC")
+	if err != nil {
+		return nil, err
+	}
+	if oidc != nil {
+		discoveryURL := os.Getenv("OIDC_DISCOVERY_URL")
+		if discoveryURL == "" {
+			return nil, fmt.Errorf("missing OIDC_DISCOVERY_URL environment variable")
+		}
+		name := os.Getenv("OIDC_PROVIDER_NAME")
+		if name == "" {
+			name = "oidc"
+		}
+		providersConfig.OIDC = &config.OIDCProviderConfig{
+			OAuthProviderConfig: *oidc,
+			Name:                name,
+			DiscoveryURL:        discoveryURL,
+		}
+	}
+
+	return providersConfig, nil
+}
+
+func readOAuthProviderConfig(prefix string) (*config.OAuthProviderConfig, error) {
+	clientID := os.Getenv(prefix + "_CLIENT_ID")
+	clientSecret := os.Getenv(prefix + "_CLIENT_SECRET")
+	callbackURL := os.Getenv(prefix + "_CALLBACK_URL")
+
+	if clientID == "" && clientSecret == "" && callbackURL == "" {
+		return nil, nil
+	}
+	if clientID == "" || clientSecret == "" || callbackURL == "" {
+		return nil, fmt.Errorf("%s_CLIENT_ID, %s_CLIENT_SECRET and %s_CALLBACK_URL must be set together", prefix, prefix, prefix)
+	}
+
+	return &config.OAuthProviderConfig{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		CallbackURL:  callbackURL,
+	}, nil
+}
+
 func ReadPostgresConfig() (*config.PostgresConfig, error) {
 	host := os.Getenv("POSTGRES_HOST")
 	database := os.Getenv("POSTGRES_DATABASE")
@@ -75,6 +133,21 @@ func ReadRedisConfig() (*config.RedisConfig, error) {
 	host := os.Getenv("REDIS_HOST")
 	port := os.Getenv("REDIS_PORT")
 
+	// Without Redis settings the service caches in process, so it can run on PostgreSQL alone
+	backend := os.Getenv("CACHE_BACKEND")
+	if backend == "" {
+		backend = config.CacheBackendRedis
+		if host == "" && port == "" {
+			backend = config.CacheBackendMemory
+		}
+	}
+	if backend != config.CacheBackendRedis && backend != config.CacheBackendMemory && backend != config.CacheBackendNone {
+		return nil, fmt.Errorf("invalid CACHE_BACKEND: must be one of redis, memory or none")
+	}
+	if backend != config.CacheBackendRedis {
+		return &config.RedisConfig{CacheBackend: backend}, nil
+	}
+
 	if host == "" || port == "" {
 		return nil, fmt.Errorf("missing required Redis environment variables")
 	}
@@ -86,9 +159,32 @@ func ReadRedisConfig() (*config.RedisConfig, error) {
 	}
 
 	return &config.RedisConfig{
-		Host: host,
-		Port: port,
-		DB:   db,
+		CacheBackend: backend,
+		Host:         host,
+		Port:         port,
+		DB:           db,
+	}, nil
+}
+
+func ReadSearchConfig() (*config.SearchConfig, error) {
+	threshold := 0.4
+
+	if thresholdStr := os.Getenv("SEARCH_SIMILARITY_THRESHOLD"); thresholdStr != "" {
+		parsedThreshold, err := strconv.ParseFloat(thresholdStr, 64)
+		if err != nil || parsedThreshold <= 0 || parsedThreshold > 1 {
+			return nil, fmt.Errorf("invalid SEARCH_SIMILARITY_THRESHOLD: must be a number in (0, 1]")
+		}
+		threshold = parsedThreshold
+	}
+
+	return &config.SearchConfig{
+		SimilarityThreshold: threshold,
+	}, nil
+}
+
+func ReadRBACConfig() (*config.RBACConfig, error) {
+	return &config.RBACConfig{
+		BootstrapAdminEmail: strings.TrimSpace(os.Getenv("BOOTSTRAP_ADMIN_EMAIL")),
 	}, nil
 }
 
@@ -107,3 +203,153 @@ func ReadObservabilityConfig() (*config.ObservabilityConfig, error) {
 		LogPath:       logPath,
 	}, nil
 }
+
+func ReadMailerConfig() (*config.MailerConfig, error) {
+	backend := os.Getenv("MAILER_BACKEND")
+	if backend == "" {
+		backend = config.MailerBackendLog
+	}
+
+	// Links in emails lead to the client, which defaults to where OAuth logins land
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = os.Getenv("REDIRECT_URL")
+	}
+	if appURL == "" {
+		return nil, fmt.Errorf("missing APP_URL environment variable")
+	}
+
+	// Links to API endpoints, such as data export downloads, bypass the client and default to the origin that serves
+	// the Google OAuth callback
+	apiURL := os.Getenv("API_URL")
+	if apiURL == "" {
+		if callbackURL, err := url.Parse(os.Getenv("GOOGLE_CALLBACK_URL")); err == nil && callbackURL.Host != "" {
+			apiURL = callbackURL.Scheme + "://" + callbackURL.Host
+		}
+	}
+	if apiURL == "" {
+		return nil, fmt.Errorf("missing API_URL environment variable")
+	}
+
+	mailerConfig := &config.MailerConfig{
+		Backend:      backend,
+		From:         os.Getenv("MAIL_FROM"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     os.Getenv("SMTP_PORT"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		Directory:    os.Getenv("MAIL_DIRECTORY"),
+		AppURL:       strings.TrimRight(appURL, "/"),
+		APIURL:       strings.TrimRight(apiURL, "/"),
+	}
+
+	switch backend {
+	case config.MailerBackendSMTP:
+		if mailerConfig.From == "" || mailerConfig.SMTPHost == "" || mailerConfig.SMTPPort == "" {
+			return nil, fmt.Errorf("missing required SMTP environment variables")
+		}
+	case config.MailerBackendFile:
+		if mailerConfig.Directory == "" {
+			mailerConfig.Directory = "./mail"
+		}
+	case config.MailerBackendLog:
+	default:
+		return nil, fmt.Errorf("invalid MAILER_BACKEND: must be one of smtp, file or log")
+	}
+	// The local backends keep sign-in and reset links out of users' inboxes and in logs or on disk instead
+	if backend != config.MailerBackendSMTP && os.Getenv("ENV") == "production" {
+		return nil, fmt.Errorf("invalid MAILER_BACKEND: production must send mail with smtp")
+	}
+	if mailerConfig.From == "" {
+		mailerConfig.From = "Movie Search <no-reply@localhost>"
+	}
+	from, err := mail.ParseAddress(mailerConfig.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+	mailerConfig.From = from.String()
+	mailerConfig.FromAddress = from.Address
+
+	return mailerConfig, nil
+}
+
+func ReadTwoFactorConfig() (*config.TwoFactorConfig, error) {
+	issuer := os.Getenv("TWO_FACTOR_ISSUER")
+	if issuer == "" {
+		issuer = "Movie Search"
+	}
+
+	// Without a dedicated key, derive one from the session secret so existing deployments keep starting
+	var key []byte
+	if encoded := os.Getenv("TWO_FACTOR_ENCRYPTION_KEY"); encoded != "" {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(decoded) != 32 {
+			return nil, fmt.Errorf("invalid TWO_FACTOR_ENCRYPTION_KEY: must be 32 bytes encoded as base64")
+		}
+		key = decoded
+	} else if sessionSecret := os.Getenv("SESSION_SECRET"); sessionSecret != "" {
+		derived := sha256.Sum256([]byte("two-factor-encryption:" + sessionSecret))
+		key = derived[:]
+	} else {
+		return nil, fmt.Errorf("missing TWO_FACTOR_ENCRYPTION_KEY or SESSION_SECRET environment variable")
+	}
+
+	return &config.TwoFactorConfig{
+		Issuer:        issuer,
+		EncryptionKey: key,
+	}, nil
+}
+
+func ReadLoginProtectionConfig() (*config.LoginProtectionConfig, error) {
+	loginConfig := &config.LoginProtectionConfig{
+		MaxFailuresPerAccount: 5,
+		MaxFailuresPerIP:      20,
+		FailureWindow:         15 * time.Minute,
+		LockoutDuration:       15 * time.Minute,
+		DelayBase:             250 * time.Millisecond,
+		DelayMax:              4 * time.Second,
+	}
+
+	intParams := []struct {
+		name  string
+		value *int
+	}{
+		{"LOGIN_MAX_FAILURES_PER_ACCOUNT", &loginConfig.MaxFailuresPerAccount},
+		{"LOGIN_MAX_FAILURES_PER_IP", &loginConfig.MaxFailuresPerIP},
+	}
+	for _, param := range intParams {
+		if valueStr := os.Getenv(param.name); valueStr != "" {
+			value, err := strconv.Atoi(valueStr)
+			if err != nil || value < 1 {
+				return nil, fmt.Errorf("invalid %s: must be a positive integer", param.name)
+			}
+			*param.value = value
+		}
+	}
+
+	durationParams := []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"LOGIN_FAILURE_WINDOW", &loginConfig.FailureWindow},
+		{"LOGIN_LOCKOUT_DURATION", &loginConfig.LockoutDuration},
+		{"LOGIN_DELAY_BASE", &loginConfig.DelayBase},
+		{"LOGIN_DELAY_MAX", &loginConfig.DelayMax},
+	}
+	for _, param := range durationParams {
+		if valueStr := os.Getenv(param.name); valueStr != "" {
+			value, err := time.ParseDuration(valueStr)
+			if err != nil || value < 0 {
+				return nil, fmt.Errorf("invalid %s: must be a duration such as 15m", param.name)
+			}
+			*param.value = value
+		}
+	}
+	if loginConfig.FailureWindow == 0 || loginConfig.LockoutDuration == 0 {
+		return nil, fmt.Errorf("invalid login protection config: LOGIN_FAILURE_WINDOW and LOGIN_LOCKOUT_DURATION must be positive")
+	}
+
+	loginConfig.TrustForwardedFor = os.Getenv("TRUST_FORWARDED_FOR") == "true"
+
+	return loginConfig, nil
+}
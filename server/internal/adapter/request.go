@@ -1,13 +1,31 @@
 package adapter
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/markbates/goth/gothic"
+	"github.com/martishin/movie-search-service/internal/model/domain"
 )
 
+type userIDContextKey struct{}
+
+// WithUserID records the authenticated user in the context, for requests authenticated by other means than the session
+func WithUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userIDContextKey{}, userID)
+}
+
+// GetUserIDFromSession returns the user the request was authenticated as, by an API key or else the session
 func GetUserIDFromSession(r *http.Request) (int, error) {
+	if userID, ok := r.Context().Value(userIDContextKey{}).(int); ok {
+		return userID, nil
+	}
+
 	// Retrieve user ID from session
 	userIDStr, err := gothic.GetFromSession("user_id", r)
 	if err != nil || userIDStr == "" {
@@ -22,3 +40,255 @@ func GetUserIDFromSession(r *http.Request) (int, error) {
 
 	return userID, nil
 }
+
+// ParseMovieFilter reads listing filters from the query string.
+// List parameters may be repeated (?genre=1&genre=2) or comma separated (?genre=1,2).
+func ParseMovieFilter(r *http.Request) (domain.MovieFilter, error) {
+	query := r.URL.Query()
+	filter := domain.MovieFilter{GenreMatch: domain.GenreMatchAny}
+
+	for _, value := range splitQueryValues(query["genre"]) {
+		genreID, err := strconv.Atoi(value)
+		if err != nil || genreID < 1 {
+			return filter, fmt.Errorf("invalid genre: %q", value)
+		}
+		filter.GenreIDs = append(filter.GenreIDs, genreID)
+	}
+
+	if genreMatch := query.Get("genre_match"); genreMatch != "" {
+		if genreMatch != domain.GenreMatchAny && genreMatch != domain.GenreMatchAll {
+			return filter, fmt.Errorf("invalid genre_match: must be %q or %q", domain.GenreMatchAny, domain.GenreMatchAll)
+		}
+		filter.GenreMatch = genreMatch
+	}
+
+	intParams := []struct {
+		name  string
+		value *int
+	}{
+		{"year_from", &filter.YearFrom},
+		{"year_to", &filter.YearTo},
+		{"runtime_min", &filter.RuntimeMin},
+		{"runtime_max", &filter.RuntimeMax},
+	}
+	for _, param := range intParams {
+		valueStr := query.Get(param.name)
+		if valueStr == "" {
+			continue
+		}
+		value, err := strconv.Atoi(valueStr)
+		if err != nil || value < 1 {
+			return filter, fmt.Errorf("invalid %s: %q", param.name, valueStr)
+		}
+		*param.value = value
+	}
+
+	if filter.YearTo > 0 && filter.YearFrom > filter.YearTo {
+		return filter, fmt.Errorf("year_from must not be after year_to")
+	}
+	if filter.RuntimeMax > 0 && filter.RuntimeMin > filter.RuntimeMax {
+		return filter, fmt.Errorf("runtime_min must not exceed runtime_max")
+	}
+
+	filter.MPAARatings = splitQueryValues(query["mpaa_rating"])
+
+	if minRatingStr := query.Get("min_rating"); minRatingStr != "" {
+		minRating, err := strconv.ParseFloat(minRatingStr, 64)
+		if err != nil || minRating < 0 || minRating > 5 {
+			return filter, fmt.Errorf("invalid min_rating: must be between 0 and 5")
+		}
+		filter.MinUserRating = minRating
+	}
+
+	return filter, nil
+}
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// ParseMoviePageRequest reads the sort, order, limit and cursor query parameters.
+// A cursor is only valid together with the sort and order it was issued for.
+func ParseMoviePageRequest(r *http.Request) (domain.MoviePageRequest, error) {
+	query := r.URL.Query()
+	page := domain.MoviePageRequest{
+		Sort:  domain.MovieSort{Field: domain.SortByTitle, Direction: domain.SortAsc},
+		Limit: defaultPageLimit,
+	}
+
+	if field := query.Get("sort"); field != "" {
+		switch field {
+		case domain.SortByTitle, domain.SortByReleaseDate, domain.SortByUserRating, domain.SortByRuntime, domain.SortByCreatedAt:
+			page.Sort.Field = field
+		default:
+			return page, fmt.Errorf("invalid sort: %q", field)
+		}
+	}
+
+	if direction := query.Get("order"); direction != "" {
+		if direction != domain.SortAsc && direction != domain.SortDesc {
+			return page, fmt.Errorf("invalid order: must be %q or %q", domain.SortAsc, domain.SortDesc)
+		}
+		page.Sort.Direction = direction
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return page, fmt.Errorf("invalid limit: must be between 1 and %d", maxPageLimit)
+		}
+		page.Limit = limit
+	}
+
+	if cursorStr := query.Get("cursor"); cursorStr != "" {
+		cursor, err := domain.DecodeMovieCursor(cursorStr)
+		if err != nil {
+			return page, err
+		}
+		if cursor.Field != page.Sort.Field || cursor.Direction != page.Sort.Direction {
+			return page, fmt.Errorf("cursor does not match sort and order")
+		}
+		page.After = cursor
+	}
+
+	return page, nil
+}
+
+const (
+	defaultReviewPageLimit = 20
+	maxReviewPageLimit     = 100
+)
+
+// ParseReviewPageRequest reads the limit and cursor query parameters of review listings
+func ParseReviewPageRequest(r *http.Request) (domain.ReviewPageRequest, error) {
+	query := r.URL.Query()
+	page := domain.ReviewPageRequest{Limit: defaultReviewPageLimit}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxReviewPageLimit {
+			return page, fmt.Errorf("invalid limit: must be between 1 and %d", maxReviewPageLimit)
+		}
+		page.Limit = limit
+	}
+
+	if cursorStr := query.Get("cursor"); cursorStr != "" {
+		afterID, err := domain.DecodeReviewCursor(cursorStr)
+		if err != nil {
+			return page, err
+		}
+		page.AfterID = afterID
+	}
+
+	return page, nil
+}
+
+const (
+	defaultAuditPageLimit = 50
+	maxAuditPageLimit     = 100
+)
+
+// ParseAuditFilter reads the audit log filters from the query string. since and until are RFC 3339 times.
+func ParseAuditFilter(r *http.Request) (domain.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := domain.AuditFilter{
+		Action:     query.Get("action"),
+		EntityType: query.Get("entity_type"),
+	}
+
+	intParams := []struct {
+		name  string
+		value *int
+	}{
+		{"actor_id", &filter.ActorID},
+		{"entity_id", &filter.EntityID},
+	}
+	for _, param := range intParams {
+		valueStr := query.Get(param.name)
+		if valueStr == "" {
+			continue
+		}
+		value, err := strconv.Atoi(valueStr)
+		if err != nil || value < 1 {
+			return filter, fmt.Errorf("invalid %s: %q", param.name, valueStr)
+		}
+		*param.value = value
+	}
+
+	timeParams := []struct {
+		name  string
+		value *time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	}
+	for _, param := range timeParams {
+		valueStr := query.Get(param.name)
+		if valueStr == "" {
+			continue
+		}
+		value, err := time.Parse(time.RFC3339, valueStr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s: must be an RFC 3339 time", param.name)
+		}
+		*param.value = value
+	}
+
+	return filter, nil
+}
+
+// ParseAuditPageRequest reads the limit and cursor query parameters of the audit log
+func ParseAuditPageRequest(r *http.Request) (domain.AuditPageRequest, error) {
+	query := r.URL.Query()
+	page := domain.AuditPageRequest{Limit: defaultAuditPageLimit}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxAuditPageLimit {
+			return page, fmt.Errorf("invalid limit: must be between 1 and %d", maxAuditPageLimit)
+		}
+		page.Limit = limit
+	}
+
+	if cursorStr := query.Get("cursor"); cursorStr != "" {
+		beforeID, err := domain.DecodeAuditCursor(cursorStr)
+		if err != nil {
+			return page, err
+		}
+		page.BeforeID = beforeID
+	}
+
+	return page, nil
+}
+
+func splitQueryValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+// ClientIP returns the address of the client. Behind a load balancer, trustForwardedFor takes the last
+// X-Forwarded-For entry, which the balancer appended; earlier entries come from the client and can be forged.
+func ClientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			entries := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
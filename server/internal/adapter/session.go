@@ -0,0 +1,147 @@
+package adapter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth/gothic"
+)
+
+// Session keys. A password login of a two-factor account first stores the pending key, and only the code exchanges it
+// for the user ID, so the half-authenticated session passes no SessionAuthMiddleware. The pending link marks an OAuth
+// flow started to link a provider account rather than to sign in.
+const (
+	SessionUserIDKey           = "user_id"
+	SessionPendingTwoFactorKey = "pending_two_factor"
+	SessionPendingLinkKey      = "pending_link"
+)
+
+// StoreSessionValues sets and deletes session values in a single save. Values are compressed the way
+// gothic.StoreInSession does, so gothic.GetFromSession reads them; several gothic.StoreInSession calls in one request
+// would each start again from the request's cookie and keep only the last value.
+func StoreSessionValues(w http.ResponseWriter, r *http.Request, values map[string]string, deleteKeys ...string) error {
+	session, _ := gothic.Store.New(r, gothic.SessionName)
+
+	for _, key := range deleteKeys {
+		delete(session.Values, key)
+	}
+	for key, value := range values {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		if _, err := writer.Write([]byte(value)); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		session.Values[key] = compressed.String()
+	}
+
+	return session.Save(r, w)
+}
+
+// StorePendingTwoFactor replaces the session's login with a pending login of userID that awaits a two-factor code
+func StorePendingTwoFactor(w http.ResponseWriter, r *http.Request, userID int) error {
+	pending := fmt.Sprintf("%d:%d", userID, time.Now().Unix())
+	return StoreSessionValues(w, r, map[string]string{SessionPendingTwoFactorKey: pending}, SessionUserIDKey)
+}
+
+// GetPendingTwoFactor returns the user of a pending two-factor login no older than maxAge
+func GetPendingTwoFactor(r *http.Request, maxAge time.Duration) (int, error) {
+	pending, err := gothic.GetFromSession(SessionPendingTwoFactorKey, r)
+	if err != nil || pending == "" {
+		return 0, fmt.Errorf("no pending two-factor login")
+	}
+
+	userIDStr, startedStr, ok := strings.Cut(pending, ":")
+	userID, userErr := strconv.Atoi(userIDStr)
+	started, startedErr := strconv.ParseInt(startedStr, 10, 64)
+	if !ok || userErr != nil || startedErr != nil {
+		return 0, fmt.Errorf("invalid pending two-factor login")
+	}
+	if time.Since(time.Unix(started, 0)) > maxAge {
+		return 0, fmt.Errorf("pending two-factor login expired")
+	}
+
+	return userID, nil
+}
+
+// StorePendingLink records that userID is linking an account at provider
+func StorePendingLink(w http.ResponseWriter, r *http.Request, userID int, provider string) error {
+	pending := fmt.Sprintf("%d:%d:%s", userID, time.Now().Unix(), provider)
+	return StoreSessionValues(w, r, map[string]string{SessionPendingLinkKey: pending})
+}
+
+// GetPendingLink returns the user and provider of a pending link no older than maxAge
+func GetPendingLink(r *http.Request, maxAge time.Duration) (int, string, error) {
+	pending, err := gothic.GetFromSession(SessionPendingLinkKey, r)
+	if err != nil || pending == "" {
+		return 0, "", fmt.Errorf("no pending link")
+	}
+
+	parts := strings.SplitN(pending, ":", 3)
+	if len(parts) != 3 {
+		return 0, "", fmt.Errorf("invalid pending link")
+	}
+	userID, userErr := strconv.Atoi(parts[0])
+	started, startedErr := strconv.ParseInt(parts[1], 10, 64)
+	if userErr != nil || startedErr != nil {
+		return 0, "", fmt.Errorf("invalid pending link")
+	}
+	if time.Since(time.Unix(started, 0)) > maxAge {
+		return 0, "", fmt.Errorf("pending link expired")
+	}
+
+	return userID, parts[2], nil
+}
+
+// StoreUserSession signs userID in, ending any pending two-factor login
+func StoreUserSession(w http.ResponseWriter, r *http.Request, userID int) error {
+	return StoreSessionValues(
+		w, r, map[string]string{SessionUserIDKey: strconv.Itoa(userID)}, SessionPendingTwoFactorKey, SessionPendingLinkKey,
+	)
+}
+
+// SessionUserID returns the user signed in to the session, or 0 when nobody is
+func SessionUserID(session *sessions.Session) int {
+	compressed, ok := session.Values[SessionUserIDKey].(string)
+	if !ok {
+		return 0
+	}
+
+	reader, err := gzip.NewReader(strings.NewReader(compressed))
+	if err != nil {
+		return 0
+	}
+	defer reader.Close()
+
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		return 0
+	}
+
+	userID, err := strconv.Atoi(string(value))
+	if err != nil {
+		return 0
+	}
+	return userID
+}
+
+// GetSessionID returns the ID of the request's stored session
+func GetSessionID(r *http.Request) (string, error) {
+	session, err := gothic.Store.Get(r, gothic.SessionName)
+	if err != nil {
+		return "", err
+	}
+	if session.ID == "" {
+		return "", fmt.Errorf("no stored session")
+	}
+	return session.ID, nil
+}
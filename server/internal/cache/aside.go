@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"golang.org/x/sync/singleflight"
+)
+
+// notFoundMarker is stored in place of a value when the loader reported that it does not exist
+const notFoundMarker = "\x00not-found"
+
+// Options configure one family of keys, such as every single-movie entry
+type Options struct {
+	// Family labels the metrics and logs of the keys, e.g. "movie"
+	Family string
+
+	// TTL is how long loaded values stay cached. Jitter spreads it by up to that fraction in either direction
+	// so that entries written together do not all expire, and get reloaded, at the same moment.
+	TTL    time.Duration
+	Jitter float64
+
+	// NotFound is the loader error that means the value does not exist. When set, it is remembered for
+	// NegativeTTL so that repeated lookups of a missing key do not each reach the database.
+	NotFound    error
+	NegativeTTL time.Duration
+
+	// DegradedFor is how long the backend is bypassed after it fails, so that an outage costs one timeout
+	// rather than one per request
+	DegradedFor time.Duration
+
+	// LocalSize and LocalTTL bound an in-process cache consulted before the backend. It is disabled when LocalSize is 0.
+	// Keys must change whenever the value does, because local copies are never told about writes.
+	LocalSize int
+	LocalTTL  time.Duration
+}
+
+// Aside is a typed cache-aside layer over a Backend, optionally fronted by an in-process LRU.
+// Concurrent misses on the same key share a single load.
+type Aside[T any] struct {
+	backend Backend
+	options Options
+	local   *LRU[T]
+
+	group         singleflight.Group
+	degradedUntil atomic.Int64
+}
+
+func NewAside[T any](backend Backend, options Options) *Aside[T] {
+	a := &Aside[T]{backend: backend, options: options}
+	if options.LocalSize > 0 {
+		a.local = NewLRU[T](options.Family, options.LocalSize, options.LocalTTL)
+	}
+	return a
+}
+
+// Get returns the value cached under key, or loads, caches and returns it.
+// An empty key skips the cache and calls load directly.
+func (a *Aside[T]) Get(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	if key == "" {
+		return load(ctx)
+	}
+
+	if a.local != nil {
+		if value, ok := a.local.Get(key); ok {
+			return value, nil
+		}
+	}
+
+	if value, err, ok := a.lookup(ctx, key); ok {
+		if err == nil && a.local != nil {
+			a.local.Set(key, value)
+		}
+		return value, err
+	}
+
+	// The load outlives a caller that gives up, because the other callers waiting on it still need the result
+	result, err, _ := a.group.Do(key, func() (interface{}, error) {
+		loadCtx := context.WithoutCancel(ctx)
+
+		value, err := load(loadCtx)
+		if err != nil {
+			if a.options.NotFound != nil && errors.Is(err, a.options.NotFound) {
+				a.store(loadCtx, key, notFoundMarker, a.options.NegativeTTL)
+			}
+			return value, err
+		}
+
+		if a.local != nil {
+			a.local.Set(key, value)
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return value, err
+		}
+		a.store(loadCtx, key, string(encoded), a.jitteredTTL())
+
+		return value, nil
+	})
+	if result == nil {
+		var zero T
+		return zero, err
+	}
+	return result.(T), err
+}
+
+// lookup reports whether key was answered from the backend, either with a value or with the remembered not-found error
+func (a *Aside[T]) lookup(ctx context.Context, key string) (T, error, bool) {
+	var value T
+
+	if a.degraded() {
+		cacheMisses.WithLabelValues(a.options.Family).Inc()
+		return value, nil, false
+	}
+
+	cached, err := a.backend.Get(ctx, key)
+	if errors.Is(err, ErrMiss) {
+		cacheMisses.WithLabelValues(a.options.Family).Inc()
+		return value, nil, false
+	}
+	if err != nil {
+		a.fail(ctx, "get", err)
+		cacheMisses.WithLabelValues(a.options.Family).Inc()
+		return value, nil, false
+	}
+
+	if cached == notFoundMarker {
+		cacheHits.WithLabelValues(a.options.Family).Inc()
+		return value, a.options.NotFound, true
+	}
+
+	if err := json.Unmarshal([]byte(cached), &value); err != nil {
+		// A value written by an older version of the type is reloaded rather than served
+		cacheErrors.WithLabelValues(a.options.Family, "decode").Inc()
+		cacheMisses.WithLabelValues(a.options.Family).Inc()
+		return value, nil, false
+	}
+
+	cacheHits.WithLabelValues(a.options.Family).Inc()
+	return value, nil, true
+}
+
+func (a *Aside[T]) store(ctx context.Context, key, value string, ttl time.Duration) {
+	if ttl <= 0 || a.degraded() {
+		return
+	}
+	if err := a.backend.Set(ctx, key, value, ttl); err != nil {
+		a.fail(ctx, "set", err)
+	}
+}
+
+func (a *Aside[T]) jitteredTTL() time.Duration {
+	if a.options.Jitter <= 0 {
+		return a.options.TTL
+	}
+	spread := float64(a.options.TTL) * a.options.Jitter
+	return a.options.TTL + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+func (a *Aside[T]) degraded() bool {
+	return time.Now().UnixNano() < a.degradedUntil.Load()
+}
+
+func (a *Aside[T]) fail(ctx context.Context, op string, err error) {
+	cacheErrors.WithLabelValues(a.options.Family, op).Inc()
+	middleware.GetLogger(ctx).Error(
+		"Cache unavailable, serving from the database",
+		slog.Any("error", err),
+		slog.String("family", a.options.Family),
+		slog.String("op", op),
+	)
+
+	if a.options.DegradedFor > 0 {
+		a.degradedUntil.Store(time.Now().Add(a.options.DegradedFor).UnixNano())
+	}
+}
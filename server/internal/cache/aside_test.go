@@ -0,0 +1,257 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/martishin/movie-search-service/internal/cache"
+)
+
+var errNotFound = errors.New("not found")
+
+// recordingBackend counts the calls that reach the in-memory backend and can make them fail like an unreachable Redis
+type recordingBackend struct {
+	*cache.MemoryBackend
+
+	mu   sync.Mutex
+	gets int
+	ttls []time.Duration
+	down bool
+}
+
+func newRecordingBackend() *recordingBackend {
+	return &recordingBackend{MemoryBackend: cache.NewMemoryBackend()}
+}
+
+func (b *recordingBackend) Get(ctx context.Context, key string) (string, error) {
+	b.mu.Lock()
+	b.gets++
+	down := b.down
+	b.mu.Unlock()
+
+	if down {
+		return "", errors.New("connection refused")
+	}
+	return b.MemoryBackend.Get(ctx, key)
+}
+
+func (b *recordingBackend) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	b.mu.Lock()
+	b.ttls = append(b.ttls, ttl)
+	down := b.down
+	b.mu.Unlock()
+
+	if down {
+		return errors.New("connection refused")
+	}
+	return b.MemoryBackend.Set(ctx, key, value, ttl)
+}
+
+func (b *recordingBackend) setDown(down bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.down = down
+}
+
+func (b *recordingBackend) stats() (int, []time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.gets, append([]time.Duration(nil), b.ttls...)
+}
+
+// countingLoader returns value and counts how often it ran
+func countingLoader(loads *atomic.Int32, value string, err error) func(context.Context) (string, error) {
+	return func(context.Context) (string, error) {
+		loads.Add(1)
+		return value, err
+	}
+}
+
+func TestAsideCachesLoadedValues(t *testing.T) {
+	backend := newRecordingBackend()
+	aside := cache.NewAside[string](backend, cache.Options{Family: "test", TTL: time.Minute})
+	ctx := context.Background()
+
+	var loads atomic.Int32
+	for range 3 {
+		value, err := aside.Get(ctx, "key", countingLoader(&loads, "value", nil))
+		if err != nil || value != "value" {
+			t.Fatalf("Get = %q, %v", value, err)
+		}
+	}
+	if loads.Load() != 1 {
+		t.Errorf("loader ran %d times, want 1", loads.Load())
+	}
+
+	// An empty key is never cached
+	for range 2 {
+		if _, err := aside.Get(ctx, "", countingLoader(&loads, "value", nil)); err != nil {
+			t.Fatalf("Get without key: %v", err)
+		}
+	}
+	if loads.Load() != 3 {
+		t.Errorf("loader ran %d times, want 3", loads.Load())
+	}
+}
+
+func TestAsideSharesConcurrentLoads(t *testing.T) {
+	aside := cache.NewAside[string](newRecordingBackend(), cache.Options{Family: "test", TTL: time.Minute})
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (string, error) {
+		loads.Add(1)
+		<-release
+		return "value", nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := aside.Get(context.Background(), "key", load)
+			if err == nil && value != "value" {
+				err = fmt.Errorf("got %q", value)
+			}
+			errs <- err
+		}()
+	}
+
+	// Give every caller time to miss and join the load before it finishes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Get: %v", err)
+		}
+	}
+	if loads.Load() != 1 {
+		t.Errorf("loader ran %d times for %d concurrent misses, want 1", loads.Load(), callers)
+	}
+}
+
+func TestAsideRemembersNotFound(t *testing.T) {
+	backend := newRecordingBackend()
+	aside := cache.NewAside[string](backend, cache.Options{
+		Family:      "test",
+		TTL:         time.Minute,
+		NotFound:    errNotFound,
+		NegativeTTL: time.Minute,
+	})
+	ctx := context.Background()
+
+	var loads atomic.Int32
+	for range 3 {
+		if _, err := aside.Get(ctx, "missing", countingLoader(&loads, "", errNotFound)); !errors.Is(err, errNotFound) {
+			t.Fatalf("Get of missing key: got %v, want errNotFound", err)
+		}
+	}
+	if loads.Load() != 1 {
+		t.Errorf("loader ran %d times for a missing key, want 1", loads.Load())
+	}
+
+	// Other errors are not remembered
+	errDatabase := errors.New("database unavailable")
+	for range 2 {
+		if _, err := aside.Get(ctx, "failing", countingLoader(&loads, "", errDatabase)); !errors.Is(err, errDatabase) {
+			t.Fatalf("Get of failing key: got %v, want errDatabase", err)
+		}
+	}
+	if loads.Load() != 3 {
+		t.Errorf("loader ran %d times, want 3", loads.Load())
+	}
+}
+
+func TestAsideJittersTTL(t *testing.T) {
+	backend := newRecordingBackend()
+	aside := cache.NewAside[string](backend, cache.Options{Family: "test", TTL: 100 * time.Second, Jitter: 0.1})
+	ctx := context.Background()
+
+	var loads atomic.Int32
+	for i := range 50 {
+		if _, err := aside.Get(ctx, fmt.Sprintf("key-%d", i), countingLoader(&loads, "value", nil)); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+
+	_, ttls := backend.stats()
+	distinct := make(map[time.Duration]bool)
+	for _, ttl := range ttls {
+		if ttl < 90*time.Second || ttl > 110*time.Second {
+			t.Errorf("TTL %v is outside 100s ± 10%%", ttl)
+		}
+		distinct[ttl] = true
+	}
+	if len(distinct) < 2 {
+		t.Errorf("50 entries share the TTLs %v, want them spread", distinct)
+	}
+}
+
+func TestAsideBypassesFailedBackend(t *testing.T) {
+	backend := newRecordingBackend()
+	aside := cache.NewAside[string](backend, cache.Options{Family: "test", TTL: time.Minute, DegradedFor: 200 * time.Millisecond})
+	ctx := context.Background()
+
+	// Failures fall through to the loader, and the next calls do not wait on the backend again
+	backend.setDown(true)
+	var loads atomic.Int32
+	for range 3 {
+		value, err := aside.Get(ctx, "key", countingLoader(&loads, "value", nil))
+		if err != nil || value != "value" {
+			t.Fatalf("Get with the backend down = %q, %v", value, err)
+		}
+	}
+	if gets, ttls := backend.stats(); gets != 1 || len(ttls) != 0 {
+		t.Errorf("degraded cache made %d gets and %d sets, want 1 and 0", gets, len(ttls))
+	}
+	if loads.Load() != 3 {
+		t.Errorf("loader ran %d times, want 3", loads.Load())
+	}
+
+	// Once the window passes the backend is used again
+	backend.setDown(false)
+	time.Sleep(250 * time.Millisecond)
+	for range 2 {
+		if _, err := aside.Get(ctx, "key", countingLoader(&loads, "value", nil)); err != nil {
+			t.Fatalf("Get after recovery: %v", err)
+		}
+	}
+	if gets, ttls := backend.stats(); gets != 3 || len(ttls) != 1 {
+		t.Errorf("recovered cache made %d gets and %d sets, want 3 and 1", gets, len(ttls))
+	}
+	if loads.Load() != 4 {
+		t.Errorf("loader ran %d times, want 4", loads.Load())
+	}
+}
+
+func TestAsideServesLocalCopies(t *testing.T) {
+	backend := newRecordingBackend()
+	aside := cache.NewAside[string](backend, cache.Options{
+		Family:    "test",
+		TTL:       time.Minute,
+		LocalSize: 10,
+		LocalTTL:  time.Minute,
+	})
+	ctx := context.Background()
+
+	var loads atomic.Int32
+	for range 3 {
+		if _, err := aside.Get(ctx, "key", countingLoader(&loads, "value", nil)); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+	if gets, _ := backend.stats(); gets != 1 || loads.Load() != 1 {
+		t.Errorf("made %d backend gets and %d loads, want 1 and 1", gets, loads.Load())
+	}
+}
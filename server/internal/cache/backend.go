@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrMiss is returned by Backend.Get when the key holds no value
+	ErrMiss = errors.New("cache miss")
+	// ErrDisabled is returned by backends that store nothing, so callers can skip caching altogether
+	ErrDisabled = errors.New("cache disabled")
+)
+
+// Backend is the key-value store behind the caches. Redis is shared between instances; the in-memory
+// backend is private to the process and suits a single instance or tests.
+type Backend interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+
+	// MGet returns one value per key, with "" for keys that hold none
+	MGet(ctx context.Context, keys ...string) ([]string, error)
+
+	// Incr increments every key by one, treating missing keys as 0
+	Incr(ctx context.Context, keys ...string) error
+
+	Publish(ctx context.Context, channel, message string) error
+
+	// Subscribe delivers messages published to channel until ctx is cancelled, then closes the returned channel
+	Subscribe(ctx context.Context, channel string) (<-chan string, error)
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a bounded in-process cache. Entries expire after the TTL, and the least recently used entry
+// is evicted once the cache holds size entries. Values are shared between callers and must not be modified.
+type LRU[T any] struct {
+	name string
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry[T any] struct {
+	key       string
+	value     T
+	expiresAt time.Time
+}
+
+// NewLRU creates a cache whose stats are exported under name
+func NewLRU[T any](name string, size int, ttl time.Duration) *LRU[T] {
+	return &LRU[T]{
+		name:    name,
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *LRU[T]) Get(key string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		localMisses.WithLabelValues(c.name).Inc()
+		var zero T
+		return zero, false
+	}
+
+	entry := element.Value.(*lruEntry[T])
+	if time.Now().After(entry.expiresAt) {
+		c.removeElement(element)
+		localMisses.WithLabelValues(c.name).Inc()
+		var zero T
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	localHits.WithLabelValues(c.name).Inc()
+	return entry.value, true
+}
+
+func (c *LRU[T]) Set(key string, value T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*lruEntry[T])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry[T]{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		localEvictions.WithLabelValues(c.name).Inc()
+	}
+	localEntries.WithLabelValues(c.name).Set(float64(c.order.Len()))
+}
+
+func (c *LRU[T]) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+// Purge drops every entry
+func (c *LRU[T]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.entries = make(map[string]*list.Element, c.size)
+	localEntries.WithLabelValues(c.name).Set(0)
+}
+
+func (c *LRU[T]) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*lruEntry[T]).key)
+	localEntries.WithLabelValues(c.name).Set(float64(c.order.Len()))
+}
@@ -0,0 +1,80 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/martishin/movie-search-service/internal/cache"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	lru := cache.NewLRU[int]("test", 2, time.Minute)
+
+	lru.Set("a", 1)
+	lru.Set("b", 2)
+	if value, ok := lru.Get("a"); !ok || value != 1 {
+		t.Fatalf("Get(a) = %d, %v", value, ok)
+	}
+
+	// Reading a made b the least recently used entry
+	lru.Set("c", 3)
+	if _, ok := lru.Get("b"); ok {
+		t.Errorf("b was not evicted")
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if value, ok := lru.Get(key); !ok || value != want {
+			t.Errorf("Get(%s) = %d, %v; want %d", key, value, ok, want)
+		}
+	}
+
+	// Overwriting an entry does not evict another one
+	lru.Set("a", 10)
+	if value, ok := lru.Get("a"); !ok || value != 10 {
+		t.Errorf("Get(a) after overwrite = %d, %v", value, ok)
+	}
+	if _, ok := lru.Get("c"); !ok {
+		t.Errorf("c was evicted by an overwrite")
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	lru := cache.NewLRU[string]("test", 10, 50*time.Millisecond)
+
+	lru.Set("key", "value")
+	if value, ok := lru.Get("key"); !ok || value != "value" {
+		t.Fatalf("Get = %q, %v", value, ok)
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	if value, ok := lru.Get("key"); ok {
+		t.Errorf("expired entry = %q", value)
+	}
+}
+
+func TestLRURemoveAndPurge(t *testing.T) {
+	lru := cache.NewLRU[int]("test", 10, time.Minute)
+	lru.Set("a", 1)
+	lru.Set("b", 2)
+	lru.Set("c", 3)
+
+	lru.Remove("a")
+	if _, ok := lru.Get("a"); ok {
+		t.Errorf("removed entry is still cached")
+	}
+	if _, ok := lru.Get("b"); !ok {
+		t.Errorf("Remove dropped another entry")
+	}
+
+	lru.Purge()
+	for _, key := range []string{"b", "c"} {
+		if _, ok := lru.Get(key); ok {
+			t.Errorf("%s survived Purge", key)
+		}
+	}
+
+	// The cache keeps working after a purge
+	lru.Set("d", 4)
+	if value, ok := lru.Get("d"); !ok || value != 4 {
+		t.Errorf("Get(d) after Purge = %d, %v", value, ok)
+	}
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memorySweepEvery is how many writes pass between sweeps of expired keys
+const memorySweepEvery = 1024
+
+// MemoryBackend keeps values in the process. Messages are only delivered to subscribers in the same process.
+type MemoryBackend struct {
+	mu          sync.Mutex
+	entries     map[string]memoryEntry
+	writes      int
+	subscribers map[string][]chan string
+}
+
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time // zero for keys without a TTL
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		entries:     make(map[string]memoryEntry),
+		subscribers: make(map[string][]chan string),
+	}
+}
+
+func (b *MemoryBackend) Get(_ context.Context, key string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	value, ok := b.get(key, time.Now())
+	if !ok {
+		return "", ErrMiss
+	}
+	return value, nil
+}
+
+func (b *MemoryBackend) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	b.entries[key] = entry
+	b.sweep()
+	return nil
+}
+
+func (b *MemoryBackend) MGet(_ context.Context, keys ...string) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i], _ = b.get(key, now)
+	}
+	return values, nil
+}
+
+func (b *MemoryBackend) Incr(_ context.Context, keys ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		value, _ := b.get(key, now)
+		counter, _ := strconv.ParseInt(value, 10, 64)
+		b.entries[key] = memoryEntry{value: strconv.FormatInt(counter+1, 10)}
+	}
+	b.sweep()
+	return nil
+}
+
+func (b *MemoryBackend) Publish(_ context.Context, channel, message string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, subscriber := range b.subscribers[channel] {
+		// Slow subscribers miss messages rather than block publishers, as with Redis pub/sub
+		select {
+		case subscriber <- message:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	messages := make(chan string, 64) //nolint:mnd
+
+	b.mu.Lock()
+	b.subscribers[channel] = append(b.subscribers[channel], messages)
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		subscribers := b.subscribers[channel]
+		for i, subscriber := range subscribers {
+			if subscriber == messages {
+				b.subscribers[channel] = append(subscribers[:i], subscribers[i+1:]...)
+				break
+			}
+		}
+		close(messages)
+	}()
+	return messages, nil
+}
+
+func (b *MemoryBackend) get(key string, now time.Time) (string, bool) {
+	entry, ok := b.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+		delete(b.entries, key)
+		return "", false
+	}
+	return entry.value, true
+}
+
+// sweep drops expired keys every so often, since keys that are never read again would otherwise stay forever
+func (b *MemoryBackend) sweep() {
+	b.writes++
+	if b.writes%memorySweepEvery != 0 {
+		return
+	}
+
+	now := time.Now()
+	for key, entry := range b.entries {
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			delete(b.entries, key)
+		}
+	}
+}
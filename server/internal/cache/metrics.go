@@ -0,0 +1,65 @@
+package cache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	cacheHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_hits_total",
+			Help: "Total number of cache lookups answered from the cache backend, including remembered not-found keys",
+		},
+		[]string{"family"},
+	)
+
+	cacheMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_misses_total",
+			Help: "Total number of cache lookups that fell through to the loader",
+		},
+		[]string{"family"},
+	)
+
+	cacheErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_errors_total",
+			Help: "Total number of failed cache operations",
+		},
+		[]string{"family", "op"},
+	)
+
+	localHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_local_hits_total",
+			Help: "Total number of lookups answered from an in-process cache",
+		},
+		[]string{"cache"},
+	)
+
+	localMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_local_misses_total",
+			Help: "Total number of in-process cache lookups that found no live entry",
+		},
+		[]string{"cache"},
+	)
+
+	localEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_local_evictions_total",
+			Help: "Total number of entries evicted from an in-process cache to stay within its size limit",
+		},
+		[]string{"cache"},
+	)
+
+	localEntries = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cache_local_entries",
+			Help: "Number of entries held by an in-process cache",
+		},
+		[]string{"cache"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheHits, cacheMisses, cacheErrors, localHits, localMisses, localEvictions, localEntries)
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisBackend struct {
+	redisClient *redis.Client
+}
+
+func NewRedisBackend(redisClient *redis.Client) *RedisBackend {
+	return &RedisBackend{redisClient: redisClient}
+}
+
+func (b *RedisBackend) Get(ctx context.Context, key string) (string, error) {
+	value, err := b.redisClient.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrMiss
+	}
+	return value, err
+}
+
+func (b *RedisBackend) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return b.redisClient.Set(ctx, key, value, ttl).Err()
+}
+
+func (b *RedisBackend) MGet(ctx context.Context, keys ...string) ([]string, error) {
+	values, err := b.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, len(values))
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			result[i] = s
+		}
+	}
+	return result, nil
+}
+
+func (b *RedisBackend) Incr(ctx context.Context, keys ...string) error {
+	_, err := b.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Incr(ctx, key)
+		}
+		return nil
+	})
+	return err
+}
+
+func (b *RedisBackend) Publish(ctx context.Context, channel, message string) error {
+	return b.redisClient.Publish(ctx, channel, message).Err()
+}
+
+func (b *RedisBackend) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	pubsub := b.redisClient.Subscribe(ctx, channel)
+
+	// Wait for the subscription to be confirmed so that messages published from now on are not missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	messages := make(chan string)
+	go func() {
+		defer close(messages)
+		defer pubsub.Close()
+
+		incoming := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-incoming:
+				if !ok {
+					return
+				}
+				select {
+				case messages <- message.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return messages, nil
+}
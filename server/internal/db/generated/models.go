@@ -8,6 +8,19 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AuditEvent struct {
+	ID         int32
+	ActorID    int32
+	Action     string
+	EntityType string
+	EntityID   int32
+	RequestID  string
+	Ip         string
+	Before     []byte
+	After      []byte
+	CreatedAt  pgtype.Timestamp
+}
+
 type Genre struct {
 	ID        int32
 	Genre     string
@@ -16,17 +29,30 @@ type Genre struct {
 }
 
 type Movie struct {
-	ID          int32
-	Title       string
-	ReleaseDate pgtype.Date
-	Runtime     pgtype.Int4
-	MpaaRating  pgtype.Text
-	Description pgtype.Text
-	Image       pgtype.Text
-	Video       pgtype.Text
-	CreatedAt   pgtype.Timestamp
-	UpdatedAt   pgtype.Timestamp
-	UserRating  pgtype.Numeric
+	ID              int32
+	Title           string
+	ReleaseDate     pgtype.Date
+	Runtime         pgtype.Int4
+	MpaaRating      pgtype.Text
+	Description     pgtype.Text
+	Image           pgtype.Text
+	Video           pgtype.Text
+	CreatedAt       pgtype.Timestamp
+	UpdatedAt       pgtype.Timestamp
+	UserRating      pgtype.Numeric
+	SearchVector    interface{}
+	RatingCount     int32
+	EditorialRating pgtype.Numeric
+}
+
+type MovieCredit struct {
+	ID            int32
+	MovieID       int32
+	PersonID      int32
+	Role          string
+	CharacterName pgtype.Text
+	BillingOrder  pgtype.Int4
+	CreatedAt     pgtype.Timestamp
 }
 
 type MoviesGenre struct {
@@ -35,15 +61,120 @@ type MoviesGenre struct {
 	GenreID int32
 }
 
+type Person struct {
+	ID        int32
+	Name      string
+	Biography pgtype.Text
+	BirthDate pgtype.Date
+	Image     pgtype.Text
+	CreatedAt pgtype.Timestamp
+	UpdatedAt pgtype.Timestamp
+}
+
+type Review struct {
+	ID          int32
+	UserID      int32
+	MovieID     int32
+	Body        string
+	Status      string
+	ModeratedBy pgtype.Int4
+	ModeratedAt pgtype.Timestamp
+	CreatedAt   pgtype.Timestamp
+	UpdatedAt   pgtype.Timestamp
+}
+
+type ReviewsHelpfulVote struct {
+	ID        int32
+	UserID    int32
+	ReviewID  int32
+	CreatedAt pgtype.Timestamp
+}
+
 type User struct {
+	ID               int32
+	FirstName        string
+	LastName         string
+	Email            string
+	Password         pgtype.Text
+	PictureUrl       pgtype.Text
+	CreatedAt        pgtype.Timestamp
+	UpdatedAt        pgtype.Timestamp
+	Role             string
+	EmailVerifiedAt  pgtype.Timestamp
+	TotpSecret       []byte
+	TotpEnabledAt    pgtype.Timestamp
+	TotpLastUsedStep pgtype.Int8
+	Preferences      []byte
+	DeletedAt        pgtype.Timestamp
+}
+
+type UserApiKey struct {
 	ID         int32
-	FirstName  string
-	LastName   string
-	Email      string
-	Password   pgtype.Text
-	PictureUrl pgtype.Text
+	UserID     int32
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	LastUsedAt pgtype.Timestamp
 	CreatedAt  pgtype.Timestamp
-	UpdatedAt  pgtype.Timestamp
+}
+
+type UserDataExport struct {
+	ID          int32
+	UserID      int32
+	Status      string
+	TokenHash   pgtype.Text
+	Archive     []byte
+	ClaimedAt   pgtype.Timestamp
+	CompletedAt pgtype.Timestamp
+	ExpiresAt   pgtype.Timestamp
+	CreatedAt   pgtype.Timestamp
+}
+
+type UserIdentity struct {
+	ID        int32
+	UserID    int32
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt pgtype.Timestamp
+}
+
+type UserList struct {
+	ID          int32
+	UserID      int32
+	Name        string
+	Description string
+	Visibility  string
+	ShareToken  string
+	CreatedAt   pgtype.Timestamp
+	UpdatedAt   pgtype.Timestamp
+}
+
+type UserListEntry struct {
+	ID        int32
+	ListID    int32
+	MovieID   int32
+	Position  int32
+	CreatedAt pgtype.Timestamp
+}
+
+type UserRecoveryCode struct {
+	ID        int32
+	UserID    int32
+	CodeHash  string
+	UsedAt    pgtype.Timestamp
+	CreatedAt pgtype.Timestamp
+}
+
+type UserToken struct {
+	ID        int32
+	UserID    int32
+	Purpose   string
+	TokenHash string
+	ExpiresAt pgtype.Timestamp
+	UsedAt    pgtype.Timestamp
+	CreatedAt pgtype.Timestamp
 }
 
 type UsersLikeMovie struct {
@@ -52,3 +183,12 @@ type UsersLikeMovie struct {
 	MovieID   int32
 	CreatedAt pgtype.Timestamp
 }
+
+type UsersRateMovie struct {
+	ID        int32
+	UserID    int32
+	MovieID   int32
+	Rating    int16
+	CreatedAt pgtype.Timestamp
+	UpdatedAt pgtype.Timestamp
+}
@@ -42,9 +42,9 @@ func (q *Queries) AttachGenresToMovie(ctx context.Context, arg AttachGenresToMov
 }
 
 const createMovie = `-- name: CreateMovie :one
-INSERT INTO movies (title, release_date, runtime, mpaa_rating, description, image, video, user_rating)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, title, release_date, runtime, mpaa_rating, description, image, video, created_at, updated_at, user_rating
+INSERT INTO movies (title, release_date, runtime, mpaa_rating, description, image, video)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, title, release_date, runtime, mpaa_rating, description, image, video, created_at, updated_at, user_rating, search_vector, rating_count, editorial_rating
 `
 
 type CreateMovieParams struct {
@@ -55,7 +55,6 @@ type CreateMovieParams struct {
 	Description pgtype.Text
 	Image       pgtype.Text
 	Video       pgtype.Text
-	UserRating  pgtype.Numeric
 }
 
 func (q *Queries) CreateMovie(ctx context.Context, arg CreateMovieParams) (Movie, error) {
@@ -67,7 +66,6 @@ func (q *Queries) CreateMovie(ctx context.Context, arg CreateMovieParams) (Movie
 		arg.Description,
 		arg.Image,
 		arg.Video,
-		arg.UserRating,
 	)
 	var i Movie
 	err := row.Scan(
@@ -82,6 +80,9 @@ func (q *Queries) CreateMovie(ctx context.Context, arg CreateMovieParams) (Movie
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserRating,
+		&i.SearchVector,
+		&i.RatingCount,
+		&i.EditorialRating,
 	)
 	return i, err
 }
@@ -112,6 +113,101 @@ func (q *Queries) DeleteMovieGenres(ctx context.Context, movieID int32) error {
 	return err
 }
 
+const fuzzySearchMoviesWithGenres = `-- name: FuzzySearchMoviesWithGenres :many
+WITH matched_movies AS (
+    SELECT
+        m.id,
+        m.title,
+        m.release_date,
+        m.runtime,
+        m.mpaa_rating,
+        m.description,
+        m.image,
+        m.user_rating,
+        m.video,
+        word_similarity($1::TEXT, m.title) AS similarity
+    FROM
+        movies m
+    WHERE
+        $1::TEXT <% m.title
+    ORDER BY
+        similarity DESC, m.title
+    LIMIT $2::INTEGER
+)
+SELECT
+    mm.id AS movie_id,
+    mm.title,
+    mm.release_date,
+    mm.runtime,
+    mm.mpaa_rating,
+    mm.description,
+    mm.image,
+    mm.user_rating,
+    mm.video,
+    mm.similarity,
+    g.id AS genre_id,
+    g.genre
+FROM
+    matched_movies mm
+        LEFT JOIN movies_genres mg ON mm.id = mg.movie_id
+        LEFT JOIN genres g ON mg.genre_id = g.id
+ORDER BY
+    mm.similarity DESC, mm.title, mm.id, g.genre
+`
+
+type FuzzySearchMoviesWithGenresParams struct {
+	Query      string
+	MaxResults int32
+}
+
+type FuzzySearchMoviesWithGenresRow struct {
+	MovieID     int32
+	Title       string
+	ReleaseDate pgtype.Date
+	Runtime     pgtype.Int4
+	MpaaRating  pgtype.Text
+	Description pgtype.Text
+	Image       pgtype.Text
+	UserRating  pgtype.Numeric
+	Video       pgtype.Text
+	Similarity  float32
+	GenreID     pgtype.Int4
+	Genre       pgtype.Text
+}
+
+func (q *Queries) FuzzySearchMoviesWithGenres(ctx context.Context, arg FuzzySearchMoviesWithGenresParams) ([]FuzzySearchMoviesWithGenresRow, error) {
+	rows, err := q.db.Query(ctx, fuzzySearchMoviesWithGenres, arg.Query, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FuzzySearchMoviesWithGenresRow
+	for rows.Next() {
+		var i FuzzySearchMoviesWithGenresRow
+		if err := rows.Scan(
+			&i.MovieID,
+			&i.Title,
+			&i.ReleaseDate,
+			&i.Runtime,
+			&i.MpaaRating,
+			&i.Description,
+			&i.Image,
+			&i.UserRating,
+			&i.Video,
+			&i.Similarity,
+			&i.GenreID,
+			&i.Genre,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getLikedMoviesByUser = `-- name: GetLikedMoviesByUser :many
 SELECT
     m.id AS movie_id,
@@ -183,7 +279,7 @@ func (q *Queries) GetLikedMoviesByUser(ctx context.Context, userID int32) ([]Get
 }
 
 const getMovieByID = `-- name: GetMovieByID :one
-SELECT id, title, release_date, runtime, mpaa_rating, description, image, video, created_at, updated_at, user_rating
+SELECT id, title, release_date, runtime, mpaa_rating, description, image, video, created_at, updated_at, user_rating, search_vector, rating_count, editorial_rating
 FROM
     movies
 WHERE
@@ -205,10 +301,35 @@ func (q *Queries) GetMovieByID(ctx context.Context, id int32) (Movie, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserRating,
+		&i.SearchVector,
+		&i.RatingCount,
+		&i.EditorialRating,
 	)
 	return i, err
 }
 
+const getUserMovieRating = `-- name: GetUserMovieRating :one
+SELECT
+    rating
+FROM
+    users_rate_movies
+WHERE
+      user_id = $1
+  AND movie_id = $2
+`
+
+type GetUserMovieRatingParams struct {
+	UserID  int32
+	MovieID int32
+}
+
+func (q *Queries) GetUserMovieRating(ctx context.Context, arg GetUserMovieRatingParams) (int16, error) {
+	row := q.db.QueryRow(ctx, getUserMovieRating, arg.UserID, arg.MovieID)
+	var rating int16
+	err := row.Scan(&rating)
+	return rating, err
+}
+
 const isMovieLikedByUser = `-- name: IsMovieLikedByUser :one
 SELECT
     EXISTS (
@@ -304,7 +425,7 @@ func (q *Queries) ListGenresByMovieID(ctx context.Context, movieID int32) ([]Lis
 }
 
 const listMovies = `-- name: ListMovies :many
-SELECT id, title, release_date, runtime, mpaa_rating, description, image, video, created_at, updated_at, user_rating
+SELECT id, title, release_date, runtime, mpaa_rating, description, image, video, created_at, updated_at, user_rating, search_vector, rating_count, editorial_rating
 FROM
     movies
 ORDER BY
@@ -332,6 +453,9 @@ func (q *Queries) ListMovies(ctx context.Context) ([]Movie, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.UserRating,
+			&i.SearchVector,
+			&i.RatingCount,
+			&i.EditorialRating,
 		); err != nil {
 			return nil, err
 		}
@@ -345,7 +469,7 @@ func (q *Queries) ListMovies(ctx context.Context) ([]Movie, error) {
 
 const listMoviesByGenre = `-- name: ListMoviesByGenre :many
 SELECT
-    m.id, m.title, m.release_date, m.runtime, m.mpaa_rating, m.description, m.image, m.video, m.created_at, m.updated_at, m.user_rating
+    m.id, m.title, m.release_date, m.runtime, m.mpaa_rating, m.description, m.image, m.video, m.created_at, m.updated_at, m.user_rating, m.search_vector, m.rating_count, m.editorial_rating
 FROM
     movies m
         JOIN movies_genres mg ON m.id = mg.movie_id
@@ -376,6 +500,9 @@ func (q *Queries) ListMoviesByGenre(ctx context.Context, genreID int32) ([]Movie
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.UserRating,
+			&i.SearchVector,
+			&i.RatingCount,
+			&i.EditorialRating,
 		); err != nil {
 			return nil, err
 		}
@@ -470,12 +597,15 @@ SELECT
     CASE
     WHEN ulm.user_id IS NOT NULL THEN true
     ELSE false
-        END AS is_liked
+        END AS is_liked,
+    m.rating_count,
+    urm.rating AS my_rating
 FROM
     movies m
         LEFT JOIN movies_genres mg ON m.id = mg.movie_id
         LEFT JOIN genres g ON mg.genre_id = g.id
         LEFT JOIN users_like_movies ulm ON m.id = ulm.movie_id AND ulm.user_id = $1
+        LEFT JOIN users_rate_movies urm ON m.id = urm.movie_id AND urm.user_id = $1
 ORDER BY
     m.title, g.genre
 `
@@ -493,6 +623,8 @@ type ListMoviesWithGenresAndLikeStatusRow struct {
 	GenreID     pgtype.Int4
 	Genre       pgtype.Text
 	IsLiked     bool
+	RatingCount int32
+	MyRating    pgtype.Int2
 }
 
 func (q *Queries) ListMoviesWithGenresAndLikeStatus(ctx context.Context, userID int32) ([]ListMoviesWithGenresAndLikeStatusRow, error) {
@@ -517,6 +649,167 @@ func (q *Queries) ListMoviesWithGenresAndLikeStatus(ctx context.Context, userID
 			&i.GenreID,
 			&i.Genre,
 			&i.IsLiked,
+			&i.RatingCount,
+			&i.MyRating,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchMoviesWithGenres = `-- name: SearchMoviesWithGenres :many
+WITH ranked_movies AS (
+    SELECT
+        m.id,
+        m.title,
+        m.release_date,
+        m.runtime,
+        m.mpaa_rating,
+        m.description,
+        m.image,
+        m.user_rating,
+        m.video,
+        ts_rank(m.search_vector, query) AS rank
+    FROM
+        movies m,
+        websearch_to_tsquery('english', $1::TEXT) query
+    WHERE
+        m.search_vector @@ query
+    ORDER BY
+        rank DESC, m.title
+    LIMIT $2::INTEGER
+)
+SELECT
+    rm.id AS movie_id,
+    rm.title,
+    rm.release_date,
+    rm.runtime,
+    rm.mpaa_rating,
+    rm.description,
+    rm.image,
+    rm.user_rating,
+    rm.video,
+    rm.rank,
+    g.id AS genre_id,
+    g.genre
+FROM
+    ranked_movies rm
+        LEFT JOIN movies_genres mg ON rm.id = mg.movie_id
+        LEFT JOIN genres g ON mg.genre_id = g.id
+ORDER BY
+    rm.rank DESC, rm.title, rm.id, g.genre
+`
+
+type SearchMoviesWithGenresParams struct {
+	Query      string
+	MaxResults int32
+}
+
+type SearchMoviesWithGenresRow struct {
+	MovieID     int32
+	Title       string
+	ReleaseDate pgtype.Date
+	Runtime     pgtype.Int4
+	MpaaRating  pgtype.Text
+	Description pgtype.Text
+	Image       pgtype.Text
+	UserRating  pgtype.Numeric
+	Video       pgtype.Text
+	Rank        float32
+	GenreID     pgtype.Int4
+	Genre       pgtype.Text
+}
+
+func (q *Queries) SearchMoviesWithGenres(ctx context.Context, arg SearchMoviesWithGenresParams) ([]SearchMoviesWithGenresRow, error) {
+	rows, err := q.db.Query(ctx, searchMoviesWithGenres, arg.Query, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchMoviesWithGenresRow
+	for rows.Next() {
+		var i SearchMoviesWithGenresRow
+		if err := rows.Scan(
+			&i.MovieID,
+			&i.Title,
+			&i.ReleaseDate,
+			&i.Runtime,
+			&i.MpaaRating,
+			&i.Description,
+			&i.Image,
+			&i.UserRating,
+			&i.Video,
+			&i.Rank,
+			&i.GenreID,
+			&i.Genre,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setWordSimilarityThreshold = `-- name: SetWordSimilarityThreshold :exec
+SELECT set_config('pg_trgm.word_similarity_threshold', $1::TEXT, true)
+`
+
+func (q *Queries) SetWordSimilarityThreshold(ctx context.Context, threshold string) error {
+	_, err := q.db.Exec(ctx, setWordSimilarityThreshold, threshold)
+	return err
+}
+
+const suggestMoviesByTitle = `-- name: SuggestMoviesByTitle :many
+SELECT
+    id,
+    title,
+    release_date,
+    image
+FROM
+    movies
+WHERE
+     lower(title) LIKE $1::TEXT || '%'
+  OR lower(title) LIKE '% ' || $1::TEXT || '%'
+ORDER BY
+    title, id
+LIMIT $2::INTEGER
+`
+
+type SuggestMoviesByTitleParams struct {
+	Prefix     string
+	MaxResults int32
+}
+
+type SuggestMoviesByTitleRow struct {
+	ID          int32
+	Title       string
+	ReleaseDate pgtype.Date
+	Image       pgtype.Text
+}
+
+// Matches titles with a word starting with the prefix, for when the Redis suggestion index is unavailable
+func (q *Queries) SuggestMoviesByTitle(ctx context.Context, arg SuggestMoviesByTitleParams) ([]SuggestMoviesByTitleRow, error) {
+	rows, err := q.db.Query(ctx, suggestMoviesByTitle, arg.Prefix, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SuggestMoviesByTitleRow
+	for rows.Next() {
+		var i SuggestMoviesByTitleRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.ReleaseDate,
+			&i.Image,
 		); err != nil {
 			return nil, err
 		}
@@ -536,8 +829,7 @@ SET title        = $2,
     mpaa_rating  = $5,
     description  = $6,
     image        = $7,
-    video        = $8,
-    user_rating  = $9
+    video        = $8
 WHERE
     id = $1
 `
@@ -551,7 +843,6 @@ type UpdateMovieParams struct {
 	Description pgtype.Text
 	Image       pgtype.Text
 	Video       pgtype.Text
-	UserRating  pgtype.Numeric
 }
 
 func (q *Queries) UpdateMovie(ctx context.Context, arg UpdateMovieParams) error {
@@ -564,7 +855,6 @@ func (q *Queries) UpdateMovie(ctx context.Context, arg UpdateMovieParams) error
 		arg.Description,
 		arg.Image,
 		arg.Video,
-		arg.UserRating,
 	)
 	return err
 }
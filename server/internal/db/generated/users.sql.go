@@ -14,7 +14,7 @@ import (
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (first_name, last_name, email, picture_url, password)
 VALUES ($1, $2, $3, $4, $5)
-RETURNING id, first_name, last_name, email, password, picture_url, created_at, updated_at
+RETURNING id, first_name, last_name, email, password, picture_url, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, preferences, deleted_at
 `
 
 type CreateUserParams struct {
@@ -43,10 +43,36 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.PictureUrl,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastUsedStep,
+		&i.Preferences,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
+const deleteMovieRating = `-- name: DeleteMovieRating :exec
+DELETE
+FROM
+    users_rate_movies
+WHERE
+      user_id = $1
+  AND movie_id = $2
+`
+
+type DeleteMovieRatingParams struct {
+	UserID  int32
+	MovieID int32
+}
+
+func (q *Queries) DeleteMovieRating(ctx context.Context, arg DeleteMovieRatingParams) error {
+	_, err := q.db.Exec(ctx, deleteMovieRating, arg.UserID, arg.MovieID)
+	return err
+}
+
 const deleteUser = `-- name: DeleteUser :exec
 DELETE
 FROM
@@ -61,7 +87,7 @@ func (q *Queries) DeleteUser(ctx context.Context, id int32) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, first_name, last_name, email, password, picture_url, created_at, updated_at
+SELECT id, first_name, last_name, email, password, picture_url, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, preferences, deleted_at
 FROM
     users
 WHERE
@@ -80,12 +106,19 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.PictureUrl,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastUsedStep,
+		&i.Preferences,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, first_name, last_name, email, password, picture_url, created_at, updated_at
+SELECT id, first_name, last_name, email, password, picture_url, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, preferences, deleted_at
 FROM
     users
 WHERE
@@ -104,6 +137,13 @@ func (q *Queries) GetUserByID(ctx context.Context, id int32) (User, error) {
 		&i.PictureUrl,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastUsedStep,
+		&i.Preferences,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -124,8 +164,41 @@ func (q *Queries) LikeMovie(ctx context.Context, arg LikeMovieParams) error {
 	return err
 }
 
+const listMoviesRatedByDeletedUsers = `-- name: ListMoviesRatedByDeletedUsers :many
+SELECT DISTINCT
+    r.movie_id
+FROM
+    users_rate_movies r
+    JOIN users u ON u.id = r.user_id
+WHERE
+    u.deleted_at < CURRENT_TIMESTAMP - $1::INTERVAL
+ORDER BY
+    r.movie_id
+`
+
+// Movies whose aggregate rating changes when the users past their deletion grace period are purged
+func (q *Queries) ListMoviesRatedByDeletedUsers(ctx context.Context, gracePeriod pgtype.Interval) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listMoviesRatedByDeletedUsers, gracePeriod)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var movie_id int32
+		if err := rows.Scan(&movie_id); err != nil {
+			return nil, err
+		}
+		items = append(items, movie_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listUsers = `-- name: ListUsers :many
-SELECT id, first_name, last_name, email, password, picture_url, created_at, updated_at
+SELECT id, first_name, last_name, email, password, picture_url, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, preferences, deleted_at
 FROM
     users
 ORDER BY
@@ -150,6 +223,13 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 			&i.PictureUrl,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Role,
+			&i.EmailVerifiedAt,
+			&i.TotpSecret,
+			&i.TotpEnabledAt,
+			&i.TotpLastUsedStep,
+			&i.Preferences,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -161,6 +241,204 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
+const lockMovieForRating = `-- name: LockMovieForRating :one
+SELECT
+    id
+FROM
+    movies
+WHERE
+    id = $1
+    FOR UPDATE
+`
+
+func (q *Queries) LockMovieForRating(ctx context.Context, id int32) (int32, error) {
+	row := q.db.QueryRow(ctx, lockMovieForRating, id)
+	err := row.Scan(&id)
+	return id, err
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :exec
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+WHERE
+    id = $1
+`
+
+// Keeps the original verification time when the address was already verified
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, markUserEmailVerified, id)
+	return err
+}
+
+const promoteFirstAdmin = `-- name: PromoteFirstAdmin :execrows
+UPDATE users
+SET role = 'admin'
+WHERE
+      lower(email) = lower($1)
+  AND email_verified_at IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM users WHERE role = 'admin')
+`
+
+// Promotes the user only once the email is verified and while nobody holds the admin role yet
+func (q *Queries) PromoteFirstAdmin(ctx context.Context, email string) (int64, error) {
+	result, err := q.db.Exec(ctx, promoteFirstAdmin, email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE
+FROM
+    users
+WHERE
+    deleted_at < CURRENT_TIMESTAMP - $1::INTERVAL
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, gracePeriod pgtype.Interval) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedUsers, gracePeriod)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const recomputeMovieRating = `-- name: RecomputeMovieRating :one
+UPDATE movies
+SET user_rating  = COALESCE(stats.average, movies.editorial_rating),
+    rating_count = stats.total
+FROM
+    (
+        SELECT
+            ROUND(AVG(rating), 1) AS average,
+            COUNT(*)              AS total
+        FROM
+            users_rate_movies
+        WHERE
+            movie_id = $1
+    ) stats
+WHERE
+    movies.id = $1
+RETURNING movies.user_rating, movies.rating_count
+`
+
+type RecomputeMovieRatingRow struct {
+	UserRating  pgtype.Numeric
+	RatingCount int32
+}
+
+func (q *Queries) RecomputeMovieRating(ctx context.Context, movieID int32) (RecomputeMovieRatingRow, error) {
+	row := q.db.QueryRow(ctx, recomputeMovieRating, movieID)
+	var i RecomputeMovieRatingRow
+	err := row.Scan(&i.UserRating, &i.RatingCount)
+	return i, err
+}
+
+const restoreUser = `-- name: RestoreUser :execrows
+UPDATE users
+SET deleted_at = NULL
+WHERE
+      id = $1
+  AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) RestoreUser(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, restoreUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :execrows
+UPDATE users
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE
+      id = $1
+  AND deleted_at IS NULL
+`
+
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, scheduleUserDeletion, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setUserPassword = `-- name: SetUserPassword :one
+UPDATE users
+SET password = $2
+WHERE
+    id = $1
+RETURNING id, first_name, last_name, email, password, picture_url, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, preferences, deleted_at
+`
+
+type SetUserPasswordParams struct {
+	ID       int32
+	Password pgtype.Text
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) (User, error) {
+	row := q.db.QueryRow(ctx, setUserPassword, arg.ID, arg.Password)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.FirstName,
+		&i.LastName,
+		&i.Email,
+		&i.Password,
+		&i.PictureUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastUsedStep,
+		&i.Preferences,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2
+WHERE
+    id = $1
+RETURNING id, first_name, last_name, email, password, picture_url, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, preferences, deleted_at
+`
+
+type SetUserRoleParams struct {
+	ID   int32
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRow(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.FirstName,
+		&i.LastName,
+		&i.Email,
+		&i.Password,
+		&i.PictureUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastUsedStep,
+		&i.Preferences,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const unlikeMovie = `-- name: UnlikeMovie :exec
 DELETE
 FROM
@@ -179,3 +457,68 @@ func (q *Queries) UnlikeMovie(ctx context.Context, arg UnlikeMovieParams) error
 	_, err := q.db.Exec(ctx, unlikeMovie, arg.UserID, arg.MovieID)
 	return err
 }
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET first_name  = $2,
+    last_name   = $3,
+    picture_url = $4,
+    preferences = $5
+WHERE
+    id = $1
+RETURNING id, first_name, last_name, email, password, picture_url, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at, totp_last_used_step, preferences, deleted_at
+`
+
+type UpdateUserProfileParams struct {
+	ID          int32
+	FirstName   string
+	LastName    string
+	PictureUrl  pgtype.Text
+	Preferences []byte
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserProfile,
+		arg.ID,
+		arg.FirstName,
+		arg.LastName,
+		arg.PictureUrl,
+		arg.Preferences,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.FirstName,
+		&i.LastName,
+		&i.Email,
+		&i.Password,
+		&i.PictureUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastUsedStep,
+		&i.Preferences,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const upsertMovieRating = `-- name: UpsertMovieRating :exec
+INSERT INTO users_rate_movies (user_id, movie_id, rating)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, movie_id) DO UPDATE SET rating = excluded.rating
+`
+
+type UpsertMovieRatingParams struct {
+	UserID  int32
+	MovieID int32
+	Rating  int16
+}
+
+func (q *Queries) UpsertMovieRating(ctx context.Context, arg UpsertMovieRatingParams) error {
+	_, err := q.db.Exec(ctx, upsertMovieRating, arg.UserID, arg.MovieID, arg.Rating)
+	return err
+}
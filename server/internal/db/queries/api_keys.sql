@@ -0,0 +1,30 @@
+-- name: CreateUserAPIKey :one
+INSERT INTO user_api_keys (user_id, name, prefix, key_hash, scopes)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ListUserAPIKeys :many
+SELECT *
+FROM
+    user_api_keys
+WHERE
+    user_id = $1
+ORDER BY
+    created_at DESC, id DESC;
+
+-- name: UseUserAPIKey :one
+-- Looks a key up by its hash and records that it was used. Keys of accounts awaiting deletion do not work.
+UPDATE user_api_keys
+SET last_used_at = CURRENT_TIMESTAMP
+WHERE
+      key_hash = $1
+  AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+RETURNING *;
+
+-- name: DeleteUserAPIKey :execrows
+DELETE
+FROM
+    user_api_keys
+WHERE
+      id = $1
+  AND user_id = $2;
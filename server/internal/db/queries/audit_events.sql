@@ -0,0 +1,21 @@
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (actor_id, action, entity_type, entity_id, request_id, ip, before, after)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: ListAuditEvents :many
+-- Returns events newest first. Each filter applies only when it is set.
+SELECT *
+FROM
+    audit_events
+WHERE
+      (sqlc.narg(actor_id)::INTEGER IS NULL OR actor_id = sqlc.narg(actor_id)::INTEGER)
+  AND (sqlc.narg(action)::TEXT IS NULL OR action = sqlc.narg(action)::TEXT)
+  AND (sqlc.narg(entity_type)::TEXT IS NULL OR entity_type = sqlc.narg(entity_type)::TEXT)
+  AND (sqlc.narg(entity_id)::INTEGER IS NULL OR entity_id = sqlc.narg(entity_id)::INTEGER)
+  AND (sqlc.narg(since)::TIMESTAMP IS NULL OR created_at >= sqlc.narg(since)::TIMESTAMP)
+  AND (sqlc.narg(until)::TIMESTAMP IS NULL OR created_at < sqlc.narg(until)::TIMESTAMP)
+  AND (sqlc.narg(before_id)::INTEGER IS NULL OR id < sqlc.narg(before_id)::INTEGER)
+ORDER BY
+    id DESC
+LIMIT sqlc.arg(max_results)::INTEGER;
@@ -0,0 +1,113 @@
+-- name: CreateUserDataExport :one
+INSERT INTO user_data_exports (user_id)
+VALUES ($1)
+RETURNING *;
+
+-- name: GetUserDataExport :one
+SELECT *
+FROM
+    user_data_exports
+WHERE
+      id = $1
+  AND user_id = $2;
+
+-- name: GetUserDataExportByTokenHash :one
+SELECT *
+FROM
+    user_data_exports
+WHERE
+      token_hash = $1
+  AND expires_at > CURRENT_TIMESTAMP;
+
+-- name: ClaimUserDataExport :one
+-- Takes the oldest pending export for a worker. An export claimed longer than claim_timeout ago is taken over,
+-- as its worker is presumed to have died; SKIP LOCKED keeps concurrent workers from taking the same export.
+UPDATE user_data_exports
+SET claimed_at = CURRENT_TIMESTAMP
+WHERE
+    id = (
+        SELECT
+            id
+        FROM
+            user_data_exports
+        WHERE
+              status = 'pending'
+          AND (claimed_at IS NULL OR claimed_at < CURRENT_TIMESTAMP - sqlc.arg(claim_timeout)::INTERVAL)
+        ORDER BY
+            id
+        LIMIT 1 FOR UPDATE SKIP LOCKED
+    )
+RETURNING *;
+
+-- name: CompleteUserDataExport :execrows
+UPDATE user_data_exports
+SET status       = 'ready',
+    token_hash   = $2,
+    archive      = $3,
+    completed_at = CURRENT_TIMESTAMP,
+    expires_at   = CURRENT_TIMESTAMP + sqlc.arg(ttl)::INTERVAL
+WHERE
+      id = $1
+  AND status = 'pending';
+
+-- name: FailUserDataExport :execrows
+-- Failed exports are kept for ttl so their owner can see what happened
+UPDATE user_data_exports
+SET status       = 'failed',
+    completed_at = CURRENT_TIMESTAMP,
+    expires_at   = CURRENT_TIMESTAMP + sqlc.arg(ttl)::INTERVAL
+WHERE
+      id = $1
+  AND status = 'pending';
+
+-- name: DeleteExpiredUserDataExports :execrows
+DELETE
+FROM
+    user_data_exports
+WHERE
+    expires_at <= CURRENT_TIMESTAMP;
+
+-- name: ListUserLikes :many
+SELECT
+    ulm.movie_id,
+    m.title,
+    ulm.created_at AS liked_at
+FROM
+    users_like_movies ulm
+        JOIN movies m ON ulm.movie_id = m.id
+WHERE
+    ulm.user_id = $1
+ORDER BY
+    ulm.created_at, ulm.id;
+
+-- name: ListUserRatings :many
+SELECT
+    umr.movie_id,
+    m.title,
+    umr.rating,
+    umr.created_at,
+    umr.updated_at
+FROM
+    users_rate_movies umr
+        JOIN movies m ON umr.movie_id = m.id
+WHERE
+    umr.user_id = $1
+ORDER BY
+    umr.created_at, umr.id;
+
+-- name: ListUserReviews :many
+SELECT
+    r.id,
+    r.movie_id,
+    m.title,
+    r.body,
+    r.status,
+    r.created_at,
+    r.updated_at
+FROM
+    reviews r
+        JOIN movies m ON r.movie_id = m.id
+WHERE
+    r.user_id = $1
+ORDER BY
+    r.created_at, r.id;
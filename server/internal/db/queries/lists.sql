@@ -0,0 +1,156 @@
+-- name: CreateUserList :one
+INSERT INTO user_lists (user_id, name, description, visibility, share_token)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetUserListByID :one
+SELECT *
+FROM
+    user_lists
+WHERE
+      id = $1
+  AND user_id = $2;
+
+-- name: GetUserListByShareToken :one
+-- Private lists are never reachable through their share URL
+SELECT *
+FROM
+    user_lists
+WHERE
+      share_token = $1
+  AND visibility <> 'private';
+
+-- name: LockUserList :one
+SELECT
+    id
+FROM
+    user_lists
+WHERE
+      id = $1
+  AND user_id = $2
+    FOR UPDATE;
+
+-- name: ListUserListsByUser :many
+SELECT
+    l.id,
+    l.user_id,
+    l.name,
+    l.description,
+    l.visibility,
+    l.share_token,
+    l.created_at,
+    l.updated_at,
+    (
+        SELECT
+            COUNT(*)
+        FROM
+            user_list_entries e
+        WHERE
+            e.list_id = l.id
+    ) AS entry_count
+FROM
+    user_lists l
+WHERE
+    l.user_id = $1
+ORDER BY
+    l.id;
+
+-- name: ListPublicUserListsByUser :many
+-- Unlisted lists stay reachable only through their share URL, so discovery shows public lists alone
+SELECT
+    l.id,
+    l.user_id,
+    l.name,
+    l.description,
+    l.visibility,
+    l.share_token,
+    l.created_at,
+    l.updated_at,
+    (
+        SELECT
+            COUNT(*)
+        FROM
+            user_list_entries e
+        WHERE
+            e.list_id = l.id
+    ) AS entry_count
+FROM
+    user_lists l
+WHERE
+      l.user_id = $1
+  AND l.visibility = 'public'
+ORDER BY
+    l.id;
+
+-- name: UpdateUserList :one
+UPDATE user_lists
+SET name        = $3,
+    description = $4,
+    visibility  = $5
+WHERE
+      id = $1
+  AND user_id = $2
+RETURNING *;
+
+-- name: DeleteUserList :execrows
+DELETE
+FROM
+    user_lists
+WHERE
+      id = $1
+  AND user_id = $2;
+
+-- name: ListUserListEntries :many
+SELECT
+    e.movie_id,
+    e.position,
+    e.created_at AS added_at,
+    m.title,
+    m.release_date,
+    m.image,
+    m.user_rating
+FROM
+    user_list_entries e
+        JOIN movies m ON e.movie_id = m.id
+WHERE
+    e.list_id = $1
+ORDER BY
+    e.position, e.id;
+
+-- name: ListUserListEntryMovieIDs :many
+SELECT
+    movie_id
+FROM
+    user_list_entries
+WHERE
+    list_id = $1
+ORDER BY
+    position, id;
+
+-- name: AddUserListEntry :exec
+-- New entries go to the end of the list
+INSERT INTO user_list_entries (list_id, movie_id, position)
+SELECT
+    sqlc.arg(list_id),
+    sqlc.arg(movie_id),
+    COALESCE(MAX(position), 0) + 1
+FROM
+    user_list_entries
+WHERE
+    list_id = sqlc.arg(list_id)
+ON CONFLICT (list_id, movie_id) DO NOTHING;
+
+-- name: RemoveUserListEntry :execrows
+DELETE
+FROM
+    user_list_entries
+WHERE
+      list_id = $1
+  AND movie_id = $2;
+
+-- name: SetUserListEntryPosition :exec
+UPDATE user_list_entries
+SET position = $3
+WHERE
+      list_id = $1
+  AND movie_id = $2;
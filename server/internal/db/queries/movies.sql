@@ -1,6 +1,6 @@
 -- name: CreateMovie :one
-INSERT INTO movies (title, release_date, runtime, mpaa_rating, description, image, video, user_rating)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+INSERT INTO movies (title, release_date, runtime, mpaa_rating, description, image, video)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING *;
 
 -- name: GetMovieByID :one
@@ -45,8 +45,7 @@ SET title        = $2,
     mpaa_rating  = $5,
     description  = $6,
     image        = $7,
-    video        = $8,
-    user_rating  = $9
+    video        = $8
 WHERE
     id = $1;
 
@@ -112,12 +111,15 @@ SELECT
     CASE
     WHEN ulm.user_id IS NOT NULL THEN true
     ELSE false
-        END AS is_liked
+        END AS is_liked,
+    m.rating_count,
+    urm.rating AS my_rating
 FROM
     movies m
         LEFT JOIN movies_genres mg ON m.id = mg.movie_id
         LEFT JOIN genres g ON mg.genre_id = g.id
         LEFT JOIN users_like_movies ulm ON m.id = ulm.movie_id AND ulm.user_id = sqlc.arg(user_id)
+        LEFT JOIN users_rate_movies urm ON m.id = urm.movie_id AND urm.user_id = sqlc.arg(user_id)
 ORDER BY
     m.title, g.genre;
 
@@ -159,3 +161,114 @@ WHERE
     ulm.user_id = $1
 ORDER BY
     m.title;
+
+-- name: SearchMoviesWithGenres :many
+WITH ranked_movies AS (
+    SELECT
+        m.id,
+        m.title,
+        m.release_date,
+        m.runtime,
+        m.mpaa_rating,
+        m.description,
+        m.image,
+        m.user_rating,
+        m.video,
+        ts_rank(m.search_vector, query) AS rank
+    FROM
+        movies m,
+        websearch_to_tsquery('english', sqlc.arg(query)::TEXT) query
+    WHERE
+        m.search_vector @@ query
+    ORDER BY
+        rank DESC, m.title
+    LIMIT sqlc.arg(max_results)::INTEGER
+)
+SELECT
+    rm.id AS movie_id,
+    rm.title,
+    rm.release_date,
+    rm.runtime,
+    rm.mpaa_rating,
+    rm.description,
+    rm.image,
+    rm.user_rating,
+    rm.video,
+    rm.rank,
+    g.id AS genre_id,
+    g.genre
+FROM
+    ranked_movies rm
+        LEFT JOIN movies_genres mg ON rm.id = mg.movie_id
+        LEFT JOIN genres g ON mg.genre_id = g.id
+ORDER BY
+    rm.rank DESC, rm.title, rm.id, g.genre;
+
+-- name: SetWordSimilarityThreshold :exec
+SELECT set_config('pg_trgm.word_similarity_threshold', sqlc.arg(threshold)::TEXT, true);
+
+-- name: FuzzySearchMoviesWithGenres :many
+WITH matched_movies AS (
+    SELECT
+        m.id,
+        m.title,
+        m.release_date,
+        m.runtime,
+        m.mpaa_rating,
+        m.description,
+        m.image,
+        m.user_rating,
+        m.video,
+        word_similarity(sqlc.arg(query)::TEXT, m.title) AS similarity
+    FROM
+        movies m
+    WHERE
+        sqlc.arg(query)::TEXT <% m.title
+    ORDER BY
+        similarity DESC, m.title
+    LIMIT sqlc.arg(max_results)::INTEGER
+)
+SELECT
+    mm.id AS movie_id,
+    mm.title,
+    mm.release_date,
+    mm.runtime,
+    mm.mpaa_rating,
+    mm.description,
+    mm.image,
+    mm.user_rating,
+    mm.video,
+    mm.similarity,
+    g.id AS genre_id,
+    g.genre
+FROM
+    matched_movies mm
+        LEFT JOIN movies_genres mg ON mm.id = mg.movie_id
+        LEFT JOIN genres g ON mg.genre_id = g.id
+ORDER BY
+    mm.similarity DESC, mm.title, mm.id, g.genre;
+
+-- name: GetUserMovieRating :one
+SELECT
+    rating
+FROM
+    users_rate_movies
+WHERE
+      user_id = $1
+  AND movie_id = $2;
+
+-- name: SuggestMoviesByTitle :many
+-- Matches titles with a word starting with the prefix, for when the Redis suggestion index is unavailable
+SELECT
+    id,
+    title,
+    release_date,
+    image
+FROM
+    movies
+WHERE
+     lower(title) LIKE sqlc.arg(prefix)::TEXT || '%'
+  OR lower(title) LIKE '% ' || sqlc.arg(prefix)::TEXT || '%'
+ORDER BY
+    title, id
+LIMIT sqlc.arg(max_results)::INTEGER;
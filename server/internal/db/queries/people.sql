@@ -0,0 +1,92 @@
+-- name: CreatePerson :one
+INSERT INTO people (name, biography, birth_date, image)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetPersonByID :one
+SELECT *
+FROM
+    people
+WHERE
+    id = $1;
+
+-- name: ListPeople :many
+SELECT *
+FROM
+    people
+ORDER BY
+    name, id;
+
+-- name: UpdatePerson :one
+UPDATE people
+SET name       = $2,
+    biography  = $3,
+    birth_date = $4,
+    image      = $5
+WHERE
+    id = $1
+RETURNING *;
+
+-- name: DeletePerson :execrows
+DELETE
+FROM
+    people
+WHERE
+    id = $1;
+
+-- name: CreateMovieCredit :one
+INSERT INTO movie_credits (movie_id, person_id, role, character_name, billing_order)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: DeleteMovieCredit :execrows
+DELETE
+FROM
+    movie_credits
+WHERE
+      id = $1
+  AND movie_id = $2;
+
+-- name: ListCreditsByMovieID :many
+-- Crew first, then the cast in billing order
+SELECT
+    mc.id,
+    mc.person_id,
+    p.name,
+    p.image,
+    mc.role,
+    mc.character_name,
+    mc.billing_order
+FROM
+    movie_credits mc
+        JOIN people p ON mc.person_id = p.id
+WHERE
+    mc.movie_id = $1
+ORDER BY
+    CASE mc.role
+        WHEN 'director' THEN 1
+        WHEN 'writer' THEN 2
+        WHEN 'actor' THEN 3
+        ELSE 4
+        END,
+    mc.billing_order NULLS LAST,
+    mc.id;
+
+-- name: ListCreditsByPersonID :many
+SELECT
+    mc.id,
+    mc.movie_id,
+    m.title,
+    m.release_date,
+    m.image,
+    mc.role,
+    mc.character_name,
+    mc.billing_order
+FROM
+    movie_credits mc
+        JOIN movies m ON mc.movie_id = m.id
+WHERE
+    mc.person_id = $1
+ORDER BY
+    m.release_date DESC NULLS LAST,
+    mc.id;
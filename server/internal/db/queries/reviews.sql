@@ -0,0 +1,128 @@
+-- name: CreateReview :one
+INSERT INTO reviews (user_id, movie_id, body)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetReviewByID :one
+SELECT *
+FROM
+    reviews
+WHERE
+    id = $1;
+
+-- name: GetReviewByUserAndMovie :one
+SELECT *
+FROM
+    reviews
+WHERE
+      user_id = $1
+  AND movie_id = $2;
+
+-- name: UpdateReviewBody :one
+-- Edited reviews go back to the moderation queue
+UPDATE reviews
+SET body         = $3,
+    status       = 'pending',
+    moderated_by = NULL,
+    moderated_at = NULL
+WHERE
+      user_id = $1
+  AND movie_id = $2
+RETURNING *;
+
+-- name: DeleteReviewByUserAndMovie :execrows
+DELETE
+FROM
+    reviews
+WHERE
+      user_id = $1
+  AND movie_id = $2;
+
+-- name: SetReviewStatus :one
+UPDATE reviews
+SET status       = $2,
+    moderated_by = $3,
+    moderated_at = CURRENT_TIMESTAMP
+WHERE
+    id = $1
+RETURNING *;
+
+-- name: ListApprovedReviewsByMovie :many
+SELECT
+    r.id,
+    r.user_id,
+    r.movie_id,
+    r.body,
+    r.status,
+    r.created_at,
+    r.updated_at,
+    u.first_name,
+    u.last_name,
+    (
+        SELECT
+            COUNT(*)
+        FROM
+            reviews_helpful_votes rhv
+        WHERE
+            rhv.review_id = r.id
+    ) AS helpful_count
+FROM
+    reviews r
+        JOIN users u ON r.user_id = u.id
+WHERE
+      r.movie_id = sqlc.arg(movie_id)
+  AND r.status = 'approved'
+  AND (sqlc.narg(before_id)::INTEGER IS NULL OR r.id < sqlc.narg(before_id)::INTEGER)
+ORDER BY
+    r.id DESC
+LIMIT sqlc.arg(max_results)::INTEGER;
+
+-- name: ListReviewsByStatus :many
+SELECT
+    r.id,
+    r.user_id,
+    r.movie_id,
+    r.body,
+    r.status,
+    r.created_at,
+    r.updated_at,
+    u.first_name,
+    u.last_name,
+    (
+        SELECT
+            COUNT(*)
+        FROM
+            reviews_helpful_votes rhv
+        WHERE
+            rhv.review_id = r.id
+    ) AS helpful_count
+FROM
+    reviews r
+        JOIN users u ON r.user_id = u.id
+WHERE
+      r.status = sqlc.arg(status)
+  AND (sqlc.narg(after_id)::INTEGER IS NULL OR r.id > sqlc.narg(after_id)::INTEGER)
+ORDER BY
+    r.id
+LIMIT sqlc.arg(max_results)::INTEGER;
+
+-- name: CountReviewHelpfulVotes :one
+SELECT
+    COUNT(*)
+FROM
+    reviews_helpful_votes
+WHERE
+    review_id = $1;
+
+-- name: AddReviewHelpfulVote :exec
+INSERT INTO reviews_helpful_votes (user_id, review_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, review_id) DO NOTHING;
+
+-- name: RemoveReviewHelpfulVote :exec
+DELETE
+FROM
+    reviews_helpful_votes
+WHERE
+      user_id = $1
+  AND review_id = $2;
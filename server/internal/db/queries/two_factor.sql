@@ -0,0 +1,61 @@
+-- name: SetUserTOTPSecret :execrows
+-- Starts enrollment: stores a pending secret and leaves two-factor disabled until it is confirmed
+UPDATE users
+SET totp_secret         = $2,
+    totp_enabled_at     = NULL,
+    totp_last_used_step = NULL
+WHERE
+      id = $1
+  AND totp_enabled_at IS NULL;
+
+-- name: EnableUserTOTP :execrows
+UPDATE users
+SET totp_enabled_at = CURRENT_TIMESTAMP
+WHERE
+      id = $1
+  AND totp_secret IS NOT NULL
+  AND totp_enabled_at IS NULL;
+
+-- name: DisableUserTOTP :execrows
+UPDATE users
+SET totp_secret         = NULL,
+    totp_enabled_at     = NULL,
+    totp_last_used_step = NULL
+WHERE
+    id = $1;
+
+-- name: MarkUserTOTPStepUsed :execrows
+-- Records the time step of an accepted code; fails for a step at or before the last one, so each code works once
+UPDATE users
+SET totp_last_used_step = $2
+WHERE
+      id = $1
+  AND (totp_last_used_step IS NULL OR totp_last_used_step < $2);
+
+-- name: DeleteUserRecoveryCodes :exec
+DELETE
+FROM
+    user_recovery_codes
+WHERE
+    user_id = $1;
+
+-- name: CreateUserRecoveryCode :exec
+INSERT INTO user_recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: ConsumeUserRecoveryCode :execrows
+UPDATE user_recovery_codes
+SET used_at = CURRENT_TIMESTAMP
+WHERE
+      user_id = $1
+  AND code_hash = $2
+  AND used_at IS NULL;
+
+-- name: CountUnusedUserRecoveryCodes :one
+SELECT
+    COUNT(*)
+FROM
+    user_recovery_codes
+WHERE
+      user_id = $1
+  AND used_at IS NULL;
@@ -0,0 +1,24 @@
+-- name: CreateUserToken :one
+INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+VALUES ($1, $2, $3, CURRENT_TIMESTAMP + sqlc.arg(ttl)::INTERVAL)
+RETURNING *;
+
+-- name: ConsumeUserToken :one
+-- Redeems an unused, unexpired token; the update makes redemption single-use even under concurrent requests
+UPDATE user_tokens
+SET used_at = CURRENT_TIMESTAMP
+WHERE
+      token_hash = $1
+  AND purpose = $2
+  AND used_at IS NULL
+  AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: RevokeUserTokens :exec
+-- Supersedes every outstanding token the user holds for the purpose
+UPDATE user_tokens
+SET used_at = CURRENT_TIMESTAMP
+WHERE
+      user_id = $1
+  AND purpose = $2
+  AND used_at IS NULL;
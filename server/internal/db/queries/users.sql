@@ -43,3 +43,116 @@ FROM
 WHERE
       user_id = $1
   AND movie_id = $2;
+
+-- name: LockMovieForRating :one
+SELECT
+    id
+FROM
+    movies
+WHERE
+    id = $1
+    FOR UPDATE;
+
+-- name: UpsertMovieRating :exec
+INSERT INTO users_rate_movies (user_id, movie_id, rating)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, movie_id) DO UPDATE SET rating = excluded.rating;
+
+-- name: DeleteMovieRating :exec
+DELETE
+FROM
+    users_rate_movies
+WHERE
+      user_id = $1
+  AND movie_id = $2;
+
+-- name: RecomputeMovieRating :one
+UPDATE movies
+SET user_rating  = COALESCE(stats.average, movies.editorial_rating),
+    rating_count = stats.total
+FROM
+    (
+        SELECT
+            ROUND(AVG(rating), 1) AS average,
+            COUNT(*)              AS total
+        FROM
+            users_rate_movies
+        WHERE
+            movie_id = sqlc.arg(movie_id)
+    ) stats
+WHERE
+    movies.id = sqlc.arg(movie_id)
+RETURNING movies.user_rating, movies.rating_count;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2
+WHERE
+    id = $1
+RETURNING *;
+
+-- name: PromoteFirstAdmin :execrows
+-- Promotes the user only once the email is verified and while nobody holds the admin role yet
+UPDATE users
+SET role = 'admin'
+WHERE
+      lower(email) = lower($1)
+  AND email_verified_at IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM users WHERE role = 'admin');
+
+-- name: SetUserPassword :one
+UPDATE users
+SET password = $2
+WHERE
+    id = $1
+RETURNING *;
+
+-- name: MarkUserEmailVerified :exec
+-- Keeps the original verification time when the address was already verified
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+WHERE
+    id = $1;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET first_name  = $2,
+    last_name   = $3,
+    picture_url = $4,
+    preferences = $5
+WHERE
+    id = $1
+RETURNING *;
+
+-- name: ScheduleUserDeletion :execrows
+UPDATE users
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE
+      id = $1
+  AND deleted_at IS NULL;
+
+-- name: RestoreUser :execrows
+UPDATE users
+SET deleted_at = NULL
+WHERE
+      id = $1
+  AND deleted_at IS NOT NULL;
+
+-- name: ListMoviesRatedByDeletedUsers :many
+-- Movies whose aggregate rating changes when the users past their deletion grace period are purged
+SELECT DISTINCT
+    r.movie_id
+FROM
+    users_rate_movies r
+    JOIN users u ON u.id = r.user_id
+WHERE
+    u.deleted_at < CURRENT_TIMESTAMP - sqlc.arg(grace_period)::INTERVAL
+ORDER BY
+    r.movie_id;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE
+FROM
+    users
+WHERE
+    deleted_at < CURRENT_TIMESTAMP - sqlc.arg(grace_period)::INTERVAL;
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/martishin/movie-search-service/internal/cache"
 	"github.com/martishin/movie-search-service/internal/model/config"
 	"github.com/redis/go-redis/v9"
 )
@@ -27,3 +28,19 @@ func NewRedisClient(config *config.RedisConfig) (*redis.Client, error) {
 
 	return client, nil
 }
+
+// NewCacheBackend creates the configured cache backend. The Redis client is nil unless the backend is Redis.
+func NewCacheBackend(redisConfig *config.RedisConfig) (cache.Backend, *redis.Client, error) {
+	switch redisConfig.CacheBackend {
+	case config.CacheBackendMemory:
+		return cache.NewMemoryBackend(), nil, nil
+	case config.CacheBackendNone:
+		return cache.NewNopBackend(), nil, nil
+	}
+
+	client, err := NewRedisClient(redisConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cache.NewRedisBackend(client), client, nil
+}
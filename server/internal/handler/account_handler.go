@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/markbates/goth/gothic"
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/model/config"
+	"github.com/martishin/movie-search-service/internal/service"
+)
+
+type AccountHandler struct {
+	accountService *service.AccountService
+	loginGuard     *service.LoginGuard
+	loginConfig    *config.LoginProtectionConfig
+	auditor        *Auditor
+}
+
+func NewAccountHandler(
+	accountService *service.AccountService,
+	loginGuard *service.LoginGuard,
+	loginConfig *config.LoginProtectionConfig,
+	auditor *Auditor,
+) *AccountHandler {
+	return &AccountHandler{accountService: accountService, loginGuard: loginGuard, loginConfig: loginConfig, auditor: auditor}
+}
+
+// ChangePasswordHandler changes the signed-in user's password, or sets the first one of an account that signs in
+// with providers only, and signs out the user's other sessions
+func (h *AccountHandler) ChangePasswordHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			CurrentPassword string `json:"current_password"`
+			NewPassword     string `json:"new_password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		// A stolen session must not be able to guess the password any faster than the login form
+		account := passwordAccount(userID)
+		ip := adapter.ClientIP(r, h.loginConfig.TrustForwardedFor)
+		if !checkLogin(w, r, logger, h.loginGuard, account, ip) {
+			return
+		}
+
+		currentID, _ := adapter.GetSessionID(r)
+		err = h.accountService.ChangePassword(r.Context(), userID, request.CurrentPassword, request.NewPassword, currentID)
+		switch {
+		case errors.Is(err, service.ErrIncorrectPassword):
+			logger.Warn("Incorrect current password", slog.Int("user_id", userID))
+			if !recordLoginFailure(w, r, logger, h.loginGuard, h.auditor, userID, account, ip, service.LoginFailureInvalidPassword) {
+				adapter.JsonErrorResponse(w, err.Error(), http.StatusForbidden)
+			}
+			return
+		case errors.Is(err, service.ErrInvalidPassword):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to change password", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not change password", http.StatusInternalServerError)
+			return
+		}
+
+		if err := h.loginGuard.RecordSuccess(r.Context(), account); err != nil {
+			logger.Error("Failed to clear failed logins", slog.Any("error", err))
+		}
+
+		logger.Info("Password changed", slog.Int("user_id", userID))
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Password changed"})
+	}
+}
+
+// DeleteAccountHandler schedules the signed-in user's account for deletion and signs it out everywhere
+func (h *AccountHandler) DeleteAccountHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		account := passwordAccount(userID)
+		ip := adapter.ClientIP(r, h.loginConfig.TrustForwardedFor)
+		if !checkLogin(w, r, logger, h.loginGuard, account, ip) {
+			return
+		}
+
+		err = h.accountService.DeleteAccount(r.Context(), userID, request.Password)
+		switch {
+		case errors.Is(err, service.ErrIncorrectPassword):
+			logger.Warn("Incorrect password for account deletion", slog.Int("user_id", userID))
+			if !recordLoginFailure(w, r, logger, h.loginGuard, h.auditor, userID, account, ip, service.LoginFailureInvalidPassword) {
+				adapter.JsonErrorResponse(w, "password is incorrect", http.StatusForbidden)
+			}
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to delete account", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not delete account", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Account scheduled for deletion", slog.Int("user_id", userID))
+
+		// The session is already revoked; this only clears the cookie
+		if err := gothic.Logout(w, r); err != nil {
+			logger.Error("Failed to clear session cookie", slog.Any("error", err))
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{
+			"message":    "Account scheduled for deletion. Sign in again to restore it.",
+			"deletes_at": time.Now().Add(service.AccountDeletionGracePeriod).UTC(),
+		})
+	}
+}
+
+// restoreAccount cancels the pending deletion of an account its owner signed back in to. It reports whether the
+// sign-in may go on, having answered 500 otherwise.
+func restoreAccount(
+	w http.ResponseWriter,
+	r *http.Request,
+	logger *slog.Logger,
+	accountService *service.AccountService,
+	userID int,
+) bool {
+	restored, err := accountService.RestoreAccount(r.Context(), userID)
+	if err != nil {
+		logger.Error("Failed to restore account", slog.Any("error", err), slog.Int("user_id", userID))
+		adapter.JsonErrorResponse(w, "Could not restore account", http.StatusInternalServerError)
+		return false
+	}
+	if restored {
+		logger.Info("Account deletion cancelled", slog.Int("user_id", userID))
+	}
+	return true
+}
+
+func passwordAccount(userID int) string {
+	return fmt.Sprintf("user:%d:password", userID)
+}
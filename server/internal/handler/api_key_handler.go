@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/service"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// ListAPIKeysHandler lists the user's API keys without their secrets
+func (h *APIKeyHandler) ListAPIKeysHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		keys, err := h.apiKeyService.ListAPIKeys(r.Context(), userID)
+		if err != nil {
+			logger.Error("Failed to list API keys", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not list API keys", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(keys)
+	}
+}
+
+// CreateAPIKeyHandler issues a key and returns it; this is the only time the key is shown
+func (h *APIKeyHandler) CreateAPIKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		key, err := h.apiKeyService.CreateAPIKey(r.Context(), userID, request.Name, request.Scopes)
+		switch {
+		case errors.Is(err, service.ErrInvalidAPIKeyName), errors.Is(err, service.ErrInvalidAPIKeyScope):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to create API key", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not create API key", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("API key created", slog.Int("user_id", userID), slog.Int("api_key_id", key.ID))
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(key)
+	}
+}
+
+// RevokeAPIKeyHandler deletes one of the user's keys, rejecting it from then on
+func (h *APIKeyHandler) RevokeAPIKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		keyID, err := strconv.Atoi(r.PathValue("key_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid API key ID", http.StatusBadRequest)
+			return
+		}
+
+		err = h.apiKeyService.RevokeAPIKey(r.Context(), userID, keyID)
+		switch {
+		case errors.Is(err, service.ErrAPIKeyNotFound):
+			adapter.JsonErrorResponse(w, "API key not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to revoke API key", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not revoke API key", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("API key revoked", slog.Int("user_id", userID), slog.Int("api_key_id", keyID))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/model/config"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/service"
+)
+
+// Auditor records admin writes and login lockouts in the audit log on behalf of the handlers that make them
+type Auditor struct {
+	auditService *service.AuditService
+	loginConfig  *config.LoginProtectionConfig
+}
+
+func NewAuditor(auditService *service.AuditService, loginConfig *config.LoginProtectionConfig) *Auditor {
+	return &Auditor{auditService: auditService, loginConfig: loginConfig}
+}
+
+// Transaction runs an admin write in one transaction with the events it records, so the write is saved only
+// together with its audit trail. write makes its repository calls and Record calls with the context it receives.
+func (a *Auditor) Transaction(r *http.Request, write func(ctx context.Context) error) error {
+	return a.auditService.InTransaction(r.Context(), write)
+}
+
+// Record logs a write by the request's user as part of the transaction of ctx. before and after are the entity as
+// returned by the API, nil for a create or a delete respectively.
+func (a *Auditor) Record(
+	ctx context.Context,
+	r *http.Request,
+	action, entityType string,
+	entityID int,
+	before, after any,
+) error {
+	actorID, err := adapter.GetUserIDFromSession(r)
+	if err != nil {
+		return fmt.Errorf("identify the actor: %w", err)
+	}
+
+	_, err = a.auditService.Record(ctx, service.AuditEntry{
+		ActorID:    actorID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		RequestID:  middleware.GetRequestID(r.Context()),
+		IP:         adapter.ClientIP(r, a.loginConfig.TrustForwardedFor),
+		Before:     before,
+		After:      after,
+	})
+	if err != nil {
+		return fmt.Errorf("record %s of %s %d: %w", action, entityType, entityID, err)
+	}
+	return nil
+}
+
+// loginLockout is the After state of a lockout event
+type loginLockout struct {
+	Scope            string `json:"scope"`
+	Account          string `json:"account"`
+	Reason           string `json:"reason"`
+	Failures         int    `json:"failures"`
+	LockedForSeconds int    `json:"locked_for_seconds"`
+}
+
+// RecordLockout logs a lockout imposed by the login guard. It has no actor; the entity is the user whose account was
+// guessed at, 0 when the account does not exist.
+func (a *Auditor) RecordLockout(r *http.Request, userID int, account, reason string, lockout *service.LoginLockout) error {
+	_, err := a.auditService.Record(r.Context(), service.AuditEntry{
+		Action:     domain.AuditActionLockout,
+		EntityType: domain.AuditEntityUser,
+		EntityID:   userID,
+		RequestID:  middleware.GetRequestID(r.Context()),
+		IP:         adapter.ClientIP(r, a.loginConfig.TrustForwardedFor),
+		After: loginLockout{
+			Scope:            lockout.Scope,
+			Account:          account,
+			Reason:           reason,
+			Failures:         lockout.Failures,
+			LockedForSeconds: int(lockout.Duration.Seconds()),
+		},
+	})
+	return err
+}
+
+type AuditHandler struct {
+	auditService *service.AuditService
+}
+
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// ListAuditEventsHandler returns one page of the audit log, newest first, narrowed by the query's filters
+func (h *AuditHandler) ListAuditEventsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		filter, err := adapter.ParseAuditFilter(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := adapter.ParseAuditPageRequest(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		events, err := h.auditService.ListEvents(r.Context(), filter, page)
+		switch {
+		case errors.Is(err, service.ErrInvalidAuditFilter):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			logger.Error("Failed to list audit events", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Could not fetch audit events", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(events)
+	}
+}
@@ -2,31 +2,60 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
-	"strconv"
+	"net/url"
 
 	"github.com/markbates/goth/gothic"
 	"github.com/martishin/movie-search-service/internal/adapter"
 	"github.com/martishin/movie-search-service/internal/middleware"
 	"github.com/martishin/movie-search-service/internal/model/config"
+	"github.com/martishin/movie-search-service/internal/model/domain"
 	"github.com/martishin/movie-search-service/internal/service"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
-	userService *service.UserService
-	oauthConfig *config.OAuthConfig
+	userService     *service.UserService
+	accountService  *service.AccountService
+	identityService *service.IdentityService
+	loginGuard      *service.LoginGuard
+	oauthConfig     *config.OAuthConfig
+	loginConfig     *config.LoginProtectionConfig
+	auditor         *Auditor
 }
 
-func NewAuthHandler(userService *service.UserService, oauthConfig *config.OAuthConfig) *AuthHandler {
-	return &AuthHandler{userService: userService, oauthConfig: oauthConfig}
+func NewAuthHandler(
+	userService *service.UserService,
+	accountService *service.AccountService,
+	identityService *service.IdentityService,
+	loginGuard *service.LoginGuard,
+	oauthConfig *config.OAuthConfig,
+	loginConfig *config.LoginProtectionConfig,
+	auditor *Auditor,
+) *AuthHandler {
+	return &AuthHandler{
+		userService:     userService,
+		accountService:  accountService,
+		identityService: identityService,
+		loginGuard:      loginGuard,
+		oauthConfig:     oauthConfig,
+		loginConfig:     loginConfig,
+		auditor:         auditor,
+	}
 }
 
-func (h *AuthHandler) GoogleCallbackHandler() http.HandlerFunc {
+// OAuthCallbackHandler completes a sign-in with an OAuth or OpenID Connect provider, or links the provider account
+// to the signed-in user when the flow was started by LinkIdentityHandler
+func (h *AuthHandler) OAuthCallbackHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := middleware.GetLogger(r.Context())
 
+		// Completing the flow clears the session, so whatever it holds has to be read first
+		linkUserID, linkProvider, linkErr := adapter.GetPendingLink(r, pendingLinkMaxAge)
+		sessionUserID, _ := adapter.GetUserIDFromSession(r)
+
 		// Complete authentication process
 		authUser, err := gothic.CompleteUserAuth(w, r)
 		if err != nil {
@@ -34,23 +63,50 @@ func (h *AuthHandler) GoogleCallbackHandler() http.HandlerFunc {
 			adapter.JsonErrorResponse(w, "Authentication failed", http.StatusUnauthorized)
 			return
 		}
+		profile := oauthProfile(authUser)
+
+		if linkErr == nil && linkUserID == sessionUserID && linkProvider == profile.Provider {
+			h.completeLink(w, r, linkUserID, profile)
+			return
+		}
 
-		// Save user to database
 		ctx := r.Context()
-		user, err := h.userService.FindOrCreateUser(ctx, authUser.FirstName, authUser.LastName, authUser.Email, authUser.AvatarURL, "")
-		if err != nil {
-			logger.Error("Failed to find or create user", slog.Any("error", err), slog.String("email", authUser.Email))
+		user, err := h.identityService.SignIn(ctx, profile)
+		switch {
+		case errors.Is(err, service.ErrIdentityEmailTaken):
+			logger.Warn("OAuth email belongs to another account",
+				slog.String("provider", profile.Provider), slog.String("email", profile.Email))
+			h.redirect(w, r, "error", "account_exists")
+			return
+		case errors.Is(err, service.ErrIdentityEmailNeeded):
+			h.redirect(w, r, "error", "email_required")
+			return
+		case err != nil:
+			logger.Error("Failed to sign in with provider", slog.Any("error", err), slog.String("provider", profile.Provider))
 			adapter.JsonErrorResponse(w, "Database error", http.StatusInternalServerError)
 			return
 		}
 
-		// Convert user ID to string for session storage
-		userIDStr := strconv.Itoa(user.ID)
+		// A provider sign-in to an account with two-factor still needs the code; the frontend asks for it
+		if user.TwoFactorEnabled {
+			if err := adapter.StorePendingTwoFactor(w, r, user.ID); err != nil {
+				logger.Error("Failed to store pending login in session", slog.Any("error", err), slog.Int("user_id", user.ID))
+				adapter.JsonErrorResponse(w, "Failed to save session", http.StatusInternalServerError)
+				return
+			}
+
+			h.redirect(w, r, "two_factor", "required")
+			return
+		}
+
+		// Signing in during the deletion grace period keeps the account
+		if !restoreAccount(w, r, logger, h.accountService, user.ID) {
+			return
+		}
 
 		// Store user ID in session
-		err = gothic.StoreInSession("user_id", userIDStr, r, w)
-		if err != nil {
-			logger.Error("Failed to store user ID in session", slog.Any("error", err), slog.String("user_id", userIDStr))
+		if err := adapter.StoreUserSession(w, r, user.ID); err != nil {
+			logger.Error("Failed to store user ID in session", slog.Any("error", err), slog.Int("user_id", user.ID))
 			adapter.JsonErrorResponse(w, "Failed to save session", http.StatusInternalServerError)
 			return
 		}
@@ -60,6 +116,47 @@ func (h *AuthHandler) GoogleCallbackHandler() http.HandlerFunc {
 	}
 }
 
+// completeLink links the provider account and signs the user back in to the session the flow cleared
+func (h *AuthHandler) completeLink(w http.ResponseWriter, r *http.Request, userID int, profile domain.OAuthProfile) {
+	logger := middleware.GetLogger(r.Context())
+
+	linkErr := h.identityService.LinkIdentity(r.Context(), userID, profile)
+	switch {
+	case linkErr == nil:
+		logger.Info("Identity linked", slog.Int("user_id", userID), slog.String("provider", profile.Provider))
+	case errors.Is(linkErr, service.ErrIdentityInUse), errors.Is(linkErr, service.ErrIdentityLinked):
+		logger.Warn("Identity not linked", slog.Any("error", linkErr), slog.Int("user_id", userID),
+			slog.String("provider", profile.Provider))
+	case errors.Is(linkErr, service.ErrUserNotFound):
+		adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+		return
+	default:
+		logger.Error("Failed to link identity", slog.Any("error", linkErr), slog.Int("user_id", userID))
+		adapter.JsonErrorResponse(w, "Could not link account", http.StatusInternalServerError)
+		return
+	}
+
+	if err := adapter.StoreUserSession(w, r, userID); err != nil {
+		logger.Error("Failed to store user ID in session", slog.Any("error", err), slog.Int("user_id", userID))
+		adapter.JsonErrorResponse(w, "Failed to save session", http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case errors.Is(linkErr, service.ErrIdentityInUse):
+		h.redirect(w, r, "link_error", "identity_in_use")
+	case errors.Is(linkErr, service.ErrIdentityLinked):
+		h.redirect(w, r, "link_error", "provider_already_linked")
+	default:
+		h.redirect(w, r, "linked", profile.Provider)
+	}
+}
+
+// redirect sends the browser back to the frontend with the outcome in the query string
+func (h *AuthHandler) redirect(w http.ResponseWriter, r *http.Request, key, value string) {
+	http.Redirect(w, r, h.oauthConfig.RedirectURL+"?"+url.Values{key: {value}}.Encode(), http.StatusFound)
+}
+
 // LogoutHandler logs users out and logs events properly
 func (h *AuthHandler) LogoutHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -122,10 +219,13 @@ func (h *AuthHandler) SignUpHandler() http.HandlerFunc {
 			return
 		}
 
+		// The account works without verification, so a mail failure must not fail the signup
+		if err := h.accountService.SendEmailVerification(ctx, user.ID); err != nil {
+			logger.Error("Failed to send verification email", slog.Any("error", err), slog.Int("user_id", user.ID))
+		}
+
 		// Store user ID in session
-		userIDStr := strconv.Itoa(user.ID)
-		err = gothic.StoreInSession("user_id", userIDStr, r, w)
-		if err != nil {
+		if err := adapter.StoreUserSession(w, r, user.ID); err != nil {
 			logger.Error("Failed to store session", slog.Any("error", err))
 			adapter.JsonErrorResponse(w, "Failed to save session", http.StatusInternalServerError)
 			return
@@ -152,19 +252,29 @@ func (h *AuthHandler) LoginHandler() http.HandlerFunc {
 			return
 		}
 
+		// Throttle guessing before looking at the credentials
+		ip := adapter.ClientIP(r, h.loginConfig.TrustForwardedFor)
+		if !checkLogin(w, r, logger, h.loginGuard, request.Email, ip) {
+			return
+		}
+
 		// Validate user
 		ctx := r.Context()
 		userID, password, err := h.userService.GetUserIDAndPasswordByEmail(ctx, request.Email)
 		if err != nil {
 			logger.Error("User not found", slog.String("email", request.Email))
-			adapter.JsonErrorResponse(w, "Invalid credentials", http.StatusUnauthorized)
+			if !recordLoginFailure(w, r, logger, h.loginGuard, h.auditor, 0, request.Email, ip, service.LoginFailureUnknownAccount) {
+				adapter.JsonErrorResponse(w, "Invalid credentials", http.StatusUnauthorized)
+			}
 			return
 		}
 
 		// Check if user is an OAuth user (i.e., no password set)
 		if password == "" {
 			logger.Warn("Attempted password login for OAuth user", slog.String("email", request.Email))
-			adapter.JsonErrorResponse(w, "This account uses Google authentication. Please log in with Google.", http.StatusUnauthorized)
+			if !recordLoginFailure(w, r, logger, h.loginGuard, h.auditor, userID, request.Email, ip, service.LoginFailureOAuthAccount) {
+				adapter.JsonErrorResponse(w, "This account signs in with an external provider. Please log in with it.", http.StatusUnauthorized)
+			}
 			return
 		}
 
@@ -172,14 +282,43 @@ func (h *AuthHandler) LoginHandler() http.HandlerFunc {
 		err = bcrypt.CompareHashAndPassword([]byte(password), []byte(request.Password))
 		if err != nil {
 			logger.Error("Invalid password attempt", slog.String("email", request.Email))
-			adapter.JsonErrorResponse(w, "Invalid credentials", http.StatusUnauthorized)
+			if !recordLoginFailure(w, r, logger, h.loginGuard, h.auditor, userID, request.Email, ip, service.LoginFailureInvalidPassword) {
+				adapter.JsonErrorResponse(w, "Invalid credentials", http.StatusUnauthorized)
+			}
 			return
 		}
 
-		// Store user ID in session
-		userIDStr := strconv.Itoa(userID)
-		err = gothic.StoreInSession("user_id", userIDStr, r, w)
+		if err := h.loginGuard.RecordSuccess(ctx, request.Email); err != nil {
+			logger.Error("Failed to clear failed logins", slog.Any("error", err))
+		}
+
+		user, err := h.userService.GetUserByID(ctx, userID)
 		if err != nil {
+			logger.Error("Failed to get user", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// With two-factor enabled the password only starts the login; /auth/2fa/verify completes it
+		if user.TwoFactorEnabled {
+			if err := adapter.StorePendingTwoFactor(w, r, userID); err != nil {
+				logger.Error("Failed to store session", slog.Any("error", err))
+				adapter.JsonErrorResponse(w, "Failed to save session", http.StatusInternalServerError)
+				return
+			}
+
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]any{"message": "Two-factor code required", "two_factor_required": true})
+			return
+		}
+
+		// Signing in during the deletion grace period keeps the account
+		if !restoreAccount(w, r, logger, h.accountService, userID) {
+			return
+		}
+
+		// Store user ID in session
+		if err := adapter.StoreUserSession(w, r, userID); err != nil {
 			logger.Error("Failed to store session", slog.Any("error", err))
 			adapter.JsonErrorResponse(w, "Failed to save session", http.StatusInternalServerError)
 			return
@@ -189,3 +328,119 @@ func (h *AuthHandler) LoginHandler() http.HandlerFunc {
 		json.NewEncoder(w).Encode(map[string]string{"message": "Login successful"})
 	}
 }
+
+// ForgotPasswordHandler mails a password reset link. It answers the same whether or not the account exists.
+func (h *AuthHandler) ForgotPasswordHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		var request struct {
+			Email string `json:"email"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Email == "" {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		if err := h.accountService.RequestPasswordReset(r.Context(), request.Email); err != nil {
+			logger.Error("Failed to send password reset email", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Could not send password reset email", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"message": "If the account exists, a password reset email has been sent"})
+	}
+}
+
+// ResetPasswordHandler sets a new password using the token from a reset email
+func (h *AuthHandler) ResetPasswordHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		var request struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		err := h.accountService.ResetPassword(r.Context(), request.Token, request.Password)
+		switch {
+		case errors.Is(err, service.ErrInvalidPassword), errors.Is(err, service.ErrInvalidToken):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			logger.Error("Failed to reset password", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Could not reset password", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Password has been reset"})
+	}
+}
+
+// VerifyEmailHandler confirms the user's email address using the token from a verification email
+func (h *AuthHandler) VerifyEmailHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		var request struct {
+			Token string `json:"token"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		err := h.accountService.VerifyEmail(r.Context(), request.Token)
+		switch {
+		case errors.Is(err, service.ErrInvalidToken):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			logger.Error("Failed to verify email", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Could not verify email", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Email verified"})
+	}
+}
+
+// ResendVerificationHandler mails the signed-in user a new verification link
+func (h *AuthHandler) ResendVerificationHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		err = h.accountService.SendEmailVerification(r.Context(), userID)
+		switch {
+		case errors.Is(err, service.ErrEmailAlreadyVerified):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to send verification email", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not send verification email", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Verification email sent"})
+	}
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/service"
+)
+
+type ExportHandler struct {
+	exportService *service.ExportService
+}
+
+func NewExportHandler(exportService *service.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// RequestExportHandler queues an export of the user's data; its download link is mailed once it is ready
+func (h *ExportHandler) RequestExportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		export, err := h.exportService.RequestExport(r.Context(), userID)
+		switch {
+		case errors.Is(err, service.ErrExportInProgress):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to request data export", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not request data export", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Data export requested", slog.Int("user_id", userID), slog.Int("export_id", export.ID))
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(export)
+	}
+}
+
+// GetExportHandler reports whether one of the user's exports is ready
+func (h *ExportHandler) GetExportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		exportID, err := strconv.Atoi(r.PathValue("export_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid export ID", http.StatusBadRequest)
+			return
+		}
+
+		export, err := h.exportService.GetExport(r.Context(), userID, exportID)
+		switch {
+		case errors.Is(err, service.ErrExportNotFound):
+			adapter.JsonErrorResponse(w, "Data export not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to get data export", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not get data export", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(export)
+	}
+}
+
+// DownloadExportHandler serves the archive of the export with the token from the mailed link.
+// The token alone authorizes the download, so the link works without signing in until it expires.
+func (h *ExportHandler) DownloadExportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			adapter.JsonErrorResponse(w, "Missing token", http.StatusBadRequest)
+			return
+		}
+
+		export, archive, err := h.exportService.DownloadExport(r.Context(), token)
+		switch {
+		case errors.Is(err, service.ErrExportNotFound):
+			adapter.JsonErrorResponse(w, "Data export not found or expired", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to download data export", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Could not download data export", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Data export downloaded", slog.Int("export_id", export.ID))
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="movie-search-export-%d.zip"`, export.ID))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		w.Write(archive)
+	}
+}
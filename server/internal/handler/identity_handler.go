@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/service"
+)
+
+// pendingLinkMaxAge is how long a started link waits for the provider's callback
+const pendingLinkMaxAge = 10 * time.Minute
+
+type IdentityHandler struct {
+	identityService *service.IdentityService
+}
+
+func NewIdentityHandler(identityService *service.IdentityService) *IdentityHandler {
+	return &IdentityHandler{identityService: identityService}
+}
+
+// ListIdentitiesHandler lists the provider accounts linked to the user
+func (h *IdentityHandler) ListIdentitiesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		identities, err := h.identityService.ListIdentities(r.Context(), userID)
+		if err != nil {
+			logger.Error("Failed to list identities", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not list linked accounts", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(identities)
+	}
+}
+
+// LinkIdentityHandler starts linking an account at the provider and returns the provider's URL to send the browser to.
+// The provider redirects back to the OAuth callback, which completes the link.
+func (h *IdentityHandler) LinkIdentityHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		provider := r.PathValue("provider")
+		if _, err := goth.GetProvider(provider); err != nil {
+			adapter.JsonErrorResponse(w, "Unknown provider", http.StatusNotFound)
+			return
+		}
+
+		if err := adapter.StorePendingLink(w, r, userID, provider); err != nil {
+			logger.Error("Failed to store pending link in session", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Failed to save session", http.StatusInternalServerError)
+			return
+		}
+
+		authURL, err := gothic.GetAuthURL(w, gothic.GetContextWithProvider(r, provider))
+		if err != nil {
+			logger.Error("Failed to start provider authorization", slog.Any("error", err), slog.String("provider", provider))
+			adapter.JsonErrorResponse(w, "Could not start linking", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"auth_url": authURL})
+	}
+}
+
+// UnlinkIdentityHandler unlinks the user's account at the provider
+func (h *IdentityHandler) UnlinkIdentityHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		provider := r.PathValue("provider")
+		err = h.identityService.UnlinkIdentity(r.Context(), userID, provider)
+		switch {
+		case errors.Is(err, service.ErrIdentityNotFound):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, service.ErrLastSignInMethod):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to unlink identity", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not unlink account", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Identity unlinked", slog.Int("user_id", userID), slog.String("provider", provider))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// oauthProfile reads what the provider reported about the account. Each provider tells differently whether it
+// verified the email.
+func oauthProfile(authUser goth.User) domain.OAuthProfile {
+	profile := domain.OAuthProfile{
+		Provider:   authUser.Provider,
+		Subject:    authUser.UserID,
+		Email:      authUser.Email,
+		FirstName:  authUser.FirstName,
+		LastName:   authUser.LastName,
+		PictureURL: authUser.AvatarURL,
+	}
+
+	// GitHub reports a single display name, and only a login when even that is unset
+	if profile.FirstName == "" && profile.LastName == "" {
+		profile.FirstName, profile.LastName, _ = strings.Cut(strings.TrimSpace(authUser.Name), " ")
+		if profile.FirstName == "" {
+			profile.FirstName = authUser.NickName
+		}
+	}
+
+	switch authUser.Provider {
+	case "google":
+		profile.EmailVerified, _ = authUser.RawData["verified_email"].(bool)
+	case "github":
+		// GitHub only shares verified addresses
+		profile.EmailVerified = authUser.Email != ""
+	default:
+		// The OpenID Connect claim
+		switch verified := authUser.RawData["email_verified"].(type) {
+		case bool:
+			profile.EmailVerified = verified
+		case string:
+			profile.EmailVerified = verified == "true"
+		}
+	}
+
+	return profile
+}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/service"
+)
+
+type ListHandler struct {
+	listService *service.ListService
+}
+
+func NewListHandler(listService *service.ListService) *ListHandler {
+	return &ListHandler{listService: listService}
+}
+
+type listRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Visibility  string `json:"visibility"`
+}
+
+// writeListError maps list service errors to responses, logging unexpected ones
+func writeListError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidListName),
+		errors.Is(err, service.ErrInvalidListDesc),
+		errors.Is(err, service.ErrInvalidListVisibility),
+		errors.Is(err, service.ErrInvalidListOrder):
+		adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrListNotFound):
+		adapter.JsonErrorResponse(w, "List not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrListEntryNotFound):
+		adapter.JsonErrorResponse(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrMovieNotFound):
+		adapter.JsonErrorResponse(w, "Movie not found", http.StatusNotFound)
+	default:
+		middleware.GetLogger(r.Context()).Error(message, slog.Any("error", err))
+		adapter.JsonErrorResponse(w, message, http.StatusInternalServerError)
+	}
+}
+
+func (h *ListHandler) ListListsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		lists, err := h.listService.ListUserLists(r.Context(), userID)
+		if err != nil {
+			writeListError(w, r, err, "Could not fetch lists")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(lists)
+	}
+}
+
+func (h *ListHandler) CreateListHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var request listRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		list, err := h.listService.CreateList(r.Context(), userID, request.Name, request.Description, request.Visibility)
+		if err != nil {
+			writeListError(w, r, err, "Could not create list")
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+func (h *ListHandler) GetListHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := strconv.Atoi(r.PathValue("list_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		list, err := h.listService.GetList(r.Context(), userID, listID)
+		if err != nil {
+			writeListError(w, r, err, "Could not fetch list")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+func (h *ListHandler) UpdateListHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := strconv.Atoi(r.PathValue("list_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		var request listRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		list, err := h.listService.UpdateList(r.Context(), userID, listID, request.Name, request.Description, request.Visibility)
+		if err != nil {
+			writeListError(w, r, err, "Could not update list")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+func (h *ListHandler) DeleteListHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := strconv.Atoi(r.PathValue("list_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := h.listService.DeleteList(r.Context(), userID, listID); err != nil {
+			writeListError(w, r, err, "Could not delete list")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *ListHandler) AddEntryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := strconv.Atoi(r.PathValue("list_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		var request struct {
+			MovieID int `json:"movie_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.MovieID < 1 {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		list, err := h.listService.AddEntry(r.Context(), userID, listID, request.MovieID)
+		if err != nil {
+			writeListError(w, r, err, "Could not add movie to list")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+func (h *ListHandler) RemoveEntryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := strconv.Atoi(r.PathValue("list_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		movieID, err := strconv.Atoi(r.PathValue("movie_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid movie ID", http.StatusBadRequest)
+			return
+		}
+
+		list, err := h.listService.RemoveEntry(r.Context(), userID, listID, movieID)
+		if err != nil {
+			writeListError(w, r, err, "Could not remove movie from list")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+func (h *ListHandler) ReorderEntriesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		listID, err := strconv.Atoi(r.PathValue("list_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid list ID", http.StatusBadRequest)
+			return
+		}
+
+		var request struct {
+			MovieIDs []int `json:"movie_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		list, err := h.listService.ReorderEntries(r.Context(), userID, listID, request.MovieIDs)
+		if err != nil {
+			writeListError(w, r, err, "Could not reorder list")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+func (h *ListHandler) GetSharedListHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := h.listService.GetSharedList(r.Context(), r.PathValue("share_token"))
+		if err != nil {
+			writeListError(w, r, err, "Could not fetch list")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// ListPublicListsHandler returns a user's public lists, so others can discover them without a share URL
+func (h *ListHandler) ListPublicListsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(r.PathValue("user_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		lists, err := h.listService.ListPublicLists(r.Context(), userID)
+		if err != nil {
+			writeListError(w, r, err, "Could not fetch lists")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(lists)
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/service"
+)
+
+// checkLogin applies the login guard before credentials are checked: it answers 429 for a locked out account or IP,
+// and otherwise waits out the progressive delay. It reports whether the handler may go on.
+// The guard failing open keeps logins working while Redis is down.
+func checkLogin(w http.ResponseWriter, r *http.Request, logger *slog.Logger, guard *service.LoginGuard, account, ip string) bool {
+	wait, err := guard.Check(r.Context(), account, ip)
+	switch {
+	case errors.Is(err, service.ErrLoginLocked):
+		respondLoginLocked(w, wait)
+		return false
+	case err != nil:
+		logger.Error("Failed to check login throttling", slog.Any("error", err))
+		return true
+	}
+
+	if err := sleep(r.Context(), wait); err != nil {
+		// The client went away
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed login and reports whether it locked the account or IP out, in which case it
+// has answered 429 and recorded the lockout in the audit log. userID is the account's user, 0 when there is none.
+func recordLoginFailure(
+	w http.ResponseWriter,
+	r *http.Request,
+	logger *slog.Logger,
+	guard *service.LoginGuard,
+	auditor *Auditor,
+	userID int,
+	account, ip, reason string,
+) bool {
+	lockout, err := guard.RecordFailure(r.Context(), account, ip, reason)
+	if err != nil {
+		logger.Error("Failed to record failed login", slog.Any("error", err))
+		return false
+	}
+	if lockout == nil {
+		return false
+	}
+
+	logger.Warn("Login locked out",
+		slog.String("audit_event", "login.lockout"),
+		slog.String("scope", lockout.Scope),
+		slog.String("account", account),
+		slog.String("ip", ip),
+		slog.Int("failures", lockout.Failures),
+		slog.Duration("duration", lockout.Duration),
+	)
+	// The lockout is already in force, so the client is told about it even when it could not be recorded
+	if err := auditor.RecordLockout(r, userID, account, reason, lockout); err != nil {
+		logger.Error("Failed to record login lockout", slog.Any("error", err), slog.String("scope", lockout.Scope))
+	}
+	respondLoginLocked(w, lockout.Duration)
+	return true
+}
+
+func respondLoginLocked(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	adapter.JsonErrorResponse(w, service.ErrLoginLocked.Error(), http.StatusTooManyRequests)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
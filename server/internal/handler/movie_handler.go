@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"log/slog"
 
@@ -13,12 +17,20 @@ import (
 	"github.com/martishin/movie-search-service/internal/service"
 )
 
+const (
+	defaultSearchLimit  = 20
+	maxSearchLimit      = 100
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 20
+)
+
 type MovieHandler struct {
 	movieService *service.MovieService
+	auditor      *Auditor
 }
 
-func NewMovieHandler(movieService *service.MovieService) *MovieHandler {
-	return &MovieHandler{movieService: movieService}
+func NewMovieHandler(movieService *service.MovieService, auditor *Auditor) *MovieHandler {
+	return &MovieHandler{movieService: movieService, auditor: auditor}
 }
 
 func (h *MovieHandler) CreateMovieHandler() http.HandlerFunc {
@@ -32,7 +44,14 @@ func (h *MovieHandler) CreateMovieHandler() http.HandlerFunc {
 			return
 		}
 
-		movie, err := h.movieService.CreateMovie(r.Context(), request)
+		var movie *domain.Movie
+		err := h.auditor.Transaction(r, func(ctx context.Context) error {
+			var err error
+			if movie, err = h.movieService.CreateMovie(ctx, request); err != nil {
+				return err
+			}
+			return h.auditor.Record(ctx, r, domain.AuditActionCreate, domain.AuditEntityMovie, movie.ID, nil, movie)
+		})
 		if err != nil {
 			logger.Error("Failed to create movie", slog.Any("error", err))
 			adapter.JsonErrorResponse(w, "Could not create movie", http.StatusInternalServerError)
@@ -97,15 +116,102 @@ func (h *MovieHandler) ListMoviesHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := middleware.GetLogger(r.Context())
 
-		movies, err := h.movieService.ListMoviesWithGenres(r.Context())
+		filter, err := adapter.ParseMovieFilter(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := adapter.ParseMoviePageRequest(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		movies, err := h.movieService.ListMoviesWithGenres(r.Context(), filter, page)
 		if err != nil {
 			logger.Error("Failed to fetch movies", slog.Any("error", err))
 			adapter.JsonErrorResponse(w, "Could not fetch movies", http.StatusInternalServerError)
 			return
 		}
 
+		facets, err := h.movieService.ListMovieFacets(r.Context(), filter, 0, false)
+		if err != nil {
+			logger.Error("Failed to fetch movie facets", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Could not fetch movies", http.StatusInternalServerError)
+			return
+		}
+
+		// The listing may be the cached copy other requests are reading, so the facets go on a copy
+		response := *movies
+		response.Facets = facets
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (h *MovieHandler) SearchMoviesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		if query == "" {
+			adapter.JsonErrorResponse(w, "Missing search query", http.StatusBadRequest)
+			return
+		}
+
+		limit := defaultSearchLimit
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			parsedLimit, err := strconv.Atoi(limitStr)
+			if err != nil || parsedLimit < 1 || parsedLimit > maxSearchLimit {
+				adapter.JsonErrorResponse(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = parsedLimit
+		}
+
+		results, err := h.movieService.SearchMovies(r.Context(), query, limit)
+		if err != nil {
+			logger.Error("Failed to search movies", slog.Any("error", err), slog.String("query", query))
+			adapter.JsonErrorResponse(w, "Could not search movies", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(results)
+	}
+}
+
+func (h *MovieHandler) SuggestMoviesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		prefix := strings.TrimSpace(r.URL.Query().Get("prefix"))
+		if prefix == "" {
+			adapter.JsonErrorResponse(w, "Missing prefix", http.StatusBadRequest)
+			return
+		}
+
+		limit := defaultSuggestLimit
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			parsedLimit, err := strconv.Atoi(limitStr)
+			if err != nil || parsedLimit < 1 || parsedLimit > maxSuggestLimit {
+				adapter.JsonErrorResponse(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = parsedLimit
+		}
+
+		suggestions, err := h.movieService.SuggestMovies(r.Context(), prefix, limit)
+		if err != nil {
+			logger.Error("Failed to suggest movies", slog.Any("error", err), slog.String("prefix", prefix))
+			adapter.JsonErrorResponse(w, "Could not fetch suggestions", http.StatusInternalServerError)
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(movies)
+		json.NewEncoder(w).Encode(suggestions)
 	}
 }
 
@@ -130,7 +236,27 @@ func (h *MovieHandler) UpdateMovieHandler() http.HandlerFunc {
 
 		request.ID = movieID // Ensure the correct ID is set
 
-		err = h.movieService.UpdateMovie(r.Context(), request)
+		err = h.auditor.Transaction(r, func(ctx context.Context) error {
+			before, err := h.movieService.GetMovieByIDWithGenres(ctx, movieID)
+			if err != nil {
+				return service.ErrMovieNotFound
+			}
+
+			if err := h.movieService.UpdateMovie(ctx, request); err != nil {
+				return err
+			}
+
+			after, err := h.movieService.GetMovieByIDWithGenres(ctx, movieID)
+			if err != nil {
+				return fmt.Errorf("fetch updated movie: %w", err)
+			}
+			return h.auditor.Record(ctx, r, domain.AuditActionUpdate, domain.AuditEntityMovie, movieID, before, after)
+		})
+		if errors.Is(err, service.ErrMovieNotFound) {
+			logger.Error("Movie not found", slog.String("id", idStr))
+			adapter.JsonErrorResponse(w, "Movie not found", http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to update movie", slog.Any("error", err))
 			adapter.JsonErrorResponse(w, "Could not update movie", http.StatusInternalServerError)
@@ -154,7 +280,22 @@ func (h *MovieHandler) DeleteMovieHandler() http.HandlerFunc {
 			return
 		}
 
-		err = h.movieService.DeleteMovie(r.Context(), movieID)
+		err = h.auditor.Transaction(r, func(ctx context.Context) error {
+			before, err := h.movieService.GetMovieByIDWithGenres(ctx, movieID)
+			if err != nil {
+				return service.ErrMovieNotFound
+			}
+
+			if err := h.movieService.DeleteMovie(ctx, movieID); err != nil {
+				return err
+			}
+			return h.auditor.Record(ctx, r, domain.AuditActionDelete, domain.AuditEntityMovie, movieID, before, nil)
+		})
+		if errors.Is(err, service.ErrMovieNotFound) {
+			logger.Error("Movie not found", slog.String("id", idStr))
+			adapter.JsonErrorResponse(w, "Movie not found", http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			logger.Error("Failed to delete movie", slog.Any("error", err))
 			adapter.JsonErrorResponse(w, "Could not delete movie", http.StatusInternalServerError)
@@ -192,28 +333,153 @@ func (h *MovieHandler) ListMoviesWithGenresAndLikesHandler() http.HandlerFunc {
 			return
 		}
 
+		filter, err := adapter.ParseMovieFilter(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := adapter.ParseMoviePageRequest(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Check if only_liked query parameter is passed
-		if r.URL.Query().Get("only_liked") == "true" {
-			movies, err := h.movieService.GetLikedMovies(r.Context(), userID)
+		onlyLiked := r.URL.Query().Get("only_liked") == "true"
+
+		facets, err := h.movieService.ListMovieFacets(r.Context(), filter, userID, onlyLiked)
+		if err != nil {
+			logger.Error("Failed to fetch movie facets", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Could not fetch movies", http.StatusInternalServerError)
+			return
+		}
+
+		if onlyLiked {
+			movies, err := h.movieService.GetLikedMovies(r.Context(), userID, filter, page)
 			if err != nil {
 				logger.Error("Failed to fetch liked movies", slog.Any("error", err))
 				adapter.JsonErrorResponse(w, "Could not fetch liked movies", http.StatusInternalServerError)
 				return
 			}
 
+			response := *movies
+			response.Facets = facets
+
 			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(movies)
+			json.NewEncoder(w).Encode(response)
 			return
 		}
 
-		movies, err := h.movieService.ListMoviesWithGenresAndLikes(r.Context(), userID)
+		movies, err := h.movieService.ListMoviesWithGenresAndLikes(r.Context(), userID, filter, page)
 		if err != nil {
 			logger.Error("Failed to fetch movies with genres and likes", slog.Any("error", err))
 			adapter.JsonErrorResponse(w, "Could not fetch movies", http.StatusInternalServerError)
 			return
 		}
 
+		response := *movies
+		response.Facets = facets
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (h *MovieHandler) AddCreditHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		idStr := r.PathValue("id")
+		movieID, err := strconv.Atoi(idStr)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid movie ID", http.StatusBadRequest)
+			return
+		}
+
+		var request domain.Credit
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			logger.Error("Invalid request payload", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		var credit *domain.Credit
+		err = h.auditor.Transaction(r, func(ctx context.Context) error {
+			var err error
+			if credit, err = h.movieService.AddMovieCredit(ctx, movieID, request); err != nil {
+				return err
+			}
+			return h.auditor.Record(ctx, r, domain.AuditActionCreate, domain.AuditEntityCredit, credit.ID, nil, credit)
+		})
+		switch {
+		case errors.Is(err, service.ErrInvalidCreditRole):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrMovieNotFound):
+			adapter.JsonErrorResponse(w, "Movie not found", http.StatusNotFound)
+			return
+		case errors.Is(err, service.ErrPersonNotFound):
+			adapter.JsonErrorResponse(w, "Person not found", http.StatusNotFound)
+			return
+		case errors.Is(err, service.ErrCreditExists):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			logger.Error("Failed to add credit", slog.Any("error", err), slog.String("id", idStr))
+			adapter.JsonErrorResponse(w, "Could not add credit", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(credit)
+	}
+}
+
+func (h *MovieHandler) RemoveCreditHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		idStr := r.PathValue("id")
+		movieID, err := strconv.Atoi(idStr)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid movie ID", http.StatusBadRequest)
+			return
+		}
+
+		creditID, err := strconv.Atoi(r.PathValue("credit_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid credit ID", http.StatusBadRequest)
+			return
+		}
+
+		err = h.auditor.Transaction(r, func(ctx context.Context) error {
+			// The removed credit as the movie listed it, for the audit log
+			var before *domain.Credit
+			if movie, err := h.movieService.GetMovieByIDWithGenres(ctx, movieID); err == nil {
+				for _, credit := range movie.Credits {
+					if credit.ID == creditID {
+						before = credit
+					}
+				}
+			}
+
+			if err := h.movieService.RemoveMovieCredit(ctx, movieID, creditID); err != nil {
+				return err
+			}
+			return h.auditor.Record(ctx, r, domain.AuditActionDelete, domain.AuditEntityCredit, creditID, before, nil)
+		})
+		if errors.Is(err, service.ErrCreditNotFound) {
+			adapter.JsonErrorResponse(w, "Credit not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to remove credit", slog.Any("error", err), slog.String("id", idStr))
+			adapter.JsonErrorResponse(w, "Could not remove credit", http.StatusInternalServerError)
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(movies)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Credit removed successfully"})
 	}
 }
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/service"
+)
+
+type PersonHandler struct {
+	personService *service.PersonService
+	auditor       *Auditor
+}
+
+func NewPersonHandler(personService *service.PersonService, auditor *Auditor) *PersonHandler {
+	return &PersonHandler{personService: personService, auditor: auditor}
+}
+
+func (h *PersonHandler) CreatePersonHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		var request domain.Person
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			logger.Error("Invalid request payload", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		var person *domain.Person
+		err := h.auditor.Transaction(r, func(ctx context.Context) error {
+			var err error
+			if person, err = h.personService.CreatePerson(ctx, request); err != nil {
+				return err
+			}
+			return h.auditor.Record(ctx, r, domain.AuditActionCreate, domain.AuditEntityPerson, person.ID, nil, person)
+		})
+		if errors.Is(err, service.ErrInvalidPersonName) {
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to create person", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Could not create person", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(person)
+	}
+}
+
+func (h *PersonHandler) ListPeopleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		people, err := h.personService.ListPeople(r.Context())
+		if err != nil {
+			logger.Error("Failed to list people", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Could not fetch people", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(people)
+	}
+}
+
+func (h *PersonHandler) GetPersonHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		idStr := r.PathValue("id")
+		personID, err := strconv.Atoi(idStr)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid person ID", http.StatusBadRequest)
+			return
+		}
+
+		person, err := h.personService.GetPersonWithFilmography(r.Context(), personID)
+		if errors.Is(err, service.ErrPersonNotFound) {
+			adapter.JsonErrorResponse(w, "Person not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to fetch person", slog.Any("error", err), slog.String("id", idStr))
+			adapter.JsonErrorResponse(w, "Could not fetch person", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(person)
+	}
+}
+
+func (h *PersonHandler) UpdatePersonHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		idStr := r.PathValue("id")
+		personID, err := strconv.Atoi(idStr)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid person ID", http.StatusBadRequest)
+			return
+		}
+
+		var request domain.Person
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			logger.Error("Invalid request payload", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		request.ID = personID // Ensure the correct ID is set
+
+		var person *domain.Person
+		err = h.auditor.Transaction(r, func(ctx context.Context) error {
+			// The person as they were, for the audit log; UpdatePerson reports a missing person
+			before, _ := h.personService.GetPersonWithFilmography(ctx, personID)
+			if before != nil {
+				before.Filmography = nil // Updates do not change credits
+			}
+
+			var err error
+			if person, err = h.personService.UpdatePerson(ctx, request); err != nil {
+				return err
+			}
+			return h.auditor.Record(ctx, r, domain.AuditActionUpdate, domain.AuditEntityPerson, personID, before, person)
+		})
+		switch {
+		case errors.Is(err, service.ErrInvalidPersonName):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrPersonNotFound):
+			adapter.JsonErrorResponse(w, "Person not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to update person", slog.Any("error", err), slog.String("id", idStr))
+			adapter.JsonErrorResponse(w, "Could not update person", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(person)
+	}
+}
+
+func (h *PersonHandler) DeletePersonHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		idStr := r.PathValue("id")
+		personID, err := strconv.Atoi(idStr)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid person ID", http.StatusBadRequest)
+			return
+		}
+
+		err = h.auditor.Transaction(r, func(ctx context.Context) error {
+			// The person as they were, with the credits deleted along with them, for the audit log
+			before, _ := h.personService.GetPersonWithFilmography(ctx, personID)
+
+			if err := h.personService.DeletePerson(ctx, personID); err != nil {
+				return err
+			}
+			return h.auditor.Record(ctx, r, domain.AuditActionDelete, domain.AuditEntityPerson, personID, before, nil)
+		})
+		if errors.Is(err, service.ErrPersonNotFound) {
+			adapter.JsonErrorResponse(w, "Person not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to delete person", slog.Any("error", err), slog.String("id", idStr))
+			adapter.JsonErrorResponse(w, "Could not delete person", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Person deleted successfully"})
+	}
+}
@@ -0,0 +1,339 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/service"
+)
+
+type ReviewHandler struct {
+	reviewService *service.ReviewService
+	auditor       *Auditor
+}
+
+func NewReviewHandler(reviewService *service.ReviewService, auditor *Auditor) *ReviewHandler {
+	return &ReviewHandler{reviewService: reviewService, auditor: auditor}
+}
+
+type reviewRequest struct {
+	Body string `json:"body"`
+}
+
+func (h *ReviewHandler) CreateReviewHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		movieID, err := strconv.Atoi(r.PathValue("movie_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid movie ID", http.StatusBadRequest)
+			return
+		}
+
+		var request reviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		review, err := h.reviewService.CreateReview(r.Context(), userID, movieID, request.Body)
+		switch {
+		case errors.Is(err, service.ErrInvalidReviewBody):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrMovieNotFound):
+			adapter.JsonErrorResponse(w, "Movie not found", http.StatusNotFound)
+			return
+		case errors.Is(err, service.ErrReviewExists):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			logger.Error("Failed to create review", slog.Any("error", err), slog.Int("movie_id", movieID))
+			adapter.JsonErrorResponse(w, "Could not create review", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(review)
+	}
+}
+
+func (h *ReviewHandler) GetReviewHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		movieID, err := strconv.Atoi(r.PathValue("movie_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid movie ID", http.StatusBadRequest)
+			return
+		}
+
+		review, err := h.reviewService.GetUserReview(r.Context(), userID, movieID)
+		if errors.Is(err, service.ErrReviewNotFound) {
+			adapter.JsonErrorResponse(w, "Review not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to fetch review", slog.Any("error", err), slog.Int("movie_id", movieID))
+			adapter.JsonErrorResponse(w, "Could not fetch review", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(review)
+	}
+}
+
+func (h *ReviewHandler) UpdateReviewHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		movieID, err := strconv.Atoi(r.PathValue("movie_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid movie ID", http.StatusBadRequest)
+			return
+		}
+
+		var request reviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		review, err := h.reviewService.UpdateReview(r.Context(), userID, movieID, request.Body)
+		switch {
+		case errors.Is(err, service.ErrInvalidReviewBody):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrReviewNotFound):
+			adapter.JsonErrorResponse(w, "Review not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to update review", slog.Any("error", err), slog.Int("movie_id", movieID))
+			adapter.JsonErrorResponse(w, "Could not update review", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(review)
+	}
+}
+
+func (h *ReviewHandler) DeleteReviewHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		movieID, err := strconv.Atoi(r.PathValue("movie_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid movie ID", http.StatusBadRequest)
+			return
+		}
+
+		err = h.reviewService.DeleteReview(r.Context(), userID, movieID)
+		if errors.Is(err, service.ErrReviewNotFound) {
+			adapter.JsonErrorResponse(w, "Review not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to delete review", slog.Any("error", err), slog.Int("movie_id", movieID))
+			adapter.JsonErrorResponse(w, "Could not delete review", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *ReviewHandler) ListMovieReviewsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		movieID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid movie ID", http.StatusBadRequest)
+			return
+		}
+
+		page, err := adapter.ParseReviewPageRequest(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		reviews, err := h.reviewService.ListMovieReviews(r.Context(), movieID, page)
+		if err != nil {
+			logger.Error("Failed to list reviews", slog.Any("error", err), slog.Int("movie_id", movieID))
+			adapter.JsonErrorResponse(w, "Could not fetch reviews", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(reviews)
+	}
+}
+
+func (h *ReviewHandler) MarkHelpfulHandler() http.HandlerFunc {
+	return h.helpfulVoteHandler(h.reviewService.MarkReviewHelpful)
+}
+
+func (h *ReviewHandler) UnmarkHelpfulHandler() http.HandlerFunc {
+	return h.helpfulVoteHandler(h.reviewService.UnmarkReviewHelpful)
+}
+
+func (h *ReviewHandler) helpfulVoteHandler(
+	vote func(ctx context.Context, userID, movieID, reviewID int) (*domain.Review, error),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		movieID, err := strconv.Atoi(r.PathValue("movie_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid movie ID", http.StatusBadRequest)
+			return
+		}
+
+		reviewID, err := strconv.Atoi(r.PathValue("review_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid review ID", http.StatusBadRequest)
+			return
+		}
+
+		review, err := vote(r.Context(), userID, movieID, reviewID)
+		switch {
+		case errors.Is(err, service.ErrReviewNotFound):
+			adapter.JsonErrorResponse(w, "Review not found", http.StatusNotFound)
+			return
+		case errors.Is(err, service.ErrOwnReviewVote):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusForbidden)
+			return
+		case err != nil:
+			logger.Error("Failed to record helpful vote", slog.Any("error", err), slog.Int("review_id", reviewID))
+			adapter.JsonErrorResponse(w, "Could not record vote", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(review)
+	}
+}
+
+func (h *ReviewHandler) ListReviewsForModerationHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = domain.ReviewStatusPending
+		}
+
+		page, err := adapter.ParseReviewPageRequest(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		reviews, err := h.reviewService.ListReviewsForModeration(r.Context(), status, page)
+		if errors.Is(err, service.ErrInvalidReviewStatus) {
+			adapter.JsonErrorResponse(w, "Invalid status", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to list reviews for moderation", slog.Any("error", err), slog.String("status", status))
+			adapter.JsonErrorResponse(w, "Could not fetch reviews", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(reviews)
+	}
+}
+
+func (h *ReviewHandler) ModerateReviewHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		moderatorID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		reviewID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid review ID", http.StatusBadRequest)
+			return
+		}
+
+		var request struct {
+			Status string `json:"status"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		var review *domain.Review
+		err = h.auditor.Transaction(r, func(ctx context.Context) error {
+			// The review as it was, for the audit log; ModerateReview reports a missing review
+			before, _ := h.reviewService.GetReview(ctx, reviewID)
+
+			var err error
+			if review, err = h.reviewService.ModerateReview(ctx, reviewID, request.Status, moderatorID); err != nil {
+				return err
+			}
+			return h.auditor.Record(ctx, r, domain.AuditActionModerate, domain.AuditEntityReview, reviewID, before, review)
+		})
+		switch {
+		case errors.Is(err, service.ErrInvalidReviewStatus):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrReviewNotFound):
+			adapter.JsonErrorResponse(w, "Review not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to moderate review", slog.Any("error", err), slog.Int("review_id", reviewID))
+			adapter.JsonErrorResponse(w, "Could not moderate review", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(review)
+	}
+}
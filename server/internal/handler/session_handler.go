@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/service"
+)
+
+type SessionHandler struct {
+	sessionService *service.SessionService
+}
+
+func NewSessionHandler(sessionService *service.SessionService) *SessionHandler {
+	return &SessionHandler{sessionService: sessionService}
+}
+
+// ListSessionsHandler lists the devices the user is signed in on
+func (h *SessionHandler) ListSessionsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		currentID, _ := adapter.GetSessionID(r)
+
+		sessions, err := h.sessionService.ListSessions(r.Context(), userID, currentID)
+		if err != nil {
+			logger.Error("Failed to list sessions", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not list sessions", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(sessions)
+	}
+}
+
+// RevokeSessionHandler signs one of the user's sessions out
+func (h *SessionHandler) RevokeSessionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		err = h.sessionService.RevokeSession(r.Context(), userID, r.PathValue("session_id"))
+		switch {
+		case errors.Is(err, service.ErrSessionNotFound):
+			adapter.JsonErrorResponse(w, "Session not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to revoke session", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not revoke session", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RevokeOtherSessionsHandler signs the user out everywhere except the current session
+func (h *SessionHandler) RevokeOtherSessionsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		currentID, err := adapter.GetSessionID(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		revoked, err := h.sessionService.RevokeOtherSessions(r.Context(), userID, currentID)
+		if err != nil {
+			logger.Error("Failed to revoke sessions", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not revoke sessions", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Other sessions revoked", slog.Int("user_id", userID), slog.Int("revoked", revoked))
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]int{"revoked": revoked})
+	}
+}
@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/model/config"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/service"
+)
+
+// pendingTwoFactorMaxAge is how long a password login waits for its two-factor code
+const pendingTwoFactorMaxAge = 5 * time.Minute
+
+type TwoFactorHandler struct {
+	twoFactorService *service.TwoFactorService
+	accountService   *service.AccountService
+	loginGuard       *service.LoginGuard
+	loginConfig      *config.LoginProtectionConfig
+	auditor          *Auditor
+}
+
+func NewTwoFactorHandler(
+	twoFactorService *service.TwoFactorService,
+	accountService *service.AccountService,
+	loginGuard *service.LoginGuard,
+	loginConfig *config.LoginProtectionConfig,
+	auditor *Auditor,
+) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		accountService:   accountService,
+		loginGuard:       loginGuard,
+		loginConfig:      loginConfig,
+		auditor:          auditor,
+	}
+}
+
+// VerifyLoginHandler completes a pending login with a TOTP or recovery code
+func (h *TwoFactorHandler) VerifyLoginHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetPendingTwoFactor(r, pendingTwoFactorMaxAge)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "No pending login, sign in again", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		// Codes are throttled like passwords, under an account of their own: a million codes are quick to guess
+		account := twoFactorAccount(userID)
+		ip := adapter.ClientIP(r, h.loginConfig.TrustForwardedFor)
+		if !checkLogin(w, r, logger, h.loginGuard, account, ip) {
+			return
+		}
+
+		err = h.twoFactorService.Verify(r.Context(), userID, request.Code)
+		switch {
+		case errors.Is(err, service.ErrInvalidTwoFactorCode):
+			logger.Warn("Invalid two-factor code", slog.Int("user_id", userID))
+			if !recordLoginFailure(w, r, logger, h.loginGuard, h.auditor, userID, account, ip, service.LoginFailureInvalidTwoFactor) {
+				adapter.JsonErrorResponse(w, err.Error(), http.StatusUnauthorized)
+			}
+			return
+		case errors.Is(err, service.ErrTwoFactorNotEnabled), errors.Is(err, service.ErrUserNotFound):
+			// Two-factor was reset or the account removed while the login was pending
+			adapter.JsonErrorResponse(w, "No pending login, sign in again", http.StatusUnauthorized)
+			return
+		case err != nil:
+			logger.Error("Failed to verify two-factor code", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not verify two-factor code", http.StatusInternalServerError)
+			return
+		}
+
+		if err := h.loginGuard.RecordSuccess(r.Context(), account); err != nil {
+			logger.Error("Failed to clear failed logins", slog.Any("error", err))
+		}
+
+		if !restoreAccount(w, r, logger, h.accountService, userID) {
+			return
+		}
+
+		if err := adapter.StoreUserSession(w, r, userID); err != nil {
+			logger.Error("Failed to store session", slog.Any("error", err))
+			adapter.JsonErrorResponse(w, "Failed to save session", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Login successful"})
+	}
+}
+
+// EnrollHandler starts enrollment and returns the secret to add to an authenticator app
+func (h *TwoFactorHandler) EnrollHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		enrollment, err := h.twoFactorService.BeginEnrollment(r.Context(), userID)
+		switch {
+		case errors.Is(err, service.ErrTwoFactorRequiresPassword):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrTwoFactorEnabled):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to start two-factor enrollment", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not start two-factor enrollment", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(enrollment)
+	}
+}
+
+// ConfirmEnrollHandler enables two-factor with a code from the enrolled app and returns the recovery codes
+func (h *TwoFactorHandler) ConfirmEnrollHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		codes, err := h.twoFactorService.ConfirmEnrollment(r.Context(), userID, request.Code)
+		switch {
+		case errors.Is(err, service.ErrInvalidTwoFactorCode), errors.Is(err, service.ErrTwoFactorNotPending):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrTwoFactorEnabled):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to confirm two-factor enrollment", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not enable two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Two-factor authentication enabled", slog.Int("user_id", userID))
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(domain.RecoveryCodes{RecoveryCodes: codes})
+	}
+}
+
+// RegenerateRecoveryCodesHandler replaces the recovery codes, invalidating the old ones
+func (h *TwoFactorHandler) RegenerateRecoveryCodesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		codes, err := h.twoFactorService.RegenerateRecoveryCodes(r.Context(), userID, request.Code)
+		switch {
+		case errors.Is(err, service.ErrInvalidTwoFactorCode):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrTwoFactorNotEnabled):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to regenerate recovery codes", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not regenerate recovery codes", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(domain.RecoveryCodes{RecoveryCodes: codes})
+	}
+}
+
+// DisableHandler turns two-factor off for the signed-in user, who must supply a current code
+func (h *TwoFactorHandler) DisableHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var request struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		err = h.twoFactorService.Disable(r.Context(), userID, request.Code)
+		switch {
+		case errors.Is(err, service.ErrInvalidTwoFactorCode):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrTwoFactorNotEnabled):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to disable two-factor authentication", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not disable two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Two-factor authentication disabled", slog.Int("user_id", userID))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ResetHandler lets an admin turn off two-factor for a user locked out of their account
+func (h *TwoFactorHandler) ResetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		actorID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		err = h.auditor.Transaction(r, func(ctx context.Context) error {
+			if err := h.twoFactorService.Reset(ctx, userID); err != nil {
+				return err
+			}
+			return h.auditor.Record(ctx, r, domain.AuditActionResetTwoFactor, domain.AuditEntityUser, userID,
+				map[string]bool{"twoFactorEnabled": true}, map[string]bool{"twoFactorEnabled": false})
+		})
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to reset two-factor authentication", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not reset two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Two-factor authentication reset", slog.Int("user_id", userID), slog.Int("reset_by", actorID))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func twoFactorAccount(userID int) string {
+	return fmt.Sprintf("user:%d:two-factor", userID)
+}
@@ -1,20 +1,26 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/model/domain"
 	"github.com/martishin/movie-search-service/internal/service"
 )
 
 type UserHandler struct {
 	userService *service.UserService
+	auditor     *Auditor
 }
 
-func NewUserHandler(userService *service.UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUserHandler(userService *service.UserService, auditor *Auditor) *UserHandler {
+	return &UserHandler{userService: userService, auditor: auditor}
 }
 
 func (h *UserHandler) GetUserHandler() http.HandlerFunc {
@@ -37,6 +43,44 @@ func (h *UserHandler) GetUserHandler() http.HandlerFunc {
 	}
 }
 
+// UpdateProfileHandler changes the signed-in user's names, picture and preferences
+func (h *UserHandler) UpdateProfileHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var update domain.ProfileUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		user, err := h.userService.UpdateProfile(r.Context(), userID, update)
+		switch {
+		case errors.Is(err, service.ErrInvalidName),
+			errors.Is(err, service.ErrInvalidPictureURL),
+			errors.Is(err, service.ErrInvalidPreferences):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to update profile", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not update profile", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(user)
+	}
+}
+
 func (h *UserHandler) AddLikeHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := adapter.GetUserIDFromSession(r)
@@ -51,7 +95,12 @@ func (h *UserHandler) AddLikeHandler() http.HandlerFunc {
 			return
 		}
 
-		if err := h.userService.LikeMovie(r.Context(), userID, movieID); err != nil {
+		err = h.userService.LikeMovie(r.Context(), userID, movieID)
+		if errors.Is(err, service.ErrMovieNotFound) {
+			adapter.JsonErrorResponse(w, "Movie not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
 			adapter.JsonErrorResponse(w, "Could not like movie", http.StatusInternalServerError)
 			return
 		}
@@ -82,3 +131,133 @@ func (h *UserHandler) RemoveLikeHandler() http.HandlerFunc {
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+func (h *UserHandler) RateMovieHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		movieID, err := strconv.Atoi(r.PathValue("movie_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid movie ID", http.StatusBadRequest)
+			return
+		}
+
+		var request struct {
+			Rating int `json:"rating"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		rating, err := h.userService.RateMovie(r.Context(), userID, movieID, request.Rating)
+		switch {
+		case errors.Is(err, service.ErrInvalidRating):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrMovieNotFound):
+			adapter.JsonErrorResponse(w, "Movie not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to rate movie", slog.Any("error", err), slog.Int("movie_id", movieID))
+			adapter.JsonErrorResponse(w, "Could not rate movie", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(rating)
+	}
+}
+
+func (h *UserHandler) RemoveRatingHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		userID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		movieID, err := strconv.Atoi(r.PathValue("movie_id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid movie ID", http.StatusBadRequest)
+			return
+		}
+
+		rating, err := h.userService.UnrateMovie(r.Context(), userID, movieID)
+		if errors.Is(err, service.ErrMovieNotFound) {
+			adapter.JsonErrorResponse(w, "Movie not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to remove movie rating", slog.Any("error", err), slog.Int("movie_id", movieID))
+			adapter.JsonErrorResponse(w, "Could not remove rating", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(rating)
+	}
+}
+
+func (h *UserHandler) SetUserRoleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := middleware.GetLogger(r.Context())
+
+		actorID, err := adapter.GetUserIDFromSession(r)
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		userID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			adapter.JsonErrorResponse(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		var request struct {
+			Role string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			adapter.JsonErrorResponse(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		var user *domain.User
+		err = h.auditor.Transaction(r, func(ctx context.Context) error {
+			// The user as it was, for the audit log; SetUserRole reports a missing user
+			before, _ := h.userService.GetUserByID(ctx, userID)
+
+			var err error
+			if user, err = h.userService.SetUserRole(ctx, actorID, userID, request.Role); err != nil {
+				return err
+			}
+			return h.auditor.Record(ctx, r, domain.AuditActionChangeRole, domain.AuditEntityUser, userID, before, user)
+		})
+		switch {
+		case errors.Is(err, service.ErrInvalidRole), errors.Is(err, service.ErrOwnRoleChange):
+			adapter.JsonErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, service.ErrUserNotFound):
+			adapter.JsonErrorResponse(w, "User not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Error("Failed to set user role", slog.Any("error", err), slog.Int("user_id", userID))
+			adapter.JsonErrorResponse(w, "Could not set user role", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("User role changed", slog.Int("user_id", userID), slog.String("role", user.Role), slog.Int("changed_by", actorID))
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(user)
+	}
+}
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileMailer writes every message to its own .eml file in a directory, so local mail can be opened in a mail client
+type FileMailer struct {
+	directory string
+	from      string
+
+	mu       sync.Mutex
+	sequence int
+}
+
+func NewFileMailer(directory, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{directory: directory, from: from}, nil
+}
+
+func (m *FileMailer) Send(_ context.Context, message Message) error {
+	now := time.Now()
+	data, err := format(m.from, message, now)
+	if err != nil {
+		return err
+	}
+
+	// The sequence keeps names unique and in sending order when several messages share a timestamp
+	m.mu.Lock()
+	m.sequence++
+	name := fmt.Sprintf("%s-%06d-%s.eml", now.UTC().Format("20060102T150405"), m.sequence, fileSafe(message.To))
+	m.mu.Unlock()
+
+	return os.WriteFile(filepath.Join(m.directory, name), data, 0o600)
+}
+
+func fileSafe(address string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, address)
+}
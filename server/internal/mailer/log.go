@@ -0,0 +1,24 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogMailer writes messages to the log instead of sending them. The body is logged too, so use it only locally.
+type LogMailer struct {
+	logger *slog.Logger
+}
+
+func NewLogMailer(logger *slog.Logger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+func (m *LogMailer) Send(_ context.Context, message Message) error {
+	m.logger.Info("Email not sent: log mailer",
+		slog.String("to", message.To),
+		slog.String("subject", message.Subject),
+		slog.String("body", message.Body),
+	)
+	return nil
+}
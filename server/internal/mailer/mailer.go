@@ -0,0 +1,61 @@
+// Package mailer sends the transactional emails of the account flows.
+// SMTPMailer delivers them; FileMailer and LogMailer keep them local for development and tests.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/martishin/movie-search-service/internal/model/config"
+)
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// New creates the configured mailer
+func New(mailerConfig *config.MailerConfig, logger *slog.Logger) (Mailer, error) {
+	switch mailerConfig.Backend {
+	case config.MailerBackendSMTP:
+		return NewSMTPMailer(mailerConfig), nil
+	case config.MailerBackendFile:
+		return NewFileMailer(mailerConfig.Directory, mailerConfig.From)
+	case config.MailerBackendLog:
+		return NewLogMailer(logger), nil
+	}
+	return nil, fmt.Errorf("unknown mailer backend: %q", mailerConfig.Backend)
+}
+
+// format renders the message as RFC 5322 text with CRLF line endings
+func format(from string, message Message, date time.Time) ([]byte, error) {
+	if _, err := mail.ParseAddress(message.To); err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", message.To, err)
+	}
+	if strings.ContainsAny(message.Subject, "\r\n") {
+		return nil, fmt.Errorf("subject must be a single line")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", message.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(message.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"time"
+
+	"github.com/martishin/movie-search-service/internal/model/config"
+)
+
+const (
+	smtpDialTimeout = 10 * time.Second
+	// smtpSendTimeout bounds a whole delivery when the caller sets no earlier deadline, so a stalled relay cannot
+	// hold the caller forever
+	smtpSendTimeout = 30 * time.Second
+)
+
+// SMTPMailer delivers mail through an SMTP relay, upgrading to TLS when the server offers STARTTLS
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+	sender   string
+}
+
+func NewSMTPMailer(mailerConfig *config.MailerConfig) *SMTPMailer {
+	return &SMTPMailer{
+		host:     mailerConfig.SMTPHost,
+		port:     mailerConfig.SMTPPort,
+		username: mailerConfig.SMTPUsername,
+		password: mailerConfig.SMTPPassword,
+		from:     mailerConfig.From,
+		sender:   mailerConfig.FromAddress,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	data, err := format(m.from, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpSendTimeout)
+	defer cancel()
+
+	dialer := net.Dialer{Timeout: smtpDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.host, m.port))
+	if err != nil {
+		return err
+	}
+	// The SMTP client has no context support, so the deadline bounds the whole conversation instead
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(m.sender); err != nil {
+		return err
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
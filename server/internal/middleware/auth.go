@@ -2,12 +2,32 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"regexp"
+	"strings"
 
 	"github.com/markbates/goth/gothic"
+	"github.com/martishin/movie-search-service/internal/adapter"
 	"github.com/martishin/movie-search-service/internal/model/config"
+	"github.com/martishin/movie-search-service/internal/model/domain"
 )
 
+// ErrInvalidAPIKey is returned by an APIKeyAuthenticator for keys that do not exist or were revoked
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// likePath matches the endpoints the likes scope grants
+var likePath = regexp.MustCompile(`^/api/movies/[^/]+/like/?$`)
+
+// adminPath matches the admin endpoints, which only the admin scope grants
+var adminPath = regexp.MustCompile(`^/api/admin(/|$)`)
+
+// APIKeyAuthenticator resolves personal API keys; service.APIKeyService satisfies it
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (int, []string, error)
+}
+
 func SessionAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Retrieve user ID from the session
@@ -32,6 +52,59 @@ func SessionAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// AuthMiddleware accepts a personal API key sent as "Authorization: Bearer <key>" and otherwise falls back to
+// SessionAuthMiddleware. Keys are limited to what their scopes grant; handlers read the user as they would for a session.
+func AuthMiddleware(keys APIKeyAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		sessionAuth := SessionAuthMiddleware(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				sessionAuth.ServeHTTP(w, r)
+				return
+			}
+
+			userID, scopes, err := keys.AuthenticateAPIKey(r.Context(), strings.TrimSpace(key))
+			if errors.Is(err, ErrInvalidAPIKey) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				GetLogger(r.Context()).Error("Failed to authenticate API key", slog.Any("error", err))
+				http.Error(w, "Failed to authenticate API key", http.StatusInternalServerError)
+				return
+			}
+
+			if !apiKeyAllows(scopes, r) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(adapter.WithUserID(r.Context(), userID)))
+		})
+	}
+}
+
+// apiKeyAllows reports whether any of the scopes grants the request
+func apiKeyAllows(scopes []string, r *http.Request) bool {
+	for _, scope := range scopes {
+		switch scope {
+		case domain.APIKeyScopeAdmin:
+			return true
+		case domain.APIKeyScopeRead:
+			if (r.Method == http.MethodGet || r.Method == http.MethodHead) && !adminPath.MatchString(r.URL.Path) {
+				return true
+			}
+		case domain.APIKeyScopeLikes:
+			if (r.Method == http.MethodPost || r.Method == http.MethodDelete) && likePath.MatchString(r.URL.Path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func AlloyAuthMiddleware(alloyConfig *config.ObservabilityConfig) func(http.Handler) http.Handler {
 	return func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+)
+
+type userContextKey struct{}
+
+// UserLoader loads the signed-in user; service.UserService satisfies it
+type UserLoader interface {
+	GetUserByID(ctx context.Context, id int) (*domain.User, error)
+}
+
+// RequireRole loads the session user and rejects the request unless their role grants at least the given role.
+// It must run after SessionAuthMiddleware or AuthMiddleware. The loaded user is available to handlers via GetUser.
+func RequireRole(users UserLoader, role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := GetUser(r.Context())
+			if !ok {
+				userID, err := adapter.GetUserIDFromSession(r)
+				if err != nil || userID == 0 {
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+
+				// Roles are read on every request so that a demotion takes effect immediately
+				user, err = users.GetUserByID(r.Context(), userID)
+				if err != nil {
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+			}
+
+			if !user.HasRole(role) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userContextKey{}, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetUser returns the user loaded by RequireRole
+func GetUser(ctx context.Context) (*domain.User, bool) {
+	user, ok := ctx.Value(userContextKey{}).(*domain.User)
+	return user, ok && user != nil
+}
@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+type LoginProtectionConfig struct {
+	// MaxFailuresPerAccount and MaxFailuresPerIP are the failed logins within FailureWindow that trigger a lockout
+	MaxFailuresPerAccount int
+	MaxFailuresPerIP      int
+	FailureWindow         time.Duration
+	LockoutDuration       time.Duration
+	// DelayBase is the wait before checking credentials after one recent failure; it doubles with every further
+	// failure up to DelayMax
+	DelayBase time.Duration
+	DelayMax  time.Duration
+	// TrustForwardedFor takes the client IP from X-Forwarded-For, for deployments behind a load balancer
+	TrustForwardedFor bool
+}
@@ -0,0 +1,24 @@
+package config
+
+const (
+	MailerBackendSMTP = "smtp"
+	MailerBackendFile = "file"
+	MailerBackendLog  = "log"
+)
+
+type MailerConfig struct {
+	// Backend is one of "smtp", "file" or "log"; the SMTP settings are only used with "smtp" and Directory with "file"
+	Backend string
+	// From is the From header; FromAddress is its bare address, which SMTP sends as the envelope sender
+	From         string
+	FromAddress  string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	Directory    string
+	// AppURL is the client's base URL, which the links in emails point to
+	AppURL string
+	// APIURL is the API's public base URL, which links to API endpoints in emails point to
+	APIURL string
+}
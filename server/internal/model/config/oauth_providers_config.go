@@ -0,0 +1,21 @@
+package config
+
+// OAuthProvidersConfig holds the sign-in providers offered besides Google. A provider is nil when not configured.
+type OAuthProvidersConfig struct {
+	GitHub *OAuthProviderConfig
+	OIDC   *OIDCProviderConfig
+}
+
+type OAuthProviderConfig struct {
+	ClientID     string
+	ClientSecret string
+	CallbackURL  string
+}
+
+// OIDCProviderConfig configures a generic OpenID Connect provider, discovered from its issuer's well-known document
+type OIDCProviderConfig struct {
+	OAuthProviderConfig
+	// Name is the provider's name in URLs, such as "okta" in /auth/start?provider=okta-oidc
+	Name         string
+	DiscoveryURL string
+}
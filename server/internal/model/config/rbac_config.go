@@ -0,0 +1,7 @@
+package config
+
+type RBACConfig struct {
+	// BootstrapAdminEmail is promoted to admin once its email is verified, while no admin exists yet; empty disables
+	// the bootstrap
+	BootstrapAdminEmail string
+}
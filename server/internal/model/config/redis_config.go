@@ -1,7 +1,15 @@
 package config
 
+const (
+	CacheBackendRedis  = "redis"
+	CacheBackendMemory = "memory"
+	CacheBackendNone   = "none"
+)
+
 type RedisConfig struct {
-	Host string
-	Port string
-	DB   int
+	// CacheBackend is one of "redis", "memory" or "none"; Host, Port and DB are only used with "redis"
+	CacheBackend string
+	Host         string
+	Port         string
+	DB           int
 }
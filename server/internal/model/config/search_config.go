@@ -0,0 +1,5 @@
+package config
+
+type SearchConfig struct {
+	SimilarityThreshold float64
+}
@@ -0,0 +1,8 @@
+package config
+
+type TwoFactorConfig struct {
+	// Issuer names the service in authenticator apps
+	Issuer string
+	// EncryptionKey is the AES-256 key that seals TOTP secrets at rest
+	EncryptionKey []byte
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+// API key scopes. A key can hold several; each grants requests of its kind, subject to the user's role.
+const (
+	// APIKeyScopeRead allows reading: GET and HEAD requests outside the admin API
+	APIKeyScopeRead = "read"
+	// APIKeyScopeLikes allows liking and unliking movies
+	APIKeyScopeLikes = "likes"
+	// APIKeyScopeAdmin allows every request the user could make while signed in
+	APIKeyScopeAdmin = "admin"
+)
+
+// APIKeyScopes lists the known scopes
+var APIKeyScopes = []string{APIKeyScopeRead, APIKeyScopeLikes, APIKeyScopeAdmin}
+
+// APIKey is a personal API key as listed to its owner. The key itself is shown only once, when it is created.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatedAPIKey is a newly created key together with its secret
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Audited actions. Creates, updates and deletes cover the catalog; the others name the admin operation on its entity,
+// except for lockouts, which the login guard imposes on a user without an actor.
+const (
+	AuditActionCreate         = "create"
+	AuditActionUpdate         = "update"
+	AuditActionDelete         = "delete"
+	AuditActionModerate       = "moderate"
+	AuditActionChangeRole     = "change_role"
+	AuditActionResetTwoFactor = "reset_two_factor"
+	AuditActionLockout        = "lockout"
+)
+
+// Kinds of entities admin writes change
+const (
+	AuditEntityMovie  = "movie"
+	AuditEntityCredit = "credit"
+	AuditEntityPerson = "person"
+	AuditEntityReview = "review"
+	AuditEntityUser   = "user"
+)
+
+// AuditEvent records one admin write. Before and After hold only the fields the write changed: a create has no
+// Before and a delete has no After.
+type AuditEvent struct {
+	ID         int             `json:"id"`
+	ActorID    int             `json:"actor_id"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int             `json:"entity_id"`
+	RequestID  string          `json:"request_id"`
+	IP         string          `json:"ip"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditEventList struct {
+	Events     []*AuditEvent `json:"events"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// AuditFilter narrows the audit log. Zero values mean "no constraint"; Until is exclusive.
+type AuditFilter struct {
+	ActorID    int
+	Action     string
+	EntityType string
+	EntityID   int
+	Since      time.Time
+	Until      time.Time
+}
+
+// AuditPageRequest selects one page of the audit log, newest first. BeforeID is 0 for the first page.
+type AuditPageRequest struct {
+	Limit    int
+	BeforeID int
+}
+
+// EncodeAuditCursor returns the opaque next_cursor for a page ending at event id (empty when there is no next page)
+func EncodeAuditCursor(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func DecodeAuditCursor(cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(string(decoded))
+	if err != nil || id < 1 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
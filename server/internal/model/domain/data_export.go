@@ -0,0 +1,46 @@
+package domain
+
+import "time"
+
+// Data export states. An export waits as pending until the background worker has assembled its archive.
+const (
+	DataExportStatusPending = "pending"
+	DataExportStatusReady   = "ready"
+	DataExportStatusFailed  = "failed"
+)
+
+// DataExport is a user's request for an archive of their data. Its download link is mailed once the archive is ready.
+type DataExport struct {
+	ID          int        `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// ExportedLike is a liked movie as written to a data export
+type ExportedLike struct {
+	MovieID int       `json:"movie_id"`
+	Title   string    `json:"title"`
+	LikedAt time.Time `json:"liked_at"`
+}
+
+// ExportedRating is a movie rating as written to a data export
+type ExportedRating struct {
+	MovieID   int       `json:"movie_id"`
+	Title     string    `json:"title"`
+	Rating    int       `json:"rating"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExportedReview is a review as written to a data export, whatever its moderation status
+type ExportedReview struct {
+	ID        int       `json:"id"`
+	MovieID   int       `json:"movie_id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// OAuthProfile is what an identity provider reports about the account signing in
+type OAuthProfile struct {
+	Provider string
+	// Subject is the provider's stable ID for the account
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+	PictureURL    string
+}
+
+// Identity is a provider account linked to a user
+type Identity struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
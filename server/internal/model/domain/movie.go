@@ -12,5 +12,7 @@ type Movie struct {
 	Image       string    `json:"image"`
 	Video       string    `json:"video"`
 	Genres      []*Genre  `json:"genres,omitempty"`
+	Credits     []*Credit `json:"credits,omitempty"`
 	UserRating  float64   `json:"user_rating"`
+	RatingCount int       `json:"rating_count"`
 }
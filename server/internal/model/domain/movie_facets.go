@@ -0,0 +1,17 @@
+package domain
+
+type GenreFacet struct {
+	ID    int    `json:"id"`
+	Genre string `json:"genre"`
+	Count int    `json:"count"`
+}
+
+type MPAARatingFacet struct {
+	Rating string `json:"rating"`
+	Count  int    `json:"count"`
+}
+
+type MovieFacets struct {
+	Genres      []*GenreFacet      `json:"genres"`
+	MPAARatings []*MPAARatingFacet `json:"mpaa_ratings"`
+}
@@ -0,0 +1,23 @@
+package domain
+
+const (
+	GenreMatchAny = "any"
+	GenreMatchAll = "all"
+)
+
+// MovieFilter narrows a movie listing. Zero values mean "no constraint".
+type MovieFilter struct {
+	GenreIDs      []int
+	GenreMatch    string
+	YearFrom      int
+	YearTo        int
+	RuntimeMin    int
+	RuntimeMax    int
+	MPAARatings   []string
+	MinUserRating float64
+}
+
+func (f MovieFilter) IsEmpty() bool {
+	return len(f.GenreIDs) == 0 && f.YearFrom == 0 && f.YearTo == 0 && f.RuntimeMin == 0 &&
+		f.RuntimeMax == 0 && len(f.MPAARatings) == 0 && f.MinUserRating == 0
+}
@@ -0,0 +1,7 @@
+package domain
+
+type MovieList[T any] struct {
+	Movies     []T          `json:"movies"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Facets     *MovieFacets `json:"facets,omitempty"`
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+const (
+	SortByTitle       = "title"
+	SortByReleaseDate = "release_date"
+	SortByUserRating  = "user_rating"
+	SortByRuntime     = "runtime"
+	SortByCreatedAt   = "created_at"
+
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type MovieSort struct {
+	Field     string
+	Direction string
+}
+
+// MoviePageRequest selects one page of a listing. After is nil for the first page.
+type MoviePageRequest struct {
+	Sort  MovieSort
+	Limit int
+	After *MovieCursor
+}
+
+// MovieCursor points at the last movie of a page: its sort value and ID break ties deterministically
+type MovieCursor struct {
+	Field     string `json:"f"`
+	Direction string `json:"d"`
+	Value     string `json:"v"`
+	ID        int    `json:"id"`
+}
+
+// Encode returns the opaque string handed to clients as next_cursor
+func (c *MovieCursor) Encode() string {
+	if c == nil {
+		return ""
+	}
+	encoded, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func DecodeMovieCursor(cursor string) (*MovieCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c MovieCursor
+	if err := json.Unmarshal(decoded, &c); err != nil || c.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,9 @@
+package domain
+
+// MovieRating is a movie's aggregate rating together with the caller's own rating (0 when unrated)
+type MovieRating struct {
+	MovieID     int     `json:"movie_id"`
+	UserRating  float64 `json:"user_rating"`
+	RatingCount int     `json:"rating_count"`
+	MyRating    int     `json:"my_rating"`
+}
@@ -0,0 +1,8 @@
+package domain
+
+type MovieSuggestion struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	ReleaseYear int    `json:"release_year"`
+	Image       string `json:"image"`
+}
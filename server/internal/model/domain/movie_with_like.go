@@ -12,6 +12,9 @@ type MovieWithLike struct {
 	Image       string    `json:"image"`
 	Video       string    `json:"video"`
 	Genres      []*Genre  `json:"genres,omitempty"`
+	Credits     []*Credit `json:"credits,omitempty"`
 	UserRating  float64   `json:"user_rating"`
+	RatingCount int       `json:"rating_count"`
 	IsLiked     bool      `json:"is_liked"`
+	MyRating    int       `json:"my_rating,omitempty"`
 }
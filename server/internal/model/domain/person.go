@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+// Credit roles a person can hold on a movie
+const (
+	CreditRoleActor    = "actor"
+	CreditRoleDirector = "director"
+	CreditRoleWriter   = "writer"
+	CreditRoleComposer = "composer"
+)
+
+type Person struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Biography   string          `json:"biography"`
+	BirthDate   *time.Time      `json:"birth_date,omitempty"`
+	Image       string          `json:"image"`
+	Filmography []*PersonCredit `json:"filmography,omitempty"`
+}
+
+// Credit is a person's role on a movie, as listed on the movie
+type Credit struct {
+	ID            int    `json:"id"`
+	PersonID      int    `json:"person_id"`
+	Name          string `json:"name"`
+	Image         string `json:"image"`
+	Role          string `json:"role"`
+	CharacterName string `json:"character_name,omitempty"`
+	BillingOrder  int    `json:"billing_order,omitempty"`
+}
+
+// PersonCredit is a movie a person worked on, as listed in their filmography
+type PersonCredit struct {
+	ID            int       `json:"id"`
+	MovieID       int       `json:"movie_id"`
+	Title         string    `json:"title"`
+	ReleaseDate   time.Time `json:"release_date"`
+	Image         string    `json:"image"`
+	Role          string    `json:"role"`
+	CharacterName string    `json:"character_name,omitempty"`
+	BillingOrder  int       `json:"billing_order,omitempty"`
+}
+
+// IsValidCreditRole reports whether role is one of the known credit roles
+func IsValidCreditRole(role string) bool {
+	switch role {
+	case CreditRoleActor, CreditRoleDirector, CreditRoleWriter, CreditRoleComposer:
+		return true
+	default:
+		return false
+	}
+}
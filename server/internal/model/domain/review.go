@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// Review moderation states. New and edited reviews wait in the pending queue until an admin decides.
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+)
+
+type Review struct {
+	ID           int       `json:"id"`
+	MovieID      int       `json:"movie_id"`
+	UserID       int       `json:"user_id"`
+	AuthorName   string    `json:"author_name,omitempty"`
+	Body         string    `json:"body"`
+	Status       string    `json:"status"`
+	HelpfulCount int       `json:"helpful_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type ReviewList struct {
+	Reviews    []*Review `json:"reviews"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// ReviewPageRequest selects one page of reviews. AfterID is 0 for the first page.
+type ReviewPageRequest struct {
+	Limit   int
+	AfterID int
+}
+
+// EncodeReviewCursor returns the opaque next_cursor for a page ending at review id (empty when there is no next page)
+func EncodeReviewCursor(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func DecodeReviewCursor(cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(string(decoded))
+	if err != nil || id < 1 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
@@ -0,0 +1,12 @@
+package domain
+
+// TwoFactorEnrollment is what the user adds to their authenticator app. The secret is shown for manual entry.
+type TwoFactorEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodes are shown once; each stands in for a TOTP code a single time
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -1,9 +1,55 @@
 package domain
 
+import "encoding/json"
+
+// User roles, from least to most privileged
+const (
+	RoleUser   = "user"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Purposes of the single-use tokens mailed to users
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+var roleRanks = map[string]int{
+	RoleUser:   1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
 type User struct {
-	ID         int    `json:"id"`
-	FirstName  string `json:"firstName"`
-	LastName   string `json:"lastName"`
-	Email      string `json:"email"`
-	PictureURL string `json:"pictureUrl"`
+	ID               int             `json:"id"`
+	FirstName        string          `json:"firstName"`
+	LastName         string          `json:"lastName"`
+	Email            string          `json:"email"`
+	PictureURL       string          `json:"pictureUrl"`
+	Role             string          `json:"role"`
+	EmailVerified    bool            `json:"emailVerified"`
+	TwoFactorEnabled bool            `json:"twoFactorEnabled"`
+	HasPassword      bool            `json:"hasPassword"`
+	Preferences      json.RawMessage `json:"preferences"`
+}
+
+// ProfileUpdate changes the fields that are set and keeps the others. Preferences replace the stored ones whole.
+type ProfileUpdate struct {
+	FirstName   *string         `json:"first_name"`
+	LastName    *string         `json:"last_name"`
+	PictureURL  *string         `json:"picture_url"`
+	Preferences json.RawMessage `json:"preferences"`
+}
+
+// IsValidRole reports whether role is one of the known roles
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// HasRole reports whether the user's role grants at least the privileges of role
+func (u *User) HasRole(role string) bool {
+	required, ok := roleRanks[role]
+	return ok && roleRanks[u.Role] >= required
 }
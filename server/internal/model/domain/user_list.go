@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// List visibility: unlisted lists are reachable only through their share URL, public lists are also listed on their
+// owner's public profile
+const (
+	ListVisibilityPrivate  = "private"
+	ListVisibilityUnlisted = "unlisted"
+	ListVisibilityPublic   = "public"
+)
+
+type UserList struct {
+	ID          int              `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Visibility  string           `json:"visibility"`
+	ShareURL    string           `json:"share_url,omitempty"`
+	EntryCount  int              `json:"entry_count"`
+	Entries     []*UserListEntry `json:"entries,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+type UserListEntry struct {
+	MovieID     int       `json:"movie_id"`
+	Title       string    `json:"title"`
+	ReleaseDate time.Time `json:"release_date"`
+	Image       string    `json:"image"`
+	UserRating  float64   `json:"user_rating"`
+	Position    int       `json:"position"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+// IsValidListVisibility reports whether visibility is one of the known list visibilities
+func IsValidListVisibility(visibility string) bool {
+	switch visibility {
+	case ListVisibilityPrivate, ListVisibilityUnlisted, ListVisibilityPublic:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+)
+
+// APIKeyStore holds the users' personal API keys, keyed by their hash.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, userID int, name, prefix, keyHash string, scopes []string) (db.UserApiKey, error)
+	ListAPIKeys(ctx context.Context, userID int) ([]db.UserApiKey, error)
+	UseAPIKey(ctx context.Context, keyHash string) (db.UserApiKey, error)
+	DeleteAPIKey(ctx context.Context, userID, id int) (bool, error)
+}
+
+var _ APIKeyStore = (*APIKeyRepository)(nil)
+
+type APIKeyRepository struct {
+	queries *db.Queries
+}
+
+func NewAPIKeyRepository(postgresPool *pgxpool.Pool) *APIKeyRepository {
+	return &APIKeyRepository{
+		queries: db.New(newConn(postgresPool)),
+	}
+}
+
+func (r *APIKeyRepository) CreateAPIKey(
+	ctx context.Context,
+	userID int,
+	name, prefix, keyHash string,
+	scopes []string,
+) (db.UserApiKey, error) {
+	return r.queries.CreateUserAPIKey(ctx, db.CreateUserAPIKeyParams{
+		UserID:  int32(userID),
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: keyHash,
+		Scopes:  scopes,
+	})
+}
+
+// ListAPIKeys returns the user's keys, newest first
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context, userID int) ([]db.UserApiKey, error) {
+	return r.queries.ListUserAPIKeys(ctx, int32(userID))
+}
+
+// UseAPIKey records that the key was used and returns it. It returns pgx.ErrNoRows when there is no such key
+// or its account awaits deletion.
+func (r *APIKeyRepository) UseAPIKey(ctx context.Context, keyHash string) (db.UserApiKey, error) {
+	return r.queries.UseUserAPIKey(ctx, keyHash)
+}
+
+// DeleteAPIKey removes one of the user's keys. It reports false when the user has no such key.
+func (r *APIKeyRepository) DeleteAPIKey(ctx context.Context, userID, id int) (bool, error) {
+	deleted, err := r.queries.DeleteUserAPIKey(ctx, db.DeleteUserAPIKeyParams{
+		ID:     int32(id),
+		UserID: int32(userID),
+	})
+	return deleted > 0, err
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+)
+
+// AuditStore holds the append-only log of admin writes and login lockouts; it offers no way to change or remove
+// an event.
+type AuditStore interface {
+	CreateAuditEvent(ctx context.Context, event db.CreateAuditEventParams) (db.AuditEvent, error)
+	ListAuditEvents(ctx context.Context, filter domain.AuditFilter, beforeID, limit int) ([]db.AuditEvent, error)
+}
+
+var _ AuditStore = (*AuditRepository)(nil)
+
+type AuditRepository struct {
+	queries *db.Queries
+}
+
+func NewAuditRepository(postgresPool *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{
+		queries: db.New(newConn(postgresPool)),
+	}
+}
+
+func (r *AuditRepository) CreateAuditEvent(ctx context.Context, event db.CreateAuditEventParams) (db.AuditEvent, error) {
+	return r.queries.CreateAuditEvent(ctx, event)
+}
+
+// ListAuditEvents returns the events matching filter newest first, starting below beforeID when it is non-zero
+func (r *AuditRepository) ListAuditEvents(
+	ctx context.Context,
+	filter domain.AuditFilter,
+	beforeID, limit int,
+) ([]db.AuditEvent, error) {
+	return r.queries.ListAuditEvents(ctx, db.ListAuditEventsParams{
+		ActorID:    pgtype.Int4{Int32: int32(filter.ActorID), Valid: filter.ActorID != 0},
+		Action:     pgtype.Text{String: filter.Action, Valid: filter.Action != ""},
+		EntityType: pgtype.Text{String: filter.EntityType, Valid: filter.EntityType != ""},
+		EntityID:   pgtype.Int4{Int32: int32(filter.EntityID), Valid: filter.EntityID != 0},
+		Since:      pgtype.Timestamp{Time: filter.Since.UTC(), Valid: !filter.Since.IsZero()},
+		Until:      pgtype.Timestamp{Time: filter.Until.UTC(), Valid: !filter.Until.IsZero()},
+		BeforeID:   pgtype.Int4{Int32: int32(beforeID), Valid: beforeID != 0},
+		MaxResults: int32(limit),
+	})
+}
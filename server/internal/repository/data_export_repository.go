@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+)
+
+// DataExportStore holds the users' data export jobs and reads the data that goes into their archives.
+type DataExportStore interface {
+	CreateExport(ctx context.Context, userID int) (db.UserDataExport, error)
+	GetExport(ctx context.Context, userID, id int) (db.UserDataExport, error)
+	GetExportByTokenHash(ctx context.Context, tokenHash string) (db.UserDataExport, error)
+	ClaimExport(ctx context.Context, claimTimeout time.Duration) (db.UserDataExport, error)
+	CompleteExport(ctx context.Context, id int, tokenHash string, archive []byte, ttl time.Duration) (bool, error)
+	FailExport(ctx context.Context, id int, ttl time.Duration) (bool, error)
+	DeleteExpiredExports(ctx context.Context) (int, error)
+
+	ListUserLikes(ctx context.Context, userID int) ([]db.ListUserLikesRow, error)
+	ListUserRatings(ctx context.Context, userID int) ([]db.ListUserRatingsRow, error)
+	ListUserReviews(ctx context.Context, userID int) ([]db.ListUserReviewsRow, error)
+}
+
+var _ DataExportStore = (*DataExportRepository)(nil)
+
+type DataExportRepository struct {
+	queries *db.Queries
+}
+
+func NewDataExportRepository(postgresPool *pgxpool.Pool) *DataExportRepository {
+	return &DataExportRepository{
+		queries: db.New(newConn(postgresPool)),
+	}
+}
+
+// CreateExport queues an export for the user. It fails with a unique violation of unique_user_data_export_pending
+// while the user has another export pending.
+func (r *DataExportRepository) CreateExport(ctx context.Context, userID int) (db.UserDataExport, error) {
+	return r.queries.CreateUserDataExport(ctx, int32(userID))
+}
+
+// GetExport returns one of the user's exports. It returns pgx.ErrNoRows when the user has no such export.
+func (r *DataExportRepository) GetExport(ctx context.Context, userID, id int) (db.UserDataExport, error) {
+	return r.queries.GetUserDataExport(ctx, db.GetUserDataExportParams{
+		ID:     int32(id),
+		UserID: int32(userID),
+	})
+}
+
+// GetExportByTokenHash returns the ready, unexpired export with the download token.
+// It returns pgx.ErrNoRows when there is no such export.
+func (r *DataExportRepository) GetExportByTokenHash(ctx context.Context, tokenHash string) (db.UserDataExport, error) {
+	return r.queries.GetUserDataExportByTokenHash(ctx, pgtype.Text{String: tokenHash, Valid: true})
+}
+
+// ClaimExport takes the oldest pending export that no worker has claimed within claimTimeout.
+// It returns pgx.ErrNoRows when there is none.
+func (r *DataExportRepository) ClaimExport(ctx context.Context, claimTimeout time.Duration) (db.UserDataExport, error) {
+	return r.queries.ClaimUserDataExport(ctx, pgtype.Interval{Microseconds: claimTimeout.Microseconds(), Valid: true})
+}
+
+// CompleteExport stores the archive of a pending export, which can then be downloaded with the token until ttl
+// from now, by the database clock. It reports false when the export is not pending.
+func (r *DataExportRepository) CompleteExport(
+	ctx context.Context,
+	id int,
+	tokenHash string,
+	archive []byte,
+	ttl time.Duration,
+) (bool, error) {
+	completed, err := r.queries.CompleteUserDataExport(ctx, db.CompleteUserDataExportParams{
+		ID:        int32(id),
+		TokenHash: pgtype.Text{String: tokenHash, Valid: true},
+		Archive:   archive,
+		Ttl:       pgtype.Interval{Microseconds: ttl.Microseconds(), Valid: true},
+	})
+	return completed > 0, err
+}
+
+// FailExport marks a pending export as failed, keeping it until ttl from now. It reports false when the export
+// is not pending.
+func (r *DataExportRepository) FailExport(ctx context.Context, id int, ttl time.Duration) (bool, error) {
+	failed, err := r.queries.FailUserDataExport(ctx, db.FailUserDataExportParams{
+		ID:  int32(id),
+		Ttl: pgtype.Interval{Microseconds: ttl.Microseconds(), Valid: true},
+	})
+	return failed > 0, err
+}
+
+// DeleteExpiredExports deletes the exports past their expiry and returns how many it deleted
+func (r *DataExportRepository) DeleteExpiredExports(ctx context.Context) (int, error) {
+	deleted, err := r.queries.DeleteExpiredUserDataExports(ctx)
+	return int(deleted), err
+}
+
+// ListUserLikes returns the movies the user likes, in the order they were liked
+func (r *DataExportRepository) ListUserLikes(ctx context.Context, userID int) ([]db.ListUserLikesRow, error) {
+	return r.queries.ListUserLikes(ctx, int32(userID))
+}
+
+// ListUserRatings returns the user's ratings, in the order they were first given
+func (r *DataExportRepository) ListUserRatings(ctx context.Context, userID int) ([]db.ListUserRatingsRow, error) {
+	return r.queries.ListUserRatings(ctx, int32(userID))
+}
+
+// ListUserReviews returns the user's reviews in every moderation state, oldest first
+func (r *DataExportRepository) ListUserReviews(ctx context.Context, userID int) ([]db.ListUserReviewsRow, error) {
+	return r.queries.ListUserReviews(ctx, int32(userID))
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+)
+
+// IdentityStore holds the provider accounts linked to users.
+type IdentityStore interface {
+	CreateUserWithIdentity(
+		ctx context.Context,
+		firstName, lastName, email, pictureURL string,
+		emailVerified bool,
+		provider, subject string,
+	) (db.User, error)
+	CreateUserIdentity(ctx context.Context, userID int, provider, subject, email string) (db.UserIdentity, error)
+	GetUserIdentity(ctx context.Context, provider, subject string) (db.UserIdentity, error)
+	ListUserIdentities(ctx context.Context, userID int) ([]db.UserIdentity, error)
+	DeleteUserIdentity(ctx context.Context, userID int, provider string) (bool, error)
+}
+
+var _ IdentityStore = (*IdentityRepository)(nil)
+
+type IdentityRepository struct {
+	pool    conn
+	queries *db.Queries
+}
+
+func NewIdentityRepository(postgresPool *pgxpool.Pool) *IdentityRepository {
+	return &IdentityRepository{
+		pool:    newConn(postgresPool),
+		queries: db.New(newConn(postgresPool)),
+	}
+}
+
+// CreateUserWithIdentity creates a user without a password who signs in with the provider account, in one transaction
+func (r *IdentityRepository) CreateUserWithIdentity(
+	ctx context.Context,
+	firstName, lastName, email, pictureURL string,
+	emailVerified bool,
+	provider, subject string,
+) (db.User, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return db.User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := r.queries.WithTx(tx)
+
+	user, err := qtx.CreateUser(ctx, db.CreateUserParams{
+		FirstName:  firstName,
+		LastName:   lastName,
+		Email:      email,
+		PictureUrl: pgtype.Text{String: pictureURL, Valid: true},
+	})
+	if err != nil {
+		return db.User{}, err
+	}
+	if emailVerified {
+		if err := qtx.MarkUserEmailVerified(ctx, user.ID); err != nil {
+			return db.User{}, err
+		}
+		if user, err = qtx.GetUserByID(ctx, user.ID); err != nil {
+			return db.User{}, err
+		}
+	}
+	if _, err := qtx.CreateUserIdentity(ctx, db.CreateUserIdentityParams{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	}); err != nil {
+		return db.User{}, err
+	}
+
+	return user, tx.Commit(ctx)
+}
+
+func (r *IdentityRepository) CreateUserIdentity(
+	ctx context.Context,
+	userID int,
+	provider, subject, email string,
+) (db.UserIdentity, error) {
+	return r.queries.CreateUserIdentity(ctx, db.CreateUserIdentityParams{
+		UserID:   int32(userID),
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	})
+}
+
+// GetUserIdentity returns the identity of the provider account. It returns pgx.ErrNoRows when it is not linked.
+func (r *IdentityRepository) GetUserIdentity(ctx context.Context, provider, subject string) (db.UserIdentity, error) {
+	return r.queries.GetUserIdentity(ctx, db.GetUserIdentityParams{
+		Provider: provider,
+		Subject:  subject,
+	})
+}
+
+// ListUserIdentities returns the user's identities in the order they were linked
+func (r *IdentityRepository) ListUserIdentities(ctx context.Context, userID int) ([]db.UserIdentity, error) {
+	return r.queries.ListUserIdentities(ctx, int32(userID))
+}
+
+// DeleteUserIdentity unlinks the user's identity at the provider. It reports false when there is none.
+func (r *IdentityRepository) DeleteUserIdentity(ctx context.Context, userID int, provider string) (bool, error) {
+	deleted, err := r.queries.DeleteUserIdentity(ctx, db.DeleteUserIdentityParams{
+		UserID:   int32(userID),
+		Provider: provider,
+	})
+	return deleted > 0, err
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+)
+
+// ListStore holds personal movie lists and their entries.
+type ListStore interface {
+	CreateUserList(ctx context.Context, userID int, name, description, visibility, shareToken string) (db.UserList, error)
+	GetUserListByID(ctx context.Context, userID, listID int) (db.UserList, error)
+	GetUserListByShareToken(ctx context.Context, shareToken string) (db.UserList, error)
+	ListUserListsByUser(ctx context.Context, userID int) ([]db.ListUserListsByUserRow, error)
+	ListPublicUserListsByUser(ctx context.Context, userID int) ([]db.ListPublicUserListsByUserRow, error)
+	UpdateUserList(ctx context.Context, userID, listID int, name, description, visibility string) (db.UserList, error)
+	DeleteUserList(ctx context.Context, userID, listID int) (bool, error)
+
+	ListUserListEntries(ctx context.Context, listID int) ([]db.ListUserListEntriesRow, error)
+	AddUserListEntry(ctx context.Context, userID, listID, movieID int) error
+	RemoveUserListEntry(ctx context.Context, userID, listID, movieID int) (bool, error)
+	ReorderUserListEntries(ctx context.Context, userID, listID int, reorder func(current []int32) ([]int32, error)) error
+}
+
+var _ ListStore = (*ListRepository)(nil)
+
+type ListRepository struct {
+	pool    conn
+	queries *db.Queries
+}
+
+func NewListRepository(postgresPool *pgxpool.Pool) *ListRepository {
+	return &ListRepository{
+		pool:    newConn(postgresPool),
+		queries: db.New(newConn(postgresPool)),
+	}
+}
+
+func (r *ListRepository) CreateUserList(
+	ctx context.Context,
+	userID int,
+	name, description, visibility, shareToken string,
+) (db.UserList, error) {
+	return r.queries.CreateUserList(ctx, db.CreateUserListParams{
+		UserID:      int32(userID),
+		Name:        name,
+		Description: description,
+		Visibility:  visibility,
+		ShareToken:  shareToken,
+	})
+}
+
+// GetUserListByID returns the list only when it belongs to the user
+func (r *ListRepository) GetUserListByID(ctx context.Context, userID, listID int) (db.UserList, error) {
+	return r.queries.GetUserListByID(ctx, db.GetUserListByIDParams{
+		ID:     int32(listID),
+		UserID: int32(userID),
+	})
+}
+
+func (r *ListRepository) GetUserListByShareToken(ctx context.Context, shareToken string) (db.UserList, error) {
+	return r.queries.GetUserListByShareToken(ctx, shareToken)
+}
+
+func (r *ListRepository) ListUserListsByUser(ctx context.Context, userID int) ([]db.ListUserListsByUserRow, error) {
+	return r.queries.ListUserListsByUser(ctx, int32(userID))
+}
+
+func (r *ListRepository) ListPublicUserListsByUser(ctx context.Context, userID int) ([]db.ListPublicUserListsByUserRow, error) {
+	return r.queries.ListPublicUserListsByUser(ctx, int32(userID))
+}
+
+func (r *ListRepository) UpdateUserList(
+	ctx context.Context,
+	userID, listID int,
+	name, description, visibility string,
+) (db.UserList, error) {
+	return r.queries.UpdateUserList(ctx, db.UpdateUserListParams{
+		ID:          int32(listID),
+		UserID:      int32(userID),
+		Name:        name,
+		Description: description,
+		Visibility:  visibility,
+	})
+}
+
+// DeleteUserList reports whether the user had the list to delete
+func (r *ListRepository) DeleteUserList(ctx context.Context, userID, listID int) (bool, error) {
+	deleted, err := r.queries.DeleteUserList(ctx, db.DeleteUserListParams{
+		ID:     int32(listID),
+		UserID: int32(userID),
+	})
+	return deleted > 0, err
+}
+
+func (r *ListRepository) ListUserListEntries(ctx context.Context, listID int) ([]db.ListUserListEntriesRow, error) {
+	return r.queries.ListUserListEntries(ctx, int32(listID))
+}
+
+// AddUserListEntry appends the movie to the end of the user's list; adding a movie twice is a no-op
+func (r *ListRepository) AddUserListEntry(ctx context.Context, userID, listID, movieID int) error {
+	return r.changeUserListEntries(ctx, userID, listID, func(qtx *db.Queries) error {
+		return qtx.AddUserListEntry(ctx, db.AddUserListEntryParams{
+			ListID:  int32(listID),
+			MovieID: int32(movieID),
+		})
+	})
+}
+
+// RemoveUserListEntry reports whether the movie was in the user's list
+func (r *ListRepository) RemoveUserListEntry(ctx context.Context, userID, listID, movieID int) (bool, error) {
+	var removed int64
+	err := r.changeUserListEntries(ctx, userID, listID, func(qtx *db.Queries) error {
+		var err error
+		removed, err = qtx.RemoveUserListEntry(ctx, db.RemoveUserListEntryParams{
+			ListID:  int32(listID),
+			MovieID: int32(movieID),
+		})
+		return err
+	})
+	return removed > 0, err
+}
+
+// ReorderUserListEntries passes the current movie order to reorder and stores the order it returns
+func (r *ListRepository) ReorderUserListEntries(
+	ctx context.Context,
+	userID, listID int,
+	reorder func(current []int32) ([]int32, error),
+) error {
+	return r.changeUserListEntries(ctx, userID, listID, func(qtx *db.Queries) error {
+		current, err := qtx.ListUserListEntryMovieIDs(ctx, int32(listID))
+		if err != nil {
+			return err
+		}
+
+		ordered, err := reorder(current)
+		if err != nil {
+			return err
+		}
+
+		for i, movieID := range ordered {
+			if err := qtx.SetUserListEntryPosition(ctx, db.SetUserListEntryPositionParams{
+				ListID:   int32(listID),
+				MovieID:  movieID,
+				Position: int32(i + 1),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *ListRepository) changeUserListEntries(
+	ctx context.Context,
+	userID, listID int,
+	change func(qtx *db.Queries) error,
+) error {
+	// Start transaction
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := r.queries.WithTx(tx)
+
+	// Lock the list so that concurrent changes see consistent positions; this also checks ownership
+	if _, err := qtx.LockUserList(ctx, db.LockUserListParams{
+		ID:     int32(listID),
+		UserID: int32(userID),
+	}); err != nil {
+		return err
+	}
+
+	if err := change(qtx); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.APIKeyStore = (*APIKeyRepository)(nil)
+
+type APIKeyRepository struct {
+	store *Store
+}
+
+func NewAPIKeyRepository(store *Store) *APIKeyRepository {
+	return &APIKeyRepository{store: store}
+}
+
+func (r *APIKeyRepository) CreateAPIKey(
+	_ context.Context,
+	userID int,
+	name, prefix, keyHash string,
+	scopes []string,
+) (db.UserApiKey, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(scopes) == 0 {
+		return db.UserApiKey{}, checkViolation("user_api_keys", "check_user_api_key_scopes")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(domain.APIKeyScopes, scope) {
+			return db.UserApiKey{}, checkViolation("user_api_keys", "check_user_api_key_scopes")
+		}
+	}
+	if _, ok := s.users[int32(userID)]; !ok {
+		return db.UserApiKey{}, foreignKeyViolation("user_api_keys", "fk_users")
+	}
+	for _, key := range s.apiKeys {
+		if key.KeyHash == keyHash {
+			return db.UserApiKey{}, uniqueViolation("unique_user_api_key_hash")
+		}
+	}
+
+	key := &db.UserApiKey{
+		ID:        s.nextID("user_api_keys"),
+		UserID:    int32(userID),
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   keyHash,
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: timestamp(),
+	}
+	s.apiKeys[key.ID] = key
+	return *key, nil
+}
+
+// ListAPIKeys returns the user's keys, newest first
+func (r *APIKeyRepository) ListAPIKeys(_ context.Context, userID int) ([]db.UserApiKey, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []db.UserApiKey
+	for _, key := range s.apiKeys {
+		if key.UserID == int32(userID) {
+			keys = append(keys, *key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Time.Equal(keys[j].CreatedAt.Time) {
+			return keys[i].CreatedAt.Time.After(keys[j].CreatedAt.Time)
+		}
+		return keys[i].ID > keys[j].ID
+	})
+	return keys, nil
+}
+
+// UseAPIKey records that the key was used and returns it. It returns pgx.ErrNoRows when there is no such key
+// or its account awaits deletion.
+func (r *APIKeyRepository) UseAPIKey(_ context.Context, keyHash string) (db.UserApiKey, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.apiKeys {
+		if key.KeyHash == keyHash && !s.users[key.UserID].DeletedAt.Valid {
+			key.LastUsedAt = timestamp()
+			return *key, nil
+		}
+	}
+	return db.UserApiKey{}, pgx.ErrNoRows
+}
+
+// DeleteAPIKey removes one of the user's keys. It reports false when the user has no such key.
+func (r *APIKeyRepository) DeleteAPIKey(_ context.Context, userID, id int) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[int32(id)]
+	if !ok || key.UserID != int32(userID) {
+		return false, nil
+	}
+	delete(s.apiKeys, key.ID)
+	return true, nil
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.AuditStore = (*AuditRepository)(nil)
+
+type AuditRepository struct {
+	store *Store
+}
+
+func NewAuditRepository(store *Store) *AuditRepository {
+	return &AuditRepository{store: store}
+}
+
+func (r *AuditRepository) CreateAuditEvent(_ context.Context, event db.CreateAuditEventParams) (db.AuditEvent, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	auditEvent := &db.AuditEvent{
+		ID:         s.nextID("audit_events"),
+		ActorID:    event.ActorID,
+		Action:     event.Action,
+		EntityType: event.EntityType,
+		EntityID:   event.EntityID,
+		RequestID:  event.RequestID,
+		Ip:         event.Ip,
+		Before:     event.Before,
+		After:      event.After,
+		CreatedAt:  timestamp(),
+	}
+	s.auditEvents = append(s.auditEvents, auditEvent)
+	return *auditEvent, nil
+}
+
+// ListAuditEvents returns the events matching filter newest first, starting below beforeID when it is non-zero
+func (r *AuditRepository) ListAuditEvents(
+	_ context.Context,
+	filter domain.AuditFilter,
+	beforeID, limit int,
+) ([]db.AuditEvent, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]db.AuditEvent, 0, limit)
+	for i := len(s.auditEvents) - 1; i >= 0 && len(events) < limit; i-- {
+		event := s.auditEvents[i]
+		if matchesAuditFilter(event, filter) && (beforeID == 0 || event.ID < int32(beforeID)) {
+			events = append(events, *event)
+		}
+	}
+	return events, nil
+}
+
+func matchesAuditFilter(event *db.AuditEvent, filter domain.AuditFilter) bool {
+	return (filter.ActorID == 0 || event.ActorID == int32(filter.ActorID)) &&
+		(filter.Action == "" || event.Action == filter.Action) &&
+		(filter.EntityType == "" || event.EntityType == filter.EntityType) &&
+		(filter.EntityID == 0 || event.EntityID == int32(filter.EntityID)) &&
+		(filter.Since.IsZero() || !event.CreatedAt.Time.Before(filter.Since)) &&
+		(filter.Until.IsZero() || event.CreatedAt.Time.Before(filter.Until))
+}
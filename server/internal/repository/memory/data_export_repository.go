@@ -0,0 +1,269 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.DataExportStore = (*DataExportRepository)(nil)
+
+type DataExportRepository struct {
+	store *Store
+}
+
+func NewDataExportRepository(store *Store) *DataExportRepository {
+	return &DataExportRepository{store: store}
+}
+
+// CreateExport queues an export for the user. It fails with a unique violation of unique_user_data_export_pending
+// while the user has another export pending.
+func (r *DataExportRepository) CreateExport(_ context.Context, userID int) (db.UserDataExport, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[int32(userID)]; !ok {
+		return db.UserDataExport{}, foreignKeyViolation("user_data_exports", "fk_users")
+	}
+	for _, export := range s.dataExports {
+		if export.UserID == int32(userID) && export.Status == domain.DataExportStatusPending {
+			return db.UserDataExport{}, uniqueViolation("unique_user_data_export_pending")
+		}
+	}
+
+	export := &db.UserDataExport{
+		ID:        s.nextID("user_data_exports"),
+		UserID:    int32(userID),
+		Status:    domain.DataExportStatusPending,
+		CreatedAt: timestamp(),
+	}
+	s.dataExports[export.ID] = export
+	return *export, nil
+}
+
+// GetExport returns one of the user's exports. It returns pgx.ErrNoRows when the user has no such export.
+func (r *DataExportRepository) GetExport(_ context.Context, userID, id int) (db.UserDataExport, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	export, ok := s.dataExports[int32(id)]
+	if !ok || export.UserID != int32(userID) {
+		return db.UserDataExport{}, pgx.ErrNoRows
+	}
+	return *export, nil
+}
+
+// GetExportByTokenHash returns the ready, unexpired export with the download token.
+// It returns pgx.ErrNoRows when there is no such export.
+func (r *DataExportRepository) GetExportByTokenHash(_ context.Context, tokenHash string) (db.UserDataExport, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now().UTC()
+	for _, export := range s.dataExports {
+		if export.TokenHash.Valid && export.TokenHash.String == tokenHash && export.ExpiresAt.Time.After(now) {
+			return *export, nil
+		}
+	}
+	return db.UserDataExport{}, pgx.ErrNoRows
+}
+
+// ClaimExport takes the oldest pending export that no worker has claimed within claimTimeout.
+// It returns pgx.ErrNoRows when there is none.
+func (r *DataExportRepository) ClaimExport(_ context.Context, claimTimeout time.Duration) (db.UserDataExport, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := timestamp()
+	var claimed *db.UserDataExport
+	for _, export := range s.dataExports {
+		if export.Status != domain.DataExportStatusPending {
+			continue
+		}
+		if export.ClaimedAt.Valid && !export.ClaimedAt.Time.Before(now.Time.Add(-claimTimeout)) {
+			continue
+		}
+		if claimed == nil || export.ID < claimed.ID {
+			claimed = export
+		}
+	}
+	if claimed == nil {
+		return db.UserDataExport{}, pgx.ErrNoRows
+	}
+
+	claimed.ClaimedAt = now
+	return *claimed, nil
+}
+
+// CompleteExport stores the archive of a pending export, which can then be downloaded with the token until ttl
+// from now. It reports false when the export is not pending.
+func (r *DataExportRepository) CompleteExport(
+	_ context.Context,
+	id int,
+	tokenHash string,
+	archive []byte,
+	ttl time.Duration,
+) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	export, ok := s.dataExports[int32(id)]
+	if !ok || export.Status != domain.DataExportStatusPending {
+		return false, nil
+	}
+	for _, other := range s.dataExports {
+		if other.TokenHash.Valid && other.TokenHash.String == tokenHash {
+			return false, uniqueViolation("unique_user_data_export_token_hash")
+		}
+	}
+
+	now := timestamp()
+	export.Status = domain.DataExportStatusReady
+	export.TokenHash = pgtype.Text{String: tokenHash, Valid: true}
+	export.Archive = archive
+	export.CompletedAt = now
+	export.ExpiresAt = pgtype.Timestamp{Time: now.Time.Add(ttl), Valid: true}
+	return true, nil
+}
+
+// FailExport marks a pending export as failed, keeping it until ttl from now. It reports false when the export
+// is not pending.
+func (r *DataExportRepository) FailExport(_ context.Context, id int, ttl time.Duration) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	export, ok := s.dataExports[int32(id)]
+	if !ok || export.Status != domain.DataExportStatusPending {
+		return false, nil
+	}
+
+	now := timestamp()
+	export.Status = domain.DataExportStatusFailed
+	export.CompletedAt = now
+	export.ExpiresAt = pgtype.Timestamp{Time: now.Time.Add(ttl), Valid: true}
+	return true, nil
+}
+
+// DeleteExpiredExports deletes the exports past their expiry and returns how many it deleted
+func (r *DataExportRepository) DeleteExpiredExports(_ context.Context) (int, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	deleted := 0
+	for id, export := range s.dataExports {
+		if export.ExpiresAt.Valid && !export.ExpiresAt.Time.After(now) {
+			delete(s.dataExports, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// ListUserLikes returns the movies the user likes, in the order they were liked
+func (r *DataExportRepository) ListUserLikes(_ context.Context, userID int) ([]db.ListUserLikesRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var likes []*db.UsersLikeMovie
+	for _, like := range s.likes {
+		if like.UserID == int32(userID) {
+			likes = append(likes, like)
+		}
+	}
+	sort.Slice(likes, func(i, j int) bool {
+		return inCreationOrder(likes[i].CreatedAt, likes[j].CreatedAt, likes[i].ID, likes[j].ID)
+	})
+
+	var rows []db.ListUserLikesRow
+	for _, like := range likes {
+		rows = append(rows, db.ListUserLikesRow{
+			MovieID: like.MovieID,
+			Title:   s.movies[like.MovieID].Title,
+			LikedAt: like.CreatedAt,
+		})
+	}
+	return rows, nil
+}
+
+// ListUserRatings returns the user's ratings, in the order they were first given
+func (r *DataExportRepository) ListUserRatings(_ context.Context, userID int) ([]db.ListUserRatingsRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ratings []*db.UsersRateMovie
+	for _, rating := range s.ratings {
+		if rating.UserID == int32(userID) {
+			ratings = append(ratings, rating)
+		}
+	}
+	sort.Slice(ratings, func(i, j int) bool {
+		return inCreationOrder(ratings[i].CreatedAt, ratings[j].CreatedAt, ratings[i].ID, ratings[j].ID)
+	})
+
+	var rows []db.ListUserRatingsRow
+	for _, rating := range ratings {
+		rows = append(rows, db.ListUserRatingsRow{
+			MovieID:   rating.MovieID,
+			Title:     s.movies[rating.MovieID].Title,
+			Rating:    rating.Rating,
+			CreatedAt: rating.CreatedAt,
+			UpdatedAt: rating.UpdatedAt,
+		})
+	}
+	return rows, nil
+}
+
+// ListUserReviews returns the user's reviews in every moderation state, oldest first
+func (r *DataExportRepository) ListUserReviews(_ context.Context, userID int) ([]db.ListUserReviewsRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reviews []*db.Review
+	for _, review := range s.reviews {
+		if review.UserID == int32(userID) {
+			reviews = append(reviews, review)
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool {
+		return inCreationOrder(reviews[i].CreatedAt, reviews[j].CreatedAt, reviews[i].ID, reviews[j].ID)
+	})
+
+	var rows []db.ListUserReviewsRow
+	for _, review := range reviews {
+		rows = append(rows, db.ListUserReviewsRow{
+			ID:        review.ID,
+			MovieID:   review.MovieID,
+			Title:     s.movies[review.MovieID].Title,
+			Body:      review.Body,
+			Status:    review.Status,
+			CreatedAt: review.CreatedAt,
+			UpdatedAt: review.UpdatedAt,
+		})
+	}
+	return rows, nil
+}
+
+// inCreationOrder orders rows by creation time, then ID, like ORDER BY created_at, id
+func inCreationOrder(createdAt, otherCreatedAt pgtype.Timestamp, id, otherID int32) bool {
+	if !createdAt.Time.Equal(otherCreatedAt.Time) {
+		return createdAt.Time.Before(otherCreatedAt.Time)
+	}
+	return id < otherID
+}
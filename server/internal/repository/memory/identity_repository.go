@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.IdentityStore = (*IdentityRepository)(nil)
+
+type IdentityRepository struct {
+	store *Store
+}
+
+func NewIdentityRepository(store *Store) *IdentityRepository {
+	return &IdentityRepository{store: store}
+}
+
+// CreateUserWithIdentity creates a user without a password who signs in with the provider account, in one transaction
+func (r *IdentityRepository) CreateUserWithIdentity(
+	_ context.Context,
+	firstName, lastName, email, pictureURL string,
+	emailVerified bool,
+	provider, subject string,
+) (db.User, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Checked first, so that a failure leaves no user behind as the rolled back transaction would
+	if err := s.checkIdentity(0, provider, subject); err != nil {
+		return db.User{}, err
+	}
+	user, err := s.insertUser(firstName, lastName, email, pictureURL, "")
+	if err != nil {
+		return db.User{}, err
+	}
+	if emailVerified {
+		user.EmailVerifiedAt = timestamp()
+	}
+	s.insertIdentity(user.ID, provider, subject, email)
+	return *user, nil
+}
+
+func (r *IdentityRepository) CreateUserIdentity(
+	_ context.Context,
+	userID int,
+	provider, subject, email string,
+) (db.UserIdentity, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[int32(userID)]; !ok {
+		return db.UserIdentity{}, foreignKeyViolation("user_identities", "fk_users")
+	}
+	if err := s.checkIdentity(int32(userID), provider, subject); err != nil {
+		return db.UserIdentity{}, err
+	}
+	return *s.insertIdentity(int32(userID), provider, subject, email), nil
+}
+
+// GetUserIdentity returns the identity of the provider account. It returns pgx.ErrNoRows when it is not linked.
+func (r *IdentityRepository) GetUserIdentity(_ context.Context, provider, subject string) (db.UserIdentity, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, identity := range s.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return *identity, nil
+		}
+	}
+	return db.UserIdentity{}, pgx.ErrNoRows
+}
+
+// ListUserIdentities returns the user's identities in the order they were linked
+func (r *IdentityRepository) ListUserIdentities(_ context.Context, userID int) ([]db.UserIdentity, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var identities []db.UserIdentity
+	for _, identity := range s.identities {
+		if identity.UserID == int32(userID) {
+			identities = append(identities, *identity)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].ID < identities[j].ID })
+	return identities, nil
+}
+
+// DeleteUserIdentity unlinks the user's identity at the provider. It reports false when there is none.
+func (r *IdentityRepository) DeleteUserIdentity(_ context.Context, userID int, provider string) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, identity := range s.identities {
+		if identity.UserID == int32(userID) && identity.Provider == provider {
+			delete(s.identities, id)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkIdentity enforces the unique constraints of user_identities. Callers hold the lock.
+func (s *Store) checkIdentity(userID int32, provider, subject string) error {
+	for _, identity := range s.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return uniqueViolation("unique_user_identity")
+		}
+		if identity.UserID == userID && identity.Provider == provider {
+			return uniqueViolation("unique_user_identity_provider")
+		}
+	}
+	return nil
+}
+
+// insertIdentity adds an identity that passed checkIdentity. Callers hold the write lock.
+func (s *Store) insertIdentity(userID int32, provider, subject, email string) *db.UserIdentity {
+	identity := &db.UserIdentity{
+		ID:        s.nextID("user_identities"),
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: timestamp(),
+	}
+	s.identities[identity.ID] = identity
+	return identity
+}
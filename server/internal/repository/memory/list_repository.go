@@ -0,0 +1,301 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.ListStore = (*ListRepository)(nil)
+
+type ListRepository struct {
+	store *Store
+}
+
+func NewListRepository(store *Store) *ListRepository {
+	return &ListRepository{store: store}
+}
+
+func (r *ListRepository) CreateUserList(
+	_ context.Context,
+	userID int,
+	name, description, visibility, shareToken string,
+) (db.UserList, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !domain.IsValidListVisibility(visibility) {
+		return db.UserList{}, checkViolation("user_lists", "user_lists_visibility_check")
+	}
+	if _, ok := s.users[int32(userID)]; !ok {
+		return db.UserList{}, foreignKeyViolation("user_lists", "fk_users")
+	}
+	for _, list := range s.lists {
+		if list.ShareToken == shareToken {
+			return db.UserList{}, uniqueViolation("unique_user_list_share_token")
+		}
+	}
+
+	now := timestamp()
+	list := &db.UserList{
+		ID:          s.nextID("user_lists"),
+		UserID:      int32(userID),
+		Name:        name,
+		Description: description,
+		Visibility:  visibility,
+		ShareToken:  shareToken,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.lists[list.ID] = list
+	return *list, nil
+}
+
+// GetUserListByID returns the list only when it belongs to the user
+func (r *ListRepository) GetUserListByID(_ context.Context, userID, listID int) (db.UserList, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, ok := s.ownedList(userID, listID)
+	if !ok {
+		return db.UserList{}, pgx.ErrNoRows
+	}
+	return *list, nil
+}
+
+// GetUserListByShareToken never returns private lists
+func (r *ListRepository) GetUserListByShareToken(_ context.Context, shareToken string) (db.UserList, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, list := range s.lists {
+		if list.ShareToken == shareToken && list.Visibility != domain.ListVisibilityPrivate {
+			return *list, nil
+		}
+	}
+	return db.UserList{}, pgx.ErrNoRows
+}
+
+func (r *ListRepository) ListUserListsByUser(_ context.Context, userID int) ([]db.ListUserListsByUserRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rows []db.ListUserListsByUserRow
+	for _, list := range s.lists {
+		if list.UserID != int32(userID) {
+			continue
+		}
+		rows = append(rows, db.ListUserListsByUserRow{
+			ID:          list.ID,
+			UserID:      list.UserID,
+			Name:        list.Name,
+			Description: list.Description,
+			Visibility:  list.Visibility,
+			ShareToken:  list.ShareToken,
+			CreatedAt:   list.CreatedAt,
+			UpdatedAt:   list.UpdatedAt,
+			EntryCount:  int64(len(s.entriesOf(list.ID))),
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	return rows, nil
+}
+
+func (r *ListRepository) ListPublicUserListsByUser(ctx context.Context, userID int) ([]db.ListPublicUserListsByUserRow, error) {
+	lists, err := r.ListUserListsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []db.ListPublicUserListsByUserRow
+	for _, list := range lists {
+		if list.Visibility == domain.ListVisibilityPublic {
+			rows = append(rows, db.ListPublicUserListsByUserRow(list))
+		}
+	}
+	return rows, nil
+}
+
+func (r *ListRepository) UpdateUserList(
+	_ context.Context,
+	userID, listID int,
+	name, description, visibility string,
+) (db.UserList, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, ok := s.ownedList(userID, listID)
+	if !ok {
+		return db.UserList{}, pgx.ErrNoRows
+	}
+	if !domain.IsValidListVisibility(visibility) {
+		return db.UserList{}, checkViolation("user_lists", "user_lists_visibility_check")
+	}
+
+	list.Name = name
+	list.Description = description
+	list.Visibility = visibility
+	list.UpdatedAt = timestamp()
+	return *list, nil
+}
+
+// DeleteUserList reports whether the user had the list to delete; its entries are removed with it
+func (r *ListRepository) DeleteUserList(_ context.Context, userID, listID int) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, ok := s.ownedList(userID, listID)
+	if !ok {
+		return false, nil
+	}
+	for _, entry := range s.entriesOf(list.ID) {
+		delete(s.listEntries, entry.ID)
+	}
+	delete(s.lists, list.ID)
+	return true, nil
+}
+
+func (r *ListRepository) ListUserListEntries(_ context.Context, listID int) ([]db.ListUserListEntriesRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := s.entriesOf(int32(listID))
+	rows := make([]db.ListUserListEntriesRow, len(entries))
+	for i, entry := range entries {
+		movie := s.movies[entry.MovieID]
+		rows[i] = db.ListUserListEntriesRow{
+			MovieID:     entry.MovieID,
+			Position:    entry.Position,
+			AddedAt:     entry.CreatedAt,
+			Title:       movie.Title,
+			ReleaseDate: movie.ReleaseDate,
+			Image:       movie.Image,
+			UserRating:  movie.UserRating,
+		}
+	}
+	return rows, nil
+}
+
+// AddUserListEntry appends the movie to the end of the user's list; adding a movie twice is a no-op
+func (r *ListRepository) AddUserListEntry(_ context.Context, userID, listID, movieID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, ok := s.ownedList(userID, listID)
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	if _, ok := s.movies[int32(movieID)]; !ok {
+		return foreignKeyViolation("user_list_entries", "fk_movies")
+	}
+
+	var lastPosition int32
+	for _, entry := range s.entriesOf(list.ID) {
+		if entry.MovieID == int32(movieID) {
+			return nil
+		}
+		lastPosition = max(lastPosition, entry.Position)
+	}
+
+	entry := &db.UserListEntry{
+		ID:        s.nextID("user_list_entries"),
+		ListID:    list.ID,
+		MovieID:   int32(movieID),
+		Position:  lastPosition + 1,
+		CreatedAt: timestamp(),
+	}
+	s.listEntries[entry.ID] = entry
+	return nil
+}
+
+// RemoveUserListEntry reports whether the movie was in the user's list
+func (r *ListRepository) RemoveUserListEntry(_ context.Context, userID, listID, movieID int) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, ok := s.ownedList(userID, listID)
+	if !ok {
+		return false, pgx.ErrNoRows
+	}
+	for _, entry := range s.entriesOf(list.ID) {
+		if entry.MovieID == int32(movieID) {
+			delete(s.listEntries, entry.ID)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ReorderUserListEntries passes the current movie order to reorder and stores the order it returns
+func (r *ListRepository) ReorderUserListEntries(
+	_ context.Context,
+	userID, listID int,
+	reorder func(current []int32) ([]int32, error),
+) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, ok := s.ownedList(userID, listID)
+	if !ok {
+		return pgx.ErrNoRows
+	}
+
+	entries := s.entriesOf(list.ID)
+	current := make([]int32, len(entries))
+	for i, entry := range entries {
+		current[i] = entry.MovieID
+	}
+
+	ordered, err := reorder(current)
+	if err != nil {
+		return err
+	}
+
+	for i, movieID := range ordered {
+		for _, entry := range entries {
+			if entry.MovieID == movieID {
+				entry.Position = int32(i + 1)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) ownedList(userID, listID int) (*db.UserList, bool) {
+	list, ok := s.lists[int32(listID)]
+	if !ok || list.UserID != int32(userID) {
+		return nil, false
+	}
+	return list, true
+}
+
+// entriesOf returns the list's entries ordered by position, then by when they were added
+func (s *Store) entriesOf(listID int32) []*db.UserListEntry {
+	var entries []*db.UserListEntry
+	for _, entry := range s.listEntries {
+		if entry.ListID == listID {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Position != entries[j].Position {
+			return entries[i].Position < entries[j].Position
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
@@ -0,0 +1,28 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/martishin/movie-search-service/internal/repository/memory"
+	"github.com/martishin/movie-search-service/internal/repository/repositorytest"
+)
+
+func TestRepositoryContract(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Stores {
+		store := memory.NewStore()
+		return repositorytest.Stores{
+			Movies:      memory.NewMovieRepository(store),
+			Users:       memory.NewUserRepository(store),
+			Reviews:     memory.NewReviewRepository(store),
+			Lists:       memory.NewListRepository(store),
+			People:      memory.NewPersonRepository(store),
+			Tokens:      memory.NewUserTokenRepository(store),
+			TwoFactor:   memory.NewTwoFactorRepository(store),
+			APIKeys:     memory.NewAPIKeyRepository(store),
+			Identities:  memory.NewIdentityRepository(store),
+			DataExports: memory.NewDataExportRepository(store),
+			Audit:       memory.NewAuditRepository(store),
+			Transactor:  memory.NewTransactor(store),
+		}
+	})
+}
@@ -0,0 +1,322 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+)
+
+// Filter dimensions that can be left out when computing facet counts
+const (
+	facetGenre      = "genre"
+	facetMPAARating = "mpaa_rating"
+)
+
+// cursorTimestampLayout matches how PostgreSQL renders a TIMESTAMP as TEXT
+const cursorTimestampLayout = "2006-01-02 15:04:05.999999"
+
+// sortValue is a movie's value for the sort field. NULLs are coalesced like the SQL sort expressions do.
+type sortValue struct {
+	text   string
+	number float64
+	time   time.Time
+}
+
+type sortColumn struct {
+	value   func(movie *db.Movie) sortValue
+	parse   func(cursor string) (sortValue, error)
+	compare func(a, b sortValue) int
+}
+
+var sortColumns = map[string]sortColumn{
+	domain.SortByTitle: {
+		value:   func(m *db.Movie) sortValue { return sortValue{text: m.Title} },
+		parse:   func(cursor string) (sortValue, error) { return sortValue{text: cursor}, nil },
+		compare: func(a, b sortValue) int { return strings.Compare(a.text, b.text) },
+	},
+	domain.SortByReleaseDate: {
+		value: func(m *db.Movie) sortValue {
+			releaseDate := time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC)
+			if m.ReleaseDate.Valid {
+				releaseDate = m.ReleaseDate.Time
+			}
+			return sortValue{text: releaseDate.Format(time.DateOnly), time: releaseDate}
+		},
+		parse:   parseTimeSortValue(time.DateOnly),
+		compare: compareTimes,
+	},
+	domain.SortByUserRating: {
+		value: func(m *db.Movie) sortValue {
+			rating, ok := numericFloat(m.UserRating)
+			if !ok {
+				return sortValue{text: "0"}
+			}
+			return sortValue{text: strconv.FormatFloat(rating, 'f', 1, 64), number: rating}
+		},
+		parse:   parseNumberSortValue,
+		compare: compareNumbers,
+	},
+	domain.SortByRuntime: {
+		value: func(m *db.Movie) sortValue {
+			return sortValue{text: strconv.Itoa(int(m.Runtime.Int32)), number: float64(m.Runtime.Int32)}
+		},
+		parse:   parseNumberSortValue,
+		compare: compareNumbers,
+	},
+	domain.SortByCreatedAt: {
+		value: func(m *db.Movie) sortValue {
+			return sortValue{text: m.CreatedAt.Time.Format(cursorTimestampLayout), time: m.CreatedAt.Time}
+		},
+		parse:   parseTimeSortValue(cursorTimestampLayout),
+		compare: compareTimes,
+	},
+}
+
+// ListFilteredMoviesWithGenres returns one page of movies matching filter as one row per movie/genre pair,
+// plus the cursor of the next page when there is one.
+// The like status is computed for userID when it is non-zero, and likedOnly keeps only that user's likes.
+func (r *MovieRepository) ListFilteredMoviesWithGenres(
+	_ context.Context,
+	filter domain.MovieFilter,
+	page domain.MoviePageRequest,
+	userID int,
+	likedOnly bool,
+) ([]db.ListMoviesWithGenresAndLikeStatusRow, *domain.MovieCursor, error) {
+	column, ok := sortColumns[page.Sort.Field]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported sort field: %q", page.Sort.Field)
+	}
+	descending := page.Sort.Direction == domain.SortDesc
+
+	var after sortValue
+	if page.After != nil {
+		var err error
+		if after, err = column.parse(page.After.Value); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// compare orders movies by (sort value, id) in the requested direction
+	compare := func(aValue sortValue, aID int32, bValue sortValue, bID int32) int {
+		c := column.compare(aValue, bValue)
+		if c == 0 {
+			c = int(aID) - int(bID)
+		}
+		if descending {
+			return -c
+		}
+		return c
+	}
+
+	type pageMovie struct {
+		movie *db.Movie
+		value sortValue
+	}
+	var movies []pageMovie
+	for _, movie := range s.movies {
+		if !s.matchesFilter(movie, filter, "", userID, likedOnly) {
+			continue
+		}
+		value := column.value(movie)
+		if page.After != nil && compare(value, movie.ID, after, int32(page.After.ID)) <= 0 {
+			continue
+		}
+		movies = append(movies, pageMovie{movie: movie, value: value})
+	}
+	sort.Slice(movies, func(i, j int) bool {
+		return compare(movies[i].value, movies[i].movie.ID, movies[j].value, movies[j].movie.ID) < 0
+	})
+
+	var nextCursor *domain.MovieCursor
+	if len(movies) > page.Limit {
+		last := movies[page.Limit-1]
+		nextCursor = &domain.MovieCursor{
+			Field:     page.Sort.Field,
+			Direction: page.Sort.Direction,
+			Value:     last.value.text,
+			ID:        int(last.movie.ID),
+		}
+		movies = movies[:page.Limit]
+	}
+
+	var items []db.ListMoviesWithGenresAndLikeStatusRow
+	for _, m := range movies {
+		row := db.ListMoviesWithGenresAndLikeStatusRow{
+			MovieID:     m.movie.ID,
+			Title:       m.movie.Title,
+			ReleaseDate: m.movie.ReleaseDate,
+			Runtime:     m.movie.Runtime,
+			MpaaRating:  m.movie.MpaaRating,
+			Description: m.movie.Description,
+			Image:       m.movie.Image,
+			UserRating:  m.movie.UserRating,
+			Video:       m.movie.Video,
+			RatingCount: m.movie.RatingCount,
+		}
+		if userID != 0 {
+			key := userMovieKey{userID: int32(userID), movieID: m.movie.ID}
+			_, row.IsLiked = s.likes[key]
+			if rating, ok := s.ratings[key]; ok {
+				row.MyRating = pgtype.Int2{Int16: rating.Rating, Valid: true}
+			}
+		}
+
+		for _, genre := range s.sortedMovieGenres(m.movie.ID) {
+			row.GenreID, row.Genre = genre.id, genre.name
+			items = append(items, row)
+		}
+	}
+
+	return items, nextCursor, nil
+}
+
+// ListMovieFacets counts matching movies per genre and per MPAA rating.
+// Each facet ignores its own dimension of the filter so the UI can offer the alternatives.
+func (r *MovieRepository) ListMovieFacets(
+	_ context.Context,
+	filter domain.MovieFilter,
+	userID int,
+	likedOnly bool,
+) (*domain.MovieFacets, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	facets := &domain.MovieFacets{
+		Genres:      []*domain.GenreFacet{},
+		MPAARatings: []*domain.MPAARatingFacet{},
+	}
+
+	genreCounts := make(map[int32]int)
+	for _, movieGenre := range s.movieGenres {
+		if s.matchesFilter(s.movies[movieGenre.MovieID], filter, facetGenre, userID, likedOnly) {
+			genreCounts[movieGenre.GenreID]++
+		}
+	}
+	for _, genre := range s.genres {
+		facets.Genres = append(facets.Genres, &domain.GenreFacet{ID: int(genre.ID), Genre: genre.Genre, Count: genreCounts[genre.ID]})
+	}
+	sort.Slice(facets.Genres, func(i, j int) bool { return facets.Genres[i].Genre < facets.Genres[j].Genre })
+
+	ratingCounts := make(map[string]int)
+	for _, movie := range s.movies {
+		if movie.MpaaRating.Valid && s.matchesFilter(movie, filter, facetMPAARating, userID, likedOnly) {
+			ratingCounts[movie.MpaaRating.String]++
+		}
+	}
+	for rating, count := range ratingCounts {
+		facets.MPAARatings = append(facets.MPAARatings, &domain.MPAARatingFacet{Rating: rating, Count: count})
+	}
+	sort.Slice(facets.MPAARatings, func(i, j int) bool { return facets.MPAARatings[i].Rating < facets.MPAARatings[j].Rating })
+
+	return facets, nil
+}
+
+// matchesFilter evaluates filter against a movie, skipping the excluded dimension.
+// As in SQL, a NULL column never satisfies a condition on it.
+func (s *Store) matchesFilter(movie *db.Movie, filter domain.MovieFilter, exclude string, userID int, likedOnly bool) bool {
+	if len(filter.GenreIDs) > 0 && exclude != facetGenre {
+		linked := make(map[int32]bool)
+		for _, genre := range s.movieGenresOf(movie.ID) {
+			linked[genre.ID] = true
+		}
+
+		matched := make(map[int]bool)
+		for _, id := range filter.GenreIDs {
+			if linked[int32(id)] {
+				matched[id] = true
+			}
+		}
+
+		if filter.GenreMatch == domain.GenreMatchAll {
+			if len(matched) != len(filter.GenreIDs) {
+				return false
+			}
+		} else if len(matched) == 0 {
+			return false
+		}
+	}
+
+	if filter.YearFrom > 0 && (!movie.ReleaseDate.Valid || movie.ReleaseDate.Time.Year() < filter.YearFrom) {
+		return false
+	}
+	if filter.YearTo > 0 && (!movie.ReleaseDate.Valid || movie.ReleaseDate.Time.Year() > filter.YearTo) {
+		return false
+	}
+	if filter.RuntimeMin > 0 && (!movie.Runtime.Valid || int(movie.Runtime.Int32) < filter.RuntimeMin) {
+		return false
+	}
+	if filter.RuntimeMax > 0 && (!movie.Runtime.Valid || int(movie.Runtime.Int32) > filter.RuntimeMax) {
+		return false
+	}
+	if len(filter.MPAARatings) > 0 && exclude != facetMPAARating {
+		if !movie.MpaaRating.Valid || !containsString(filter.MPAARatings, movie.MpaaRating.String) {
+			return false
+		}
+	}
+	if filter.MinUserRating > 0 {
+		rating, ok := numericFloat(movie.UserRating)
+		if !ok || rating < filter.MinUserRating {
+			return false
+		}
+	}
+
+	if likedOnly {
+		if _, liked := s.likes[userMovieKey{userID: int32(userID), movieID: movie.ID}]; !liked {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTimeSortValue(layout string) func(cursor string) (sortValue, error) {
+	return func(cursor string) (sortValue, error) {
+		parsed, err := time.Parse(layout, cursor)
+		if err != nil {
+			return sortValue{}, domain.ErrInvalidCursor
+		}
+		return sortValue{text: cursor, time: parsed}, nil
+	}
+}
+
+func parseNumberSortValue(cursor string) (sortValue, error) {
+	parsed, err := strconv.ParseFloat(cursor, 64)
+	if err != nil {
+		return sortValue{}, domain.ErrInvalidCursor
+	}
+	return sortValue{text: cursor, number: parsed}, nil
+}
+
+func compareTimes(a, b sortValue) int {
+	return a.time.Compare(b.time)
+}
+
+func compareNumbers(a, b sortValue) int {
+	switch {
+	case a.number < b.number:
+		return -1
+	case a.number > b.number:
+		return 1
+	}
+	return 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,409 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.MovieStore = (*MovieRepository)(nil)
+
+// creditRoleOrder lists crew before the cast, as ListCreditsByMovieID does
+var creditRoleOrder = map[string]int{
+	domain.CreditRoleDirector: 1,
+	domain.CreditRoleWriter:   2,
+	domain.CreditRoleActor:    3,
+}
+
+// likeUnescaper undoes the escaping applied to LIKE patterns, since prefixes here are matched literally
+var likeUnescaper = strings.NewReplacer(`\\`, `\`, `\%`, "%", `\_`, "_")
+
+type MovieRepository struct {
+	store *Store
+}
+
+func NewMovieRepository(store *Store) *MovieRepository {
+	return &MovieRepository{store: store}
+}
+
+func (r *MovieRepository) CreateMovieWithGenres(_ context.Context, movie domain.Movie) (db.Movie, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check every constraint first, since the database rolls the whole insert back when one fails
+	for _, genre := range movie.Genres {
+		if _, ok := s.genres[int32(genre.ID)]; !ok {
+			return db.Movie{}, foreignKeyViolation("movies_genres", "fk_genres")
+		}
+	}
+
+	now := timestamp()
+	dbMovie := &db.Movie{
+		ID:          s.nextID("movies"),
+		Title:       movie.Title,
+		ReleaseDate: pgtype.Date{Time: movie.ReleaseDate, Valid: true},
+		Runtime:     pgtype.Int4{Int32: int32(movie.RunTime), Valid: true},
+		MpaaRating:  pgtype.Text{String: movie.MPAARating, Valid: true},
+		Description: pgtype.Text{String: movie.Description, Valid: true},
+		Image:       pgtype.Text{String: movie.Image, Valid: true},
+		Video:       pgtype.Text{String: movie.Video, Valid: true},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.movies[dbMovie.ID] = dbMovie
+
+	for _, genre := range movie.Genres {
+		s.addMovieGenre(dbMovie.ID, int32(genre.ID))
+	}
+
+	return *dbMovie, nil
+}
+
+func (r *MovieRepository) GetMovieByID(_ context.Context, id int) (db.Movie, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	movie, ok := s.movies[int32(id)]
+	if !ok {
+		return db.Movie{}, pgx.ErrNoRows
+	}
+	return *movie, nil
+}
+
+func (r *MovieRepository) ListMovies(_ context.Context) ([]db.Movie, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	movies := s.movieList()
+	sort.Slice(movies, func(i, j int) bool { return movies[i].ID < movies[j].ID })
+	return movies, nil
+}
+
+// UpdateMovie overwrites the movie's fields; like the UPDATE it replaces, it succeeds when the movie does not exist
+func (r *MovieRepository) UpdateMovie(_ context.Context, movie domain.Movie) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dbMovie, ok := s.movies[int32(movie.ID)]
+	if !ok {
+		return nil
+	}
+
+	dbMovie.Title = movie.Title
+	dbMovie.ReleaseDate = pgtype.Date{Time: movie.ReleaseDate, Valid: true}
+	dbMovie.Runtime = pgtype.Int4{Int32: int32(movie.RunTime), Valid: true}
+	dbMovie.MpaaRating = pgtype.Text{String: movie.MPAARating, Valid: true}
+	dbMovie.Description = pgtype.Text{String: movie.Description, Valid: true}
+	dbMovie.Image = pgtype.Text{String: movie.Image, Valid: true}
+	dbMovie.Video = pgtype.Text{String: movie.Video, Valid: true}
+	dbMovie.UpdatedAt = timestamp()
+	return nil
+}
+
+// DeleteMovie removes the movie along with the rows that cascade from it
+func (r *MovieRepository) DeleteMovie(_ context.Context, id int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	movieID := int32(id)
+	delete(s.movies, movieID)
+	s.deleteMovieGenres(movieID)
+
+	for key := range s.likes {
+		if key.movieID == movieID {
+			delete(s.likes, key)
+		}
+	}
+	for key := range s.ratings {
+		if key.movieID == movieID {
+			delete(s.ratings, key)
+		}
+	}
+	for creditID, credit := range s.credits {
+		if credit.MovieID == movieID {
+			delete(s.credits, creditID)
+		}
+	}
+	for reviewID, review := range s.reviews {
+		if review.MovieID == movieID {
+			s.deleteReview(reviewID)
+		}
+	}
+	for entryID, entry := range s.listEntries {
+		if entry.MovieID == movieID {
+			delete(s.listEntries, entryID)
+		}
+	}
+	return nil
+}
+
+func (r *MovieRepository) ListGenres(_ context.Context) ([]db.Genre, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	genres := make([]db.Genre, 0, len(s.genres))
+	for _, genre := range s.genres {
+		genres = append(genres, *genre)
+	}
+	sort.Slice(genres, func(i, j int) bool { return genres[i].Genre < genres[j].Genre })
+	return genres, nil
+}
+
+func (r *MovieRepository) ListGenresByMovieID(_ context.Context, movieID int) ([]db.Genre, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var genres []db.Genre
+	for _, genre := range s.movieGenresOf(int32(movieID)) {
+		genres = append(genres, db.Genre{ID: genre.ID, Genre: genre.Genre})
+	}
+	return genres, nil
+}
+
+func (r *MovieRepository) AddMovieGenre(_ context.Context, movieID, genreID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.movies[int32(movieID)]; !ok {
+		return foreignKeyViolation("movies_genres", "fk_movies")
+	}
+	if _, ok := s.genres[int32(genreID)]; !ok {
+		return foreignKeyViolation("movies_genres", "fk_genres")
+	}
+
+	s.addMovieGenre(int32(movieID), int32(genreID))
+	return nil
+}
+
+func (r *MovieRepository) DeleteMovieGenres(_ context.Context, movieID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteMovieGenres(int32(movieID))
+	return nil
+}
+
+func (r *MovieRepository) ListMoviesByGenre(_ context.Context, genreID int) ([]db.Movie, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var movies []db.Movie
+	for _, movieGenre := range s.movieGenres {
+		if movieGenre.GenreID == int32(genreID) {
+			movies = append(movies, *s.movies[movieGenre.MovieID])
+		}
+	}
+	sort.SliceStable(movies, func(i, j int) bool { return movies[i].Title < movies[j].Title })
+	return movies, nil
+}
+
+// SuggestMoviesByTitle matches a lower-case prefix against the start of every title word
+func (r *MovieRepository) SuggestMoviesByTitle(_ context.Context, prefix string, limit int) ([]db.SuggestMoviesByTitleRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefix = likeUnescaper.Replace(prefix)
+
+	var matches []db.Movie
+	for _, movie := range s.movies {
+		title := strings.ToLower(movie.Title)
+		if strings.HasPrefix(title, prefix) || strings.Contains(title, " "+prefix) {
+			matches = append(matches, *movie)
+		}
+	}
+	sortByTitle(matches)
+
+	rows := make([]db.SuggestMoviesByTitleRow, 0, min(limit, len(matches)))
+	for i := 0; i < len(matches) && i < limit; i++ {
+		rows = append(rows, db.SuggestMoviesByTitleRow{
+			ID:          matches[i].ID,
+			Title:       matches[i].Title,
+			ReleaseDate: matches[i].ReleaseDate,
+			Image:       matches[i].Image,
+		})
+	}
+	return rows, nil
+}
+
+func (r *MovieRepository) IsMovieLikedByUser(_ context.Context, movieID, userID int) (bool, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, liked := s.likes[userMovieKey{userID: int32(userID), movieID: int32(movieID)}]
+	return liked, nil
+}
+
+// GetUserMovieRating returns the user's own rating of a movie, or 0 if they have not rated it
+func (r *MovieRepository) GetUserMovieRating(_ context.Context, movieID, userID int) (int, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rating, ok := s.ratings[userMovieKey{userID: int32(userID), movieID: int32(movieID)}]
+	if !ok {
+		return 0, nil
+	}
+	return int(rating.Rating), nil
+}
+
+func (r *MovieRepository) ListCreditsByMovieID(_ context.Context, movieID int) ([]db.ListCreditsByMovieIDRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var credits []*db.MovieCredit
+	for _, credit := range s.credits {
+		if credit.MovieID == int32(movieID) {
+			credits = append(credits, credit)
+		}
+	}
+
+	// Crew first, then the cast in billing order
+	sort.Slice(credits, func(i, j int) bool {
+		a, b := credits[i], credits[j]
+		if roleOrder(a.Role) != roleOrder(b.Role) {
+			return roleOrder(a.Role) < roleOrder(b.Role)
+		}
+		if a.BillingOrder.Valid != b.BillingOrder.Valid {
+			return a.BillingOrder.Valid
+		}
+		if a.BillingOrder.Int32 != b.BillingOrder.Int32 {
+			return a.BillingOrder.Int32 < b.BillingOrder.Int32
+		}
+		return a.ID < b.ID
+	})
+
+	rows := make([]db.ListCreditsByMovieIDRow, len(credits))
+	for i, credit := range credits {
+		person := s.people[credit.PersonID]
+		rows[i] = db.ListCreditsByMovieIDRow{
+			ID:            credit.ID,
+			PersonID:      credit.PersonID,
+			Name:          person.Name,
+			Image:         person.Image,
+			Role:          credit.Role,
+			CharacterName: credit.CharacterName,
+			BillingOrder:  credit.BillingOrder,
+		}
+	}
+	return rows, nil
+}
+
+func (r *MovieRepository) CreateMovieCredit(_ context.Context, movieID int, credit domain.Credit) (db.MovieCredit, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !domain.IsValidCreditRole(credit.Role) {
+		return db.MovieCredit{}, checkViolation("movie_credits", "movie_credits_role_check")
+	}
+	if _, ok := s.movies[int32(movieID)]; !ok {
+		return db.MovieCredit{}, foreignKeyViolation("movie_credits", "fk_movies")
+	}
+	if _, ok := s.people[int32(credit.PersonID)]; !ok {
+		return db.MovieCredit{}, foreignKeyViolation("movie_credits", "fk_people")
+	}
+	for _, existing := range s.credits {
+		if existing.MovieID == int32(movieID) && existing.PersonID == int32(credit.PersonID) && existing.Role == credit.Role {
+			return db.MovieCredit{}, uniqueViolation("unique_movie_person_role")
+		}
+	}
+
+	dbCredit := &db.MovieCredit{
+		ID:            s.nextID("movie_credits"),
+		MovieID:       int32(movieID),
+		PersonID:      int32(credit.PersonID),
+		Role:          credit.Role,
+		CharacterName: pgtype.Text{String: credit.CharacterName, Valid: credit.CharacterName != ""},
+		BillingOrder:  pgtype.Int4{Int32: int32(credit.BillingOrder), Valid: credit.BillingOrder > 0},
+		CreatedAt:     timestamp(),
+	}
+	s.credits[dbCredit.ID] = dbCredit
+	return *dbCredit, nil
+}
+
+// DeleteMovieCredit reports whether the credit existed on the movie
+func (r *MovieRepository) DeleteMovieCredit(_ context.Context, movieID, creditID int) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credit, ok := s.credits[int32(creditID)]
+	if !ok || credit.MovieID != int32(movieID) {
+		return false, nil
+	}
+	delete(s.credits, credit.ID)
+	return true, nil
+}
+
+// addMovieGenre links a genre to a movie. Like the movies_genres table, it does not prevent duplicate links.
+func (s *Store) addMovieGenre(movieID, genreID int32) {
+	s.movieGenres = append(s.movieGenres, db.MoviesGenre{
+		ID:      s.nextID("movies_genres"),
+		MovieID: movieID,
+		GenreID: genreID,
+	})
+}
+
+func (s *Store) deleteMovieGenres(movieID int32) {
+	kept := s.movieGenres[:0]
+	for _, movieGenre := range s.movieGenres {
+		if movieGenre.MovieID != movieID {
+			kept = append(kept, movieGenre)
+		}
+	}
+	s.movieGenres = kept
+}
+
+// movieGenresOf returns the genres linked to a movie in the order they were linked
+func (s *Store) movieGenresOf(movieID int32) []*db.Genre {
+	var genres []*db.Genre
+	for _, movieGenre := range s.movieGenres {
+		if movieGenre.MovieID == movieID {
+			genres = append(genres, s.genres[movieGenre.GenreID])
+		}
+	}
+	return genres
+}
+
+func (s *Store) movieList() []db.Movie {
+	movies := make([]db.Movie, 0, len(s.movies))
+	for _, movie := range s.movies {
+		movies = append(movies, *movie)
+	}
+	return movies
+}
+
+func sortByTitle(movies []db.Movie) {
+	sort.Slice(movies, func(i, j int) bool {
+		if movies[i].Title != movies[j].Title {
+			return movies[i].Title < movies[j].Title
+		}
+		return movies[i].ID < movies[j].ID
+	})
+}
+
+func roleOrder(role string) int {
+	if order, ok := creditRoleOrder[role]; ok {
+		return order
+	}
+	return len(creditRoleOrder) + 1
+}
@@ -0,0 +1,243 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+)
+
+// Full-text weights: title matches rank above description matches, which rank above cast and crew names
+const (
+	titleWeight       = 1.0
+	descriptionWeight = 0.4
+	creditWeight      = 0.2
+)
+
+type rankedMovie struct {
+	movie *db.Movie
+	score float32
+}
+
+// SearchMoviesWithGenres approximates the tsvector search: a movie matches when every query word starts a word
+// of its title, description or cast and crew names. It ranks the same fields in the same order as PostgreSQL,
+// but without stemming, so only word prefixes match.
+func (r *MovieRepository) SearchMoviesWithGenres(_ context.Context, query string, limit int) ([]db.SearchMoviesWithGenresRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := splitWords(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var ranked []rankedMovie
+	for _, movie := range s.movies {
+		fields := []struct {
+			words  []string
+			weight float32
+		}{
+			{splitWords(movie.Title), titleWeight},
+			{splitWords(movie.Description.String), descriptionWeight},
+			{s.creditWords(movie.ID), creditWeight},
+		}
+
+		var score float32
+		matchedAll := true
+		for _, term := range terms {
+			var termScore float32
+			for _, field := range fields {
+				if hasWordWithPrefix(field.words, term) {
+					termScore += field.weight
+				}
+			}
+			if termScore == 0 {
+				matchedAll = false
+				break
+			}
+			score += termScore
+		}
+		if matchedAll {
+			ranked = append(ranked, rankedMovie{movie: movie, score: score})
+		}
+	}
+	ranked = topRanked(ranked, limit)
+
+	var rows []db.SearchMoviesWithGenresRow
+	for _, match := range ranked {
+		row := db.SearchMoviesWithGenresRow{
+			MovieID:     match.movie.ID,
+			Title:       match.movie.Title,
+			ReleaseDate: match.movie.ReleaseDate,
+			Runtime:     match.movie.Runtime,
+			MpaaRating:  match.movie.MpaaRating,
+			Description: match.movie.Description,
+			Image:       match.movie.Image,
+			UserRating:  match.movie.UserRating,
+			Video:       match.movie.Video,
+			Rank:        match.score,
+		}
+		for _, genre := range s.sortedMovieGenres(match.movie.ID) {
+			row.GenreID, row.Genre = genre.id, genre.name
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// FuzzySearchMoviesWithGenres matches titles by trigram word similarity, like pg_trgm's <% operator:
+// the query is compared with every run of consecutive title words and the best match counts.
+func (r *MovieRepository) FuzzySearchMoviesWithGenres(
+	_ context.Context,
+	query string,
+	threshold float64,
+	limit int,
+) ([]db.FuzzySearchMoviesWithGenresRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	queryTrigrams := trigrams(splitWords(query))
+	if len(queryTrigrams) == 0 {
+		return nil, nil
+	}
+
+	var ranked []rankedMovie
+	for _, movie := range s.movies {
+		similarity := wordSimilarity(queryTrigrams, splitWords(movie.Title))
+		if float64(similarity) >= threshold {
+			ranked = append(ranked, rankedMovie{movie: movie, score: similarity})
+		}
+	}
+	ranked = topRanked(ranked, limit)
+
+	var rows []db.FuzzySearchMoviesWithGenresRow
+	for _, match := range ranked {
+		row := db.FuzzySearchMoviesWithGenresRow{
+			MovieID:     match.movie.ID,
+			Title:       match.movie.Title,
+			ReleaseDate: match.movie.ReleaseDate,
+			Runtime:     match.movie.Runtime,
+			MpaaRating:  match.movie.MpaaRating,
+			Description: match.movie.Description,
+			Image:       match.movie.Image,
+			UserRating:  match.movie.UserRating,
+			Video:       match.movie.Video,
+			Similarity:  match.score,
+		}
+		for _, genre := range s.sortedMovieGenres(match.movie.ID) {
+			row.GenreID, row.Genre = genre.id, genre.name
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+type genreColumns struct {
+	id   pgtype.Int4
+	name pgtype.Text
+}
+
+// sortedMovieGenres returns the LEFT JOIN of a movie to its genres ordered by name: one NULL row when it has none
+func (s *Store) sortedMovieGenres(movieID int32) []genreColumns {
+	genres := s.movieGenresOf(movieID)
+	if len(genres) == 0 {
+		return []genreColumns{{}}
+	}
+	sort.SliceStable(genres, func(i, j int) bool { return genres[i].Genre < genres[j].Genre })
+
+	columns := make([]genreColumns, len(genres))
+	for i, genre := range genres {
+		columns[i] = genreColumns{
+			id:   pgtype.Int4{Int32: genre.ID, Valid: true},
+			name: pgtype.Text{String: genre.Genre, Valid: true},
+		}
+	}
+	return columns
+}
+
+func (s *Store) creditWords(movieID int32) []string {
+	var words []string
+	for _, credit := range s.credits {
+		if credit.MovieID == movieID {
+			words = append(words, splitWords(s.people[credit.PersonID].Name)...)
+		}
+	}
+	return words
+}
+
+// topRanked orders matches best first, then by title, and keeps the first limit
+func topRanked(ranked []rankedMovie, limit int) []rankedMovie {
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.movie.Title != b.movie.Title {
+			return a.movie.Title < b.movie.Title
+		}
+		return a.movie.ID < b.movie.ID
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// splitWords lower-cases text and splits it into runs of letters and digits
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func hasWordWithPrefix(words []string, prefix string) bool {
+	for _, word := range words {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// trigrams returns the set of trigrams of the words, each padded with two leading spaces and one trailing space
+func trigrams(words []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+func wordSimilarity(queryTrigrams map[string]bool, titleWords []string) float32 {
+	var best float32
+	for start := range titleWords {
+		for end := start + 1; end <= len(titleWords); end++ {
+			if similarity := trigramSimilarity(queryTrigrams, trigrams(titleWords[start:end])); similarity > best {
+				best = similarity
+			}
+		}
+	}
+	return best
+}
+
+func trigramSimilarity(a, b map[string]bool) float32 {
+	shared := 0
+	for trigram := range a {
+		if b[trigram] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 0
+	}
+	return float32(shared) / float32(union)
+}
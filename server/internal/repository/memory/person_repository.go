@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.PersonStore = (*PersonRepository)(nil)
+
+type PersonRepository struct {
+	store *Store
+}
+
+func NewPersonRepository(store *Store) *PersonRepository {
+	return &PersonRepository{store: store}
+}
+
+func (r *PersonRepository) CreatePerson(_ context.Context, person domain.Person) (db.Person, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := timestamp()
+	dbPerson := &db.Person{ID: s.nextID("people"), CreatedAt: now}
+	setPersonColumns(dbPerson, person)
+	dbPerson.UpdatedAt = now
+	s.people[dbPerson.ID] = dbPerson
+	return *dbPerson, nil
+}
+
+func (r *PersonRepository) GetPersonByID(_ context.Context, id int) (db.Person, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	person, ok := s.people[int32(id)]
+	if !ok {
+		return db.Person{}, pgx.ErrNoRows
+	}
+	return *person, nil
+}
+
+func (r *PersonRepository) ListPeople(_ context.Context) ([]db.Person, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	people := make([]db.Person, 0, len(s.people))
+	for _, person := range s.people {
+		people = append(people, *person)
+	}
+	sort.Slice(people, func(i, j int) bool {
+		if people[i].Name != people[j].Name {
+			return people[i].Name < people[j].Name
+		}
+		return people[i].ID < people[j].ID
+	})
+	return people, nil
+}
+
+func (r *PersonRepository) UpdatePerson(_ context.Context, person domain.Person) (db.Person, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dbPerson, ok := s.people[int32(person.ID)]
+	if !ok {
+		return db.Person{}, pgx.ErrNoRows
+	}
+	setPersonColumns(dbPerson, person)
+	dbPerson.UpdatedAt = timestamp()
+	return *dbPerson, nil
+}
+
+// DeletePerson reports whether the person existed; their credits are removed with them
+func (r *PersonRepository) DeletePerson(_ context.Context, id int) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.people[int32(id)]; !ok {
+		return false, nil
+	}
+	delete(s.people, int32(id))
+	for creditID, credit := range s.credits {
+		if credit.PersonID == int32(id) {
+			delete(s.credits, creditID)
+		}
+	}
+	return true, nil
+}
+
+// ListCreditsByPersonID returns the person's filmography, newest release first
+func (r *PersonRepository) ListCreditsByPersonID(_ context.Context, personID int) ([]db.ListCreditsByPersonIDRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rows []db.ListCreditsByPersonIDRow
+	for _, credit := range s.credits {
+		if credit.PersonID != int32(personID) {
+			continue
+		}
+		movie := s.movies[credit.MovieID]
+		rows = append(rows, db.ListCreditsByPersonIDRow{
+			ID:            credit.ID,
+			MovieID:       credit.MovieID,
+			Title:         movie.Title,
+			ReleaseDate:   movie.ReleaseDate,
+			Image:         movie.Image,
+			Role:          credit.Role,
+			CharacterName: credit.CharacterName,
+			BillingOrder:  credit.BillingOrder,
+		})
+	}
+
+	// ORDER BY release_date DESC NULLS LAST, id
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i].ReleaseDate, rows[j].ReleaseDate
+		if a.Valid != b.Valid {
+			return a.Valid
+		}
+		if a.Valid && !a.Time.Equal(b.Time) {
+			return a.Time.After(b.Time)
+		}
+		return rows[i].ID < rows[j].ID
+	})
+	return rows, nil
+}
+
+func setPersonColumns(dbPerson *db.Person, person domain.Person) {
+	dbPerson.Name = person.Name
+	dbPerson.Biography = pgtype.Text{String: person.Biography, Valid: person.Biography != ""}
+	dbPerson.Image = pgtype.Text{String: person.Image, Valid: person.Image != ""}
+	dbPerson.BirthDate = pgtype.Date{}
+	if person.BirthDate != nil {
+		dbPerson.BirthDate = pgtype.Date{Time: *person.BirthDate, Valid: true}
+	}
+}
@@ -0,0 +1,286 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.ReviewStore = (*ReviewRepository)(nil)
+
+type ReviewRepository struct {
+	store *Store
+}
+
+func NewReviewRepository(store *Store) *ReviewRepository {
+	return &ReviewRepository{store: store}
+}
+
+func (r *ReviewRepository) CreateReview(_ context.Context, userID, movieID int, body string) (db.Review, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkUserMovie("reviews", userID, movieID); err != nil {
+		return db.Review{}, err
+	}
+	if _, ok := s.reviewByUserAndMovie(userID, movieID); ok {
+		return db.Review{}, uniqueViolation("unique_user_movie_review")
+	}
+
+	now := timestamp()
+	review := &db.Review{
+		ID:        s.nextID("reviews"),
+		UserID:    int32(userID),
+		MovieID:   int32(movieID),
+		Body:      body,
+		Status:    domain.ReviewStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.reviews[review.ID] = review
+	return *review, nil
+}
+
+func (r *ReviewRepository) GetReviewByID(_ context.Context, id int) (db.Review, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	review, ok := s.reviews[int32(id)]
+	if !ok {
+		return db.Review{}, pgx.ErrNoRows
+	}
+	return *review, nil
+}
+
+func (r *ReviewRepository) GetReviewByUserAndMovie(_ context.Context, userID, movieID int) (db.Review, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	review, ok := s.reviewByUserAndMovie(userID, movieID)
+	if !ok {
+		return db.Review{}, pgx.ErrNoRows
+	}
+	return *review, nil
+}
+
+// UpdateReviewBody replaces the text and sends the review back to the moderation queue
+func (r *ReviewRepository) UpdateReviewBody(_ context.Context, userID, movieID int, body string) (db.Review, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	review, ok := s.reviewByUserAndMovie(userID, movieID)
+	if !ok {
+		return db.Review{}, pgx.ErrNoRows
+	}
+	review.Body = body
+	review.Status = domain.ReviewStatusPending
+	review.ModeratedBy = pgtype.Int4{}
+	review.ModeratedAt = pgtype.Timestamp{}
+	review.UpdatedAt = timestamp()
+	return *review, nil
+}
+
+// DeleteReview reports whether the user had a review of the movie to delete
+func (r *ReviewRepository) DeleteReview(_ context.Context, userID, movieID int) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	review, ok := s.reviewByUserAndMovie(userID, movieID)
+	if !ok {
+		return false, nil
+	}
+	s.deleteReview(review.ID)
+	return true, nil
+}
+
+func (r *ReviewRepository) SetReviewStatus(_ context.Context, id int, status string, moderatorID int) (db.Review, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	review, ok := s.reviews[int32(id)]
+	if !ok {
+		return db.Review{}, pgx.ErrNoRows
+	}
+	switch status {
+	case domain.ReviewStatusPending, domain.ReviewStatusApproved, domain.ReviewStatusRejected:
+	default:
+		return db.Review{}, checkViolation("reviews", "reviews_status_check")
+	}
+	if _, ok := s.users[int32(moderatorID)]; moderatorID != 0 && !ok {
+		return db.Review{}, foreignKeyViolation("reviews", "fk_moderators")
+	}
+
+	now := timestamp()
+	review.Status = status
+	review.ModeratedBy = pgtype.Int4{Int32: int32(moderatorID), Valid: moderatorID != 0}
+	review.ModeratedAt = now
+	review.UpdatedAt = now
+	return *review, nil
+}
+
+// ListApprovedReviewsByMovie returns approved reviews newest first, starting below beforeID when it is non-zero
+func (r *ReviewRepository) ListApprovedReviewsByMovie(
+	_ context.Context,
+	movieID, beforeID, limit int,
+) ([]db.ListApprovedReviewsByMovieRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reviews := s.filterReviews(func(review *db.Review) bool {
+		return review.MovieID == int32(movieID) &&
+			review.Status == domain.ReviewStatusApproved &&
+			(beforeID == 0 || review.ID < int32(beforeID))
+	})
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].ID > reviews[j].ID })
+
+	rows := make([]db.ListApprovedReviewsByMovieRow, 0, limit)
+	for _, review := range reviews {
+		if len(rows) == limit {
+			break
+		}
+		author := s.users[review.UserID]
+		rows = append(rows, db.ListApprovedReviewsByMovieRow{
+			ID:           review.ID,
+			UserID:       review.UserID,
+			MovieID:      review.MovieID,
+			Body:         review.Body,
+			Status:       review.Status,
+			CreatedAt:    review.CreatedAt,
+			UpdatedAt:    review.UpdatedAt,
+			FirstName:    author.FirstName,
+			LastName:     author.LastName,
+			HelpfulCount: s.countVotes(review.ID),
+		})
+	}
+	return rows, nil
+}
+
+// ListReviewsByStatus returns reviews in the given state oldest first, starting above afterID when it is non-zero
+func (r *ReviewRepository) ListReviewsByStatus(
+	_ context.Context,
+	status string,
+	afterID, limit int,
+) ([]db.ListReviewsByStatusRow, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reviews := s.filterReviews(func(review *db.Review) bool {
+		return review.Status == status && (afterID == 0 || review.ID > int32(afterID))
+	})
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].ID < reviews[j].ID })
+
+	rows := make([]db.ListReviewsByStatusRow, 0, limit)
+	for _, review := range reviews {
+		if len(rows) == limit {
+			break
+		}
+		author := s.users[review.UserID]
+		rows = append(rows, db.ListReviewsByStatusRow{
+			ID:           review.ID,
+			UserID:       review.UserID,
+			MovieID:      review.MovieID,
+			Body:         review.Body,
+			Status:       review.Status,
+			CreatedAt:    review.CreatedAt,
+			UpdatedAt:    review.UpdatedAt,
+			FirstName:    author.FirstName,
+			LastName:     author.LastName,
+			HelpfulCount: s.countVotes(review.ID),
+		})
+	}
+	return rows, nil
+}
+
+func (r *ReviewRepository) CountReviewHelpfulVotes(_ context.Context, reviewID int) (int64, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.countVotes(int32(reviewID)), nil
+}
+
+// AddReviewHelpfulVote records the vote; voting twice is a no-op
+func (r *ReviewRepository) AddReviewHelpfulVote(_ context.Context, userID, reviewID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[int32(userID)]; !ok {
+		return foreignKeyViolation("reviews_helpful_votes", "fk_users")
+	}
+	if _, ok := s.reviews[int32(reviewID)]; !ok {
+		return foreignKeyViolation("reviews_helpful_votes", "fk_reviews")
+	}
+
+	key := userReviewKey{userID: int32(userID), reviewID: int32(reviewID)}
+	if _, ok := s.votes[key]; !ok {
+		s.votes[key] = &db.ReviewsHelpfulVote{
+			ID:        s.nextID("reviews_helpful_votes"),
+			UserID:    key.userID,
+			ReviewID:  key.reviewID,
+			CreatedAt: timestamp(),
+		}
+	}
+	return nil
+}
+
+func (r *ReviewRepository) RemoveReviewHelpfulVote(_ context.Context, userID, reviewID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.votes, userReviewKey{userID: int32(userID), reviewID: int32(reviewID)})
+	return nil
+}
+
+func (s *Store) reviewByUserAndMovie(userID, movieID int) (*db.Review, bool) {
+	for _, review := range s.reviews {
+		if review.UserID == int32(userID) && review.MovieID == int32(movieID) {
+			return review, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Store) filterReviews(keep func(review *db.Review) bool) []*db.Review {
+	var reviews []*db.Review
+	for _, review := range s.reviews {
+		if keep(review) {
+			reviews = append(reviews, review)
+		}
+	}
+	return reviews
+}
+
+func (s *Store) countVotes(reviewID int32) int64 {
+	var count int64
+	for key := range s.votes {
+		if key.reviewID == reviewID {
+			count++
+		}
+	}
+	return count
+}
+
+// deleteReview removes the review and its helpful votes. Callers hold the write lock.
+func (s *Store) deleteReview(reviewID int32) {
+	delete(s.reviews, reviewID)
+	for key := range s.votes {
+		if key.reviewID == reviewID {
+			delete(s.votes, key)
+		}
+	}
+}
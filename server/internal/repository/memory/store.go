@@ -0,0 +1,138 @@
+// Package memory implements the repository interfaces in process, for tests that should not need PostgreSQL.
+// It mirrors the schema's constraints: failures surface as the same pgx and pgconn errors the database returns.
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+)
+
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+)
+
+// seedGenres are the genres inserted by the migrations, in ID order
+var seedGenres = []string{
+	"Comedy", "Sci-Fi", "Horror", "Romance", "Action", "Thriller", "Drama",
+	"Mystery", "Crime", "Animation", "Adventure", "Fantasy", "Superhero",
+}
+
+type userMovieKey struct {
+	userID  int32
+	movieID int32
+}
+
+type userReviewKey struct {
+	userID   int32
+	reviewID int32
+}
+
+// Store holds the tables shared by the in-memory repositories. Like the database, one Store backs every repository.
+type Store struct {
+	mu sync.RWMutex
+	// txMu lets one transaction run at a time
+	txMu sync.Mutex
+
+	sequences   map[string]int32
+	users       map[int32]*db.User
+	genres      map[int32]*db.Genre
+	movies      map[int32]*db.Movie
+	movieGenres []db.MoviesGenre
+	likes       map[userMovieKey]*db.UsersLikeMovie
+	ratings     map[userMovieKey]*db.UsersRateMovie
+	people      map[int32]*db.Person
+	credits     map[int32]*db.MovieCredit
+	reviews     map[int32]*db.Review
+	votes       map[userReviewKey]*db.ReviewsHelpfulVote
+	lists       map[int32]*db.UserList
+	listEntries map[int32]*db.UserListEntry
+	userTokens  map[int32]*db.UserToken
+	apiKeys     map[int32]*db.UserApiKey
+	identities  map[int32]*db.UserIdentity
+	dataExports map[int32]*db.UserDataExport
+	// auditEvents is append only, in ID order
+	auditEvents []*db.AuditEvent
+	// recoveryCodes is keyed by user ID
+	recoveryCodes map[int32][]*db.UserRecoveryCode
+}
+
+// NewStore returns a store holding the same genres as a freshly migrated database
+func NewStore() *Store {
+	s := &Store{
+		sequences:     make(map[string]int32),
+		users:         make(map[int32]*db.User),
+		genres:        make(map[int32]*db.Genre),
+		movies:        make(map[int32]*db.Movie),
+		likes:         make(map[userMovieKey]*db.UsersLikeMovie),
+		ratings:       make(map[userMovieKey]*db.UsersRateMovie),
+		people:        make(map[int32]*db.Person),
+		credits:       make(map[int32]*db.MovieCredit),
+		reviews:       make(map[int32]*db.Review),
+		votes:         make(map[userReviewKey]*db.ReviewsHelpfulVote),
+		lists:         make(map[int32]*db.UserList),
+		listEntries:   make(map[int32]*db.UserListEntry),
+		userTokens:    make(map[int32]*db.UserToken),
+		apiKeys:       make(map[int32]*db.UserApiKey),
+		identities:    make(map[int32]*db.UserIdentity),
+		dataExports:   make(map[int32]*db.UserDataExport),
+		recoveryCodes: make(map[int32][]*db.UserRecoveryCode),
+	}
+
+	now := timestamp()
+	for _, genre := range seedGenres {
+		id := s.nextID("genres")
+		s.genres[id] = &db.Genre{ID: id, Genre: genre, CreatedAt: now, UpdatedAt: now}
+	}
+	return s
+}
+
+// nextID advances the table's SERIAL sequence. Callers hold the write lock.
+func (s *Store) nextID(table string) int32 {
+	s.sequences[table]++
+	return s.sequences[table]
+}
+
+func timestamp() pgtype.Timestamp {
+	return pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}
+}
+
+func numericFloat(value pgtype.Numeric) (float64, bool) {
+	f, err := value.Float64Value()
+	if err != nil || !f.Valid {
+		return 0, false
+	}
+	return f.Float64, true
+}
+
+func uniqueViolation(constraint string) error {
+	return &pgconn.PgError{
+		Code:           pgUniqueViolation,
+		ConstraintName: constraint,
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+	}
+}
+
+func foreignKeyViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Code:           pgForeignKeyViolation,
+		TableName:      table,
+		ConstraintName: constraint,
+		Message:        fmt.Sprintf("insert or update on table %q violates foreign key constraint %q", table, constraint),
+	}
+}
+
+func checkViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Code:           pgCheckViolation,
+		TableName:      table,
+		ConstraintName: constraint,
+		Message:        fmt.Sprintf("new row for relation %q violates check constraint %q", table, constraint),
+	}
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.Transactor = (*Transactor)(nil)
+
+// Transactor runs one transaction at a time against the store. Rolling back restores the tables as they were when
+// the transaction began, which also discards writes made meanwhile outside it; tests do not interleave those.
+// Sequences are not rolled back, as in PostgreSQL.
+type Transactor struct {
+	store *Store
+}
+
+func NewTransactor(store *Store) *Transactor {
+	return &Transactor{store: store}
+}
+
+func (t *Transactor) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if repository.InTransaction(ctx) {
+		return fn(ctx)
+	}
+
+	s := t.store
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.RLock()
+	snapshot := s.tables()
+	s.mu.RUnlock()
+
+	err := repository.RunTransaction(ctx, nil, fn, func(context.Context) error { return nil })
+	if err != nil {
+		s.mu.Lock()
+		s.restore(snapshot)
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// tables copies every table. Callers hold the read lock.
+func (s *Store) tables() *Store {
+	recoveryCodes := make(map[int32][]*db.UserRecoveryCode, len(s.recoveryCodes))
+	for userID, codes := range s.recoveryCodes {
+		recoveryCodes[userID] = cloneRowSlice(codes)
+	}
+
+	return &Store{
+		users:         cloneRows(s.users),
+		genres:        cloneRows(s.genres),
+		movies:        cloneRows(s.movies),
+		movieGenres:   slices.Clone(s.movieGenres),
+		likes:         cloneRows(s.likes),
+		ratings:       cloneRows(s.ratings),
+		people:        cloneRows(s.people),
+		credits:       cloneRows(s.credits),
+		reviews:       cloneRows(s.reviews),
+		votes:         cloneRows(s.votes),
+		lists:         cloneRows(s.lists),
+		listEntries:   cloneRows(s.listEntries),
+		userTokens:    cloneRows(s.userTokens),
+		apiKeys:       cloneRows(s.apiKeys),
+		identities:    cloneRows(s.identities),
+		dataExports:   cloneRows(s.dataExports),
+		auditEvents:   slices.Clone(s.auditEvents),
+		recoveryCodes: recoveryCodes,
+	}
+}
+
+// restore puts back the tables copied by tables. Callers hold the write lock.
+func (s *Store) restore(snapshot *Store) {
+	s.users = snapshot.users
+	s.genres = snapshot.genres
+	s.movies = snapshot.movies
+	s.movieGenres = snapshot.movieGenres
+	s.likes = snapshot.likes
+	s.ratings = snapshot.ratings
+	s.people = snapshot.people
+	s.credits = snapshot.credits
+	s.reviews = snapshot.reviews
+	s.votes = snapshot.votes
+	s.lists = snapshot.lists
+	s.listEntries = snapshot.listEntries
+	s.userTokens = snapshot.userTokens
+	s.apiKeys = snapshot.apiKeys
+	s.identities = snapshot.identities
+	s.dataExports = snapshot.dataExports
+	s.auditEvents = snapshot.auditEvents
+	s.recoveryCodes = snapshot.recoveryCodes
+}
+
+// cloneRows copies a table, so that later updates to its rows do not reach the copy
+func cloneRows[K comparable, V any](rows map[K]*V) map[K]*V {
+	clone := maps.Clone(rows)
+	for key, row := range clone {
+		copied := *row
+		clone[key] = &copied
+	}
+	return clone
+}
+
+func cloneRowSlice[V any](rows []*V) []*V {
+	clone := make([]*V, len(rows))
+	for i, row := range rows {
+		copied := *row
+		clone[i] = &copied
+	}
+	return clone
+}
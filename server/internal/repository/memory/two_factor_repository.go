@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.TwoFactorStore = (*TwoFactorRepository)(nil)
+
+type TwoFactorRepository struct {
+	store *Store
+}
+
+func NewTwoFactorRepository(store *Store) *TwoFactorRepository {
+	return &TwoFactorRepository{store: store}
+}
+
+// SetUserTOTPSecret stores a pending secret. It reports false when two-factor is already enabled or the user is unknown.
+func (r *TwoFactorRepository) SetUserTOTPSecret(_ context.Context, userID int, encryptedSecret []byte) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[int32(userID)]
+	if !ok || user.TotpEnabledAt.Valid {
+		return false, nil
+	}
+
+	user.TotpSecret = append([]byte(nil), encryptedSecret...)
+	user.TotpLastUsedStep = pgtype.Int8{}
+	return true, nil
+}
+
+// EnableUserTOTP enables the pending secret and replaces the recovery codes. It reports false when there is no pending secret.
+func (r *TwoFactorRepository) EnableUserTOTP(_ context.Context, userID int, recoveryCodeHashes []string) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[int32(userID)]
+	if !ok || user.TotpSecret == nil || user.TotpEnabledAt.Valid {
+		return false, nil
+	}
+	if err := s.replaceRecoveryCodes(user.ID, recoveryCodeHashes); err != nil {
+		return false, err
+	}
+
+	user.TotpEnabledAt = timestamp()
+	return true, nil
+}
+
+// DisableUserTOTP clears the secret and the recovery codes. It reports false when the user is unknown.
+func (r *TwoFactorRepository) DisableUserTOTP(_ context.Context, userID int) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[int32(userID)]
+	if !ok {
+		return false, nil
+	}
+
+	user.TotpSecret = nil
+	user.TotpEnabledAt = pgtype.Timestamp{}
+	user.TotpLastUsedStep = pgtype.Int8{}
+	delete(s.recoveryCodes, user.ID)
+	return true, nil
+}
+
+// MarkUserTOTPStepUsed records an accepted code's time step. It reports false for a step that was already used.
+func (r *TwoFactorRepository) MarkUserTOTPStepUsed(_ context.Context, userID int, step int64) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[int32(userID)]
+	if !ok || user.TotpLastUsedStep.Valid && user.TotpLastUsedStep.Int64 >= step {
+		return false, nil
+	}
+
+	user.TotpLastUsedStep = pgtype.Int8{Int64: step, Valid: true}
+	return true, nil
+}
+
+func (r *TwoFactorRepository) ReplaceUserRecoveryCodes(_ context.Context, userID int, codeHashes []string) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.replaceRecoveryCodes(int32(userID), codeHashes)
+}
+
+// ConsumeUserRecoveryCode marks an unused recovery code as used and reports whether there was one
+func (r *TwoFactorRepository) ConsumeUserRecoveryCode(_ context.Context, userID int, codeHash string) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range s.recoveryCodes[int32(userID)] {
+		if code.CodeHash == codeHash && !code.UsedAt.Valid {
+			code.UsedAt = timestamp()
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *TwoFactorRepository) CountUnusedUserRecoveryCodes(_ context.Context, userID int) (int, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, code := range s.recoveryCodes[int32(userID)] {
+		if !code.UsedAt.Valid {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// replaceRecoveryCodes swaps the user's recovery codes, enforcing the foreign key and uniqueness. Callers hold the write lock.
+func (s *Store) replaceRecoveryCodes(userID int32, codeHashes []string) error {
+	if _, ok := s.users[userID]; !ok && len(codeHashes) > 0 {
+		return foreignKeyViolation("user_recovery_codes", "fk_users")
+	}
+
+	seen := make(map[string]bool)
+	codes := make([]*db.UserRecoveryCode, 0, len(codeHashes))
+	now := timestamp()
+	for _, codeHash := range codeHashes {
+		if seen[codeHash] {
+			return uniqueViolation("unique_user_recovery_code")
+		}
+		seen[codeHash] = true
+		codes = append(codes, &db.UserRecoveryCode{
+			ID:        s.nextID("user_recovery_codes"),
+			UserID:    userID,
+			CodeHash:  codeHash,
+			CreatedAt: now,
+		})
+	}
+
+	delete(s.recoveryCodes, userID)
+	if len(codes) > 0 {
+		s.recoveryCodes[userID] = codes
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+package memory
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.UserStore = (*UserRepository)(nil)
+
+type UserRepository struct {
+	store *Store
+}
+
+func NewUserRepository(store *Store) *UserRepository {
+	return &UserRepository{store: store}
+}
+
+func (r *UserRepository) CreateUser(_ context.Context, firstName, lastName, email, pictureURL string, password string) (db.User, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.insertUser(firstName, lastName, email, pictureURL, password)
+	if err != nil {
+		return db.User{}, err
+	}
+	return *user, nil
+}
+
+// insertUser adds a user the way the users table's defaults would. Callers hold the write lock.
+func (s *Store) insertUser(firstName, lastName, email, pictureURL, password string) (*db.User, error) {
+	for _, user := range s.users {
+		if user.Email == email {
+			return nil, uniqueViolation("users_email_key")
+		}
+	}
+
+	now := timestamp()
+	user := &db.User{
+		ID:          s.nextID("users"),
+		FirstName:   firstName,
+		LastName:    lastName,
+		Email:       email,
+		Password:    pgtype.Text{String: password, Valid: password != ""},
+		PictureUrl:  pgtype.Text{String: pictureURL, Valid: true},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Role:        domain.RoleUser,
+		Preferences: []byte("{}"),
+	}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (r *UserRepository) GetUserByID(_ context.Context, id int) (db.User, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[int32(id)]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+	return *user, nil
+}
+
+func (r *UserRepository) GetUserByEmail(_ context.Context, email string) (db.User, error) {
+	s := r.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return *user, nil
+		}
+	}
+	return db.User{}, pgx.ErrNoRows
+}
+
+func (r *UserRepository) SetUserRole(_ context.Context, id int, role string) (db.User, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[int32(id)]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+	if !domain.IsValidRole(role) {
+		return db.User{}, checkViolation("users", "check_users_role")
+	}
+
+	user.Role = role
+	user.UpdatedAt = timestamp()
+	return *user, nil
+}
+
+// PromoteFirstAdmin makes the verified user with the email an admin unless an admin already exists, and reports
+// whether it did
+func (r *UserRepository) PromoteFirstAdmin(_ context.Context, email string) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var target *db.User
+	for _, user := range s.users {
+		if user.Role == domain.RoleAdmin {
+			return false, nil
+		}
+		if strings.EqualFold(user.Email, email) && user.EmailVerifiedAt.Valid {
+			target = user
+		}
+	}
+	if target == nil {
+		return false, nil
+	}
+
+	target.Role = domain.RoleAdmin
+	target.UpdatedAt = timestamp()
+	return true, nil
+}
+
+func (r *UserRepository) SetUserPassword(_ context.Context, id int, password string) (db.User, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[int32(id)]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+
+	user.Password = pgtype.Text{String: password, Valid: password != ""}
+	user.UpdatedAt = timestamp()
+	return *user, nil
+}
+
+// MarkEmailVerified records that the user owns their email address. Verifying twice keeps the first time.
+func (r *UserRepository) MarkEmailVerified(_ context.Context, id int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[int32(id)]; ok && !user.EmailVerifiedAt.Valid {
+		user.EmailVerifiedAt = timestamp()
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdateUserProfile(
+	_ context.Context,
+	id int,
+	firstName, lastName, pictureURL string,
+	preferences []byte,
+) (db.User, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[int32(id)]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+
+	user.FirstName = firstName
+	user.LastName = lastName
+	user.PictureUrl = pgtype.Text{String: pictureURL, Valid: true}
+	user.Preferences = slices.Clone(preferences)
+	user.UpdatedAt = timestamp()
+	return *user, nil
+}
+
+// ScheduleDeletion starts the user's deletion grace period. It reports false when the user does not exist
+// or is already scheduled for deletion.
+func (r *UserRepository) ScheduleDeletion(_ context.Context, id int) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[int32(id)]
+	if !ok || user.DeletedAt.Valid {
+		return false, nil
+	}
+	user.DeletedAt = timestamp()
+	user.UpdatedAt = user.DeletedAt
+	return true, nil
+}
+
+// RestoreUser cancels the user's scheduled deletion and reports whether there was one
+func (r *UserRepository) RestoreUser(_ context.Context, id int) (bool, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[int32(id)]
+	if !ok || !user.DeletedAt.Valid {
+		return false, nil
+	}
+	user.DeletedAt = pgtype.Timestamp{}
+	user.UpdatedAt = timestamp()
+	return true, nil
+}
+
+// PurgeDeletedUsers removes the users scheduled for deletion longer than gracePeriod ago, along with the rows that
+// cascade from them, and recomputes the ratings of the movies they rated. It returns how many users it removed and
+// the movies whose ratings it recomputed.
+func (r *UserRepository) PurgeDeletedUsers(_ context.Context, gracePeriod time.Duration) (int, []int, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := timestamp().Time.Add(-gracePeriod)
+
+	purged := 0
+	rated := make(map[int32]bool)
+	for userID, user := range s.users {
+		if !user.DeletedAt.Valid || !user.DeletedAt.Time.Before(cutoff) {
+			continue
+		}
+		for key := range s.ratings {
+			if key.userID == userID {
+				rated[key.movieID] = true
+			}
+		}
+		s.deleteUser(userID)
+		purged++
+	}
+
+	movieIDs := make([]int, 0, len(rated))
+	for movieID := range rated {
+		s.recomputeMovieRating(movieID)
+		movieIDs = append(movieIDs, int(movieID))
+	}
+	slices.Sort(movieIDs)
+	return purged, movieIDs, nil
+}
+
+// deleteUser removes the user along with the rows that cascade from it. Callers hold the write lock.
+func (s *Store) deleteUser(userID int32) {
+	delete(s.users, userID)
+	delete(s.recoveryCodes, userID)
+
+	for key := range s.likes {
+		if key.userID == userID {
+			delete(s.likes, key)
+		}
+	}
+	for key := range s.ratings {
+		if key.userID == userID {
+			delete(s.ratings, key)
+		}
+	}
+	for key := range s.votes {
+		if key.userID == userID {
+			delete(s.votes, key)
+		}
+	}
+	for reviewID, review := range s.reviews {
+		switch {
+		case review.UserID == userID:
+			s.deleteReview(reviewID)
+		case review.ModeratedBy.Valid && review.ModeratedBy.Int32 == userID:
+			review.ModeratedBy = pgtype.Int4{}
+		}
+	}
+	for listID, list := range s.lists {
+		if list.UserID != userID {
+			continue
+		}
+		delete(s.lists, listID)
+		for entryID, entry := range s.listEntries {
+			if entry.ListID == listID {
+				delete(s.listEntries, entryID)
+			}
+		}
+	}
+	for id, token := range s.userTokens {
+		if token.UserID == userID {
+			delete(s.userTokens, id)
+		}
+	}
+	for id, key := range s.apiKeys {
+		if key.UserID == userID {
+			delete(s.apiKeys, id)
+		}
+	}
+	for id, identity := range s.identities {
+		if identity.UserID == userID {
+			delete(s.identities, id)
+		}
+	}
+	for id, export := range s.dataExports {
+		if export.UserID == userID {
+			delete(s.dataExports, id)
+		}
+	}
+}
+
+func (r *UserRepository) LikeMovie(_ context.Context, userID, movieID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkUserMovie("users_like_movies", userID, movieID); err != nil {
+		return err
+	}
+
+	key := userMovieKey{userID: int32(userID), movieID: int32(movieID)}
+	if _, ok := s.likes[key]; ok {
+		return nil
+	}
+	s.likes[key] = &db.UsersLikeMovie{
+		ID:        s.nextID("users_like_movies"),
+		UserID:    key.userID,
+		MovieID:   key.movieID,
+		CreatedAt: timestamp(),
+	}
+	return nil
+}
+
+func (r *UserRepository) UnlikeMovie(_ context.Context, userID, movieID int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.likes, userMovieKey{userID: int32(userID), movieID: int32(movieID)})
+	return nil
+}
+
+// RateMovie stores the user's rating and recomputes the movie's aggregate rating
+func (r *UserRepository) RateMovie(_ context.Context, userID, movieID, rating int) (db.RecomputeMovieRatingRow, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.movies[int32(movieID)]; !ok {
+		return db.RecomputeMovieRatingRow{}, pgx.ErrNoRows
+	}
+	if rating < 1 || rating > 5 {
+		return db.RecomputeMovieRatingRow{}, checkViolation("users_rate_movies", "users_rate_movies_rating_check")
+	}
+	if err := s.checkUserMovie("users_rate_movies", userID, movieID); err != nil {
+		return db.RecomputeMovieRatingRow{}, err
+	}
+
+	key := userMovieKey{userID: int32(userID), movieID: int32(movieID)}
+	now := timestamp()
+	if existing, ok := s.ratings[key]; ok {
+		existing.Rating = int16(rating)
+		existing.UpdatedAt = now
+	} else {
+		s.ratings[key] = &db.UsersRateMovie{
+			ID:        s.nextID("users_rate_movies"),
+			UserID:    key.userID,
+			MovieID:   key.movieID,
+			Rating:    int16(rating),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	}
+
+	return s.recomputeMovieRating(key.movieID), nil
+}
+
+// UnrateMovie removes the user's rating and recomputes the movie's aggregate rating
+func (r *UserRepository) UnrateMovie(_ context.Context, userID, movieID int) (db.RecomputeMovieRatingRow, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.movies[int32(movieID)]; !ok {
+		return db.RecomputeMovieRatingRow{}, pgx.ErrNoRows
+	}
+
+	delete(s.ratings, userMovieKey{userID: int32(userID), movieID: int32(movieID)})
+	return s.recomputeMovieRating(int32(movieID)), nil
+}
+
+// checkUserMovie enforces the foreign keys of the tables joining users to movies. Callers hold the write lock.
+func (s *Store) checkUserMovie(table string, userID, movieID int) error {
+	if _, ok := s.users[int32(userID)]; !ok {
+		return foreignKeyViolation(table, "fk_users")
+	}
+	if _, ok := s.movies[int32(movieID)]; !ok {
+		return foreignKeyViolation(table, "fk_movies")
+	}
+	return nil
+}
+
+// recomputeMovieRating sets the movie's rating to the average of its user ratings rounded to one decimal,
+// or back to its editorial rating when nobody rated it. Callers hold the write lock.
+func (s *Store) recomputeMovieRating(movieID int32) db.RecomputeMovieRatingRow {
+	var sum, count int64
+	for key, rating := range s.ratings {
+		if key.movieID == movieID {
+			sum += int64(rating.Rating)
+			count++
+		}
+	}
+
+	movie := s.movies[movieID]
+	movie.RatingCount = int32(count)
+	movie.UserRating = movie.EditorialRating
+	if count > 0 {
+		// ROUND(AVG(rating), 1), which rounds halves away from zero
+		tenths := (sum*20 + count) / (count * 2)
+		movie.UserRating = pgtype.Numeric{Int: big.NewInt(tenths), Exp: -1, Valid: true}
+	}
+	movie.UpdatedAt = timestamp()
+
+	return db.RecomputeMovieRatingRow{UserRating: movie.UserRating, RatingCount: movie.RatingCount}
+}
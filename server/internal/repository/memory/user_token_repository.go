@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var _ repository.UserTokenStore = (*UserTokenRepository)(nil)
+
+type UserTokenRepository struct {
+	store *Store
+}
+
+func NewUserTokenRepository(store *Store) *UserTokenRepository {
+	return &UserTokenRepository{store: store}
+}
+
+func (r *UserTokenRepository) CreateUserToken(
+	_ context.Context,
+	userID int,
+	purpose, tokenHash string,
+	ttl time.Duration,
+) (db.UserToken, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if purpose != domain.TokenPurposePasswordReset && purpose != domain.TokenPurposeEmailVerification {
+		return db.UserToken{}, checkViolation("user_tokens", "user_tokens_purpose_check")
+	}
+	if _, ok := s.users[int32(userID)]; !ok {
+		return db.UserToken{}, foreignKeyViolation("user_tokens", "fk_users")
+	}
+	for _, token := range s.userTokens {
+		if token.TokenHash == tokenHash {
+			return db.UserToken{}, uniqueViolation("unique_user_token_hash")
+		}
+	}
+
+	now := timestamp()
+	token := &db.UserToken{
+		ID:        s.nextID("user_tokens"),
+		UserID:    int32(userID),
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		ExpiresAt: pgtype.Timestamp{Time: now.Time.Add(ttl), Valid: true},
+		CreatedAt: now,
+	}
+	s.userTokens[token.ID] = token
+	return *token, nil
+}
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns it.
+// It returns pgx.ErrNoRows when there is no such token.
+func (r *UserTokenRepository) ConsumeUserToken(_ context.Context, purpose, tokenHash string) (db.UserToken, error) {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := timestamp()
+	for _, token := range s.userTokens {
+		if token.TokenHash == tokenHash && token.Purpose == purpose && !token.UsedAt.Valid && token.ExpiresAt.Time.After(now.Time) {
+			token.UsedAt = now
+			return *token, nil
+		}
+	}
+	return db.UserToken{}, pgx.ErrNoRows
+}
+
+// RevokeUserTokens invalidates the user's outstanding tokens for the purpose
+func (r *UserTokenRepository) RevokeUserTokens(_ context.Context, userID int, purpose string) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := timestamp()
+	for _, token := range s.userTokens {
+		if token.UserID == int32(userID) && token.Purpose == purpose && !token.UsedAt.Valid {
+			token.UsedAt = now
+		}
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+)
+
+// Filter dimensions that can be left out when computing facet counts
+const (
+	facetGenre      = "genre"
+	facetMPAARating = "mpaa_rating"
+)
+
+// filterQuery accumulates WHERE conditions and their positional arguments.
+// Every condition references the filtered movie as "fm".
+type filterQuery struct {
+	conditions []string
+	args       []interface{}
+}
+
+func (q *filterQuery) addArg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *filterQuery) where() string {
+	if len(q.conditions) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(q.conditions, " AND ")
+}
+
+// addMovieFilter appends the conditions for filter, skipping the excluded dimension
+func (q *filterQuery) addMovieFilter(filter domain.MovieFilter, exclude string) {
+	if len(filter.GenreIDs) > 0 && exclude != facetGenre {
+		genreIDs := make([]int32, len(filter.GenreIDs))
+		for i, id := range filter.GenreIDs {
+			genreIDs[i] = int32(id)
+		}
+		genreArg := q.addArg(genreIDs)
+
+		if filter.GenreMatch == domain.GenreMatchAll {
+			q.conditions = append(q.conditions, fmt.Sprintf(
+				"(SELECT COUNT(DISTINCT fmg.genre_id) FROM movies_genres fmg WHERE fmg.movie_id = fm.id AND fmg.genre_id = ANY(%s::INTEGER[])) = cardinality(%s::INTEGER[])",
+				genreArg, genreArg,
+			))
+		} else {
+			q.conditions = append(q.conditions, fmt.Sprintf(
+				"EXISTS (SELECT 1 FROM movies_genres fmg WHERE fmg.movie_id = fm.id AND fmg.genre_id = ANY(%s::INTEGER[]))",
+				genreArg,
+			))
+		}
+	}
+
+	if filter.YearFrom > 0 {
+		q.conditions = append(q.conditions, fmt.Sprintf("EXTRACT(YEAR FROM fm.release_date) >= %s", q.addArg(filter.YearFrom)))
+	}
+	if filter.YearTo > 0 {
+		q.conditions = append(q.conditions, fmt.Sprintf("EXTRACT(YEAR FROM fm.release_date) <= %s", q.addArg(filter.YearTo)))
+	}
+	if filter.RuntimeMin > 0 {
+		q.conditions = append(q.conditions, fmt.Sprintf("fm.runtime >= %s", q.addArg(filter.RuntimeMin)))
+	}
+	if filter.RuntimeMax > 0 {
+		q.conditions = append(q.conditions, fmt.Sprintf("fm.runtime <= %s", q.addArg(filter.RuntimeMax)))
+	}
+	if len(filter.MPAARatings) > 0 && exclude != facetMPAARating {
+		q.conditions = append(q.conditions, fmt.Sprintf("fm.mpaa_rating = ANY(%s::TEXT[])", q.addArg(filter.MPAARatings)))
+	}
+	if filter.MinUserRating > 0 {
+		q.conditions = append(q.conditions, fmt.Sprintf("fm.user_rating >= %s", q.addArg(filter.MinUserRating)))
+	}
+}
+
+// addLikedBy restricts the filtered movies to those liked by the user
+func (q *filterQuery) addLikedBy(userID int) {
+	q.conditions = append(q.conditions, fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM users_like_movies fulm WHERE fulm.movie_id = fm.id AND fulm.user_id = %s)",
+		q.addArg(int32(userID)),
+	))
+}
+
+// sortColumns maps each sort field to an expression over the filtered movie and the type used to compare cursor values.
+// Nullable columns are coalesced so that keyset comparisons never see NULL.
+var sortColumns = map[string]struct {
+	expression string
+	sqlType    string
+}{
+	domain.SortByTitle:       {"fm.title", "TEXT"},
+	domain.SortByReleaseDate: {"COALESCE(fm.release_date, DATE '0001-01-01')", "DATE"},
+	domain.SortByUserRating:  {"COALESCE(fm.user_rating, 0)", "NUMERIC"},
+	domain.SortByRuntime:     {"COALESCE(fm.runtime, 0)", "INTEGER"},
+	domain.SortByCreatedAt:   {"fm.created_at", "TIMESTAMP"},
+}
+
+// ListFilteredMoviesWithGenres returns one page of movies matching filter as one row per movie/genre pair,
+// plus the cursor of the next page when there is one.
+// The like status is computed for userID when it is non-zero, and likedOnly keeps only that user's likes.
+func (r *MovieRepository) ListFilteredMoviesWithGenres(
+	ctx context.Context,
+	filter domain.MovieFilter,
+	page domain.MoviePageRequest,
+	userID int,
+	likedOnly bool,
+) ([]db.ListMoviesWithGenresAndLikeStatusRow, *domain.MovieCursor, error) {
+	sortColumn, ok := sortColumns[page.Sort.Field]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported sort field: %q", page.Sort.Field)
+	}
+
+	direction, comparison := "ASC", ">"
+	if page.Sort.Direction == domain.SortDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	query := &filterQuery{}
+	query.addMovieFilter(filter, "")
+	if likedOnly {
+		query.addLikedBy(userID)
+	}
+
+	if page.After != nil {
+		query.conditions = append(query.conditions, fmt.Sprintf(
+			"(%s, fm.id) %s (%s::TEXT::%s, %s)",
+			sortColumn.expression, comparison, query.addArg(page.After.Value), sortColumn.sqlType, query.addArg(int32(page.After.ID)),
+		))
+	}
+
+	// Fetch one extra movie to find out whether another page follows
+	limitArg := query.addArg(int32(page.Limit + 1))
+
+	isLiked := "false"
+	myRating := "NULL::SMALLINT"
+	userJoins := ""
+	if userID != 0 {
+		userArg := query.addArg(int32(userID))
+		isLiked = "ulm.user_id IS NOT NULL"
+		myRating = "urm.rating"
+		userJoins = fmt.Sprintf(`LEFT JOIN users_like_movies ulm ON m.id = ulm.movie_id AND ulm.user_id = %[1]s
+        LEFT JOIN users_rate_movies urm ON m.id = urm.movie_id AND urm.user_id = %[1]s`, userArg)
+	}
+
+	sql := fmt.Sprintf(`
+WITH page AS (
+    SELECT
+        fm.id,
+        %[1]s AS sort_value
+    FROM
+        movies fm
+    WHERE
+        %[2]s
+    ORDER BY
+        sort_value %[3]s, fm.id %[3]s
+    LIMIT %[4]s
+)
+SELECT
+    m.id AS movie_id,
+    m.title,
+    m.release_date,
+    m.runtime,
+    m.mpaa_rating,
+    m.description,
+    m.image,
+    m.user_rating,
+    m.video,
+    g.id AS genre_id,
+    g.genre,
+    %[5]s AS is_liked,
+    m.rating_count,
+    %[6]s AS my_rating,
+    page.sort_value::TEXT
+FROM
+    page
+        JOIN movies m ON page.id = m.id
+        LEFT JOIN movies_genres mg ON m.id = mg.movie_id
+        LEFT JOIN genres g ON mg.genre_id = g.id
+        %[7]s
+ORDER BY
+    page.sort_value %[3]s, m.id %[3]s, g.genre`,
+		sortColumn.expression, query.where(), direction, limitArg, isLiked, myRating, userJoins)
+
+	rows, err := r.pool.Query(ctx, sql, query.args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var items []db.ListMoviesWithGenresAndLikeStatusRow
+	var lastCursor *domain.MovieCursor
+	var nextCursor *domain.MovieCursor
+	movieCount := 0
+
+	for rows.Next() {
+		var i db.ListMoviesWithGenresAndLikeStatusRow
+		var sortValue string
+		if err := rows.Scan(
+			&i.MovieID,
+			&i.Title,
+			&i.ReleaseDate,
+			&i.Runtime,
+			&i.MpaaRating,
+			&i.Description,
+			&i.Image,
+			&i.UserRating,
+			&i.Video,
+			&i.GenreID,
+			&i.Genre,
+			&i.IsLiked,
+			&i.RatingCount,
+			&i.MyRating,
+			&sortValue,
+		); err != nil {
+			return nil, nil, err
+		}
+
+		if lastCursor == nil || lastCursor.ID != int(i.MovieID) {
+			movieCount++
+			if movieCount > page.Limit {
+				// The extra movie only tells us that there is a next page, starting after the previous movie
+				nextCursor = lastCursor
+				break
+			}
+			lastCursor = &domain.MovieCursor{
+				Field:     page.Sort.Field,
+				Direction: page.Sort.Direction,
+				Value:     sortValue,
+				ID:        int(i.MovieID),
+			}
+		}
+
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return items, nextCursor, nil
+}
+
+// ListMovieFacets counts matching movies per genre and per MPAA rating.
+// Each facet ignores its own dimension of the filter so the UI can offer the alternatives.
+func (r *MovieRepository) ListMovieFacets(
+	ctx context.Context,
+	filter domain.MovieFilter,
+	userID int,
+	likedOnly bool,
+) (*domain.MovieFacets, error) {
+	facets := &domain.MovieFacets{
+		Genres:      []*domain.GenreFacet{},
+		MPAARatings: []*domain.MPAARatingFacet{},
+	}
+
+	genreQuery := &filterQuery{}
+	genreQuery.addMovieFilter(filter, facetGenre)
+	if likedOnly {
+		genreQuery.addLikedBy(userID)
+	}
+
+	genreRows, err := r.pool.Query(ctx, fmt.Sprintf(`
+SELECT
+    g.id,
+    g.genre,
+    COUNT(fm.id) AS movie_count
+FROM
+    genres g
+        LEFT JOIN movies_genres mg ON g.id = mg.genre_id
+        LEFT JOIN movies fm ON mg.movie_id = fm.id AND %s
+GROUP BY
+    g.id, g.genre
+ORDER BY
+    g.genre`, genreQuery.where()), genreQuery.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer genreRows.Close()
+
+	for genreRows.Next() {
+		var id int32
+		var genre string
+		var count int64
+		if err := genreRows.Scan(&id, &genre, &count); err != nil {
+			return nil, err
+		}
+		facets.Genres = append(facets.Genres, &domain.GenreFacet{ID: int(id), Genre: genre, Count: int(count)})
+	}
+	if err := genreRows.Err(); err != nil {
+		return nil, err
+	}
+
+	ratingQuery := &filterQuery{}
+	ratingQuery.addMovieFilter(filter, facetMPAARating)
+	if likedOnly {
+		ratingQuery.addLikedBy(userID)
+	}
+
+	ratingRows, err := r.pool.Query(ctx, fmt.Sprintf(`
+SELECT
+    fm.mpaa_rating,
+    COUNT(*) AS movie_count
+FROM
+    movies fm
+WHERE
+    fm.mpaa_rating IS NOT NULL AND %s
+GROUP BY
+    fm.mpaa_rating
+ORDER BY
+    fm.mpaa_rating`, ratingQuery.where()), ratingQuery.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer ratingRows.Close()
+
+	for ratingRows.Next() {
+		var rating string
+		var count int64
+		if err := ratingRows.Scan(&rating, &count); err != nil {
+			return nil, err
+		}
+		facets.MPAARatings = append(facets.MPAARatings, &domain.MPAARatingFacet{Rating: rating, Count: int(count)})
+	}
+	if err := ratingRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
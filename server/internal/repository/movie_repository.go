@@ -2,23 +2,62 @@ package repository
 
 import (
 	"context"
-	"math/big"
+	"errors"
+	"strconv"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	db "github.com/martishin/movie-search-service/internal/db/generated"
 	"github.com/martishin/movie-search-service/internal/model/domain"
 )
 
+// MovieStore is the movie catalog as used by the services.
+type MovieStore interface {
+	CreateMovieWithGenres(ctx context.Context, movie domain.Movie) (db.Movie, error)
+	GetMovieByID(ctx context.Context, id int) (db.Movie, error)
+	ListMovies(ctx context.Context) ([]db.Movie, error)
+	UpdateMovie(ctx context.Context, movie domain.Movie) error
+	DeleteMovie(ctx context.Context, id int) error
+
+	ListGenres(ctx context.Context) ([]db.Genre, error)
+	ListGenresByMovieID(ctx context.Context, movieID int) ([]db.Genre, error)
+	AddMovieGenre(ctx context.Context, movieID, genreID int) error
+	DeleteMovieGenres(ctx context.Context, movieID int) error
+	ListMoviesByGenre(ctx context.Context, genreID int) ([]db.Movie, error)
+
+	ListFilteredMoviesWithGenres(
+		ctx context.Context,
+		filter domain.MovieFilter,
+		page domain.MoviePageRequest,
+		userID int,
+		likedOnly bool,
+	) ([]db.ListMoviesWithGenresAndLikeStatusRow, *domain.MovieCursor, error)
+	ListMovieFacets(ctx context.Context, filter domain.MovieFilter, userID int, likedOnly bool) (*domain.MovieFacets, error)
+
+	SearchMoviesWithGenres(ctx context.Context, query string, limit int) ([]db.SearchMoviesWithGenresRow, error)
+	FuzzySearchMoviesWithGenres(ctx context.Context, query string, threshold float64, limit int) ([]db.FuzzySearchMoviesWithGenresRow, error)
+	SuggestMoviesByTitle(ctx context.Context, prefix string, limit int) ([]db.SuggestMoviesByTitleRow, error)
+
+	IsMovieLikedByUser(ctx context.Context, movieID, userID int) (bool, error)
+	GetUserMovieRating(ctx context.Context, movieID, userID int) (int, error)
+
+	ListCreditsByMovieID(ctx context.Context, movieID int) ([]db.ListCreditsByMovieIDRow, error)
+	CreateMovieCredit(ctx context.Context, movieID int, credit domain.Credit) (db.MovieCredit, error)
+	DeleteMovieCredit(ctx context.Context, movieID, creditID int) (bool, error)
+}
+
+var _ MovieStore = (*MovieRepository)(nil)
+
 type MovieRepository struct {
-	pool    *pgxpool.Pool
+	pool    conn
 	queries *db.Queries
 }
 
 func NewMovieRepository(postgresPool *pgxpool.Pool) *MovieRepository {
 	return &MovieRepository{
-		pool:    postgresPool,
-		queries: db.New(postgresPool),
+		pool:    newConn(postgresPool),
+		queries: db.New(newConn(postgresPool)),
 	}
 }
 
@@ -31,7 +70,6 @@ func (r *MovieRepository) CreateMovie(ctx context.Context, movie domain.Movie) (
 		Description: pgtype.Text{String: movie.Description, Valid: true},
 		Image:       pgtype.Text{String: movie.Image, Valid: true},
 		Video:       pgtype.Text{String: movie.Video, Valid: true},
-		UserRating:  pgtype.Numeric{Int: big.NewInt(int64(movie.UserRating * 10)), Exp: -1, Valid: true},
 	}
 
 	return r.queries.CreateMovie(ctx, params)
@@ -54,8 +92,7 @@ func (r *MovieRepository) UpdateMovie(ctx context.Context, movie domain.Movie) e
 		MpaaRating:  pgtype.Text{String: movie.MPAARating, Valid: true},
 		Description: pgtype.Text{String: movie.Description, Valid: true},
 		Image:       pgtype.Text{String: movie.Image, Valid: true},
-		Video:       pgtype.Text{String: movie.Image, Valid: true},
-		UserRating:  pgtype.Numeric{Int: big.NewInt(int64(movie.UserRating * 10)), Exp: -1, Valid: true},
+		Video:       pgtype.Text{String: movie.Video, Valid: true},
 	}
 	return r.queries.UpdateMovie(ctx, params)
 }
@@ -108,6 +145,58 @@ func (r *MovieRepository) ListMoviesWithGenresAndLikes(ctx context.Context, user
 	return r.queries.ListMoviesWithGenresAndLikeStatus(ctx, int32(userID))
 }
 
+func (r *MovieRepository) SearchMoviesWithGenres(ctx context.Context, query string, limit int) ([]db.SearchMoviesWithGenresRow, error) {
+	params := db.SearchMoviesWithGenresParams{
+		Query:      query,
+		MaxResults: int32(limit),
+	}
+	return r.queries.SearchMoviesWithGenres(ctx, params)
+}
+
+// FuzzySearchMoviesWithGenres matches titles by trigram word similarity so that misspelled queries still find movies
+func (r *MovieRepository) FuzzySearchMoviesWithGenres(
+	ctx context.Context,
+	query string,
+	threshold float64,
+	limit int,
+) ([]db.FuzzySearchMoviesWithGenresRow, error) {
+	// The threshold is a transaction-local setting, so it only applies to this search
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := r.queries.WithTx(tx)
+
+	err = qtx.SetWordSimilarityThreshold(ctx, strconv.FormatFloat(threshold, 'f', -1, 64))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qtx.FuzzySearchMoviesWithGenres(ctx, db.FuzzySearchMoviesWithGenresParams{
+		Query:      query,
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// SuggestMoviesByTitle matches a lower-case prefix against the start of every title word
+func (r *MovieRepository) SuggestMoviesByTitle(ctx context.Context, prefix string, limit int) ([]db.SuggestMoviesByTitleRow, error) {
+	return r.queries.SuggestMoviesByTitle(ctx, db.SuggestMoviesByTitleParams{
+		Prefix:     prefix,
+		MaxResults: int32(limit),
+	})
+}
+
 func (r *MovieRepository) IsMovieLikedByUser(ctx context.Context, movieID, userID int) (bool, error) {
 	params := db.IsMovieLikedByUserParams{
 		MovieID: int32(movieID),
@@ -120,6 +209,45 @@ func (r *MovieRepository) IsMovieLikedByUser(ctx context.Context, movieID, userI
 	return liked, nil
 }
 
+// GetUserMovieRating returns the user's own rating of a movie, or 0 if they have not rated it
+func (r *MovieRepository) GetUserMovieRating(ctx context.Context, movieID, userID int) (int, error) {
+	params := db.GetUserMovieRatingParams{
+		UserID:  int32(userID),
+		MovieID: int32(movieID),
+	}
+	rating, err := r.queries.GetUserMovieRating(ctx, params)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int(rating), nil
+}
+
+func (r *MovieRepository) ListCreditsByMovieID(ctx context.Context, movieID int) ([]db.ListCreditsByMovieIDRow, error) {
+	return r.queries.ListCreditsByMovieID(ctx, int32(movieID))
+}
+
+func (r *MovieRepository) CreateMovieCredit(ctx context.Context, movieID int, credit domain.Credit) (db.MovieCredit, error) {
+	return r.queries.CreateMovieCredit(ctx, db.CreateMovieCreditParams{
+		MovieID:       int32(movieID),
+		PersonID:      int32(credit.PersonID),
+		Role:          credit.Role,
+		CharacterName: pgtype.Text{String: credit.CharacterName, Valid: credit.CharacterName != ""},
+		BillingOrder:  pgtype.Int4{Int32: int32(credit.BillingOrder), Valid: credit.BillingOrder > 0},
+	})
+}
+
+// DeleteMovieCredit reports whether the credit existed on the movie
+func (r *MovieRepository) DeleteMovieCredit(ctx context.Context, movieID, creditID int) (bool, error) {
+	deleted, err := r.queries.DeleteMovieCredit(ctx, db.DeleteMovieCreditParams{
+		ID:      int32(creditID),
+		MovieID: int32(movieID),
+	})
+	return deleted > 0, err
+}
+
 func (r *MovieRepository) GetLikedMovies(ctx context.Context, userID int) ([]db.GetLikedMoviesByUserRow, error) {
 	return r.queries.GetLikedMoviesByUser(ctx, int32(userID))
 }
@@ -143,7 +271,6 @@ func (r *MovieRepository) CreateMovieWithGenres(ctx context.Context, movie domai
 		Description: pgtype.Text{String: movie.Description, Valid: true},
 		Image:       pgtype.Text{String: movie.Image, Valid: true},
 		Video:       pgtype.Text{String: movie.Video, Valid: true},
-		UserRating:  pgtype.Numeric{Int: big.NewInt(int64(movie.UserRating * 10)), Exp: -1, Valid: true},
 	}
 
 	dbMovie, err := qtx.CreateMovie(ctx, params)
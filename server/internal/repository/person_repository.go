@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+)
+
+// PersonStore is the cast and crew catalog.
+type PersonStore interface {
+	CreatePerson(ctx context.Context, person domain.Person) (db.Person, error)
+	GetPersonByID(ctx context.Context, id int) (db.Person, error)
+	ListPeople(ctx context.Context) ([]db.Person, error)
+	UpdatePerson(ctx context.Context, person domain.Person) (db.Person, error)
+	DeletePerson(ctx context.Context, id int) (bool, error)
+	ListCreditsByPersonID(ctx context.Context, personID int) ([]db.ListCreditsByPersonIDRow, error)
+}
+
+var _ PersonStore = (*PersonRepository)(nil)
+
+type PersonRepository struct {
+	queries *db.Queries
+}
+
+func NewPersonRepository(postgresPool *pgxpool.Pool) *PersonRepository {
+	return &PersonRepository{queries: db.New(newConn(postgresPool))}
+}
+
+func (r *PersonRepository) CreatePerson(ctx context.Context, person domain.Person) (db.Person, error) {
+	return r.queries.CreatePerson(ctx, db.CreatePersonParams{
+		Name:      person.Name,
+		Biography: pgtype.Text{String: person.Biography, Valid: person.Biography != ""},
+		BirthDate: birthDateParam(person.BirthDate),
+		Image:     pgtype.Text{String: person.Image, Valid: person.Image != ""},
+	})
+}
+
+func (r *PersonRepository) GetPersonByID(ctx context.Context, id int) (db.Person, error) {
+	return r.queries.GetPersonByID(ctx, int32(id))
+}
+
+func (r *PersonRepository) ListPeople(ctx context.Context) ([]db.Person, error) {
+	return r.queries.ListPeople(ctx)
+}
+
+func (r *PersonRepository) UpdatePerson(ctx context.Context, person domain.Person) (db.Person, error) {
+	return r.queries.UpdatePerson(ctx, db.UpdatePersonParams{
+		ID:        int32(person.ID),
+		Name:      person.Name,
+		Biography: pgtype.Text{String: person.Biography, Valid: person.Biography != ""},
+		BirthDate: birthDateParam(person.BirthDate),
+		Image:     pgtype.Text{String: person.Image, Valid: person.Image != ""},
+	})
+}
+
+// DeletePerson reports whether the person existed; their credits are removed with them
+func (r *PersonRepository) DeletePerson(ctx context.Context, id int) (bool, error) {
+	deleted, err := r.queries.DeletePerson(ctx, int32(id))
+	return deleted > 0, err
+}
+
+func (r *PersonRepository) ListCreditsByPersonID(ctx context.Context, personID int) ([]db.ListCreditsByPersonIDRow, error) {
+	return r.queries.ListCreditsByPersonID(ctx, int32(personID))
+}
+
+func birthDateParam(birthDate *time.Time) pgtype.Date {
+	if birthDate == nil {
+		return pgtype.Date{}
+	}
+	return pgtype.Date{Time: *birthDate, Valid: true}
+}
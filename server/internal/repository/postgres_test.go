@@ -0,0 +1,31 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/martishin/movie-search-service/internal/repository"
+	"github.com/martishin/movie-search-service/internal/repository/repositorytest"
+)
+
+// TestRepositoryContract runs the repository contract against the database named by TEST_POSTGRES_DSN
+func TestRepositoryContract(t *testing.T) {
+	pool := repositorytest.OpenPostgres(t)
+
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Stores {
+		repositorytest.ResetPostgres(t, pool)
+		return repositorytest.Stores{
+			Movies:      repository.NewMovieRepository(pool),
+			Users:       repository.NewUserRepository(pool),
+			Reviews:     repository.NewReviewRepository(pool),
+			Lists:       repository.NewListRepository(pool),
+			People:      repository.NewPersonRepository(pool),
+			Tokens:      repository.NewUserTokenRepository(pool),
+			TwoFactor:   repository.NewTwoFactorRepository(pool),
+			APIKeys:     repository.NewAPIKeyRepository(pool),
+			Identities:  repository.NewIdentityRepository(pool),
+			DataExports: repository.NewDataExportRepository(pool),
+			Audit:       repository.NewAuditRepository(pool),
+			Transactor:  repository.NewPostgresTransactor(pool),
+		}
+	})
+}
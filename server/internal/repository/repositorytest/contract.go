@@ -0,0 +1,1901 @@
+// Package repositorytest holds the contract every repository implementation must honour.
+// The PostgreSQL repositories and the in-memory ones run the same suite, so tests written against the
+// in-memory repositories can trust that they behave like the database.
+package repositorytest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+)
+
+// Stores are the repositories under test. They share one database, which holds the seeded genres and nothing else.
+type Stores struct {
+	Movies      repository.MovieStore
+	Users       repository.UserStore
+	Reviews     repository.ReviewStore
+	Lists       repository.ListStore
+	People      repository.PersonStore
+	Tokens      repository.UserTokenStore
+	TwoFactor   repository.TwoFactorStore
+	APIKeys     repository.APIKeyStore
+	Identities  repository.IdentityStore
+	DataExports repository.DataExportStore
+	Audit       repository.AuditStore
+	Transactor  repository.Transactor
+}
+
+// Run runs the contract, calling newStores for a clean database before each test
+func Run(t *testing.T, newStores func(t *testing.T) Stores) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, stores Stores)
+	}{
+		{"CreateAndGetUser", testCreateAndGetUser},
+		{"UniqueEmail", testUniqueEmail},
+		{"UserRoles", testUserRoles},
+		{"PasswordAndEmailVerification", testPasswordAndEmailVerification},
+		{"UpdateUserProfile", testUpdateUserProfile},
+		{"ScheduleAndRestoreDeletion", testScheduleAndRestoreDeletion},
+		{"PurgeDeletedUsers", testPurgeDeletedUsers},
+		{"UserTokens", testUserTokens},
+		{"ExpiredUserToken", testExpiredUserToken},
+		{"TwoFactorEnrollment", testTwoFactorEnrollment},
+		{"RecoveryCodes", testRecoveryCodes},
+		{"APIKeys", testAPIKeys},
+		{"CreateUserWithIdentity", testCreateUserWithIdentity},
+		{"Identities", testIdentities},
+		{"DataExports", testDataExports},
+		{"ExpiredDataExport", testExpiredDataExport},
+		{"DataExportContents", testDataExportContents},
+		{"AuditEvents", testAuditEvents},
+		{"Transactions", testTransactions},
+		{"LikeIdempotency", testLikeIdempotency},
+		{"LikeUnknownMovie", testLikeUnknownMovie},
+		{"Ratings", testRatings},
+		{"CreateMovieWithGenres", testCreateMovieWithGenres},
+		{"CreateMovieWithUnknownGenre", testCreateMovieWithUnknownGenre},
+		{"UpdateMovie", testUpdateMovie},
+		{"MovieGenres", testMovieGenres},
+		{"DeleteMovieCascades", testDeleteMovieCascades},
+		{"Credits", testCredits},
+		{"FilteredPagination", testFilteredPagination},
+		{"FilterAndFacets", testFilterAndFacets},
+		{"LikedOnlyListing", testLikedOnlyListing},
+		{"Search", testSearch},
+		{"Reviews", testReviews},
+		{"ReviewVotes", testReviewVotes},
+		{"Lists", testLists},
+		{"ListEntries", testListEntries},
+		{"People", testPeople},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStores(t))
+		})
+	}
+}
+
+func testCreateAndGetUser(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	created, err := stores.Users.CreateUser(ctx, "Ada", "Lovelace", "ada@example.com", "", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if created.ID == 0 || created.Role != domain.RoleUser || created.Password.String != "hash" {
+		t.Errorf("CreateUser returned %+v", created)
+	}
+
+	byID, err := stores.Users.GetUserByID(ctx, int(created.ID))
+	if err != nil || byID.Email != "ada@example.com" {
+		t.Errorf("GetUserByID = %+v, %v", byID, err)
+	}
+
+	byEmail, err := stores.Users.GetUserByEmail(ctx, "ada@example.com")
+	if err != nil || byEmail.ID != created.ID {
+		t.Errorf("GetUserByEmail = %+v, %v", byEmail, err)
+	}
+
+	if _, err := stores.Users.GetUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetUserByEmail of unknown email: got %v, want pgx.ErrNoRows", err)
+	}
+	if _, err := stores.Users.GetUserByID(ctx, int(created.ID)+100); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetUserByID of unknown id: got %v, want pgx.ErrNoRows", err)
+	}
+
+	oauthUser, err := stores.Users.CreateUser(ctx, "Grace", "Hopper", "grace@example.com", "https://example.com/g.png", "")
+	if err != nil {
+		t.Fatalf("CreateUser without password: %v", err)
+	}
+	if oauthUser.Password.Valid {
+		t.Errorf("OAuth user has a password: %+v", oauthUser.Password)
+	}
+}
+
+func testUniqueEmail(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	if _, err := stores.Users.CreateUser(ctx, "Ada", "Lovelace", "ada@example.com", "", "hash"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	_, err := stores.Users.CreateUser(ctx, "Other", "Ada", "ada@example.com", "", "hash")
+	assertPgError(t, err, pgUniqueViolation)
+}
+
+func testUserRoles(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	first := createUser(t, stores, "first@example.com")
+	second := createUser(t, stores, "second@example.com")
+
+	// Anyone can sign up with the address, so only a verified account is promoted
+	promoted, err := stores.Users.PromoteFirstAdmin(ctx, "first@example.com")
+	if err != nil || promoted {
+		t.Fatalf("PromoteFirstAdmin of unverified user = %v, %v; want false", promoted, err)
+	}
+	for _, id := range []int{first, second} {
+		if err := stores.Users.MarkEmailVerified(ctx, id); err != nil {
+			t.Fatalf("MarkEmailVerified: %v", err)
+		}
+	}
+
+	// The address matches regardless of case
+	promoted, err = stores.Users.PromoteFirstAdmin(ctx, "First@Example.com")
+	if err != nil || !promoted {
+		t.Fatalf("PromoteFirstAdmin = %v, %v; want true", promoted, err)
+	}
+
+	// Only the first admin is bootstrapped
+	promoted, err = stores.Users.PromoteFirstAdmin(ctx, "second@example.com")
+	if err != nil || promoted {
+		t.Errorf("second PromoteFirstAdmin = %v, %v; want false", promoted, err)
+	}
+
+	user, _ := stores.Users.GetUserByID(ctx, first)
+	if user.Role != domain.RoleAdmin {
+		t.Errorf("bootstrapped user has role %q", user.Role)
+	}
+
+	updated, err := stores.Users.SetUserRole(ctx, second, domain.RoleEditor)
+	if err != nil || updated.Role != domain.RoleEditor {
+		t.Errorf("SetUserRole = %+v, %v", updated, err)
+	}
+
+	if _, err := stores.Users.SetUserRole(ctx, second+100, domain.RoleEditor); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("SetUserRole of unknown user: got %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func testPasswordAndEmailVerification(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+
+	user, _ := stores.Users.GetUserByID(ctx, userID)
+	if user.EmailVerifiedAt.Valid {
+		t.Errorf("new user is verified: %+v", user.EmailVerifiedAt)
+	}
+
+	if err := stores.Users.MarkEmailVerified(ctx, userID); err != nil {
+		t.Fatalf("MarkEmailVerified: %v", err)
+	}
+	verified, _ := stores.Users.GetUserByID(ctx, userID)
+	if !verified.EmailVerifiedAt.Valid {
+		t.Fatalf("MarkEmailVerified did not verify the user")
+	}
+
+	// Verifying again keeps the original time
+	if err := stores.Users.MarkEmailVerified(ctx, userID); err != nil {
+		t.Fatalf("second MarkEmailVerified: %v", err)
+	}
+	again, _ := stores.Users.GetUserByID(ctx, userID)
+	if !again.EmailVerifiedAt.Time.Equal(verified.EmailVerifiedAt.Time) {
+		t.Errorf("verification time moved from %v to %v", verified.EmailVerifiedAt.Time, again.EmailVerifiedAt.Time)
+	}
+
+	updated, err := stores.Users.SetUserPassword(ctx, userID, "new-hash")
+	if err != nil || updated.Password.String != "new-hash" || !updated.EmailVerifiedAt.Valid {
+		t.Errorf("SetUserPassword = %+v, %v", updated, err)
+	}
+	if _, err := stores.Users.SetUserPassword(ctx, userID+100, "new-hash"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("SetUserPassword of unknown user: got %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func testUpdateUserProfile(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+
+	user, _ := stores.Users.GetUserByID(ctx, userID)
+	if string(user.Preferences) != "{}" {
+		t.Errorf("new user has preferences %s, want {}", user.Preferences)
+	}
+
+	updated, err := stores.Users.UpdateUserProfile(ctx, userID, "Augusta", "King", "https://example.com/a.png",
+		[]byte(`{"theme": "dark"}`))
+	if err != nil {
+		t.Fatalf("UpdateUserProfile: %v", err)
+	}
+	if updated.FirstName != "Augusta" || updated.LastName != "King" || updated.PictureUrl.String != "https://example.com/a.png" ||
+		updated.Email != "ada@example.com" || updated.Password.String != "hash" {
+		t.Errorf("UpdateUserProfile = %+v", updated)
+	}
+
+	var preferences map[string]string
+	if err := json.Unmarshal(updated.Preferences, &preferences); err != nil || preferences["theme"] != "dark" {
+		t.Errorf("preferences = %s, %v", updated.Preferences, err)
+	}
+
+	if _, err := stores.Users.UpdateUserProfile(ctx, userID+100, "A", "B", "", []byte("{}")); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("UpdateUserProfile of unknown user: got %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func testScheduleAndRestoreDeletion(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+	if _, err := stores.APIKeys.CreateAPIKey(ctx, userID, "Scripts", "msk_0001", "hash-1", []string{domain.APIKeyScopeRead}); err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	scheduled, err := stores.Users.ScheduleDeletion(ctx, userID)
+	if err != nil || !scheduled {
+		t.Fatalf("ScheduleDeletion = %v, %v; want true", scheduled, err)
+	}
+	if scheduled, _ := stores.Users.ScheduleDeletion(ctx, userID); scheduled {
+		t.Errorf("second ScheduleDeletion = true, want false")
+	}
+	if scheduled, _ := stores.Users.ScheduleDeletion(ctx, userID+100); scheduled {
+		t.Errorf("ScheduleDeletion of unknown user = true, want false")
+	}
+
+	user, _ := stores.Users.GetUserByID(ctx, userID)
+	if !user.DeletedAt.Valid {
+		t.Errorf("scheduled user has no deletion time")
+	}
+
+	// Keys stop working while the account waits for deletion
+	if _, err := stores.APIKeys.UseAPIKey(ctx, "hash-1"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("UseAPIKey of a deleted account: got %v, want pgx.ErrNoRows", err)
+	}
+
+	restored, err := stores.Users.RestoreUser(ctx, userID)
+	if err != nil || !restored {
+		t.Fatalf("RestoreUser = %v, %v; want true", restored, err)
+	}
+	if restored, _ := stores.Users.RestoreUser(ctx, userID); restored {
+		t.Errorf("second RestoreUser = true, want false")
+	}
+	if user, _ := stores.Users.GetUserByID(ctx, userID); user.DeletedAt.Valid {
+		t.Errorf("restored user is still scheduled for deletion")
+	}
+	if _, err := stores.APIKeys.UseAPIKey(ctx, "hash-1"); err != nil {
+		t.Errorf("UseAPIKey of a restored account: %v", err)
+	}
+}
+
+func testPurgeDeletedUsers(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	leaving := createUser(t, stores, "leaving@example.com")
+	staying := createUser(t, stores, "staying@example.com")
+	movieID := createMovie(t, stores, testMovie("Heat"))
+
+	if _, err := stores.Users.RateMovie(ctx, leaving, movieID, 1); err != nil {
+		t.Fatalf("RateMovie: %v", err)
+	}
+	if _, err := stores.Users.RateMovie(ctx, staying, movieID, 5); err != nil {
+		t.Fatalf("RateMovie: %v", err)
+	}
+	if err := stores.Users.LikeMovie(ctx, leaving, movieID); err != nil {
+		t.Fatalf("LikeMovie: %v", err)
+	}
+	review, err := stores.Reviews.CreateReview(ctx, staying, movieID, "Great heist movie.")
+	if err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+	if _, err := stores.Reviews.SetReviewStatus(ctx, int(review.ID), domain.ReviewStatusApproved, leaving); err != nil {
+		t.Fatalf("ModerateReview: %v", err)
+	}
+	if _, err := stores.Reviews.CreateReview(ctx, leaving, movieID, "Overrated."); err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+	if _, err := stores.Lists.CreateUserList(ctx, leaving, "Heists", "", domain.ListVisibilityPublic, "token-1"); err != nil {
+		t.Fatalf("CreateUserList: %v", err)
+	}
+
+	if _, err := stores.Users.ScheduleDeletion(ctx, leaving); err != nil {
+		t.Fatalf("ScheduleDeletion: %v", err)
+	}
+
+	// Within the grace period nothing is purged
+	purged, movieIDs, err := stores.Users.PurgeDeletedUsers(ctx, time.Hour)
+	if err != nil || purged != 0 || len(movieIDs) != 0 {
+		t.Errorf("PurgeDeletedUsers within the grace period = %d, %v, %v; want nothing", purged, movieIDs, err)
+	}
+
+	purged, movieIDs, err = stores.Users.PurgeDeletedUsers(ctx, 0)
+	if err != nil || purged != 1 || len(movieIDs) != 1 || movieIDs[0] != movieID {
+		t.Fatalf("PurgeDeletedUsers = %d, %v, %v; want 1 user and movie %d", purged, movieIDs, err, movieID)
+	}
+
+	if _, err := stores.Users.GetUserByID(ctx, leaving); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetUserByID of a purged user: got %v, want pgx.ErrNoRows", err)
+	}
+	if _, err := stores.Users.GetUserByID(ctx, staying); err != nil {
+		t.Errorf("GetUserByID of a remaining user: %v", err)
+	}
+
+	// The purged user's rating no longer counts
+	movie, _ := stores.Movies.GetMovieByID(ctx, movieID)
+	assertRating(t, db.RecomputeMovieRatingRow{UserRating: movie.UserRating, RatingCount: movie.RatingCount}, 5, 1)
+	if liked, _ := stores.Movies.IsMovieLikedByUser(ctx, movieID, leaving); liked {
+		t.Errorf("like survived the user")
+	}
+	if list, err := stores.Lists.GetUserListByShareToken(ctx, "token-1"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetUserListByShareToken after purge = %+v, %v; want pgx.ErrNoRows", list, err)
+	}
+
+	// Reviews the user moderated stay, without a moderator
+	moderated, err := stores.Reviews.GetReviewByID(ctx, int(review.ID))
+	if err != nil || moderated.Status != domain.ReviewStatusApproved || moderated.ModeratedBy.Valid {
+		t.Errorf("moderated review after purge = %+v, %v", moderated, err)
+	}
+	if pending, _ := stores.Reviews.ListReviewsByStatus(ctx, domain.ReviewStatusPending, 0, 10); len(pending) != 0 {
+		t.Errorf("the purged user's review survived: %+v", pending)
+	}
+}
+
+func testUserTokens(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+
+	created, err := stores.Tokens.CreateUserToken(ctx, userID, domain.TokenPurposePasswordReset, "hash-1", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateUserToken: %v", err)
+	}
+	if int(created.UserID) != userID || created.UsedAt.Valid || !created.ExpiresAt.Time.After(created.CreatedAt.Time) {
+		t.Errorf("CreateUserToken = %+v", created)
+	}
+
+	_, err = stores.Tokens.CreateUserToken(ctx, userID, domain.TokenPurposePasswordReset, "hash-1", time.Hour)
+	assertPgError(t, err, pgUniqueViolation)
+	_, err = stores.Tokens.CreateUserToken(ctx, userID+100, domain.TokenPurposePasswordReset, "hash-2", time.Hour)
+	assertPgError(t, err, pgForeignKeyViolation)
+	_, err = stores.Tokens.CreateUserToken(ctx, userID, "login", "hash-3", time.Hour)
+	assertPgError(t, err, pgCheckViolation)
+
+	// A token only redeems for its own purpose, and only once
+	if _, err := stores.Tokens.ConsumeUserToken(ctx, domain.TokenPurposeEmailVerification, "hash-1"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("ConsumeUserToken with the wrong purpose: got %v, want pgx.ErrNoRows", err)
+	}
+	consumed, err := stores.Tokens.ConsumeUserToken(ctx, domain.TokenPurposePasswordReset, "hash-1")
+	if err != nil || consumed.ID != created.ID || !consumed.UsedAt.Valid {
+		t.Errorf("ConsumeUserToken = %+v, %v", consumed, err)
+	}
+	if _, err := stores.Tokens.ConsumeUserToken(ctx, domain.TokenPurposePasswordReset, "hash-1"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("second ConsumeUserToken: got %v, want pgx.ErrNoRows", err)
+	}
+
+	// Revoking supersedes only the outstanding tokens of that purpose
+	for _, token := range []struct{ purpose, hash string }{
+		{domain.TokenPurposePasswordReset, "hash-4"},
+		{domain.TokenPurposePasswordReset, "hash-5"},
+		{domain.TokenPurposeEmailVerification, "hash-6"},
+	} {
+		if _, err := stores.Tokens.CreateUserToken(ctx, userID, token.purpose, token.hash, time.Hour); err != nil {
+			t.Fatalf("CreateUserToken %s: %v", token.hash, err)
+		}
+	}
+	if err := stores.Tokens.RevokeUserTokens(ctx, userID, domain.TokenPurposePasswordReset); err != nil {
+		t.Fatalf("RevokeUserTokens: %v", err)
+	}
+	for _, hash := range []string{"hash-4", "hash-5"} {
+		if _, err := stores.Tokens.ConsumeUserToken(ctx, domain.TokenPurposePasswordReset, hash); !errors.Is(err, pgx.ErrNoRows) {
+			t.Errorf("ConsumeUserToken of revoked %s: got %v, want pgx.ErrNoRows", hash, err)
+		}
+	}
+	if _, err := stores.Tokens.ConsumeUserToken(ctx, domain.TokenPurposeEmailVerification, "hash-6"); err != nil {
+		t.Errorf("ConsumeUserToken of another purpose after revoke: %v", err)
+	}
+}
+
+func testExpiredUserToken(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+
+	if _, err := stores.Tokens.CreateUserToken(ctx, userID, domain.TokenPurposeEmailVerification, "expired", -time.Minute); err != nil {
+		t.Fatalf("CreateUserToken: %v", err)
+	}
+	if _, err := stores.Tokens.ConsumeUserToken(ctx, domain.TokenPurposeEmailVerification, "expired"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("ConsumeUserToken of an expired token: got %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func testTwoFactorEnrollment(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+
+	// Enabling needs a pending secret
+	if enabled, err := stores.TwoFactor.EnableUserTOTP(ctx, userID, nil); err != nil || enabled {
+		t.Errorf("EnableUserTOTP without a secret = %v, %v; want false", enabled, err)
+	}
+
+	if set, err := stores.TwoFactor.SetUserTOTPSecret(ctx, userID, []byte("first")); err != nil || !set {
+		t.Fatalf("SetUserTOTPSecret = %v, %v", set, err)
+	}
+	// Restarting enrollment replaces the pending secret
+	if set, err := stores.TwoFactor.SetUserTOTPSecret(ctx, userID, []byte("second")); err != nil || !set {
+		t.Fatalf("second SetUserTOTPSecret = %v, %v", set, err)
+	}
+	pending, _ := stores.Users.GetUserByID(ctx, userID)
+	if string(pending.TotpSecret) != "second" || pending.TotpEnabledAt.Valid {
+		t.Errorf("pending user = %q, enabled %v", pending.TotpSecret, pending.TotpEnabledAt.Valid)
+	}
+
+	if enabled, err := stores.TwoFactor.EnableUserTOTP(ctx, userID, []string{"code-1", "code-2"}); err != nil || !enabled {
+		t.Fatalf("EnableUserTOTP = %v, %v", enabled, err)
+	}
+	if enabled, err := stores.TwoFactor.EnableUserTOTP(ctx, userID, nil); err != nil || enabled {
+		t.Errorf("second EnableUserTOTP = %v, %v; want false", enabled, err)
+	}
+	if set, err := stores.TwoFactor.SetUserTOTPSecret(ctx, userID, []byte("third")); err != nil || set {
+		t.Errorf("SetUserTOTPSecret while enabled = %v, %v; want false", set, err)
+	}
+	if count, err := stores.TwoFactor.CountUnusedUserRecoveryCodes(ctx, userID); err != nil || count != 2 {
+		t.Errorf("CountUnusedUserRecoveryCodes = %d, %v; want 2", count, err)
+	}
+
+	// Each time step is accepted once, and never after a later one
+	for _, step := range []struct {
+		step int64
+		want bool
+	}{{100, true}, {100, false}, {99, false}, {101, true}} {
+		if marked, err := stores.TwoFactor.MarkUserTOTPStepUsed(ctx, userID, step.step); err != nil || marked != step.want {
+			t.Errorf("MarkUserTOTPStepUsed(%d) = %v, %v; want %v", step.step, marked, err, step.want)
+		}
+	}
+
+	if disabled, err := stores.TwoFactor.DisableUserTOTP(ctx, userID); err != nil || !disabled {
+		t.Fatalf("DisableUserTOTP = %v, %v", disabled, err)
+	}
+	disabled, _ := stores.Users.GetUserByID(ctx, userID)
+	if disabled.TotpSecret != nil || disabled.TotpEnabledAt.Valid || disabled.TotpLastUsedStep.Valid {
+		t.Errorf("disabled user still has two-factor state: %+v", disabled)
+	}
+	if count, _ := stores.TwoFactor.CountUnusedUserRecoveryCodes(ctx, userID); count != 0 {
+		t.Errorf("%d recovery codes left after disabling", count)
+	}
+	if disabled, err := stores.TwoFactor.DisableUserTOTP(ctx, userID+100); err != nil || disabled {
+		t.Errorf("DisableUserTOTP of unknown user = %v, %v; want false", disabled, err)
+	}
+}
+
+func testRecoveryCodes(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+	otherID := createUser(t, stores, "grace@example.com")
+
+	if err := stores.TwoFactor.ReplaceUserRecoveryCodes(ctx, userID, []string{"code-1", "code-2"}); err != nil {
+		t.Fatalf("ReplaceUserRecoveryCodes: %v", err)
+	}
+	err := stores.TwoFactor.ReplaceUserRecoveryCodes(ctx, userID+100, []string{"code-1"})
+	assertPgError(t, err, pgForeignKeyViolation)
+
+	if consumed, err := stores.TwoFactor.ConsumeUserRecoveryCode(ctx, otherID, "code-1"); err != nil || consumed {
+		t.Errorf("ConsumeUserRecoveryCode of another user's code = %v, %v; want false", consumed, err)
+	}
+	if consumed, err := stores.TwoFactor.ConsumeUserRecoveryCode(ctx, userID, "code-1"); err != nil || !consumed {
+		t.Errorf("ConsumeUserRecoveryCode = %v, %v; want true", consumed, err)
+	}
+	if consumed, err := stores.TwoFactor.ConsumeUserRecoveryCode(ctx, userID, "code-1"); err != nil || consumed {
+		t.Errorf("second ConsumeUserRecoveryCode = %v, %v; want false", consumed, err)
+	}
+	if count, _ := stores.TwoFactor.CountUnusedUserRecoveryCodes(ctx, userID); count != 1 {
+		t.Errorf("%d unused recovery codes, want 1", count)
+	}
+
+	// Replacing invalidates the old codes
+	if err := stores.TwoFactor.ReplaceUserRecoveryCodes(ctx, userID, []string{"code-3"}); err != nil {
+		t.Fatalf("second ReplaceUserRecoveryCodes: %v", err)
+	}
+	if consumed, _ := stores.TwoFactor.ConsumeUserRecoveryCode(ctx, userID, "code-2"); consumed {
+		t.Errorf("a replaced recovery code was accepted")
+	}
+	if consumed, _ := stores.TwoFactor.ConsumeUserRecoveryCode(ctx, userID, "code-3"); !consumed {
+		t.Errorf("the new recovery code was rejected")
+	}
+}
+
+func testAPIKeys(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+	otherID := createUser(t, stores, "grace@example.com")
+
+	first, err := stores.APIKeys.CreateAPIKey(ctx, userID, "Scripts", "msk_0001", "hash-1", []string{domain.APIKeyScopeRead})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if int(first.UserID) != userID || first.Name != "Scripts" || first.LastUsedAt.Valid ||
+		len(first.Scopes) != 1 || first.Scopes[0] != domain.APIKeyScopeRead {
+		t.Errorf("CreateAPIKey = %+v", first)
+	}
+	second, err := stores.APIKeys.CreateAPIKey(ctx, userID, "Bot", "msk_0002", "hash-2",
+		[]string{domain.APIKeyScopeLikes, domain.APIKeyScopeRead})
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	_, err = stores.APIKeys.CreateAPIKey(ctx, otherID, "Copy", "msk_0001", "hash-1", []string{domain.APIKeyScopeRead})
+	assertPgError(t, err, pgUniqueViolation)
+	_, err = stores.APIKeys.CreateAPIKey(ctx, otherID+100, "Orphan", "msk_0003", "hash-3", []string{domain.APIKeyScopeRead})
+	assertPgError(t, err, pgForeignKeyViolation)
+	_, err = stores.APIKeys.CreateAPIKey(ctx, userID, "Root", "msk_0004", "hash-4", []string{"root"})
+	assertPgError(t, err, pgCheckViolation)
+	_, err = stores.APIKeys.CreateAPIKey(ctx, userID, "None", "msk_0005", "hash-5", []string{})
+	assertPgError(t, err, pgCheckViolation)
+
+	keys, err := stores.APIKeys.ListAPIKeys(ctx, userID)
+	if err != nil || len(keys) != 2 || keys[0].ID != second.ID || keys[1].ID != first.ID {
+		t.Errorf("ListAPIKeys = %+v, %v; want newest first", keys, err)
+	}
+	if keys, _ := stores.APIKeys.ListAPIKeys(ctx, otherID); len(keys) != 0 {
+		t.Errorf("ListAPIKeys of another user = %+v, want none", keys)
+	}
+
+	used, err := stores.APIKeys.UseAPIKey(ctx, "hash-1")
+	if err != nil || used.ID != first.ID || !used.LastUsedAt.Valid {
+		t.Errorf("UseAPIKey = %+v, %v", used, err)
+	}
+	if _, err := stores.APIKeys.UseAPIKey(ctx, "hash-unknown"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("UseAPIKey of an unknown key: got %v, want pgx.ErrNoRows", err)
+	}
+
+	// Only the owner can delete a key
+	if deleted, err := stores.APIKeys.DeleteAPIKey(ctx, otherID, int(first.ID)); err != nil || deleted {
+		t.Errorf("DeleteAPIKey by another user = %v, %v; want false", deleted, err)
+	}
+	if deleted, err := stores.APIKeys.DeleteAPIKey(ctx, userID, int(first.ID)); err != nil || !deleted {
+		t.Errorf("DeleteAPIKey = %v, %v; want true", deleted, err)
+	}
+	if _, err := stores.APIKeys.UseAPIKey(ctx, "hash-1"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("UseAPIKey of a deleted key: got %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func testCreateUserWithIdentity(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	created, err := stores.Identities.CreateUserWithIdentity(ctx, "Ada", "Lovelace", "ada@example.com", "", true, "github", "42")
+	if err != nil {
+		t.Fatalf("CreateUserWithIdentity: %v", err)
+	}
+	if created.Password.Valid || !created.EmailVerifiedAt.Valid || created.Role != domain.RoleUser {
+		t.Errorf("CreateUserWithIdentity = %+v", created)
+	}
+	identity, err := stores.Identities.GetUserIdentity(ctx, "github", "42")
+	if err != nil || identity.UserID != created.ID || identity.Email != "ada@example.com" {
+		t.Errorf("GetUserIdentity = %+v, %v", identity, err)
+	}
+
+	unverified, err := stores.Identities.CreateUserWithIdentity(ctx, "Grace", "Hopper", "grace@example.com", "", false, "github", "43")
+	if err != nil || unverified.EmailVerifiedAt.Valid {
+		t.Errorf("CreateUserWithIdentity of an unverified email = %+v, %v", unverified, err)
+	}
+
+	// Neither a taken email nor a taken identity leaves a user behind
+	_, err = stores.Identities.CreateUserWithIdentity(ctx, "Ada", "Again", "ada@example.com", "", true, "google", "7")
+	assertPgError(t, err, pgUniqueViolation)
+	_, err = stores.Identities.CreateUserWithIdentity(ctx, "Alan", "Turing", "alan@example.com", "", true, "github", "42")
+	assertPgError(t, err, pgUniqueViolation)
+	if _, err := stores.Users.GetUserByEmail(ctx, "alan@example.com"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetUserByEmail after a failed CreateUserWithIdentity: got %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func testIdentities(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+	otherID := createUser(t, stores, "grace@example.com")
+
+	if _, err := stores.Identities.GetUserIdentity(ctx, "github", "42"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetUserIdentity before linking: got %v, want pgx.ErrNoRows", err)
+	}
+
+	github, err := stores.Identities.CreateUserIdentity(ctx, userID, "github", "42", "ada@users.github.com")
+	if err != nil {
+		t.Fatalf("CreateUserIdentity: %v", err)
+	}
+	google, err := stores.Identities.CreateUserIdentity(ctx, userID, "google", "42", "ada@gmail.com")
+	if err != nil {
+		t.Fatalf("CreateUserIdentity of another provider with the same subject: %v", err)
+	}
+
+	// An account at a provider signs in to one user, and a user has one account per provider
+	_, err = stores.Identities.CreateUserIdentity(ctx, otherID, "github", "42", "grace@example.com")
+	assertPgError(t, err, pgUniqueViolation)
+	_, err = stores.Identities.CreateUserIdentity(ctx, userID, "github", "43", "ada@example.com")
+	assertPgError(t, err, pgUniqueViolation)
+	_, err = stores.Identities.CreateUserIdentity(ctx, otherID+100, "github", "44", "nobody@example.com")
+	assertPgError(t, err, pgForeignKeyViolation)
+
+	identities, err := stores.Identities.ListUserIdentities(ctx, userID)
+	if err != nil || len(identities) != 2 || identities[0].ID != github.ID || identities[1].ID != google.ID {
+		t.Errorf("ListUserIdentities = %+v, %v", identities, err)
+	}
+	if identities, _ := stores.Identities.ListUserIdentities(ctx, otherID); len(identities) != 0 {
+		t.Errorf("ListUserIdentities of another user = %+v, want none", identities)
+	}
+
+	if deleted, err := stores.Identities.DeleteUserIdentity(ctx, otherID, "github"); err != nil || deleted {
+		t.Errorf("DeleteUserIdentity by another user = %v, %v; want false", deleted, err)
+	}
+	if deleted, err := stores.Identities.DeleteUserIdentity(ctx, userID, "github"); err != nil || !deleted {
+		t.Errorf("DeleteUserIdentity = %v, %v; want true", deleted, err)
+	}
+	if _, err := stores.Identities.GetUserIdentity(ctx, "github", "42"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetUserIdentity after unlinking: got %v, want pgx.ErrNoRows", err)
+	}
+
+	// An unlinked account can be linked again, to anyone
+	if _, err := stores.Identities.CreateUserIdentity(ctx, otherID, "github", "42", "grace@example.com"); err != nil {
+		t.Errorf("CreateUserIdentity after unlinking: %v", err)
+	}
+}
+
+func testDataExports(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+	otherID := createUser(t, stores, "grace@example.com")
+
+	if _, err := stores.DataExports.ClaimExport(ctx, time.Minute); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("ClaimExport without exports: got %v, want pgx.ErrNoRows", err)
+	}
+
+	first, err := stores.DataExports.CreateExport(ctx, userID)
+	if err != nil {
+		t.Fatalf("CreateExport: %v", err)
+	}
+	if int(first.UserID) != userID || first.Status != domain.DataExportStatusPending || first.TokenHash.Valid ||
+		first.ClaimedAt.Valid || first.ExpiresAt.Valid {
+		t.Errorf("CreateExport = %+v", first)
+	}
+
+	// A user has one export pending at a time
+	_, err = stores.DataExports.CreateExport(ctx, userID)
+	assertPgError(t, err, pgUniqueViolation)
+	_, err = stores.DataExports.CreateExport(ctx, otherID+100)
+	assertPgError(t, err, pgForeignKeyViolation)
+	second, err := stores.DataExports.CreateExport(ctx, otherID)
+	if err != nil {
+		t.Fatalf("CreateExport of another user: %v", err)
+	}
+
+	if got, err := stores.DataExports.GetExport(ctx, userID, int(first.ID)); err != nil || got.ID != first.ID {
+		t.Errorf("GetExport = %+v, %v", got, err)
+	}
+	if _, err := stores.DataExports.GetExport(ctx, otherID, int(first.ID)); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetExport by another user: got %v, want pgx.ErrNoRows", err)
+	}
+
+	// Exports are claimed oldest first, and a claimed export is not claimed again until the claim times out
+	claimed, err := stores.DataExports.ClaimExport(ctx, time.Minute)
+	if err != nil || claimed.ID != first.ID || !claimed.ClaimedAt.Valid {
+		t.Fatalf("ClaimExport = %+v, %v; want the first export", claimed, err)
+	}
+	if claimed, err := stores.DataExports.ClaimExport(ctx, time.Minute); err != nil || claimed.ID != second.ID {
+		t.Fatalf("second ClaimExport = %+v, %v; want the second export", claimed, err)
+	}
+	if _, err := stores.DataExports.ClaimExport(ctx, time.Minute); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("ClaimExport with every export claimed: got %v, want pgx.ErrNoRows", err)
+	}
+	if claimed, err := stores.DataExports.ClaimExport(ctx, -time.Minute); err != nil || claimed.ID != first.ID {
+		t.Errorf("ClaimExport after the claims timed out = %+v, %v; want the first export", claimed, err)
+	}
+
+	if completed, err := stores.DataExports.CompleteExport(ctx, int(first.ID), "hash-1", []byte("archive"), time.Hour); err != nil || !completed {
+		t.Fatalf("CompleteExport = %v, %v", completed, err)
+	}
+	if completed, err := stores.DataExports.CompleteExport(ctx, int(first.ID), "hash-2", []byte("again"), time.Hour); err != nil || completed {
+		t.Errorf("CompleteExport of a ready export = %v, %v; want false", completed, err)
+	}
+	ready, err := stores.DataExports.GetExportByTokenHash(ctx, "hash-1")
+	if err != nil || ready.ID != first.ID || ready.Status != domain.DataExportStatusReady ||
+		string(ready.Archive) != "archive" || !ready.CompletedAt.Valid || !ready.ExpiresAt.Valid {
+		t.Errorf("GetExportByTokenHash = %+v, %v", ready, err)
+	}
+	if _, err := stores.DataExports.GetExportByTokenHash(ctx, "hash-2"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetExportByTokenHash of an unknown token: got %v, want pgx.ErrNoRows", err)
+	}
+
+	// Once the first export is ready the user can request another
+	if _, err := stores.DataExports.CreateExport(ctx, userID); err != nil {
+		t.Errorf("CreateExport after the first is ready: %v", err)
+	}
+
+	if failed, err := stores.DataExports.FailExport(ctx, int(second.ID), time.Hour); err != nil || !failed {
+		t.Fatalf("FailExport = %v, %v", failed, err)
+	}
+	if failed, err := stores.DataExports.FailExport(ctx, int(first.ID), time.Hour); err != nil || failed {
+		t.Errorf("FailExport of a ready export = %v, %v; want false", failed, err)
+	}
+	if got, _ := stores.DataExports.GetExport(ctx, otherID, int(second.ID)); got.Status != domain.DataExportStatusFailed || !got.ExpiresAt.Valid {
+		t.Errorf("failed export = %+v", got)
+	}
+}
+
+func testExpiredDataExport(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+
+	expired, err := stores.DataExports.CreateExport(ctx, userID)
+	if err != nil {
+		t.Fatalf("CreateExport: %v", err)
+	}
+	if _, err := stores.DataExports.CompleteExport(ctx, int(expired.ID), "expired", []byte("archive"), -time.Minute); err != nil {
+		t.Fatalf("CompleteExport: %v", err)
+	}
+	if _, err := stores.DataExports.GetExportByTokenHash(ctx, "expired"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetExportByTokenHash of an expired export: got %v, want pgx.ErrNoRows", err)
+	}
+
+	pending, err := stores.DataExports.CreateExport(ctx, userID)
+	if err != nil {
+		t.Fatalf("CreateExport: %v", err)
+	}
+
+	if deleted, err := stores.DataExports.DeleteExpiredExports(ctx); err != nil || deleted != 1 {
+		t.Errorf("DeleteExpiredExports = %d, %v; want 1", deleted, err)
+	}
+	if _, err := stores.DataExports.GetExport(ctx, userID, int(expired.ID)); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetExport of a deleted export: got %v, want pgx.ErrNoRows", err)
+	}
+	if _, err := stores.DataExports.GetExport(ctx, userID, int(pending.ID)); err != nil {
+		t.Errorf("GetExport of a pending export: %v", err)
+	}
+}
+
+func testDataExportContents(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "ada@example.com")
+	otherID := createUser(t, stores, "grace@example.com")
+	alien := createMovie(t, stores, testMovie("Alien"))
+	heat := createMovie(t, stores, testMovie("Heat"))
+
+	for _, movieID := range []int{heat, alien} {
+		if err := stores.Users.LikeMovie(ctx, userID, movieID); err != nil {
+			t.Fatalf("LikeMovie: %v", err)
+		}
+	}
+	if err := stores.Users.LikeMovie(ctx, otherID, alien); err != nil {
+		t.Fatalf("LikeMovie: %v", err)
+	}
+	if _, err := stores.Users.RateMovie(ctx, userID, alien, 5); err != nil {
+		t.Fatalf("RateMovie: %v", err)
+	}
+	review, err := stores.Reviews.CreateReview(ctx, userID, heat, "A classic")
+	if err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+
+	likes, err := stores.DataExports.ListUserLikes(ctx, userID)
+	if err != nil || len(likes) != 2 || int(likes[0].MovieID) != heat || likes[0].Title != "Heat" ||
+		int(likes[1].MovieID) != alien || !likes[0].LikedAt.Valid {
+		t.Errorf("ListUserLikes = %+v, %v; want Heat then Alien", likes, err)
+	}
+
+	ratings, err := stores.DataExports.ListUserRatings(ctx, userID)
+	if err != nil || len(ratings) != 1 || int(ratings[0].MovieID) != alien || ratings[0].Title != "Alien" || ratings[0].Rating != 5 {
+		t.Errorf("ListUserRatings = %+v, %v", ratings, err)
+	}
+	if ratings, _ := stores.DataExports.ListUserRatings(ctx, otherID); len(ratings) != 0 {
+		t.Errorf("ListUserRatings of another user = %+v, want none", ratings)
+	}
+
+	// Reviews are exported whatever their moderation status
+	reviews, err := stores.DataExports.ListUserReviews(ctx, userID)
+	if err != nil || len(reviews) != 1 || reviews[0].ID != review.ID || reviews[0].Title != "Heat" ||
+		reviews[0].Body != "A classic" || reviews[0].Status != domain.ReviewStatusPending {
+		t.Errorf("ListUserReviews = %+v, %v", reviews, err)
+	}
+}
+
+func testAuditEvents(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	events := []db.CreateAuditEventParams{
+		{ActorID: 1, Action: domain.AuditActionCreate, EntityType: domain.AuditEntityMovie, EntityID: 10,
+			RequestID: "req-1", Ip: "192.0.2.1", After: []byte(`{"title": "Alien"}`)},
+		{ActorID: 2, Action: domain.AuditActionUpdate, EntityType: domain.AuditEntityMovie, EntityID: 10,
+			RequestID: "req-2", Ip: "192.0.2.2", Before: []byte(`{"title": "Alien"}`), After: []byte(`{"title": "Aliens"}`)},
+		{ActorID: 1, Action: domain.AuditActionDelete, EntityType: domain.AuditEntityPerson, EntityID: 20,
+			RequestID: "req-3", Ip: "192.0.2.1", Before: []byte(`{"name": "Ridley Scott"}`)},
+	}
+	var created []db.AuditEvent
+	for _, params := range events {
+		event, err := stores.Audit.CreateAuditEvent(ctx, params)
+		if err != nil {
+			t.Fatalf("CreateAuditEvent: %v", err)
+		}
+		created = append(created, event)
+	}
+
+	update := created[1]
+	if update.ActorID != 2 || update.Action != domain.AuditActionUpdate || update.EntityType != domain.AuditEntityMovie ||
+		update.EntityID != 10 || update.RequestID != "req-2" || update.Ip != "192.0.2.2" || !update.CreatedAt.Valid {
+		t.Errorf("CreateAuditEvent = %+v", update)
+	}
+	assertJSONEqual(t, "Before", update.Before, `{"title": "Alien"}`)
+	assertJSONEqual(t, "After", update.After, `{"title": "Aliens"}`)
+	if created[0].Before != nil || created[2].After != nil {
+		t.Errorf("missing sides of a create and a delete = %s, %s; want NULL", created[0].Before, created[2].After)
+	}
+
+	first, second, third := created[0].ID, created[1].ID, created[2].ID
+	now := time.Now().UTC()
+
+	cases := []struct {
+		name     string
+		filter   domain.AuditFilter
+		beforeID int
+		limit    int
+		want     []int32
+	}{
+		{"all newest first", domain.AuditFilter{}, 0, 10, []int32{third, second, first}},
+		{"limit", domain.AuditFilter{}, 0, 2, []int32{third, second}},
+		{"before cursor", domain.AuditFilter{}, int(second), 10, []int32{first}},
+		{"actor", domain.AuditFilter{ActorID: 1}, 0, 10, []int32{third, first}},
+		{"action", domain.AuditFilter{Action: domain.AuditActionUpdate}, 0, 10, []int32{second}},
+		{"entity", domain.AuditFilter{EntityType: domain.AuditEntityMovie, EntityID: 10}, 0, 10, []int32{second, first}},
+		{"since", domain.AuditFilter{Since: now.Add(-time.Hour)}, 0, 10, []int32{third, second, first}},
+		{"until", domain.AuditFilter{Until: now.Add(-time.Hour)}, 0, 10, []int32{}},
+		{"no match", domain.AuditFilter{EntityType: domain.AuditEntityPerson, EntityID: 10}, 0, 10, []int32{}},
+	}
+	for _, tc := range cases {
+		got, err := stores.Audit.ListAuditEvents(ctx, tc.filter, tc.beforeID, tc.limit)
+		if err != nil {
+			t.Fatalf("ListAuditEvents %s: %v", tc.name, err)
+		}
+		if gotIDs := auditEventIDs(got); !slices.Equal(gotIDs, tc.want) {
+			t.Errorf("ListAuditEvents %s = %v, want %v", tc.name, gotIDs, tc.want)
+		}
+	}
+}
+
+func testTransactions(t *testing.T, stores Stores) {
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	// write creates a movie and its audit event, which the transaction sees before it ends
+	write := func(title string, hooks *int, result error) error {
+		return stores.Transactor.InTransaction(ctx, func(ctx context.Context) error {
+			movie, err := stores.Movies.CreateMovieWithGenres(ctx, testMovie(title))
+			if err != nil {
+				return err
+			}
+			if got, err := stores.Movies.GetMovieByID(ctx, int(movie.ID)); err != nil || got.Title != title {
+				t.Errorf("GetMovieByID in the transaction = %+v, %v", got, err)
+			}
+			_, err = stores.Audit.CreateAuditEvent(ctx, db.CreateAuditEventParams{
+				ActorID: 1, Action: domain.AuditActionCreate, EntityType: domain.AuditEntityMovie, EntityID: movie.ID,
+			})
+			if err != nil {
+				return err
+			}
+			repository.AfterCommit(ctx, func(context.Context) { *hooks++ })
+			return result
+		})
+	}
+
+	var rolledBackHooks int
+	if err := write("Alien", &rolledBackHooks, errRollback); !errors.Is(err, errRollback) {
+		t.Fatalf("InTransaction = %v, want errRollback", err)
+	}
+	if movies, err := stores.Movies.ListMovies(ctx); err != nil || len(movies) != 0 {
+		t.Errorf("movies after rollback = %+v, %v", movies, err)
+	}
+	if events, err := stores.Audit.ListAuditEvents(ctx, domain.AuditFilter{}, 0, 10); err != nil || len(events) != 0 {
+		t.Errorf("audit events after rollback = %+v, %v", events, err)
+	}
+	if rolledBackHooks != 0 {
+		t.Errorf("AfterCommit ran %d times after a rollback", rolledBackHooks)
+	}
+
+	var committedHooks int
+	if err := write("Heat", &committedHooks, nil); err != nil {
+		t.Fatalf("InTransaction: %v", err)
+	}
+	if movies, err := stores.Movies.ListMovies(ctx); err != nil || len(movies) != 1 || movies[0].Title != "Heat" {
+		t.Errorf("movies after commit = %+v, %v", movies, err)
+	}
+	if events, err := stores.Audit.ListAuditEvents(ctx, domain.AuditFilter{}, 0, 10); err != nil || len(events) != 1 {
+		t.Errorf("audit events after commit = %+v, %v", events, err)
+	}
+	if committedHooks != 1 {
+		t.Errorf("AfterCommit ran %d times after a commit, want 1", committedHooks)
+	}
+}
+
+// assertJSONEqual compares JSON documents by value; PostgreSQL normalizes the spacing and key order of JSONB
+func assertJSONEqual(t *testing.T, name string, got []byte, want string) {
+	t.Helper()
+
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Errorf("%s = %s: %v", name, got, err)
+		return
+	}
+	json.Unmarshal([]byte(want), &wantValue)
+	gotJSON, _ := json.Marshal(gotValue)
+	wantJSON, _ := json.Marshal(wantValue)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("%s = %s, want %s", name, gotJSON, wantJSON)
+	}
+}
+
+func testLikeIdempotency(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "fan@example.com")
+	movieID := createMovie(t, stores, testMovie("Alien"))
+
+	for i := 0; i < 2; i++ {
+		if err := stores.Users.LikeMovie(ctx, userID, movieID); err != nil {
+			t.Fatalf("LikeMovie #%d: %v", i+1, err)
+		}
+	}
+
+	liked, err := stores.Movies.IsMovieLikedByUser(ctx, movieID, userID)
+	if err != nil || !liked {
+		t.Errorf("IsMovieLikedByUser = %v, %v; want true", liked, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := stores.Users.UnlikeMovie(ctx, userID, movieID); err != nil {
+			t.Fatalf("UnlikeMovie #%d: %v", i+1, err)
+		}
+	}
+
+	liked, err = stores.Movies.IsMovieLikedByUser(ctx, movieID, userID)
+	if err != nil || liked {
+		t.Errorf("IsMovieLikedByUser after unlike = %v, %v; want false", liked, err)
+	}
+}
+
+func testLikeUnknownMovie(t *testing.T, stores Stores) {
+	userID := createUser(t, stores, "fan@example.com")
+
+	err := stores.Users.LikeMovie(context.Background(), userID, 424242)
+	assertPgError(t, err, pgForeignKeyViolation)
+}
+
+func testRatings(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	alice := createUser(t, stores, "alice@example.com")
+	bob := createUser(t, stores, "bob@example.com")
+	movieID := createMovie(t, stores, testMovie("Heat"))
+
+	stats, err := stores.Users.RateMovie(ctx, alice, movieID, 4)
+	if err != nil {
+		t.Fatalf("RateMovie: %v", err)
+	}
+	assertRating(t, stats, 4, 1)
+
+	stats, err = stores.Users.RateMovie(ctx, bob, movieID, 5)
+	if err != nil {
+		t.Fatalf("RateMovie: %v", err)
+	}
+	assertRating(t, stats, 4.5, 2)
+
+	// Rating again replaces the user's previous rating
+	stats, err = stores.Users.RateMovie(ctx, alice, movieID, 2)
+	if err != nil {
+		t.Fatalf("RateMovie again: %v", err)
+	}
+	assertRating(t, stats, 3.5, 2)
+
+	if rating, _ := stores.Movies.GetUserMovieRating(ctx, movieID, alice); rating != 2 {
+		t.Errorf("GetUserMovieRating = %d, want 2", rating)
+	}
+
+	movie, _ := stores.Movies.GetMovieByID(ctx, movieID)
+	assertRating(t, db.RecomputeMovieRatingRow{UserRating: movie.UserRating, RatingCount: movie.RatingCount}, 3.5, 2)
+
+	stats, err = stores.Users.UnrateMovie(ctx, bob, movieID)
+	if err != nil {
+		t.Fatalf("UnrateMovie: %v", err)
+	}
+	assertRating(t, stats, 2, 1)
+
+	// Without ratings the aggregate falls back to the editorial rating, which movies created through the API lack
+	stats, err = stores.Users.UnrateMovie(ctx, alice, movieID)
+	if err != nil {
+		t.Fatalf("UnrateMovie: %v", err)
+	}
+	if stats.UserRating.Valid || stats.RatingCount != 0 {
+		t.Errorf("rating without raters = %+v, want NULL and 0", stats)
+	}
+
+	if rating, _ := stores.Movies.GetUserMovieRating(ctx, movieID, alice); rating != 0 {
+		t.Errorf("GetUserMovieRating after unrate = %d, want 0", rating)
+	}
+
+	if _, err := stores.Users.RateMovie(ctx, alice, movieID+100, 3); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("RateMovie of unknown movie: got %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func testCreateMovieWithGenres(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	movie := testMovie("Aliens")
+	movie.Genres = []*domain.Genre{{ID: genreID(t, stores, "Action")}, {ID: genreID(t, stores, "Sci-Fi")}}
+	movieID := createMovie(t, stores, movie)
+
+	dbMovie, err := stores.Movies.GetMovieByID(ctx, movieID)
+	if err != nil {
+		t.Fatalf("GetMovieByID: %v", err)
+	}
+	if dbMovie.Title != "Aliens" || int(dbMovie.Runtime.Int32) != movie.RunTime || dbMovie.Video.String != movie.Video {
+		t.Errorf("GetMovieByID = %+v", dbMovie)
+	}
+	if !dbMovie.ReleaseDate.Time.Equal(movie.ReleaseDate) {
+		t.Errorf("release date = %v, want %v", dbMovie.ReleaseDate.Time, movie.ReleaseDate)
+	}
+
+	if got := genreNames(t, stores, movieID); !equalStrings(got, []string{"Action", "Sci-Fi"}) {
+		t.Errorf("genres = %v, want [Action Sci-Fi]", got)
+	}
+
+	if _, err := stores.Movies.GetMovieByID(ctx, movieID+100); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetMovieByID of unknown movie: got %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func testCreateMovieWithUnknownGenre(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	movie := testMovie("Nowhere")
+	movie.Genres = []*domain.Genre{{ID: 424242}}
+	_, err := stores.Movies.CreateMovieWithGenres(ctx, movie)
+	assertPgError(t, err, pgForeignKeyViolation)
+
+	// The movie is rolled back with its genres
+	movies, err := stores.Movies.ListMovies(ctx)
+	if err != nil || len(movies) != 0 {
+		t.Errorf("ListMovies = %d movies, %v; want none", len(movies), err)
+	}
+}
+
+func testUpdateMovie(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	movieID := createMovie(t, stores, testMovie("Draft"))
+
+	update := testMovie("Final")
+	update.ID = movieID
+	update.RunTime = 99
+	update.Image = "final.jpg"
+	update.Video = "final-trailer"
+	if err := stores.Movies.UpdateMovie(ctx, update); err != nil {
+		t.Fatalf("UpdateMovie: %v", err)
+	}
+
+	dbMovie, _ := stores.Movies.GetMovieByID(ctx, movieID)
+	if dbMovie.Title != "Final" || dbMovie.Runtime.Int32 != 99 || dbMovie.Image.String != "final.jpg" || dbMovie.Video.String != "final-trailer" {
+		t.Errorf("updated movie = %+v", dbMovie)
+	}
+
+	update.ID = movieID + 100
+	if err := stores.Movies.UpdateMovie(ctx, update); err != nil {
+		t.Errorf("UpdateMovie of unknown movie: %v", err)
+	}
+}
+
+func testMovieGenres(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	drama := genreID(t, stores, "Drama")
+	crime := genreID(t, stores, "Crime")
+	godfather := createMovie(t, stores, testMovie("The Godfather"))
+	heat := createMovie(t, stores, testMovie("Heat"))
+
+	for _, link := range [][2]int{{godfather, drama}, {godfather, crime}, {heat, crime}} {
+		if err := stores.Movies.AddMovieGenre(ctx, link[0], link[1]); err != nil {
+			t.Fatalf("AddMovieGenre: %v", err)
+		}
+	}
+
+	assertPgError(t, stores.Movies.AddMovieGenre(ctx, heat, 424242), pgForeignKeyViolation)
+	assertPgError(t, stores.Movies.AddMovieGenre(ctx, 424242, crime), pgForeignKeyViolation)
+
+	crimeMovies, err := stores.Movies.ListMoviesByGenre(ctx, crime)
+	if err != nil {
+		t.Fatalf("ListMoviesByGenre: %v", err)
+	}
+	if got := movieTitles(crimeMovies); !equalStrings(got, []string{"Heat", "The Godfather"}) {
+		t.Errorf("crime movies = %v, want [Heat The Godfather]", got)
+	}
+
+	if err := stores.Movies.DeleteMovieGenres(ctx, godfather); err != nil {
+		t.Fatalf("DeleteMovieGenres: %v", err)
+	}
+	if got := genreNames(t, stores, godfather); len(got) != 0 {
+		t.Errorf("genres after DeleteMovieGenres = %v", got)
+	}
+
+	genres, err := stores.Movies.ListGenres(ctx)
+	if err != nil || len(genres) == 0 {
+		t.Fatalf("ListGenres = %d genres, %v", len(genres), err)
+	}
+	if !sort.SliceIsSorted(genres, func(i, j int) bool { return genres[i].Genre < genres[j].Genre }) {
+		t.Errorf("ListGenres is not ordered by name")
+	}
+}
+
+func testDeleteMovieCascades(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "fan@example.com")
+	movie := testMovie("Doomed")
+	movie.Genres = []*domain.Genre{{ID: genreID(t, stores, "Drama")}}
+	movieID := createMovie(t, stores, movie)
+	person := createPerson(t, stores, "Some Actor")
+
+	if err := stores.Users.LikeMovie(ctx, userID, movieID); err != nil {
+		t.Fatalf("LikeMovie: %v", err)
+	}
+	if _, err := stores.Users.RateMovie(ctx, userID, movieID, 3); err != nil {
+		t.Fatalf("RateMovie: %v", err)
+	}
+	if _, err := stores.Movies.CreateMovieCredit(ctx, movieID, domain.Credit{PersonID: person, Role: domain.CreditRoleActor}); err != nil {
+		t.Fatalf("CreateMovieCredit: %v", err)
+	}
+
+	if err := stores.Movies.DeleteMovie(ctx, movieID); err != nil {
+		t.Fatalf("DeleteMovie: %v", err)
+	}
+
+	if _, err := stores.Movies.GetMovieByID(ctx, movieID); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetMovieByID after delete: got %v, want pgx.ErrNoRows", err)
+	}
+	if liked, _ := stores.Movies.IsMovieLikedByUser(ctx, movieID, userID); liked {
+		t.Errorf("like survived the movie")
+	}
+	if rating, _ := stores.Movies.GetUserMovieRating(ctx, movieID, userID); rating != 0 {
+		t.Errorf("rating survived the movie")
+	}
+	if credits, _ := stores.Movies.ListCreditsByMovieID(ctx, movieID); len(credits) != 0 {
+		t.Errorf("credits survived the movie: %+v", credits)
+	}
+	if genres := genreNames(t, stores, movieID); len(genres) != 0 {
+		t.Errorf("genres survived the movie: %v", genres)
+	}
+
+	if err := stores.Movies.DeleteMovie(ctx, movieID); err != nil {
+		t.Errorf("DeleteMovie of deleted movie: %v", err)
+	}
+}
+
+func testCredits(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	movieID := createMovie(t, stores, testMovie("Heat"))
+	mann := createPerson(t, stores, "Michael Mann")
+	pacino := createPerson(t, stores, "Al Pacino")
+	deNiro := createPerson(t, stores, "Robert De Niro")
+
+	credits := []domain.Credit{
+		{PersonID: deNiro, Role: domain.CreditRoleActor, CharacterName: "Neil McCauley", BillingOrder: 2},
+		{PersonID: pacino, Role: domain.CreditRoleActor, CharacterName: "Vincent Hanna", BillingOrder: 1},
+		{PersonID: mann, Role: domain.CreditRoleDirector},
+	}
+	var pacinoCreditID int32
+	for _, credit := range credits {
+		created, err := stores.Movies.CreateMovieCredit(ctx, movieID, credit)
+		if err != nil {
+			t.Fatalf("CreateMovieCredit: %v", err)
+		}
+		if credit.PersonID == pacino {
+			pacinoCreditID = created.ID
+		}
+	}
+
+	_, err := stores.Movies.CreateMovieCredit(ctx, movieID, domain.Credit{PersonID: mann, Role: domain.CreditRoleDirector})
+	assertPgError(t, err, pgUniqueViolation)
+
+	_, err = stores.Movies.CreateMovieCredit(ctx, movieID, domain.Credit{PersonID: 424242, Role: domain.CreditRoleWriter})
+	assertPgError(t, err, pgForeignKeyViolation)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName != "fk_people" {
+		t.Errorf("unknown person violated %q, want fk_people", pgErr.ConstraintName)
+	}
+
+	// Crew first, then the cast in billing order
+	rows, err := stores.Movies.ListCreditsByMovieID(ctx, movieID)
+	if err != nil {
+		t.Fatalf("ListCreditsByMovieID: %v", err)
+	}
+	var names []string
+	for _, row := range rows {
+		names = append(names, row.Name)
+	}
+	if !equalStrings(names, []string{"Michael Mann", "Al Pacino", "Robert De Niro"}) {
+		t.Errorf("credits = %v", names)
+	}
+
+	deleted, err := stores.Movies.DeleteMovieCredit(ctx, movieID+100, int(pacinoCreditID))
+	if err != nil || deleted {
+		t.Errorf("DeleteMovieCredit on another movie = %v, %v; want false", deleted, err)
+	}
+	deleted, err = stores.Movies.DeleteMovieCredit(ctx, movieID, int(pacinoCreditID))
+	if err != nil || !deleted {
+		t.Errorf("DeleteMovieCredit = %v, %v; want true", deleted, err)
+	}
+	if rows, _ := stores.Movies.ListCreditsByMovieID(ctx, movieID); len(rows) != 2 {
+		t.Errorf("%d credits after delete, want 2", len(rows))
+	}
+}
+
+func testFilteredPagination(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	runtimes := map[string]int{"Alpha": 120, "Bravo": 90, "Charlie": 120, "Delta": 150, "Echo": 100}
+	ids := make(map[string]int)
+	for title, runtime := range runtimes {
+		movie := testMovie(title)
+		movie.RunTime = runtime
+		ids[title] = createMovie(t, stores, movie)
+	}
+
+	// Ties on the sort value are broken by ID, so Alpha and Charlie keep their insertion order
+	ascending := []string{"Bravo", "Echo", "Alpha", "Charlie", "Delta"}
+	if ids["Alpha"] > ids["Charlie"] {
+		ascending[2], ascending[3] = "Charlie", "Alpha"
+	}
+
+	page := domain.MoviePageRequest{Sort: domain.MovieSort{Field: domain.SortByRuntime, Direction: domain.SortAsc}, Limit: 2}
+	var titles []string
+	for pages := 0; ; pages++ {
+		if pages > len(runtimes) {
+			t.Fatalf("pagination does not terminate")
+		}
+
+		rows, next, err := stores.Movies.ListFilteredMoviesWithGenres(ctx, domain.MovieFilter{}, page, 0, false)
+		if err != nil {
+			t.Fatalf("ListFilteredMoviesWithGenres: %v", err)
+		}
+		titles = append(titles, rowTitles(rows)...)
+		if next == nil {
+			break
+		}
+
+		// Cursors are handed to clients, so they must survive encoding
+		page.After, err = domain.DecodeMovieCursor(next.Encode())
+		if err != nil {
+			t.Fatalf("DecodeMovieCursor: %v", err)
+		}
+	}
+	if !equalStrings(titles, ascending) {
+		t.Errorf("pages by runtime = %v, want %v", titles, ascending)
+	}
+
+	descending := domain.MoviePageRequest{Sort: domain.MovieSort{Field: domain.SortByTitle, Direction: domain.SortDesc}, Limit: 10}
+	rows, next, err := stores.Movies.ListFilteredMoviesWithGenres(ctx, domain.MovieFilter{}, descending, 0, false)
+	if err != nil {
+		t.Fatalf("ListFilteredMoviesWithGenres: %v", err)
+	}
+	if next != nil {
+		t.Errorf("last page has a next cursor")
+	}
+	if got := rowTitles(rows); !equalStrings(got, []string{"Echo", "Delta", "Charlie", "Bravo", "Alpha"}) {
+		t.Errorf("titles descending = %v", got)
+	}
+
+	invalid := domain.MoviePageRequest{Sort: domain.MovieSort{Field: "budget", Direction: domain.SortAsc}, Limit: 10}
+	if _, _, err := stores.Movies.ListFilteredMoviesWithGenres(ctx, domain.MovieFilter{}, invalid, 0, false); err == nil {
+		t.Errorf("unsupported sort field did not fail")
+	}
+}
+
+func testFilterAndFacets(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	action := genreID(t, stores, "Action")
+	drama := genreID(t, stores, "Drama")
+
+	both := testMovie("Gladiator")
+	both.MPAARating = "R"
+	both.Genres = []*domain.Genre{{ID: action}, {ID: drama}}
+	createMovie(t, stores, both)
+
+	actionOnly := testMovie("Speed")
+	actionOnly.MPAARating = "R"
+	actionOnly.ReleaseDate = time.Date(1994, time.June, 10, 0, 0, 0, 0, time.UTC)
+	actionOnly.Genres = []*domain.Genre{{ID: action}}
+	createMovie(t, stores, actionOnly)
+
+	dramaOnly := testMovie("Up")
+	dramaOnly.MPAARating = "PG"
+	dramaOnly.Genres = []*domain.Genre{{ID: drama}}
+	createMovie(t, stores, dramaOnly)
+
+	page := domain.MoviePageRequest{Sort: domain.MovieSort{Field: domain.SortByTitle, Direction: domain.SortAsc}, Limit: 10}
+	cases := []struct {
+		name   string
+		filter domain.MovieFilter
+		want   []string
+	}{
+		{"any genre", domain.MovieFilter{GenreIDs: []int{action, drama}, GenreMatch: domain.GenreMatchAny}, []string{"Gladiator", "Speed", "Up"}},
+		{"all genres", domain.MovieFilter{GenreIDs: []int{action, drama}, GenreMatch: domain.GenreMatchAll}, []string{"Gladiator"}},
+		{"mpaa rating", domain.MovieFilter{MPAARatings: []string{"PG"}}, []string{"Up"}},
+		{"year range", domain.MovieFilter{YearFrom: 1990, YearTo: 1999}, []string{"Speed"}},
+	}
+	for _, tc := range cases {
+		rows, _, err := stores.Movies.ListFilteredMoviesWithGenres(ctx, tc.filter, page, 0, false)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := rowTitles(rows); !equalStrings(got, tc.want) {
+			t.Errorf("%s = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// Every genre of a listed movie is returned, ordered by name
+	rows, _, _ := stores.Movies.ListFilteredMoviesWithGenres(ctx, domain.MovieFilter{GenreIDs: []int{drama}, GenreMatch: domain.GenreMatchAll}, page, 0, false)
+	var gladiatorGenres []string
+	for _, row := range rows {
+		if row.Title == "Gladiator" {
+			gladiatorGenres = append(gladiatorGenres, row.Genre.String)
+		}
+	}
+	if !equalStrings(gladiatorGenres, []string{"Action", "Drama"}) {
+		t.Errorf("Gladiator genres = %v", gladiatorGenres)
+	}
+
+	// The genre facet ignores the genre filter, the MPAA facet ignores the MPAA filter
+	facets, err := stores.Movies.ListMovieFacets(ctx, domain.MovieFilter{GenreIDs: []int{action}, MPAARatings: []string{"R"}}, 0, false)
+	if err != nil {
+		t.Fatalf("ListMovieFacets: %v", err)
+	}
+	genreCounts := make(map[string]int)
+	for _, facet := range facets.Genres {
+		genreCounts[facet.Genre] = facet.Count
+	}
+	if genreCounts["Action"] != 2 || genreCounts["Drama"] != 1 || genreCounts["Comedy"] != 0 {
+		t.Errorf("genre facets = %v", genreCounts)
+	}
+	if _, ok := genreCounts["Comedy"]; !ok {
+		t.Errorf("genres without matches are missing from the facets")
+	}
+	ratingCounts := make(map[string]int)
+	for _, facet := range facets.MPAARatings {
+		ratingCounts[facet.Rating] = facet.Count
+	}
+	if len(ratingCounts) != 1 || ratingCounts["R"] != 2 {
+		t.Errorf("MPAA facets = %v", ratingCounts)
+	}
+}
+
+func testLikedOnlyListing(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	userID := createUser(t, stores, "fan@example.com")
+	liked := createMovie(t, stores, testMovie("Liked"))
+	createMovie(t, stores, testMovie("Ignored"))
+
+	if err := stores.Users.LikeMovie(ctx, userID, liked); err != nil {
+		t.Fatalf("LikeMovie: %v", err)
+	}
+	if _, err := stores.Users.RateMovie(ctx, userID, liked, 5); err != nil {
+		t.Fatalf("RateMovie: %v", err)
+	}
+
+	page := domain.MoviePageRequest{Sort: domain.MovieSort{Field: domain.SortByTitle, Direction: domain.SortAsc}, Limit: 10}
+	rows, _, err := stores.Movies.ListFilteredMoviesWithGenres(ctx, domain.MovieFilter{}, page, userID, true)
+	if err != nil {
+		t.Fatalf("ListFilteredMoviesWithGenres: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Title != "Liked" || !rows[0].IsLiked || rows[0].MyRating.Int16 != 5 {
+		t.Errorf("liked movies = %+v", rows)
+	}
+
+	rows, _, _ = stores.Movies.ListFilteredMoviesWithGenres(ctx, domain.MovieFilter{}, page, userID, false)
+	for _, row := range rows {
+		if row.Title == "Ignored" && (row.IsLiked || row.MyRating.Valid) {
+			t.Errorf("unliked movie reported as liked: %+v", row)
+		}
+	}
+}
+
+func testSearch(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	movie := testMovie("The Matrix")
+	movie.Description = "A hacker learns the true nature of reality."
+	movie.Genres = []*domain.Genre{{ID: genreID(t, stores, "Sci-Fi")}}
+	createMovie(t, stores, movie)
+	createMovie(t, stores, testMovie("Heat"))
+
+	rows, err := stores.Movies.SearchMoviesWithGenres(ctx, "matrix", 10)
+	if err != nil {
+		t.Fatalf("SearchMoviesWithGenres: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Title != "The Matrix" || rows[0].Genre.String != "Sci-Fi" {
+		t.Errorf("search for matrix = %+v", rows)
+	}
+
+	rows, _ = stores.Movies.SearchMoviesWithGenres(ctx, "hacker", 10)
+	if len(rows) != 1 || rows[0].Title != "The Matrix" {
+		t.Errorf("search by description = %+v", rows)
+	}
+
+	if rows, _ := stores.Movies.SearchMoviesWithGenres(ctx, "submarine", 10); len(rows) != 0 {
+		t.Errorf("search for submarine = %+v", rows)
+	}
+
+	fuzzy, err := stores.Movies.FuzzySearchMoviesWithGenres(ctx, "matrx", 0.4, 10)
+	if err != nil {
+		t.Fatalf("FuzzySearchMoviesWithGenres: %v", err)
+	}
+	if len(fuzzy) != 1 || fuzzy[0].Title != "The Matrix" {
+		t.Errorf("fuzzy search for matrx = %+v", fuzzy)
+	}
+
+	suggestions, err := stores.Movies.SuggestMoviesByTitle(ctx, "mat", 10)
+	if err != nil {
+		t.Fatalf("SuggestMoviesByTitle: %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Title != "The Matrix" {
+		t.Errorf("suggestions for mat = %+v", suggestions)
+	}
+	if suggestions, _ := stores.Movies.SuggestMoviesByTitle(ctx, "atrix", 10); len(suggestions) != 0 {
+		t.Errorf("suggestions match inside words: %+v", suggestions)
+	}
+}
+
+func testReviews(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	author := createUser(t, stores, "critic@example.com")
+	moderator := createUser(t, stores, "moderator@example.com")
+	movieID := createMovie(t, stores, testMovie("Heat"))
+
+	review, err := stores.Reviews.CreateReview(ctx, author, movieID, "Great heist movie.")
+	if err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+	if review.Status != domain.ReviewStatusPending {
+		t.Errorf("new review is %q, want pending", review.Status)
+	}
+
+	_, err = stores.Reviews.CreateReview(ctx, author, movieID, "Again.")
+	assertPgError(t, err, pgUniqueViolation)
+	_, err = stores.Reviews.CreateReview(ctx, author, movieID+100, "Missing movie.")
+	assertPgError(t, err, pgForeignKeyViolation)
+
+	// Pending reviews are only visible to moderators
+	if rows, _ := stores.Reviews.ListApprovedReviewsByMovie(ctx, movieID, 0, 10); len(rows) != 0 {
+		t.Errorf("pending review is listed: %+v", rows)
+	}
+	pending, err := stores.Reviews.ListReviewsByStatus(ctx, domain.ReviewStatusPending, 0, 10)
+	if err != nil || len(pending) != 1 || pending[0].FirstName != "Test" {
+		t.Errorf("ListReviewsByStatus = %+v, %v", pending, err)
+	}
+
+	approved, err := stores.Reviews.SetReviewStatus(ctx, int(review.ID), domain.ReviewStatusApproved, moderator)
+	if err != nil || approved.Status != domain.ReviewStatusApproved || approved.ModeratedBy.Int32 != int32(moderator) {
+		t.Errorf("SetReviewStatus = %+v, %v", approved, err)
+	}
+	if _, err := stores.Reviews.SetReviewStatus(ctx, int(review.ID)+100, domain.ReviewStatusApproved, moderator); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("SetReviewStatus of unknown review: got %v, want pgx.ErrNoRows", err)
+	}
+	if rows, _ := stores.Reviews.ListApprovedReviewsByMovie(ctx, movieID, 0, 10); len(rows) != 1 {
+		t.Errorf("approved review is not listed: %+v", rows)
+	}
+
+	// Editing sends the review back to the queue
+	edited, err := stores.Reviews.UpdateReviewBody(ctx, author, movieID, "Still great.")
+	if err != nil || edited.Body != "Still great." || edited.Status != domain.ReviewStatusPending || edited.ModeratedBy.Valid {
+		t.Errorf("UpdateReviewBody = %+v, %v", edited, err)
+	}
+	if _, err := stores.Reviews.UpdateReviewBody(ctx, moderator, movieID, "Not mine."); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("UpdateReviewBody without a review: got %v, want pgx.ErrNoRows", err)
+	}
+
+	deleted, err := stores.Reviews.DeleteReview(ctx, author, movieID)
+	if err != nil || !deleted {
+		t.Errorf("DeleteReview = %v, %v; want true", deleted, err)
+	}
+	if _, err := stores.Reviews.GetReviewByID(ctx, int(review.ID)); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetReviewByID after delete: got %v, want pgx.ErrNoRows", err)
+	}
+	if deleted, _ := stores.Reviews.DeleteReview(ctx, author, movieID); deleted {
+		t.Errorf("DeleteReview deleted a review twice")
+	}
+}
+
+func testReviewVotes(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	author := createUser(t, stores, "critic@example.com")
+	voter := createUser(t, stores, "voter@example.com")
+	movieID := createMovie(t, stores, testMovie("Heat"))
+	review, err := stores.Reviews.CreateReview(ctx, author, movieID, "Great heist movie.")
+	if err != nil {
+		t.Fatalf("CreateReview: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := stores.Reviews.AddReviewHelpfulVote(ctx, voter, int(review.ID)); err != nil {
+			t.Fatalf("AddReviewHelpfulVote #%d: %v", i+1, err)
+		}
+	}
+	if count, _ := stores.Reviews.CountReviewHelpfulVotes(ctx, int(review.ID)); count != 1 {
+		t.Errorf("%d helpful votes, want 1", count)
+	}
+
+	assertPgError(t, stores.Reviews.AddReviewHelpfulVote(ctx, voter, int(review.ID)+100), pgForeignKeyViolation)
+
+	if err := stores.Reviews.RemoveReviewHelpfulVote(ctx, voter, int(review.ID)); err != nil {
+		t.Fatalf("RemoveReviewHelpfulVote: %v", err)
+	}
+	if count, _ := stores.Reviews.CountReviewHelpfulVotes(ctx, int(review.ID)); count != 0 {
+		t.Errorf("%d helpful votes after removal, want 0", count)
+	}
+
+	// Reviews go away with their movie
+	if err := stores.Movies.DeleteMovie(ctx, movieID); err != nil {
+		t.Fatalf("DeleteMovie: %v", err)
+	}
+	if _, err := stores.Reviews.GetReviewByID(ctx, int(review.ID)); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("review survived the movie: %v", err)
+	}
+}
+
+func testLists(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	owner := createUser(t, stores, "owner@example.com")
+	other := createUser(t, stores, "other@example.com")
+
+	list, err := stores.Lists.CreateUserList(ctx, owner, "Heists", "", domain.ListVisibilityPrivate, "token-1")
+	if err != nil {
+		t.Fatalf("CreateUserList: %v", err)
+	}
+	_, err = stores.Lists.CreateUserList(ctx, other, "Copy", "", domain.ListVisibilityPublic, "token-1")
+	assertPgError(t, err, pgUniqueViolation)
+
+	if _, err := stores.Lists.GetUserListByID(ctx, other, int(list.ID)); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetUserListByID of someone else's list: got %v, want pgx.ErrNoRows", err)
+	}
+
+	// Private lists are not shared
+	if _, err := stores.Lists.GetUserListByShareToken(ctx, "token-1"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetUserListByShareToken of private list: got %v, want pgx.ErrNoRows", err)
+	}
+
+	updated, err := stores.Lists.UpdateUserList(ctx, owner, int(list.ID), "Best heists", "Ranked", domain.ListVisibilityUnlisted)
+	if err != nil || updated.Name != "Best heists" || updated.Visibility != domain.ListVisibilityUnlisted {
+		t.Errorf("UpdateUserList = %+v, %v", updated, err)
+	}
+	if _, err := stores.Lists.UpdateUserList(ctx, other, int(list.ID), "Mine", "", domain.ListVisibilityPublic); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("UpdateUserList of someone else's list: got %v, want pgx.ErrNoRows", err)
+	}
+	if shared, err := stores.Lists.GetUserListByShareToken(ctx, "token-1"); err != nil || shared.ID != list.ID {
+		t.Errorf("GetUserListByShareToken = %+v, %v", shared, err)
+	}
+
+	if _, err := stores.Lists.CreateUserList(ctx, owner, "Second", "", domain.ListVisibilityPublic, "token-2"); err != nil {
+		t.Fatalf("CreateUserList: %v", err)
+	}
+	lists, err := stores.Lists.ListUserListsByUser(ctx, owner)
+	if err != nil || len(lists) != 2 || lists[0].ID != list.ID {
+		t.Errorf("ListUserListsByUser = %+v, %v", lists, err)
+	}
+
+	// Only the public list is discoverable; the unlisted one needs its share URL
+	public, err := stores.Lists.ListPublicUserListsByUser(ctx, owner)
+	if err != nil || len(public) != 1 || public[0].Name != "Second" {
+		t.Errorf("ListPublicUserListsByUser = %+v, %v", public, err)
+	}
+
+	if deleted, _ := stores.Lists.DeleteUserList(ctx, other, int(list.ID)); deleted {
+		t.Errorf("DeleteUserList deleted someone else's list")
+	}
+	if deleted, err := stores.Lists.DeleteUserList(ctx, owner, int(list.ID)); err != nil || !deleted {
+		t.Errorf("DeleteUserList = %v, %v; want true", deleted, err)
+	}
+}
+
+func testListEntries(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	owner := createUser(t, stores, "owner@example.com")
+	other := createUser(t, stores, "other@example.com")
+	heat := createMovie(t, stores, testMovie("Heat"))
+	ronin := createMovie(t, stores, testMovie("Ronin"))
+	thief := createMovie(t, stores, testMovie("Thief"))
+
+	list, err := stores.Lists.CreateUserList(ctx, owner, "Heists", "", domain.ListVisibilityPublic, "token-1")
+	if err != nil {
+		t.Fatalf("CreateUserList: %v", err)
+	}
+	listID := int(list.ID)
+
+	for _, movieID := range []int{heat, ronin, thief, heat} {
+		if err := stores.Lists.AddUserListEntry(ctx, owner, listID, movieID); err != nil {
+			t.Fatalf("AddUserListEntry: %v", err)
+		}
+	}
+	if err := stores.Lists.AddUserListEntry(ctx, other, listID, heat); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("AddUserListEntry to someone else's list: got %v, want pgx.ErrNoRows", err)
+	}
+	assertPgError(t, stores.Lists.AddUserListEntry(ctx, owner, listID, 424242), pgForeignKeyViolation)
+
+	if got := entryTitles(t, stores, listID); !equalStrings(got, []string{"Heat", "Ronin", "Thief"}) {
+		t.Errorf("entries = %v", got)
+	}
+
+	err = stores.Lists.ReorderUserListEntries(ctx, owner, listID, func(current []int32) ([]int32, error) {
+		return []int32{current[2], current[0], current[1]}, nil
+	})
+	if err != nil {
+		t.Fatalf("ReorderUserListEntries: %v", err)
+	}
+	if got := entryTitles(t, stores, listID); !equalStrings(got, []string{"Thief", "Heat", "Ronin"}) {
+		t.Errorf("entries after reorder = %v", got)
+	}
+
+	removed, err := stores.Lists.RemoveUserListEntry(ctx, owner, listID, heat)
+	if err != nil || !removed {
+		t.Errorf("RemoveUserListEntry = %v, %v; want true", removed, err)
+	}
+	if removed, _ := stores.Lists.RemoveUserListEntry(ctx, owner, listID, heat); removed {
+		t.Errorf("RemoveUserListEntry removed an entry twice")
+	}
+
+	// Entries go away with their movie
+	if err := stores.Movies.DeleteMovie(ctx, ronin); err != nil {
+		t.Fatalf("DeleteMovie: %v", err)
+	}
+	if got := entryTitles(t, stores, listID); !equalStrings(got, []string{"Thief"}) {
+		t.Errorf("entries after deleting a movie = %v", got)
+	}
+	lists, _ := stores.Lists.ListUserListsByUser(ctx, owner)
+	if len(lists) != 1 || lists[0].EntryCount != 1 {
+		t.Errorf("ListUserListsByUser = %+v", lists)
+	}
+}
+
+func testPeople(t *testing.T, stores Stores) {
+	ctx := context.Background()
+
+	birthDate := time.Date(1940, time.April, 25, 0, 0, 0, 0, time.UTC)
+	pacino, err := stores.People.CreatePerson(ctx, domain.Person{Name: "Al Pacino", BirthDate: &birthDate})
+	if err != nil {
+		t.Fatalf("CreatePerson: %v", err)
+	}
+	if !pacino.BirthDate.Valid || !pacino.BirthDate.Time.Equal(birthDate) || pacino.Biography.Valid {
+		t.Errorf("CreatePerson = %+v", pacino)
+	}
+	createPerson(t, stores, "Michael Mann")
+
+	people, err := stores.People.ListPeople(ctx)
+	if err != nil || len(people) != 2 || people[0].Name != "Al Pacino" {
+		t.Errorf("ListPeople = %+v, %v", people, err)
+	}
+
+	updated, err := stores.People.UpdatePerson(ctx, domain.Person{ID: int(pacino.ID), Name: "Alfredo Pacino", Biography: "Actor."})
+	if err != nil || updated.Name != "Alfredo Pacino" || updated.Biography.String != "Actor." || updated.BirthDate.Valid {
+		t.Errorf("UpdatePerson = %+v, %v", updated, err)
+	}
+	if _, err := stores.People.UpdatePerson(ctx, domain.Person{ID: int(pacino.ID) + 100, Name: "Nobody"}); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("UpdatePerson of unknown person: got %v, want pgx.ErrNoRows", err)
+	}
+
+	// The filmography is newest first
+	older := testMovie("The Godfather")
+	older.ReleaseDate = time.Date(1972, time.March, 24, 0, 0, 0, 0, time.UTC)
+	newer := testMovie("Heat")
+	newer.ReleaseDate = time.Date(1995, time.December, 15, 0, 0, 0, 0, time.UTC)
+	for _, movie := range []domain.Movie{older, newer} {
+		credit := domain.Credit{PersonID: int(pacino.ID), Role: domain.CreditRoleActor}
+		if _, err := stores.Movies.CreateMovieCredit(ctx, createMovie(t, stores, movie), credit); err != nil {
+			t.Fatalf("CreateMovieCredit: %v", err)
+		}
+	}
+	credits, err := stores.People.ListCreditsByPersonID(ctx, int(pacino.ID))
+	if err != nil || len(credits) != 2 || credits[0].Title != "Heat" || credits[1].Title != "The Godfather" {
+		t.Errorf("ListCreditsByPersonID = %+v, %v", credits, err)
+	}
+
+	deleted, err := stores.People.DeletePerson(ctx, int(pacino.ID))
+	if err != nil || !deleted {
+		t.Errorf("DeletePerson = %v, %v; want true", deleted, err)
+	}
+	if _, err := stores.People.GetPersonByID(ctx, int(pacino.ID)); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetPersonByID after delete: got %v, want pgx.ErrNoRows", err)
+	}
+	if credits, _ := stores.People.ListCreditsByPersonID(ctx, int(pacino.ID)); len(credits) != 0 {
+		t.Errorf("credits survived the person: %+v", credits)
+	}
+}
+
+func testMovie(title string) domain.Movie {
+	return domain.Movie{
+		Title:       title,
+		ReleaseDate: time.Date(2000, time.May, 5, 0, 0, 0, 0, time.UTC),
+		RunTime:     120,
+		MPAARating:  "PG-13",
+		Description: "A test movie.",
+		Image:       "poster.jpg",
+		Video:       "trailer",
+	}
+}
+
+func createMovie(t *testing.T, stores Stores, movie domain.Movie) int {
+	t.Helper()
+	created, err := stores.Movies.CreateMovieWithGenres(context.Background(), movie)
+	if err != nil {
+		t.Fatalf("CreateMovieWithGenres(%q): %v", movie.Title, err)
+	}
+	return int(created.ID)
+}
+
+func createUser(t *testing.T, stores Stores, email string) int {
+	t.Helper()
+	created, err := stores.Users.CreateUser(context.Background(), "Test", "User", email, "", "hash")
+	if err != nil {
+		t.Fatalf("CreateUser(%q): %v", email, err)
+	}
+	return int(created.ID)
+}
+
+func createPerson(t *testing.T, stores Stores, name string) int {
+	t.Helper()
+	created, err := stores.People.CreatePerson(context.Background(), domain.Person{Name: name})
+	if err != nil {
+		t.Fatalf("CreatePerson(%q): %v", name, err)
+	}
+	return int(created.ID)
+}
+
+func genreID(t *testing.T, stores Stores, name string) int {
+	t.Helper()
+	genres, err := stores.Movies.ListGenres(context.Background())
+	if err != nil {
+		t.Fatalf("ListGenres: %v", err)
+	}
+	for _, genre := range genres {
+		if genre.Genre == name {
+			return int(genre.ID)
+		}
+	}
+	t.Fatalf("genre %q is not seeded", name)
+	return 0
+}
+
+// genreNames returns the movie's genres sorted, since the order of ListGenresByMovieID is unspecified
+func genreNames(t *testing.T, stores Stores, movieID int) []string {
+	t.Helper()
+	genres, err := stores.Movies.ListGenresByMovieID(context.Background(), movieID)
+	if err != nil {
+		t.Fatalf("ListGenresByMovieID: %v", err)
+	}
+	names := make([]string, len(genres))
+	for i, genre := range genres {
+		names[i] = genre.Genre
+	}
+	sort.Strings(names)
+	return names
+}
+
+func entryTitles(t *testing.T, stores Stores, listID int) []string {
+	t.Helper()
+	entries, err := stores.Lists.ListUserListEntries(context.Background(), listID)
+	if err != nil {
+		t.Fatalf("ListUserListEntries: %v", err)
+	}
+	titles := make([]string, len(entries))
+	for i, entry := range entries {
+		titles[i] = entry.Title
+	}
+	return titles
+}
+
+func movieTitles(movies []db.Movie) []string {
+	titles := make([]string, len(movies))
+	for i, movie := range movies {
+		titles[i] = movie.Title
+	}
+	return titles
+}
+
+// rowTitles returns each movie of a listing once, in order
+func rowTitles(rows []db.ListMoviesWithGenresAndLikeStatusRow) []string {
+	var titles []string
+	var lastID int32
+	for _, row := range rows {
+		if row.MovieID != lastID {
+			titles = append(titles, row.Title)
+			lastID = row.MovieID
+		}
+	}
+	return titles
+}
+
+func auditEventIDs(events []db.AuditEvent) []int32 {
+	ids := []int32{}
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func assertRating(t *testing.T, stats db.RecomputeMovieRatingRow, average float64, count int32) {
+	t.Helper()
+	if got := numericFloat(stats.UserRating); got != average || stats.RatingCount != count {
+		t.Errorf("rating = %v over %d, want %v over %d", got, stats.RatingCount, average, count)
+	}
+}
+
+func assertPgError(t *testing.T, err error, code string) {
+	t.Helper()
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != code {
+		t.Errorf("got error %v, want PostgreSQL error %s", err, code)
+	}
+}
+
+func numericFloat(value pgtype.Numeric) float64 {
+	f, err := value.Float64Value()
+	if err != nil {
+		return -1
+	}
+	return f.Float64
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
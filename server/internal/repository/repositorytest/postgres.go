@@ -0,0 +1,69 @@
+package repositorytest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/martishin/movie-search-service/internal/db"
+)
+
+// PostgresDSNEnv names the database the PostgreSQL tests run against. Its movies, users, people and audit events
+// are truncated.
+const PostgresDSNEnv = "TEST_POSTGRES_DSN"
+
+// migrationsMu serializes changes to the working directory, which the migrations are read relative to
+var migrationsMu sync.Mutex
+
+// OpenPostgres connects to the test database and migrates it, skipping the test when PostgresDSNEnv is unset
+func OpenPostgres(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", PostgresDSNEnv)
+	}
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatalf("failed to create connection pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := runMigrations(pool); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+	return pool
+}
+
+// ResetPostgres empties every table except the genres seeded by the migrations
+func ResetPostgres(t *testing.T, pool *pgxpool.Pool) {
+	t.Helper()
+
+	if _, err := pool.Exec(context.Background(), "TRUNCATE users, movies, people, audit_events RESTART IDENTITY CASCADE"); err != nil {
+		t.Fatalf("failed to reset the database: %v", err)
+	}
+}
+
+func runMigrations(pool *pgxpool.Pool) error {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	// This file lives in internal/repository/repositorytest of the server module
+	_, file, _, _ := runtime.Caller(0)
+	if err := os.Chdir(filepath.Join(filepath.Dir(file), "..", "..", "..")); err != nil {
+		return err
+	}
+	defer os.Chdir(workingDir)
+
+	return db.RunPostgresMigrations(pool)
+}
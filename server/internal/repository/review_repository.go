@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+)
+
+// ReviewStore holds reviews and their helpful votes.
+type ReviewStore interface {
+	CreateReview(ctx context.Context, userID, movieID int, body string) (db.Review, error)
+	GetReviewByID(ctx context.Context, id int) (db.Review, error)
+	GetReviewByUserAndMovie(ctx context.Context, userID, movieID int) (db.Review, error)
+	UpdateReviewBody(ctx context.Context, userID, movieID int, body string) (db.Review, error)
+	DeleteReview(ctx context.Context, userID, movieID int) (bool, error)
+	SetReviewStatus(ctx context.Context, id int, status string, moderatorID int) (db.Review, error)
+	ListApprovedReviewsByMovie(ctx context.Context, movieID, beforeID, limit int) ([]db.ListApprovedReviewsByMovieRow, error)
+	ListReviewsByStatus(ctx context.Context, status string, afterID, limit int) ([]db.ListReviewsByStatusRow, error)
+
+	CountReviewHelpfulVotes(ctx context.Context, reviewID int) (int64, error)
+	AddReviewHelpfulVote(ctx context.Context, userID, reviewID int) error
+	RemoveReviewHelpfulVote(ctx context.Context, userID, reviewID int) error
+}
+
+var _ ReviewStore = (*ReviewRepository)(nil)
+
+type ReviewRepository struct {
+	queries *db.Queries
+}
+
+func NewReviewRepository(postgresPool *pgxpool.Pool) *ReviewRepository {
+	return &ReviewRepository{queries: db.New(newConn(postgresPool))}
+}
+
+func (r *ReviewRepository) CreateReview(ctx context.Context, userID, movieID int, body string) (db.Review, error) {
+	return r.queries.CreateReview(ctx, db.CreateReviewParams{
+		UserID:  int32(userID),
+		MovieID: int32(movieID),
+		Body:    body,
+	})
+}
+
+func (r *ReviewRepository) GetReviewByID(ctx context.Context, id int) (db.Review, error) {
+	return r.queries.GetReviewByID(ctx, int32(id))
+}
+
+func (r *ReviewRepository) GetReviewByUserAndMovie(ctx context.Context, userID, movieID int) (db.Review, error) {
+	return r.queries.GetReviewByUserAndMovie(ctx, db.GetReviewByUserAndMovieParams{
+		UserID:  int32(userID),
+		MovieID: int32(movieID),
+	})
+}
+
+func (r *ReviewRepository) UpdateReviewBody(ctx context.Context, userID, movieID int, body string) (db.Review, error) {
+	return r.queries.UpdateReviewBody(ctx, db.UpdateReviewBodyParams{
+		UserID:  int32(userID),
+		MovieID: int32(movieID),
+		Body:    body,
+	})
+}
+
+// DeleteReview reports whether the user had a review of the movie to delete
+func (r *ReviewRepository) DeleteReview(ctx context.Context, userID, movieID int) (bool, error) {
+	deleted, err := r.queries.DeleteReviewByUserAndMovie(ctx, db.DeleteReviewByUserAndMovieParams{
+		UserID:  int32(userID),
+		MovieID: int32(movieID),
+	})
+	return deleted > 0, err
+}
+
+func (r *ReviewRepository) SetReviewStatus(ctx context.Context, id int, status string, moderatorID int) (db.Review, error) {
+	return r.queries.SetReviewStatus(ctx, db.SetReviewStatusParams{
+		ID:          int32(id),
+		Status:      status,
+		ModeratedBy: pgtype.Int4{Int32: int32(moderatorID), Valid: moderatorID != 0},
+	})
+}
+
+// ListApprovedReviewsByMovie returns approved reviews newest first, starting below beforeID when it is non-zero
+func (r *ReviewRepository) ListApprovedReviewsByMovie(
+	ctx context.Context,
+	movieID, beforeID, limit int,
+) ([]db.ListApprovedReviewsByMovieRow, error) {
+	return r.queries.ListApprovedReviewsByMovie(ctx, db.ListApprovedReviewsByMovieParams{
+		MovieID:    int32(movieID),
+		BeforeID:   pgtype.Int4{Int32: int32(beforeID), Valid: beforeID != 0},
+		MaxResults: int32(limit),
+	})
+}
+
+// ListReviewsByStatus returns reviews in the given state oldest first, starting above afterID when it is non-zero
+func (r *ReviewRepository) ListReviewsByStatus(
+	ctx context.Context,
+	status string,
+	afterID, limit int,
+) ([]db.ListReviewsByStatusRow, error) {
+	return r.queries.ListReviewsByStatus(ctx, db.ListReviewsByStatusParams{
+		Status:     status,
+		AfterID:    pgtype.Int4{Int32: int32(afterID), Valid: afterID != 0},
+		MaxResults: int32(limit),
+	})
+}
+
+func (r *ReviewRepository) CountReviewHelpfulVotes(ctx context.Context, reviewID int) (int64, error) {
+	return r.queries.CountReviewHelpfulVotes(ctx, int32(reviewID))
+}
+
+func (r *ReviewRepository) AddReviewHelpfulVote(ctx context.Context, userID, reviewID int) error {
+	return r.queries.AddReviewHelpfulVote(ctx, db.AddReviewHelpfulVoteParams{
+		UserID:   int32(userID),
+		ReviewID: int32(reviewID),
+	})
+}
+
+func (r *ReviewRepository) RemoveReviewHelpfulVote(ctx context.Context, userID, reviewID int) error {
+	return r.queries.RemoveReviewHelpfulVote(ctx, db.RemoveReviewHelpfulVoteParams{
+		UserID:   int32(userID),
+		ReviewID: int32(reviewID),
+	})
+}
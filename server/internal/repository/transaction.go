@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Transactor runs several repository calls as one unit. Calls made with the context passed to fn take part in the
+// transaction, which commits when fn returns nil and rolls back otherwise. A transaction started inside another
+// joins it.
+type Transactor interface {
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+var _ Transactor = (*PostgresTransactor)(nil)
+
+type PostgresTransactor struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresTransactor(postgresPool *pgxpool.Pool) *PostgresTransactor {
+	return &PostgresTransactor{pool: postgresPool}
+}
+
+func (t *PostgresTransactor) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if InTransaction(ctx) {
+		return fn(ctx)
+	}
+
+	tx, err := t.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	return RunTransaction(ctx, tx, fn, tx.Commit)
+}
+
+type transactionKey struct{}
+
+// transaction is the state of a running transaction, carried in its context
+type transaction struct {
+	tx          pgx.Tx
+	afterCommit []func(ctx context.Context)
+}
+
+// RunTransaction runs fn with a context carrying the transaction, then commit, then the functions registered with
+// AfterCommit. tx is nil for stores that are not backed by the database.
+func RunTransaction(
+	ctx context.Context,
+	tx pgx.Tx,
+	fn func(ctx context.Context) error,
+	commit func(ctx context.Context) error,
+) error {
+	state := &transaction{tx: tx}
+	if err := fn(context.WithValue(ctx, transactionKey{}, state)); err != nil {
+		return err
+	}
+	if err := commit(ctx); err != nil {
+		return err
+	}
+
+	for _, hook := range state.afterCommit {
+		hook(ctx)
+	}
+	return nil
+}
+
+// InTransaction reports whether ctx belongs to a transaction
+func InTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(transactionKey{}).(*transaction)
+	return ok
+}
+
+// AfterCommit runs fn once the transaction of ctx commits, or right away outside a transaction. Side effects that
+// others must not see before the write, such as cache invalidations, go through it. fn gets a context outside the
+// transaction.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if state, ok := ctx.Value(transactionKey{}).(*transaction); ok {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn(ctx)
+}
+
+// conn sends queries to the transaction of their context, or to the pool outside one
+type conn struct {
+	pool *pgxpool.Pool
+}
+
+func newConn(postgresPool *pgxpool.Pool) conn {
+	return conn{pool: postgresPool}
+}
+
+func (c conn) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if tx := transactionOf(ctx); tx != nil {
+		return tx.Exec(ctx, sql, args...)
+	}
+	return c.pool.Exec(ctx, sql, args...)
+}
+
+func (c conn) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if tx := transactionOf(ctx); tx != nil {
+		return tx.Query(ctx, sql, args...)
+	}
+	return c.pool.Query(ctx, sql, args...)
+}
+
+func (c conn) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if tx := transactionOf(ctx); tx != nil {
+		return tx.QueryRow(ctx, sql, args...)
+	}
+	return c.pool.QueryRow(ctx, sql, args...)
+}
+
+// Begin starts a transaction, or a savepoint within the transaction of ctx
+func (c conn) Begin(ctx context.Context) (pgx.Tx, error) {
+	if tx := transactionOf(ctx); tx != nil {
+		return tx.Begin(ctx)
+	}
+	return c.pool.Begin(ctx)
+}
+
+func transactionOf(ctx context.Context) pgx.Tx {
+	if state, ok := ctx.Value(transactionKey{}).(*transaction); ok {
+		return state.tx
+	}
+	return nil
+}
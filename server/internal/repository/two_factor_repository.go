@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+)
+
+// TwoFactorStore holds the users' TOTP state and recovery codes. The secret itself is read with the user.
+type TwoFactorStore interface {
+	SetUserTOTPSecret(ctx context.Context, userID int, encryptedSecret []byte) (bool, error)
+	EnableUserTOTP(ctx context.Context, userID int, recoveryCodeHashes []string) (bool, error)
+	DisableUserTOTP(ctx context.Context, userID int) (bool, error)
+	MarkUserTOTPStepUsed(ctx context.Context, userID int, step int64) (bool, error)
+	ReplaceUserRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	ConsumeUserRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+	CountUnusedUserRecoveryCodes(ctx context.Context, userID int) (int, error)
+}
+
+var _ TwoFactorStore = (*TwoFactorRepository)(nil)
+
+type TwoFactorRepository struct {
+	pool    conn
+	queries *db.Queries
+}
+
+func NewTwoFactorRepository(postgresPool *pgxpool.Pool) *TwoFactorRepository {
+	return &TwoFactorRepository{
+		pool:    newConn(postgresPool),
+		queries: db.New(newConn(postgresPool)),
+	}
+}
+
+// SetUserTOTPSecret stores a pending secret. It reports false when two-factor is already enabled or the user is unknown.
+func (r *TwoFactorRepository) SetUserTOTPSecret(ctx context.Context, userID int, encryptedSecret []byte) (bool, error) {
+	updated, err := r.queries.SetUserTOTPSecret(ctx, db.SetUserTOTPSecretParams{
+		ID:         int32(userID),
+		TotpSecret: encryptedSecret,
+	})
+	return updated > 0, err
+}
+
+// EnableUserTOTP enables the pending secret and replaces the recovery codes in one transaction.
+// It reports false when there is no pending secret.
+func (r *TwoFactorRepository) EnableUserTOTP(ctx context.Context, userID int, recoveryCodeHashes []string) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := r.queries.WithTx(tx)
+
+	enabled, err := qtx.EnableUserTOTP(ctx, int32(userID))
+	if err != nil || enabled == 0 {
+		return false, err
+	}
+	if err := replaceRecoveryCodes(ctx, qtx, userID, recoveryCodeHashes); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// DisableUserTOTP clears the secret and the recovery codes. It reports false when the user is unknown.
+func (r *TwoFactorRepository) DisableUserTOTP(ctx context.Context, userID int) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := r.queries.WithTx(tx)
+
+	disabled, err := qtx.DisableUserTOTP(ctx, int32(userID))
+	if err != nil || disabled == 0 {
+		return false, err
+	}
+	if err := qtx.DeleteUserRecoveryCodes(ctx, int32(userID)); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// MarkUserTOTPStepUsed records an accepted code's time step. It reports false for a step that was already used.
+func (r *TwoFactorRepository) MarkUserTOTPStepUsed(ctx context.Context, userID int, step int64) (bool, error) {
+	updated, err := r.queries.MarkUserTOTPStepUsed(ctx, db.MarkUserTOTPStepUsedParams{
+		ID:               int32(userID),
+		TotpLastUsedStep: pgtype.Int8{Int64: step, Valid: true},
+	})
+	return updated > 0, err
+}
+
+func (r *TwoFactorRepository) ReplaceUserRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, r.queries.WithTx(tx), userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ConsumeUserRecoveryCode marks an unused recovery code as used and reports whether there was one
+func (r *TwoFactorRepository) ConsumeUserRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	consumed, err := r.queries.ConsumeUserRecoveryCode(ctx, db.ConsumeUserRecoveryCodeParams{
+		UserID:   int32(userID),
+		CodeHash: codeHash,
+	})
+	return consumed > 0, err
+}
+
+func (r *TwoFactorRepository) CountUnusedUserRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	count, err := r.queries.CountUnusedUserRecoveryCodes(ctx, int32(userID))
+	return int(count), err
+}
+
+func replaceRecoveryCodes(ctx context.Context, qtx *db.Queries, userID int, codeHashes []string) error {
+	if err := qtx.DeleteUserRecoveryCodes(ctx, int32(userID)); err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		err := qtx.CreateUserRecoveryCode(ctx, db.CreateUserRecoveryCodeParams{
+			UserID:   int32(userID),
+			CodeHash: codeHash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
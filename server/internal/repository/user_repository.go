@@ -2,19 +2,44 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	db "github.com/martishin/movie-search-service/internal/db/generated"
 )
 
+// UserStore holds users and their likes and ratings.
+type UserStore interface {
+	CreateUser(ctx context.Context, firstName, lastName, email, pictureURL string, password string) (db.User, error)
+	GetUserByID(ctx context.Context, id int) (db.User, error)
+	GetUserByEmail(ctx context.Context, email string) (db.User, error)
+	SetUserRole(ctx context.Context, id int, role string) (db.User, error)
+	PromoteFirstAdmin(ctx context.Context, email string) (bool, error)
+	SetUserPassword(ctx context.Context, id int, password string) (db.User, error)
+	MarkEmailVerified(ctx context.Context, id int) error
+	UpdateUserProfile(ctx context.Context, id int, firstName, lastName, pictureURL string, preferences []byte) (db.User, error)
+	ScheduleDeletion(ctx context.Context, id int) (bool, error)
+	RestoreUser(ctx context.Context, id int) (bool, error)
+	PurgeDeletedUsers(ctx context.Context, gracePeriod time.Duration) (int, []int, error)
+
+	LikeMovie(ctx context.Context, userID, movieID int) error
+	UnlikeMovie(ctx context.Context, userID, movieID int) error
+	RateMovie(ctx context.Context, userID, movieID, rating int) (db.RecomputeMovieRatingRow, error)
+	UnrateMovie(ctx context.Context, userID, movieID int) (db.RecomputeMovieRatingRow, error)
+}
+
+var _ UserStore = (*UserRepository)(nil)
+
 type UserRepository struct {
+	pool    conn
 	queries *db.Queries
 }
 
 func NewUserRepository(postgresPool *pgxpool.Pool) *UserRepository {
 	return &UserRepository{
-		queries: db.New(postgresPool),
+		pool:    newConn(postgresPool),
+		queries: db.New(newConn(postgresPool)),
 	}
 }
 
@@ -37,6 +62,106 @@ func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (db.U
 	return r.queries.GetUserByEmail(ctx, email)
 }
 
+func (r *UserRepository) SetUserRole(ctx context.Context, id int, role string) (db.User, error) {
+	return r.queries.SetUserRole(ctx, db.SetUserRoleParams{
+		ID:   int32(id),
+		Role: role,
+	})
+}
+
+// PromoteFirstAdmin makes the verified user with the email an admin unless an admin already exists, and reports
+// whether it did
+func (r *UserRepository) PromoteFirstAdmin(ctx context.Context, email string) (bool, error) {
+	promoted, err := r.queries.PromoteFirstAdmin(ctx, email)
+	return promoted > 0, err
+}
+
+func (r *UserRepository) SetUserPassword(ctx context.Context, id int, password string) (db.User, error) {
+	return r.queries.SetUserPassword(ctx, db.SetUserPasswordParams{
+		ID:       int32(id),
+		Password: pgtype.Text{String: password, Valid: password != ""},
+	})
+}
+
+// MarkEmailVerified records that the user owns their email address. Verifying twice keeps the first time.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id int) error {
+	return r.queries.MarkUserEmailVerified(ctx, int32(id))
+}
+
+func (r *UserRepository) UpdateUserProfile(
+	ctx context.Context,
+	id int,
+	firstName, lastName, pictureURL string,
+	preferences []byte,
+) (db.User, error) {
+	return r.queries.UpdateUserProfile(ctx, db.UpdateUserProfileParams{
+		ID:          int32(id),
+		FirstName:   firstName,
+		LastName:    lastName,
+		PictureUrl:  pgtype.Text{String: pictureURL, Valid: true},
+		Preferences: preferences,
+	})
+}
+
+// ScheduleDeletion starts the user's deletion grace period. It reports false when the user does not exist
+// or is already scheduled for deletion.
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, id int) (bool, error) {
+	scheduled, err := r.queries.ScheduleUserDeletion(ctx, int32(id))
+	return scheduled > 0, err
+}
+
+// RestoreUser cancels the user's scheduled deletion and reports whether there was one
+func (r *UserRepository) RestoreUser(ctx context.Context, id int) (bool, error) {
+	restored, err := r.queries.RestoreUser(ctx, int32(id))
+	return restored > 0, err
+}
+
+// PurgeDeletedUsers removes the users scheduled for deletion longer than gracePeriod ago, by the database clock,
+// along with everything that cascades from them, and recomputes the ratings of the movies they rated, in one
+// transaction. It returns how many users it removed and the movies whose ratings it recomputed.
+func (r *UserRepository) PurgeDeletedUsers(ctx context.Context, gracePeriod time.Duration) (int, []int, error) {
+	cutoff := pgtype.Interval{Microseconds: gracePeriod.Microseconds(), Valid: true}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := r.queries.WithTx(tx)
+
+	movieIDs, err := qtx.ListMoviesRatedByDeletedUsers(ctx, cutoff)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Lock the movies in ID order, the way a rating change would, before their ratings disappear
+	for _, movieID := range movieIDs {
+		if _, err := qtx.LockMovieForRating(ctx, movieID); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	purged, err := qtx.PurgeDeletedUsers(ctx, cutoff)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	recomputed := make([]int, 0, len(movieIDs))
+	for _, movieID := range movieIDs {
+		if _, err := qtx.RecomputeMovieRating(ctx, movieID); err != nil {
+			return 0, nil, err
+		}
+		recomputed = append(recomputed, int(movieID))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, err
+	}
+
+	return int(purged), recomputed, nil
+}
+
 func (r *UserRepository) LikeMovie(ctx context.Context, userID, movieID int) error {
 	return r.queries.LikeMovie(ctx, db.LikeMovieParams{
 		UserID:  int32(userID),
@@ -50,3 +175,60 @@ func (r *UserRepository) UnlikeMovie(ctx context.Context, userID, movieID int) e
 		MovieID: int32(movieID),
 	})
 }
+
+// RateMovie stores the user's rating and recomputes the movie's aggregate rating in one transaction
+func (r *UserRepository) RateMovie(ctx context.Context, userID, movieID, rating int) (db.RecomputeMovieRatingRow, error) {
+	return r.changeMovieRating(ctx, movieID, func(qtx *db.Queries) error {
+		return qtx.UpsertMovieRating(ctx, db.UpsertMovieRatingParams{
+			UserID:  int32(userID),
+			MovieID: int32(movieID),
+			Rating:  int16(rating),
+		})
+	})
+}
+
+// UnrateMovie removes the user's rating and recomputes the movie's aggregate rating in one transaction
+func (r *UserRepository) UnrateMovie(ctx context.Context, userID, movieID int) (db.RecomputeMovieRatingRow, error) {
+	return r.changeMovieRating(ctx, movieID, func(qtx *db.Queries) error {
+		return qtx.DeleteMovieRating(ctx, db.DeleteMovieRatingParams{
+			UserID:  int32(userID),
+			MovieID: int32(movieID),
+		})
+	})
+}
+
+func (r *UserRepository) changeMovieRating(
+	ctx context.Context,
+	movieID int,
+	change func(qtx *db.Queries) error,
+) (db.RecomputeMovieRatingRow, error) {
+	// Start transaction
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return db.RecomputeMovieRatingRow{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := r.queries.WithTx(tx)
+
+	// Lock the movie so that concurrent rating changes recompute the aggregate one at a time
+	if _, err := qtx.LockMovieForRating(ctx, int32(movieID)); err != nil {
+		return db.RecomputeMovieRatingRow{}, err
+	}
+
+	if err := change(qtx); err != nil {
+		return db.RecomputeMovieRatingRow{}, err
+	}
+
+	stats, err := qtx.RecomputeMovieRating(ctx, int32(movieID))
+	if err != nil {
+		return db.RecomputeMovieRatingRow{}, err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(ctx); err != nil {
+		return db.RecomputeMovieRatingRow{}, err
+	}
+
+	return stats, nil
+}
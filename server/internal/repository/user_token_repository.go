@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+)
+
+// UserTokenStore holds the single-use tokens mailed to users, keyed by their hash.
+type UserTokenStore interface {
+	CreateUserToken(ctx context.Context, userID int, purpose, tokenHash string, ttl time.Duration) (db.UserToken, error)
+	ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (db.UserToken, error)
+	RevokeUserTokens(ctx context.Context, userID int, purpose string) error
+}
+
+var _ UserTokenStore = (*UserTokenRepository)(nil)
+
+type UserTokenRepository struct {
+	queries *db.Queries
+}
+
+func NewUserTokenRepository(postgresPool *pgxpool.Pool) *UserTokenRepository {
+	return &UserTokenRepository{
+		queries: db.New(newConn(postgresPool)),
+	}
+}
+
+// CreateUserToken stores a token that expires ttl from now, by the database clock
+func (r *UserTokenRepository) CreateUserToken(
+	ctx context.Context,
+	userID int,
+	purpose, tokenHash string,
+	ttl time.Duration,
+) (db.UserToken, error) {
+	return r.queries.CreateUserToken(ctx, db.CreateUserTokenParams{
+		UserID:    int32(userID),
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		Ttl:       pgtype.Interval{Microseconds: ttl.Microseconds(), Valid: true},
+	})
+}
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns it.
+// It returns pgx.ErrNoRows when there is no such token.
+func (r *UserTokenRepository) ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (db.UserToken, error) {
+	return r.queries.ConsumeUserToken(ctx, db.ConsumeUserTokenParams{
+		TokenHash: tokenHash,
+		Purpose:   purpose,
+	})
+}
+
+// RevokeUserTokens invalidates the user's outstanding tokens for the purpose
+func (r *UserTokenRepository) RevokeUserTokens(ctx context.Context, userID int, purpose string) error {
+	return r.queries.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		UserID:  int32(userID),
+		Purpose: purpose,
+	})
+}
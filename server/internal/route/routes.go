@@ -8,6 +8,8 @@ import (
 	"github.com/martishin/movie-search-service/internal/handler"
 	"github.com/martishin/movie-search-service/internal/middleware"
 	"github.com/martishin/movie-search-service/internal/model/config"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/service"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/go-chi/chi/v5"
@@ -16,9 +18,21 @@ import (
 
 func RegisterRoutes(
 	logger *slog.Logger,
+	userService *service.UserService,
 	userHandler *handler.UserHandler,
 	authHandler *handler.AuthHandler,
 	movieHandler *handler.MovieHandler,
+	reviewHandler *handler.ReviewHandler,
+	listHandler *handler.ListHandler,
+	personHandler *handler.PersonHandler,
+	twoFactorHandler *handler.TwoFactorHandler,
+	sessionHandler *handler.SessionHandler,
+	apiKeyService *service.APIKeyService,
+	apiKeyHandler *handler.APIKeyHandler,
+	identityHandler *handler.IdentityHandler,
+	accountHandler *handler.AccountHandler,
+	exportHandler *handler.ExportHandler,
+	auditHandler *handler.AuditHandler,
 	alloyConfig *config.ObservabilityConfig,
 ) http.Handler {
 	r := chi.NewRouter()
@@ -28,7 +42,7 @@ func RegisterRoutes(
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://ms.martishin.com"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
@@ -40,38 +54,163 @@ func RegisterRoutes(
 	// Authentication routes
 	r.Route("/auth", func(api chi.Router) {
 		api.Get("/start", gothic.BeginAuthHandler)
-		api.Get("/callback", authHandler.GoogleCallbackHandler())
+		api.Get("/callback", authHandler.OAuthCallbackHandler())
 		api.Post("/logout", authHandler.LogoutHandler())
 		api.Post("/signup", authHandler.SignUpHandler())
 		api.Post("/login", authHandler.LoginHandler())
+
+		// Account recovery and email verification
+		api.Post("/password/forgot", authHandler.ForgotPasswordHandler())
+		api.Post("/password/reset", authHandler.ResetPasswordHandler())
+		api.Post("/verify-email", authHandler.VerifyEmailHandler())
+		api.With(middleware.SessionAuthMiddleware).Post("/verify-email/resend", authHandler.ResendVerificationHandler())
+
+		// Second step of a login to an account with two-factor authentication
+		api.Post("/2fa/verify", twoFactorHandler.VerifyLoginHandler())
 	})
 
+	// Accepts a personal API key as well as the session; account settings stay session only
+	apiKeyAuth := middleware.AuthMiddleware(apiKeyService)
+
 	// API routes (protected)
 	r.Route("/api", func(api chi.Router) {
-		api.With(middleware.SessionAuthMiddleware).Get("/users/me", userHandler.GetUserHandler())
+		api.With(apiKeyAuth).Get("/users/me", userHandler.GetUserHandler())
+
+		// Profile and account self-service
+		api.Group(func(account chi.Router) {
+			account.Use(middleware.SessionAuthMiddleware)
+
+			account.Patch("/users/me", userHandler.UpdateProfileHandler())
+			account.Delete("/users/me", accountHandler.DeleteAccountHandler())
+			account.Put("/users/me/password", accountHandler.ChangePasswordHandler())
+		})
+
+		// Archives of the user's data, downloaded through a mailed link
+		api.Route("/users/me/export", func(exports chi.Router) {
+			exports.Use(middleware.SessionAuthMiddleware)
+
+			exports.Post("/", exportHandler.RequestExportHandler())
+			exports.Get("/{export_id}", exportHandler.GetExportHandler())
+		})
+		api.Get("/exports/download", exportHandler.DownloadExportHandler())
+
+		// Personal API keys
+		api.Route("/users/me/api-keys", func(apiKeys chi.Router) {
+			apiKeys.Use(middleware.SessionAuthMiddleware)
+
+			apiKeys.Get("/", apiKeyHandler.ListAPIKeysHandler())
+			apiKeys.Post("/", apiKeyHandler.CreateAPIKeyHandler())
+			apiKeys.Delete("/{key_id}", apiKeyHandler.RevokeAPIKeyHandler())
+		})
+
+		// Signed-in devices
+		api.Route("/users/me/sessions", func(sessions chi.Router) {
+			sessions.Use(middleware.SessionAuthMiddleware)
+
+			sessions.Get("/", sessionHandler.ListSessionsHandler())
+			sessions.Delete("/", sessionHandler.RevokeOtherSessionsHandler())
+			sessions.Delete("/{session_id}", sessionHandler.RevokeSessionHandler())
+		})
+
+		// Provider accounts linked for sign-in
+		api.Route("/users/me/identities", func(identities chi.Router) {
+			identities.Use(middleware.SessionAuthMiddleware)
+
+			identities.Get("/", identityHandler.ListIdentitiesHandler())
+			identities.Post("/{provider}", identityHandler.LinkIdentityHandler())
+			identities.Delete("/{provider}", identityHandler.UnlinkIdentityHandler())
+		})
+
+		// Two-factor authentication settings
+		api.Route("/users/me/2fa", func(twoFactor chi.Router) {
+			twoFactor.Use(middleware.SessionAuthMiddleware)
+
+			twoFactor.Post("/enroll", twoFactorHandler.EnrollHandler())
+			twoFactor.Post("/confirm", twoFactorHandler.ConfirmEnrollHandler())
+			twoFactor.Post("/recovery-codes", twoFactorHandler.RegenerateRecoveryCodesHandler())
+			twoFactor.Delete("/", twoFactorHandler.DisableHandler())
+		})
 
 		// Movie endpoints
 		api.Get("/public/movies", movieHandler.ListMoviesHandler())
+		api.Get("/public/movies/search", movieHandler.SearchMoviesHandler())
+		api.Get("/public/movies/suggest", movieHandler.SuggestMoviesHandler())
 		api.Get("/public/movies/{id}", movieHandler.GetMovieHandler())
+		api.Get("/public/movies/{id}/reviews", reviewHandler.ListMovieReviewsHandler())
 		api.Get("/public/genres", movieHandler.ListGenresHandler())
+		api.Get("/public/people/{id}", personHandler.GetPersonHandler())
+		api.Get("/public/lists/{share_token}", listHandler.GetSharedListHandler())
+		api.Get("/public/users/{user_id}/lists", listHandler.ListPublicListsHandler())
 
 		// Movies with likes
 		api.Route("/movies", func(moviesWithLikesRouter chi.Router) {
-			moviesWithLikesRouter.Use(middleware.SessionAuthMiddleware)
+			moviesWithLikesRouter.Use(apiKeyAuth)
 
 			moviesWithLikesRouter.Get("/", movieHandler.ListMoviesWithGenresAndLikesHandler())
 			moviesWithLikesRouter.Get("/{movie_id}", movieHandler.GetMovieHandlerWithLike())
 			moviesWithLikesRouter.Post("/{movie_id}/like", userHandler.AddLikeHandler())
 			moviesWithLikesRouter.Delete("/{movie_id}/like", userHandler.RemoveLikeHandler())
+			moviesWithLikesRouter.Put("/{movie_id}/rating", userHandler.RateMovieHandler())
+			moviesWithLikesRouter.Delete("/{movie_id}/rating", userHandler.RemoveRatingHandler())
+
+			// The caller's own review of the movie
+			moviesWithLikesRouter.Post("/{movie_id}/reviews", reviewHandler.CreateReviewHandler())
+			moviesWithLikesRouter.Get("/{movie_id}/reviews", reviewHandler.GetReviewHandler())
+			moviesWithLikesRouter.Put("/{movie_id}/reviews", reviewHandler.UpdateReviewHandler())
+			moviesWithLikesRouter.Delete("/{movie_id}/reviews", reviewHandler.DeleteReviewHandler())
+			moviesWithLikesRouter.Post("/{movie_id}/reviews/{review_id}/helpful", reviewHandler.MarkHelpfulHandler())
+			moviesWithLikesRouter.Delete("/{movie_id}/reviews/{review_id}/helpful", reviewHandler.UnmarkHelpfulHandler())
+		})
+
+		// Personal movie lists
+		api.Route("/lists", func(lists chi.Router) {
+			lists.Use(apiKeyAuth)
+
+			lists.Get("/", listHandler.ListListsHandler())
+			lists.Post("/", listHandler.CreateListHandler())
+			lists.Get("/{list_id}", listHandler.GetListHandler())
+			lists.Put("/{list_id}", listHandler.UpdateListHandler())
+			lists.Delete("/{list_id}", listHandler.DeleteListHandler())
+			lists.Post("/{list_id}/entries", listHandler.AddEntryHandler())
+			lists.Put("/{list_id}/entries", listHandler.ReorderEntriesHandler())
+			lists.Delete("/{list_id}/entries/{movie_id}", listHandler.RemoveEntryHandler())
 		})
 
 		// Admin endpoints
 		api.Route("/admin", func(admin chi.Router) {
-			admin.Use(middleware.SessionAuthMiddleware)
+			admin.Use(apiKeyAuth)
+
+			// Editors curate the catalog and moderate reviews
+			admin.Group(func(editor chi.Router) {
+				editor.Use(middleware.RequireRole(userService, domain.RoleEditor))
+
+				editor.Post("/movies", movieHandler.CreateMovieHandler())
+				editor.Put("/movies/{id}", movieHandler.UpdateMovieHandler())
+				editor.Post("/movies/{id}/credits", movieHandler.AddCreditHandler())
+				editor.Delete("/movies/{id}/credits/{credit_id}", movieHandler.RemoveCreditHandler())
+
+				// Cast and crew catalog
+				editor.Get("/people", personHandler.ListPeopleHandler())
+				editor.Post("/people", personHandler.CreatePersonHandler())
+				editor.Put("/people/{id}", personHandler.UpdatePersonHandler())
+
+				// Review moderation queue
+				editor.Get("/reviews", reviewHandler.ListReviewsForModerationHandler())
+				editor.Put("/reviews/{id}", reviewHandler.ModerateReviewHandler())
+			})
+
+			// Destructive operations and user management are reserved for admins
+			admin.Group(func(adminOnly chi.Router) {
+				adminOnly.Use(middleware.RequireRole(userService, domain.RoleAdmin))
+
+				adminOnly.Delete("/movies/{id}", movieHandler.DeleteMovieHandler())
+				adminOnly.Delete("/people/{id}", personHandler.DeletePersonHandler())
+				adminOnly.Put("/users/{id}/role", userHandler.SetUserRoleHandler())
+				adminOnly.Delete("/users/{id}/2fa", twoFactorHandler.ResetHandler())
 
-			admin.Post("/movies", movieHandler.CreateMovieHandler())
-			admin.Put("/movies/{id}", movieHandler.UpdateMovieHandler())
-			admin.Delete("/movies/{id}", movieHandler.DeleteMovieHandler())
+				// Log of admin writes
+				adminOnly.Get("/audit", auditHandler.ListAuditEventsHandler())
+			})
 		})
 	})
 
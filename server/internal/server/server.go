@@ -1,69 +1,155 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/martishin/movie-search-service/internal/adapter"
+	"github.com/martishin/movie-search-service/internal/cache"
 	"github.com/martishin/movie-search-service/internal/handler"
+	"github.com/martishin/movie-search-service/internal/mailer"
 	"github.com/martishin/movie-search-service/internal/model/config"
 	"github.com/martishin/movie-search-service/internal/repository"
 	"github.com/martishin/movie-search-service/internal/route"
 	"github.com/martishin/movie-search-service/internal/service"
+	"github.com/martishin/movie-search-service/internal/sessionstore"
+	"github.com/martishin/movie-search-service/internal/throttle"
 	"github.com/redis/go-redis/v9"
 
 	"github.com/gorilla/sessions"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/providers/github"
 	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/openidConnect"
 )
 
-func configureGoogleOauth(config *config.OAuthConfig) {
-	store := sessions.NewCookieStore([]byte(config.SessionSecret))
+// startupTaskTimeout bounds each task run while the handler is built, so a slow Redis or PostgreSQL
+// delays startup rather than blocking it
+const startupTaskTimeout = 30 * time.Second
 
-	store.Options = &sessions.Options{
-		HttpOnly: true,
-		Secure:   config.IsProduction, // Enable secure cookies in production
-		Path:     "/",
-		MaxAge:   30 * 24 * 60 * 60, // 30 days
-		Domain:   config.Domain,
-	}
+func configureOauth(
+	logger *slog.Logger,
+	config *config.OAuthConfig,
+	providersConfig *config.OAuthProvidersConfig,
+	loginConfig *config.LoginProtectionConfig,
+	sessionBackend sessionstore.Backend,
+) {
+	// Sessions live on the server, so they can be listed and revoked; the cookie only names one
+	store := sessionstore.NewStore(
+		sessionBackend,
+		[]byte(config.SessionSecret),
+		&sessions.Options{
+			HttpOnly: true,
+			Secure:   config.IsProduction, // Enable secure cookies in production
+			Path:     "/",
+			MaxAge:   30 * 24 * 60 * 60, // 30 days
+			Domain:   config.Domain,
+		},
+		adapter.SessionUserID,
+		func(r *http.Request) string {
+			return adapter.ClientIP(r, loginConfig.TrustForwardedFor)
+		},
+	)
 
 	gothic.Store = store //nolint:reassign
 
 	// Configure Google provider
-	goth.UseProviders(
+	providers := []goth.Provider{
 		google.New(config.ClientID, config.ClientSecret, config.CallbackURL, "email", "profile"),
-	)
+	}
+
+	if gitHub := providersConfig.GitHub; gitHub != nil {
+		providers = append(providers, github.New(gitHub.ClientID, gitHub.ClientSecret, gitHub.CallbackURL, "read:user", "user:email"))
+	}
+
+	// The OpenID Connect provider is discovered over the network; when that fails the other providers still work
+	if oidc := providersConfig.OIDC; oidc != nil {
+		provider, err := openidConnect.New(oidc.ClientID, oidc.ClientSecret, oidc.CallbackURL, oidc.DiscoveryURL, "email", "profile")
+		if err != nil {
+			logger.Error("Failed to configure OpenID Connect provider", slog.Any("error", err), slog.String("provider", oidc.Name))
+		} else {
+			provider.SetName(oidc.Name)
+			providers = append(providers, provider)
+		}
+	}
+
+	goth.UseProviders(providers...)
+	for _, provider := range providers {
+		logger.Info("OAuth provider configured", slog.String("provider", provider.Name()))
+	}
+}
+
+// Repositories are the stores the services run on
+type Repositories struct {
+	Users       repository.UserStore
+	Movies      repository.MovieStore
+	Reviews     repository.ReviewStore
+	Lists       repository.ListStore
+	People      repository.PersonStore
+	Tokens      repository.UserTokenStore
+	TwoFactor   repository.TwoFactorStore
+	APIKeys     repository.APIKeyStore
+	Identities  repository.IdentityStore
+	DataExports repository.DataExportStore
+	Audit       repository.AuditStore
+	Transactor  repository.Transactor
 }
 
+// NewPostgresRepositories returns the repositories backed by PostgreSQL
+func NewPostgresRepositories(postgresPool *pgxpool.Pool) Repositories {
+	return Repositories{
+		Users:       repository.NewUserRepository(postgresPool),
+		Movies:      repository.NewMovieRepository(postgresPool),
+		Reviews:     repository.NewReviewRepository(postgresPool),
+		Lists:       repository.NewListRepository(postgresPool),
+		People:      repository.NewPersonRepository(postgresPool),
+		Tokens:      repository.NewUserTokenRepository(postgresPool),
+		TwoFactor:   repository.NewTwoFactorRepository(postgresPool),
+		APIKeys:     repository.NewAPIKeyRepository(postgresPool),
+		Identities:  repository.NewIdentityRepository(postgresPool),
+		DataExports: repository.NewDataExportRepository(postgresPool),
+		Audit:       repository.NewAuditRepository(postgresPool),
+		Transactor:  repository.NewPostgresTransactor(postgresPool),
+	}
+}
+
+// NewServer builds the API server. Its background work runs until ctx is cancelled.
 func NewServer(
+	ctx context.Context,
 	logger *slog.Logger,
 	postgresPool *pgxpool.Pool,
 	redisClient *redis.Client,
+	cacheBackend cache.Backend,
+	mailer mailer.Mailer,
 	serverConfig *config.ServerConfig,
 	oauthConfig *config.OAuthConfig,
+	oauthProvidersConfig *config.OAuthProvidersConfig,
+	searchConfig *config.SearchConfig,
+	rbacConfig *config.RBACConfig,
+	mailerConfig *config.MailerConfig,
+	twoFactorConfig *config.TwoFactorConfig,
+	loginConfig *config.LoginProtectionConfig,
 	alloyConfig *config.ObservabilityConfig,
 ) *http.Server {
-	// Initialize repositories
-	userRepo := repository.NewUserRepository(postgresPool)
-	movieRepo := repository.NewMovieRepository(postgresPool)
-
-	// Initialise services
-	userService := service.NewUserService(userRepo)
-	movieService := service.NewMovieService(movieRepo, redisClient)
-
-	// Initialize handlers
-	userHandler := handler.NewUserHandler(userService)
-	authHandler := handler.NewAuthHandler(userService, oauthConfig)
-	movieHandler := handler.NewMovieHandler(movieService)
-
-	handlers := route.RegisterRoutes(
+	handlers := NewHandler(
+		ctx,
 		logger,
-		userHandler,
-		authHandler,
-		movieHandler,
+		NewPostgresRepositories(postgresPool),
+		redisClient,
+		cacheBackend,
+		mailer,
+		oauthConfig,
+		oauthProvidersConfig,
+		searchConfig,
+		rbacConfig,
+		mailerConfig,
+		twoFactorConfig,
+		loginConfig,
 		alloyConfig,
 	)
 
@@ -80,9 +166,117 @@ func NewServer(
 	logger.Info("Cookie domain", slog.String("domain", oauthConfig.Domain))
 	logger.Info("Environment mode", slog.Bool("is_production", oauthConfig.IsProduction))
 
+	return server
+}
+
+// NewHandler builds the services and handlers on top of repos and returns the application's router.
+// redisClient may be nil, in which case title suggestions are served from the repositories
+// and failed logins and sessions are kept in process. The cache listener and the background jobs it starts run until
+// ctx is cancelled.
+func NewHandler(
+	ctx context.Context,
+	logger *slog.Logger,
+	repos Repositories,
+	redisClient *redis.Client,
+	cacheBackend cache.Backend,
+	mailer mailer.Mailer,
+	oauthConfig *config.OAuthConfig,
+	oauthProvidersConfig *config.OAuthProvidersConfig,
+	searchConfig *config.SearchConfig,
+	rbacConfig *config.RBACConfig,
+	mailerConfig *config.MailerConfig,
+	twoFactorConfig *config.TwoFactorConfig,
+	loginConfig *config.LoginProtectionConfig,
+	alloyConfig *config.ObservabilityConfig,
+) http.Handler {
+	// Movie cache shared by every service that reads or writes movies
+	movieCache := service.NewMovieCache(cacheBackend)
+	go movieCache.Listen(ctx, logger)
+
+	// Initialise services
+	userService := service.NewUserService(repos.Users, movieCache, rbacConfig)
+	movieService := service.NewMovieService(repos.Movies, redisClient, cacheBackend, movieCache, searchConfig)
+	reviewService := service.NewReviewService(repos.Reviews)
+	listService := service.NewListService(repos.Lists)
+	personService := service.NewPersonService(repos.People, movieCache)
+	twoFactorService := service.NewTwoFactorService(repos.Users, repos.TwoFactor, twoFactorConfig)
+	apiKeyService := service.NewAPIKeyService(repos.APIKeys)
+	identityService := service.NewIdentityService(userService, repos.Users, repos.Identities)
+	auditService := service.NewAuditService(repos.Audit, repos.Transactor)
+
+	// Failed logins and sessions are kept in Redis when there is one, so every instance sees them
+	var loginStore throttle.Store = throttle.NewMemoryStore()
+	var sessionBackend sessionstore.Backend = sessionstore.NewMemoryBackend()
+	if redisClient != nil {
+		loginStore = throttle.NewRedisStore(redisClient)
+		sessionBackend = sessionstore.NewRedisBackend(redisClient)
+	}
+	loginGuard := service.NewLoginGuard(loginStore, loginConfig)
+	sessionService := service.NewSessionService(sessionBackend)
+	accountService := service.NewAccountService(repos.Users, repos.Tokens, userService, sessionService, mailer, mailerConfig)
+
+	exportService := service.NewExportService(
+		repos.DataExports, repos.Transactor, userService, listService, apiKeyService, identityService, sessionService, mailer, mailerConfig,
+	)
+
+	// Accounts past their deletion grace period are purged and data exports are assembled in the background
+	go userService.RunAccountPurge(ctx, logger)
+	go exportService.Run(ctx, logger)
+
+	// Build the title autocomplete index from the current catalog
+	rebuildCtx, cancelRebuild := context.WithTimeout(context.Background(), startupTaskTimeout)
+	if err := movieService.RebuildSuggestionIndex(rebuildCtx); err != nil {
+		logger.Error("Failed to rebuild movie suggestion index", slog.Any("error", err))
+	} else {
+		logger.Info("Movie suggestion index rebuilt")
+	}
+	cancelRebuild()
+
+	// Promote the bootstrap admin if the account already exists
+	bootstrapCtx, cancelBootstrap := context.WithTimeout(context.Background(), startupTaskTimeout)
+	if promoted, err := userService.BootstrapAdmin(bootstrapCtx); err != nil {
+		logger.Error("Failed to bootstrap admin", slog.Any("error", err))
+	} else if promoted {
+		logger.Info("Bootstrap admin promoted", slog.String("email", rbacConfig.BootstrapAdminEmail))
+	}
+	cancelBootstrap()
+
+	// Initialize handlers; admin writes are recorded in the audit log
+	auditor := handler.NewAuditor(auditService, loginConfig)
+	userHandler := handler.NewUserHandler(userService, auditor)
+	authHandler := handler.NewAuthHandler(userService, accountService, identityService, loginGuard, oauthConfig, loginConfig, auditor)
+	movieHandler := handler.NewMovieHandler(movieService, auditor)
+	reviewHandler := handler.NewReviewHandler(reviewService, auditor)
+	listHandler := handler.NewListHandler(listService)
+	personHandler := handler.NewPersonHandler(personService, auditor)
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorService, accountService, loginGuard, loginConfig, auditor)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	identityHandler := handler.NewIdentityHandler(identityService)
+	accountHandler := handler.NewAccountHandler(accountService, loginGuard, loginConfig, auditor)
+	exportHandler := handler.NewExportHandler(exportService)
+	auditHandler := handler.NewAuditHandler(auditService)
+
 	// Configure OAuth
-	configureGoogleOauth(oauthConfig)
-	logger.Info("Google OAuth provider configured", slog.String("callback_url", oauthConfig.CallbackURL))
+	configureOauth(logger, oauthConfig, oauthProvidersConfig, loginConfig, sessionBackend)
 
-	return server
+	return route.RegisterRoutes(
+		logger,
+		userService,
+		userHandler,
+		authHandler,
+		movieHandler,
+		reviewHandler,
+		listHandler,
+		personHandler,
+		twoFactorHandler,
+		sessionHandler,
+		apiKeyService,
+		apiKeyHandler,
+		identityHandler,
+		accountHandler,
+		exportHandler,
+		auditHandler,
+		alloyConfig,
+	)
 }
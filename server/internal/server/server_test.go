@@ -0,0 +1,2421 @@
+package server_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/martishin/movie-search-service/internal/cache"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/mailer"
+	"github.com/martishin/movie-search-service/internal/model/config"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+	"github.com/martishin/movie-search-service/internal/repository/memory"
+	"github.com/martishin/movie-search-service/internal/repository/repositorytest"
+	"github.com/martishin/movie-search-service/internal/server"
+	"github.com/martishin/movie-search-service/internal/totp"
+)
+
+const (
+	adminEmail    = "admin@example.com"
+	frontendURL   = "http://localhost:5173/"
+	apiURL        = "http://localhost:8100"
+	testPassword  = "correct horse battery staple"
+	alloyUsername = "alloy"
+	alloyPassword = "alloy-secret"
+
+	maxLoginFailures      = 5
+	maxLoginFailuresPerIP = 8
+	loginLockout          = 90 * time.Second
+)
+
+// testAPI is the full router served over HTTP. It runs on the in-memory repositories,
+// or on PostgreSQL when TEST_POSTGRES_DSN is set.
+type testAPI struct {
+	t       *testing.T
+	server  *httptest.Server
+	repos   server.Repositories
+	mailDir string
+	idp     *fakeProvider
+	audit   *failingAuditStore
+	mailer  *failingMailer
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+
+	repos := memoryRepositories()
+	if os.Getenv(repositorytest.PostgresDSNEnv) != "" {
+		pool := repositorytest.OpenPostgres(t)
+		repositorytest.ResetPostgres(t, pool)
+		repos = server.NewPostgresRepositories(pool)
+	}
+	audit := &failingAuditStore{AuditStore: repos.Audit}
+	repos.Audit = audit
+
+	mailerConfig := &config.MailerConfig{
+		Backend:   config.MailerBackendFile,
+		From:      "Movie Search <no-reply@example.com>",
+		Directory: t.TempDir(),
+		AppURL:    "http://localhost:5173",
+		APIURL:    apiURL,
+	}
+	fileMailer, err := mailer.NewFileMailer(mailerConfig.Directory, mailerConfig.From)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testMailer := &failingMailer{Mailer: fileMailer}
+
+	// Background jobs stop with the test
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	handler := server.NewHandler(
+		ctx,
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		repos,
+		nil,
+		cache.NewMemoryBackend(),
+		testMailer,
+		&config.OAuthConfig{
+			SessionSecret: "test-session-secret",
+			CallbackURL:   "http://localhost/auth/callback",
+			RedirectURL:   frontendURL,
+		},
+		&config.OAuthProvidersConfig{},
+		&config.SearchConfig{SimilarityThreshold: 0.3},
+		&config.RBACConfig{BootstrapAdminEmail: adminEmail},
+		mailerConfig,
+		&config.TwoFactorConfig{Issuer: "Movie Search", EncryptionKey: bytes.Repeat([]byte{7}, 32)},
+		&config.LoginProtectionConfig{
+			MaxFailuresPerAccount: maxLoginFailures,
+			MaxFailuresPerIP:      maxLoginFailuresPerIP,
+			FailureWindow:         time.Minute,
+			LockoutDuration:       loginLockout,
+			DelayBase:             time.Millisecond,
+			DelayMax:              4 * time.Millisecond,
+			TrustForwardedFor:     true,
+		},
+		&config.ObservabilityConfig{AlloyUsername: alloyUsername, AlloyPassword: alloyPassword},
+	)
+
+	// Providers are registered globally; each test replaces the fake one with its own accounts
+	idp := &fakeProvider{accounts: make(map[string]goth.User)}
+	goth.UseProviders(idp)
+
+	api := &testAPI{
+		t:       t,
+		server:  httptest.NewServer(handler),
+		repos:   repos,
+		mailDir: mailerConfig.Directory,
+		idp:     idp,
+		audit:   audit,
+		mailer:  testMailer,
+	}
+	t.Cleanup(api.server.Close)
+	return api
+}
+
+func memoryRepositories() server.Repositories {
+	store := memory.NewStore()
+	return server.Repositories{
+		Users:       memory.NewUserRepository(store),
+		Movies:      memory.NewMovieRepository(store),
+		Reviews:     memory.NewReviewRepository(store),
+		Lists:       memory.NewListRepository(store),
+		People:      memory.NewPersonRepository(store),
+		Tokens:      memory.NewUserTokenRepository(store),
+		TwoFactor:   memory.NewTwoFactorRepository(store),
+		APIKeys:     memory.NewAPIKeyRepository(store),
+		Identities:  memory.NewIdentityRepository(store),
+		DataExports: memory.NewDataExportRepository(store),
+		Audit:       memory.NewAuditRepository(store),
+		Transactor:  memory.NewTransactor(store),
+	}
+}
+
+var mailTokenPattern = regexp.MustCompile(`\?token=([0-9a-f]+)`)
+
+// mails returns the messages sent to the address, oldest first
+func (api *testAPI) mails(to string) []string {
+	api.t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(api.mailDir, "*.eml"))
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	sort.Strings(files)
+
+	var mails []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			api.t.Fatal(err)
+		}
+		if strings.Contains(string(data), "\r\nTo: "+to+"\r\n") {
+			mails = append(mails, string(data))
+		}
+	}
+	return mails
+}
+
+// mailedToken returns the token in the latest mail to the address whose subject contains subject
+func (api *testAPI) mailedToken(to, subject string) string {
+	api.t.Helper()
+
+	mails := api.mails(to)
+	for i := len(mails) - 1; i >= 0; i-- {
+		if !strings.Contains(mails[i], "Subject: "+subject) {
+			continue
+		}
+		match := mailTokenPattern.FindStringSubmatch(mails[i])
+		if match == nil {
+			api.t.Fatalf("mail to %s has no token:\n%s", to, mails[i])
+		}
+		return match[1]
+	}
+	api.t.Fatalf("no %q mail to %s", subject, to)
+	return ""
+}
+
+// testClient is a browser: it keeps the session cookie between requests
+type testClient struct {
+	api    *testAPI
+	client *http.Client
+	header http.Header // sent with every request
+}
+
+func (api *testAPI) anonymous() *testClient {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	// Redirects are checked rather than followed; OAuth ones lead to the provider and the frontend
+	return &testClient{api: api, client: &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// withAPIKey returns a client authenticating with the personal API key instead of a session
+func (api *testAPI) withAPIKey(key string) *testClient {
+	client := api.anonymous()
+	client.header = http.Header{"Authorization": {"Bearer " + key}}
+	return client
+}
+
+// signUp registers a password account and returns a client signed in as it
+func (api *testAPI) signUp(email string) *testClient {
+	api.t.Helper()
+
+	client := api.anonymous()
+	client.do(http.MethodPost, "/auth/signup", map[string]string{
+		"first_name": "Test",
+		"last_name":  "User",
+		"email":      email,
+		"password":   testPassword,
+	}).expect(http.StatusCreated)
+	return client
+}
+
+// admin signs up the bootstrap admin account and verifies its email, which promotes it
+func (api *testAPI) admin() *testClient {
+	api.t.Helper()
+
+	admin := api.signUp(adminEmail)
+	token := api.mailedToken(adminEmail, "Verify your")
+	admin.do(http.MethodPost, "/auth/verify-email", map[string]string{"token": token}).expect(http.StatusOK)
+	return admin
+}
+
+// editor signs up an account and has admin promote it to editor
+func (api *testAPI) editor(admin *testClient, email string) *testClient {
+	api.t.Helper()
+
+	editor := api.signUp(email)
+	me := editor.me()
+	admin.do(http.MethodPut, fmt.Sprintf("/api/admin/users/%d/role", me.ID), map[string]string{"role": domain.RoleEditor}).
+		expect(http.StatusOK)
+	return editor
+}
+
+type testResponse struct {
+	t      *testing.T
+	method string
+	path   string
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (c *testClient) do(method, path string, body any) *testResponse {
+	return c.doWithHeader(method, path, body, nil)
+}
+
+func (c *testClient) doWithHeader(method, path string, body any, header http.Header) *testResponse {
+	t := c.api.t
+	t.Helper()
+
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(body)
+	default:
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, c.api.server.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range c.header {
+		request.Header[name] = values
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testResponse{t: t, method: method, path: path, status: response.StatusCode, header: response.Header, body: responseBody}
+}
+
+func (c *testClient) me() *domain.User {
+	c.api.t.Helper()
+
+	var user domain.User
+	c.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusOK).decode(&user)
+	return &user
+}
+
+func (r *testResponse) expect(status int) *testResponse {
+	r.t.Helper()
+	if r.status != status {
+		r.t.Fatalf("%s %s: status %d, want %d; body: %s", r.method, r.path, r.status, status, r.body)
+	}
+	return r
+}
+
+func (r *testResponse) decode(v any) {
+	r.t.Helper()
+	if err := json.Unmarshal(r.body, v); err != nil {
+		r.t.Fatalf("%s %s: invalid JSON %q: %v", r.method, r.path, r.body, err)
+	}
+}
+
+// expectError checks the status and that the body is the JSON error carrying message
+func (r *testResponse) expectError(status int, message string) {
+	r.t.Helper()
+	r.expect(status)
+
+	var body map[string]string
+	r.decode(&body)
+	if body["error"] != message {
+		r.t.Errorf("%s %s: error %q, want %q", r.method, r.path, body["error"], message)
+	}
+}
+
+func testMovie(title string, genreIDs ...int) domain.Movie {
+	movie := domain.Movie{
+		Title:       title,
+		ReleaseDate: time.Date(1999, time.March, 31, 0, 0, 0, 0, time.UTC),
+		RunTime:     136,
+		MPAARating:  "R",
+		Description: "A computer hacker learns about the true nature of his reality.",
+		Image:       "/poster.jpg",
+		Video:       "trailer-id",
+	}
+	for _, id := range genreIDs {
+		movie.Genres = append(movie.Genres, &domain.Genre{ID: id})
+	}
+	return movie
+}
+
+func createMovie(editor *testClient, movie domain.Movie) *domain.Movie {
+	editor.api.t.Helper()
+
+	var created domain.Movie
+	editor.do(http.MethodPost, "/api/admin/movies", movie).expect(http.StatusCreated).decode(&created)
+	if created.ID == 0 {
+		editor.api.t.Fatalf("created movie has no ID: %+v", created)
+	}
+	return &created
+}
+
+func genreID(client *testClient, name string) int {
+	client.api.t.Helper()
+
+	var genres []domain.Genre
+	client.do(http.MethodGet, "/api/public/genres", nil).expect(http.StatusOK).decode(&genres)
+	for _, genre := range genres {
+		if genre.Genre == name {
+			return genre.ID
+		}
+	}
+	client.api.t.Fatalf("genre %q is not seeded", name)
+	return 0
+}
+
+func TestHealth(t *testing.T) {
+	api := newTestAPI(t)
+
+	var body map[string]string
+	api.anonymous().do(http.MethodGet, "/", nil).expect(http.StatusOK).decode(&body)
+	if body["message"] != "Hello World!" {
+		t.Errorf("GET / = %v", body)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	api := newTestAPI(t)
+
+	user := api.signUp("ada@example.com")
+	me := user.me()
+	if me.Email != "ada@example.com" || me.FirstName != "Test" || me.Role != domain.RoleUser {
+		t.Errorf("GET /api/users/me = %+v", me)
+	}
+
+	anonymous := api.anonymous()
+	anonymous.do(http.MethodPost, "/auth/signup", map[string]string{"email": "ada@example.com", "password": "other"}).
+		expectError(http.StatusConflict, "User already exists")
+	anonymous.do(http.MethodPost, "/auth/signup", "{").expectError(http.StatusBadRequest, "Invalid request")
+
+	anonymous.do(http.MethodPost, "/auth/login", map[string]string{"email": "ada@example.com", "password": "wrong"}).
+		expectError(http.StatusUnauthorized, "Invalid credentials")
+	anonymous.do(http.MethodPost, "/auth/login", map[string]string{"email": "nobody@example.com", "password": testPassword}).
+		expectError(http.StatusUnauthorized, "Invalid credentials")
+	anonymous.do(http.MethodPost, "/auth/login", "not json").expectError(http.StatusBadRequest, "Invalid request")
+	anonymous.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+
+	var login map[string]string
+	anonymous.do(http.MethodPost, "/auth/login", map[string]string{"email": "ada@example.com", "password": testPassword}).
+		expect(http.StatusOK).decode(&login)
+	if login["message"] != "Login successful" {
+		t.Errorf("POST /auth/login = %v", login)
+	}
+	if anonymous.me().ID != me.ID {
+		t.Errorf("login signed in as another user")
+	}
+
+	anonymous.do(http.MethodPost, "/auth/logout", nil).expect(http.StatusNoContent)
+	anonymous.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+
+	// The bootstrap account becomes admin only once it verifies the email
+	bootstrap := api.signUp(strings.ToUpper(adminEmail))
+	if role := bootstrap.me().Role; role != domain.RoleUser {
+		t.Errorf("unverified bootstrap account has role %q", role)
+	}
+	token := api.mailedToken(strings.ToUpper(adminEmail), "Verify your")
+	anonymous.do(http.MethodPost, "/auth/verify-email", map[string]string{"token": token}).expect(http.StatusOK)
+	if role := bootstrap.me().Role; role != domain.RoleAdmin {
+		t.Errorf("bootstrap admin has role %q", role)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.signUp("ada@example.com")
+	anonymous := api.anonymous()
+
+	// Unknown addresses get the same answer and no mail
+	var message map[string]string
+	anonymous.do(http.MethodPost, "/auth/password/forgot", map[string]string{"email": "nobody@example.com"}).
+		expect(http.StatusAccepted).decode(&message)
+	if message["message"] != "If the account exists, a password reset email has been sent" {
+		t.Errorf("POST /auth/password/forgot = %v", message)
+	}
+	if mails := api.mails("nobody@example.com"); len(mails) != 0 {
+		t.Errorf("mailed an unknown address: %v", mails)
+	}
+	anonymous.do(http.MethodPost, "/auth/password/forgot", map[string]string{}).expectError(http.StatusBadRequest, "Invalid request")
+
+	anonymous.do(http.MethodPost, "/auth/password/forgot", map[string]string{"email": "ada@example.com"}).
+		expect(http.StatusAccepted)
+	superseded := api.mailedToken("ada@example.com", "Reset your")
+	anonymous.do(http.MethodPost, "/auth/password/forgot", map[string]string{"email": "ada@example.com"}).
+		expect(http.StatusAccepted)
+	token := api.mailedToken("ada@example.com", "Reset your")
+	if token == superseded {
+		t.Fatalf("the second reset mail reused the token")
+	}
+
+	anonymous.do(http.MethodPost, "/auth/password/reset", map[string]string{"token": token, "password": "short"}).
+		expectError(http.StatusBadRequest, "password must be between 8 and 72 characters")
+	anonymous.do(http.MethodPost, "/auth/password/reset", map[string]string{"token": superseded, "password": "a new password"}).
+		expectError(http.StatusBadRequest, "invalid or expired token")
+	anonymous.do(http.MethodPost, "/auth/password/reset", map[string]string{"token": "0123", "password": "a new password"}).
+		expectError(http.StatusBadRequest, "invalid or expired token")
+	anonymous.do(http.MethodPost, "/auth/password/reset", "{").expectError(http.StatusBadRequest, "Invalid request")
+
+	anonymous.do(http.MethodPost, "/auth/password/reset", map[string]string{"token": token, "password": "a new password"}).
+		expect(http.StatusOK).decode(&message)
+	if message["message"] != "Password has been reset" {
+		t.Errorf("POST /auth/password/reset = %v", message)
+	}
+	anonymous.do(http.MethodPost, "/auth/password/reset", map[string]string{"token": token, "password": "another password"}).
+		expectError(http.StatusBadRequest, "invalid or expired token")
+
+	anonymous.do(http.MethodPost, "/auth/login", map[string]string{"email": "ada@example.com", "password": testPassword}).
+		expectError(http.StatusUnauthorized, "Invalid credentials")
+	// The reset signed the account out everywhere
+	user.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+
+	anonymous.do(http.MethodPost, "/auth/login", map[string]string{"email": "ada@example.com", "password": "a new password"}).
+		expect(http.StatusOK)
+
+	// The reset link proved ownership of the address
+	if !anonymous.me().EmailVerified {
+		t.Errorf("password reset did not verify the email")
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.signUp("ada@example.com")
+	anonymous := api.anonymous()
+
+	if user.me().EmailVerified {
+		t.Fatalf("new user is verified")
+	}
+	signupToken := api.mailedToken("ada@example.com", "Verify your")
+
+	// Resending supersedes the signup mail
+	var message map[string]string
+	user.do(http.MethodPost, "/auth/verify-email/resend", nil).expect(http.StatusAccepted).decode(&message)
+	if message["message"] != "Verification email sent" {
+		t.Errorf("POST /auth/verify-email/resend = %v", message)
+	}
+	token := api.mailedToken("ada@example.com", "Verify your")
+
+	anonymous.do(http.MethodPost, "/auth/verify-email", map[string]string{"token": signupToken}).
+		expectError(http.StatusBadRequest, "invalid or expired token")
+	anonymous.do(http.MethodPost, "/auth/verify-email", "{").expectError(http.StatusBadRequest, "Invalid request")
+
+	// Verification tokens cannot reset passwords
+	anonymous.do(http.MethodPost, "/auth/password/reset", map[string]string{"token": token, "password": "a new password"}).
+		expectError(http.StatusBadRequest, "invalid or expired token")
+
+	anonymous.do(http.MethodPost, "/auth/verify-email", map[string]string{"token": token}).expect(http.StatusOK).decode(&message)
+	if message["message"] != "Email verified" {
+		t.Errorf("POST /auth/verify-email = %v", message)
+	}
+	anonymous.do(http.MethodPost, "/auth/verify-email", map[string]string{"token": token}).
+		expectError(http.StatusBadRequest, "invalid or expired token")
+
+	if !user.me().EmailVerified {
+		t.Errorf("verified user is not verified")
+	}
+	user.do(http.MethodPost, "/auth/verify-email/resend", nil).expectError(http.StatusConflict, "email is already verified")
+}
+
+// authenticator stands in for the user's authenticator app. Each code it returns is for a later time step
+// than the one before, as the server accepts each step once.
+type authenticator struct {
+	t      *testing.T
+	secret []byte
+	step   int64
+}
+
+func newAuthenticator(t *testing.T, enrollment domain.TwoFactorEnrollment) *authenticator {
+	t.Helper()
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	if err != nil {
+		t.Fatalf("invalid secret %q: %v", enrollment.Secret, err)
+	}
+	return &authenticator{t: t, secret: secret}
+}
+
+func (a *authenticator) code() string {
+	a.step = max(a.step+1, totp.Step(time.Now())-totp.Skew)
+	if a.step > totp.Step(time.Now())+totp.Skew {
+		a.t.Fatalf("the authenticator ran out of valid time steps")
+	}
+	return totp.Code(a.secret, a.step)
+}
+
+// enableTwoFactor enrolls the client's account and returns its authenticator and recovery codes
+func (c *testClient) enableTwoFactor() (*authenticator, []string) {
+	c.api.t.Helper()
+
+	var enrollment domain.TwoFactorEnrollment
+	c.do(http.MethodPost, "/api/users/me/2fa/enroll", nil).expect(http.StatusOK).decode(&enrollment)
+	app := newAuthenticator(c.api.t, enrollment)
+
+	var codes domain.RecoveryCodes
+	c.do(http.MethodPost, "/api/users/me/2fa/confirm", map[string]string{"code": app.code()}).
+		expect(http.StatusOK).decode(&codes)
+	return app, codes.RecoveryCodes
+}
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.signUp("ada@example.com")
+
+	user.do(http.MethodPost, "/api/users/me/2fa/confirm", map[string]string{"code": "123456"}).
+		expectError(http.StatusBadRequest, "start two-factor enrollment first")
+
+	var enrollment domain.TwoFactorEnrollment
+	user.do(http.MethodPost, "/api/users/me/2fa/enroll", nil).expect(http.StatusOK).decode(&enrollment)
+	if !strings.HasPrefix(enrollment.OTPAuthURI, "otpauth://totp/Movie%20Search:ada@example.com?") ||
+		!strings.Contains(enrollment.OTPAuthURI, "secret="+enrollment.Secret) {
+		t.Errorf("POST /api/users/me/2fa/enroll = %+v", enrollment)
+	}
+	app := newAuthenticator(t, enrollment)
+
+	// Enrolling again replaces the pending secret, so codes from the first one stop working
+	var restarted domain.TwoFactorEnrollment
+	user.do(http.MethodPost, "/api/users/me/2fa/enroll", nil).expect(http.StatusOK).decode(&restarted)
+	user.do(http.MethodPost, "/api/users/me/2fa/confirm", map[string]string{"code": app.code()}).
+		expectError(http.StatusBadRequest, "invalid two-factor code")
+	if user.me().TwoFactorEnabled {
+		t.Fatalf("an unconfirmed enrollment enabled two-factor")
+	}
+
+	app = newAuthenticator(t, restarted)
+	var codes domain.RecoveryCodes
+	user.do(http.MethodPost, "/api/users/me/2fa/confirm", map[string]string{"code": app.code()}).
+		expect(http.StatusOK).decode(&codes)
+	if len(codes.RecoveryCodes) != 10 {
+		t.Errorf("got %d recovery codes, want 10", len(codes.RecoveryCodes))
+	}
+	if !user.me().TwoFactorEnabled {
+		t.Errorf("confirmed enrollment did not enable two-factor")
+	}
+
+	user.do(http.MethodPost, "/api/users/me/2fa/enroll", nil).
+		expectError(http.StatusConflict, "two-factor authentication is already enabled")
+	user.do(http.MethodPost, "/api/users/me/2fa/confirm", map[string]string{"code": "123456"}).
+		expectError(http.StatusConflict, "two-factor authentication is already enabled")
+
+	// New recovery codes replace the old ones
+	var regenerated domain.RecoveryCodes
+	user.do(http.MethodPost, "/api/users/me/2fa/recovery-codes", map[string]string{"code": "000000"}).
+		expectError(http.StatusBadRequest, "invalid two-factor code")
+	user.do(http.MethodPost, "/api/users/me/2fa/recovery-codes", map[string]string{"code": app.code()}).
+		expect(http.StatusOK).decode(&regenerated)
+	user.do(http.MethodDelete, "/api/users/me/2fa", map[string]string{"code": codes.RecoveryCodes[0]}).
+		expectError(http.StatusBadRequest, "invalid two-factor code")
+
+	user.do(http.MethodDelete, "/api/users/me/2fa", map[string]string{"code": regenerated.RecoveryCodes[0]}).
+		expect(http.StatusNoContent)
+	if user.me().TwoFactorEnabled {
+		t.Errorf("two-factor is still enabled after disabling it")
+	}
+	user.do(http.MethodDelete, "/api/users/me/2fa", map[string]string{"code": regenerated.RecoveryCodes[1]}).
+		expectError(http.StatusConflict, "two-factor authentication is not enabled")
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	api := newTestAPI(t)
+	app, recoveryCodes := api.signUp("ada@example.com").enableTwoFactor()
+	credentials := map[string]string{"email": "ada@example.com", "password": testPassword}
+
+	client := api.anonymous()
+	client.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": "123456"}).
+		expectError(http.StatusUnauthorized, "No pending login, sign in again")
+
+	// The password alone leaves the session half-authenticated
+	var body map[string]any
+	client.do(http.MethodPost, "/auth/login", credentials).expect(http.StatusAccepted).decode(&body)
+	if body["two_factor_required"] != true {
+		t.Errorf("POST /auth/login = %v", body)
+	}
+	client.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+
+	client.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": "000000"}).
+		expectError(http.StatusUnauthorized, "invalid two-factor code")
+	code := app.code()
+	client.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": code}).expect(http.StatusOK)
+	if me := client.me(); me.Email != "ada@example.com" || !me.TwoFactorEnabled {
+		t.Errorf("GET /api/users/me = %+v", me)
+	}
+
+	// A code works once, and the pending login is gone after it completes
+	client.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": code}).
+		expectError(http.StatusUnauthorized, "No pending login, sign in again")
+	replay := api.anonymous()
+	replay.do(http.MethodPost, "/auth/login", credentials).expect(http.StatusAccepted)
+	replay.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": code}).
+		expectError(http.StatusUnauthorized, "invalid two-factor code")
+
+	// Recovery codes are single-use and tolerate case and spacing
+	typed := strings.ToUpper(strings.ReplaceAll(recoveryCodes[0], "-", " "))
+	replay.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": typed}).expect(http.StatusOK)
+	replay.me()
+
+	again := api.anonymous()
+	again.do(http.MethodPost, "/auth/login", credentials).expect(http.StatusAccepted)
+	again.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": recoveryCodes[0]}).
+		expectError(http.StatusUnauthorized, "invalid two-factor code")
+
+	// Signing up from a half-authenticated session drops the pending login
+	again.do(http.MethodPost, "/auth/signup", map[string]string{
+		"first_name": "Test",
+		"last_name":  "User",
+		"email":      "other@example.com",
+		"password":   testPassword,
+	}).expect(http.StatusCreated)
+	again.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": recoveryCodes[1]}).
+		expectError(http.StatusUnauthorized, "No pending login, sign in again")
+	if me := again.me(); me.Email != "other@example.com" {
+		t.Errorf("GET /api/users/me after signup = %+v", me)
+	}
+}
+
+func TestResetTwoFactor(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	user := api.signUp("ada@example.com")
+	user.enableTwoFactor()
+	path := fmt.Sprintf("/api/admin/users/%d/2fa", user.me().ID)
+
+	// A login waiting for its code cannot complete once two-factor is reset
+	pending := api.anonymous()
+	pending.do(http.MethodPost, "/auth/login", map[string]string{"email": "ada@example.com", "password": testPassword}).
+		expect(http.StatusAccepted)
+
+	admin.do(http.MethodDelete, path, nil).expect(http.StatusNoContent)
+	if user.me().TwoFactorEnabled {
+		t.Errorf("two-factor is still enabled after the reset")
+	}
+	pending.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": "123456"}).
+		expectError(http.StatusUnauthorized, "No pending login, sign in again")
+
+	api.anonymous().do(http.MethodPost, "/auth/login", map[string]string{"email": "ada@example.com", "password": testPassword}).
+		expect(http.StatusOK)
+
+	admin.do(http.MethodDelete, "/api/admin/users/424242/2fa", nil).expectError(http.StatusNotFound, "User not found")
+	admin.do(http.MethodDelete, "/api/admin/users/abc/2fa", nil).expectError(http.StatusBadRequest, "Invalid user ID")
+}
+
+// login attempts a password login from the IP
+func (c *testClient) login(email, password, ip string) *testResponse {
+	return c.doWithHeader(http.MethodPost, "/auth/login", map[string]string{"email": email, "password": password},
+		http.Header{"X-Forwarded-For": {"203.0.113.99, " + ip}})
+}
+
+func (r *testResponse) expectLockedOut() {
+	r.t.Helper()
+	r.expectError(http.StatusTooManyRequests, "too many failed login attempts, try again later")
+
+	retryAfter, err := strconv.Atoi(r.header.Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > int(loginLockout.Seconds()) {
+		r.t.Errorf("%s %s: Retry-After %q, want up to %v", r.method, r.path, r.header.Get("Retry-After"), loginLockout)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	api := newTestAPI(t)
+	adaID := api.signUp("ada@example.com").me().ID
+	api.signUp("grace@example.com")
+	client := api.anonymous()
+
+	// A successful login clears the account's failures
+	for range maxLoginFailures - 1 {
+		client.login("ada@example.com", "wrong password", "198.51.100.1").expectError(http.StatusUnauthorized, "Invalid credentials")
+	}
+	client.login("ada@example.com", testPassword, "198.51.100.1").expect(http.StatusOK)
+
+	for range maxLoginFailures - 1 {
+		client.login("ADA@example.com", "wrong password", "198.51.100.2").expectError(http.StatusUnauthorized, "Invalid credentials")
+	}
+	client.login("ada@example.com", "wrong password", "198.51.100.3").expectLockedOut()
+
+	// The account stays locked for the right password and from other addresses; other accounts are unaffected
+	client.login("ada@example.com", testPassword, "198.51.100.4").expectLockedOut()
+	client.login("grace@example.com", testPassword, "198.51.100.2").expect(http.StatusOK)
+
+	// An address guessing many accounts is locked out for all of them
+	for i := range maxLoginFailuresPerIP - 1 {
+		client.login(fmt.Sprintf("user%d@example.com", i), "guess", "192.0.2.1").expectError(http.StatusUnauthorized, "Invalid credentials")
+	}
+	client.login("grace@example.com", "guess", "192.0.2.1").expectLockedOut()
+	client.login("grace@example.com", testPassword, "192.0.2.1").expectLockedOut()
+	client.login("grace@example.com", testPassword, "192.0.2.2").expect(http.StatusOK)
+
+	// Both lockouts are in the audit log, newest first
+	lockouts := api.admin().auditEvents("?action=" + domain.AuditActionLockout).Events
+	if len(lockouts) != 2 {
+		t.Fatalf("lockout events = %+v", lockouts)
+	}
+	var ipLockout, accountLockout struct {
+		Scope   string `json:"scope"`
+		Account string `json:"account"`
+		Reason  string `json:"reason"`
+	}
+	json.Unmarshal(lockouts[0].After, &ipLockout)
+	json.Unmarshal(lockouts[1].After, &accountLockout)
+	if ipLockout.Scope != "ip" || lockouts[0].IP != "192.0.2.1" || lockouts[0].ActorID != 0 {
+		t.Errorf("IP lockout = %+v, %s", lockouts[0], lockouts[0].After)
+	}
+	if accountLockout.Scope != "account" || accountLockout.Account != "ada@example.com" ||
+		accountLockout.Reason != "invalid_password" || lockouts[1].EntityID != adaID {
+		t.Errorf("account lockout = %+v, %s", lockouts[1], lockouts[1].After)
+	}
+
+	header := http.Header{}
+	request, _ := http.NewRequest(http.MethodGet, "/", nil)
+	request.SetBasicAuth(alloyUsername, alloyPassword)
+	header.Set("Authorization", request.Header.Get("Authorization"))
+	metrics := client.doWithHeader(http.MethodGet, "/metrics", nil, header).expect(http.StatusOK).body
+	for _, metric := range []string{
+		`login_failures_total{reason="invalid_password"}`,
+		`login_failures_total{reason="unknown_account"}`,
+		`login_lockouts_total{scope="account"}`,
+		`login_lockouts_total{scope="ip"}`,
+		`login_locked_rejections_total{scope="account"}`,
+	} {
+		if !bytes.Contains(metrics, []byte(metric)) {
+			t.Errorf("metrics do not include %s", metric)
+		}
+	}
+}
+
+func TestTwoFactorLockout(t *testing.T) {
+	api := newTestAPI(t)
+	app, _ := api.signUp("ada@example.com").enableTwoFactor()
+
+	client := api.anonymous()
+	client.login("ada@example.com", testPassword, "198.51.100.1").expect(http.StatusAccepted)
+	for range maxLoginFailures - 1 {
+		client.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": "000000"}).
+			expectError(http.StatusUnauthorized, "invalid two-factor code")
+	}
+	client.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": "000000"}).expectLockedOut()
+	client.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": app.code()}).expectLockedOut()
+	client.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+}
+
+func TestSessions(t *testing.T) {
+	api := newTestAPI(t)
+	laptop := api.signUp("ada@example.com")
+	credentials := map[string]string{"email": "ada@example.com", "password": testPassword}
+
+	phone := api.anonymous()
+	phone.doWithHeader(http.MethodPost, "/auth/login", credentials, http.Header{
+		"User-Agent":      {"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 Version/17.4 Mobile/15E148 Safari/604.1"},
+		"X-Forwarded-For": {"198.51.100.7"},
+	}).expect(http.StatusOK)
+	tablet := api.anonymous()
+	tablet.do(http.MethodPost, "/auth/login", credentials).expect(http.StatusOK)
+
+	var sessions []domain.Session
+	laptop.do(http.MethodGet, "/api/users/me/sessions", nil).expect(http.StatusOK).decode(&sessions)
+	if len(sessions) != 3 {
+		t.Fatalf("GET /api/users/me/sessions = %+v, want 3 sessions", sessions)
+	}
+	var phoneSession *domain.Session
+	current := 0
+	for i, session := range sessions {
+		if session.Current {
+			current++
+		}
+		if session.IP == "198.51.100.7" {
+			phoneSession = &sessions[i]
+		}
+		if session.ID == "" || session.CreatedAt.IsZero() || session.LastSeenAt.Before(session.CreatedAt) {
+			t.Errorf("session %+v", session)
+		}
+	}
+	if current != 1 || sessions[0].Current != true {
+		t.Errorf("the current session is not the only current and most recently seen one: %+v", sessions)
+	}
+	if phoneSession == nil || phoneSession.Device != "Safari on iOS" {
+		t.Fatalf("phone session = %+v", phoneSession)
+	}
+
+	// Revoking a session signs that device out
+	path := "/api/users/me/sessions/" + phoneSession.ID
+	laptop.do(http.MethodDelete, path, nil).expect(http.StatusNoContent)
+	phone.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+	laptop.do(http.MethodDelete, path, nil).expectError(http.StatusNotFound, "Session not found")
+
+	// Another user cannot revoke the session
+	var tabletSessions []domain.Session
+	tablet.do(http.MethodGet, "/api/users/me/sessions", nil).expect(http.StatusOK).decode(&tabletSessions)
+	api.signUp("grace@example.com").do(http.MethodDelete, "/api/users/me/sessions/"+tabletSessions[0].ID, nil).
+		expectError(http.StatusNotFound, "Session not found")
+
+	var revoked map[string]int
+	laptop.do(http.MethodDelete, "/api/users/me/sessions", nil).expect(http.StatusOK).decode(&revoked)
+	if revoked["revoked"] != 1 {
+		t.Errorf("DELETE /api/users/me/sessions = %v", revoked)
+	}
+	tablet.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+	laptop.do(http.MethodGet, "/api/users/me/sessions", nil).expect(http.StatusOK).decode(&sessions)
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("sessions after revoking the others = %+v", sessions)
+	}
+}
+
+func TestLogoutEndsSessionOnServer(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.signUp("ada@example.com")
+
+	serverURL, err := url.Parse(api.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := user.client.Jar.Cookies(serverURL)
+
+	user.do(http.MethodPost, "/auth/logout", nil).expect(http.StatusNoContent)
+
+	// A copy of the cookie taken before the logout no longer works
+	stolen := api.anonymous()
+	stolen.client.Jar.SetCookies(serverURL, cookies)
+	stolen.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+}
+
+func (c *testClient) createAPIKey(name string, scopes ...string) *domain.CreatedAPIKey {
+	c.api.t.Helper()
+
+	var key domain.CreatedAPIKey
+	c.do(http.MethodPost, "/api/users/me/api-keys", map[string]any{"name": name, "scopes": scopes}).
+		expect(http.StatusCreated).decode(&key)
+	return &key
+}
+
+func TestAPIKeys(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	user := api.signUp("ada@example.com")
+	movie := createMovie(admin, testMovie("Heat"))
+	likePath := fmt.Sprintf("/api/movies/%d/like", movie.ID)
+
+	readKey := user.createAPIKey("Scripts", domain.APIKeyScopeRead)
+	if !strings.HasPrefix(readKey.Key, "msk_") || !strings.HasPrefix(readKey.Key, readKey.Prefix) ||
+		readKey.Name != "Scripts" || readKey.LastUsedAt != nil {
+		t.Errorf("created key = %+v", readKey)
+	}
+
+	// A read key reads as the user but cannot change anything
+	reader := api.withAPIKey(readKey.Key)
+	if me := reader.me(); me.Email != "ada@example.com" {
+		t.Errorf("GET /api/users/me with a read key = %+v", me)
+	}
+	reader.do(http.MethodGet, "/api/movies/", nil).expect(http.StatusOK)
+	reader.do(http.MethodPost, likePath, nil).expect(http.StatusForbidden)
+	reader.do(http.MethodPost, "/api/lists/", map[string]string{"name": "Watch later"}).expect(http.StatusForbidden)
+
+	// A likes key can like movies and nothing else
+	liker := api.withAPIKey(user.createAPIKey("Like bot", domain.APIKeyScopeLikes).Key)
+	liker.do(http.MethodPost, likePath, nil).expect(http.StatusOK)
+	liker.do(http.MethodDelete, likePath, nil).expect(http.StatusOK)
+	liker.do(http.MethodGet, "/api/movies/", nil).expect(http.StatusForbidden)
+	liker.do(http.MethodPut, fmt.Sprintf("/api/movies/%d/rating", movie.ID), map[string]int{"rating": 4}).
+		expect(http.StatusForbidden)
+
+	// The admin scope allows what the user's role allows
+	api.withAPIKey(user.createAPIKey("Everything", domain.APIKeyScopeAdmin).Key).
+		do(http.MethodGet, "/api/admin/people", nil).expect(http.StatusForbidden)
+	api.withAPIKey(admin.createAPIKey("Catalog import", domain.APIKeyScopeAdmin).Key).
+		do(http.MethodGet, "/api/admin/people", nil).expect(http.StatusOK)
+
+	// Reading the admin API takes the admin scope, even for admins
+	adminReader := api.withAPIKey(admin.createAPIKey("Dashboard", domain.APIKeyScopeRead).Key)
+	adminReader.do(http.MethodGet, "/api/movies/", nil).expect(http.StatusOK)
+	adminReader.do(http.MethodGet, "/api/admin/people", nil).expect(http.StatusForbidden)
+	adminReader.do(http.MethodGet, "/api/admin/audit", nil).expect(http.StatusForbidden)
+
+	// Keys cannot manage keys or other account settings
+	reader.do(http.MethodGet, "/api/users/me/api-keys", nil).expect(http.StatusUnauthorized)
+	reader.do(http.MethodGet, "/api/users/me/sessions", nil).expect(http.StatusUnauthorized)
+
+	for _, key := range []string{"msk_unknown", "not-a-key", ""} {
+		api.withAPIKey(key).do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+	}
+
+	var keys []domain.APIKey
+	user.do(http.MethodGet, "/api/users/me/api-keys", nil).expect(http.StatusOK).decode(&keys)
+	if len(keys) != 3 || keys[2].ID != readKey.ID || keys[2].Prefix != readKey.Prefix || keys[2].LastUsedAt == nil {
+		t.Fatalf("GET /api/users/me/api-keys = %+v", keys)
+	}
+	if strings.Contains(string(user.do(http.MethodGet, "/api/users/me/api-keys", nil).expect(http.StatusOK).body), readKey.Key) {
+		t.Error("the key listing contains a key")
+	}
+
+	user.do(http.MethodPost, "/api/users/me/api-keys", map[string]any{"name": " ", "scopes": []string{"read"}}).
+		expectError(http.StatusBadRequest, "API key name must be between 1 and 100 characters")
+	for _, scopes := range [][]string{nil, {"root"}, {"read", "write"}} {
+		user.do(http.MethodPost, "/api/users/me/api-keys", map[string]any{"name": "Bad", "scopes": scopes}).
+			expectError(http.StatusBadRequest, "scopes must be one or more of read, likes and admin")
+	}
+	user.do(http.MethodPost, "/api/users/me/api-keys", "{").expectError(http.StatusBadRequest, "Invalid request")
+
+	// Revoked keys stop working at once; only the owner can revoke a key
+	path := fmt.Sprintf("/api/users/me/api-keys/%d", readKey.ID)
+	admin.do(http.MethodDelete, path, nil).expectError(http.StatusNotFound, "API key not found")
+	user.do(http.MethodDelete, path, nil).expect(http.StatusNoContent)
+	reader.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+	user.do(http.MethodDelete, path, nil).expectError(http.StatusNotFound, "API key not found")
+	user.do(http.MethodDelete, "/api/users/me/api-keys/abc", nil).expectError(http.StatusBadRequest, "Invalid API key ID")
+}
+
+const fakeProviderName = "idp"
+
+// fakeProvider is an identity provider whose consent screen is skipped: the code the callback receives names the
+// account that signed in
+type fakeProvider struct {
+	faux.Provider
+	accounts map[string]goth.User
+}
+
+type fakeSession struct {
+	AuthURL string
+	Code    string
+}
+
+func (p *fakeProvider) Name() string {
+	return fakeProviderName
+}
+
+func (p *fakeProvider) BeginAuth(state string) (goth.Session, error) {
+	return &fakeSession{AuthURL: "https://idp.example.com/authorize?state=" + url.QueryEscape(state)}, nil
+}
+
+func (p *fakeProvider) UnmarshalSession(data string) (goth.Session, error) {
+	var session fakeSession
+	err := json.Unmarshal([]byte(data), &session)
+	return &session, err
+}
+
+func (p *fakeProvider) FetchUser(session goth.Session) (goth.User, error) {
+	account, ok := p.accounts[session.(*fakeSession).Code]
+	if !ok {
+		return goth.User{}, fmt.Errorf("no account %q", session.(*fakeSession).Code)
+	}
+	account.Provider = fakeProviderName
+	return account, nil
+}
+
+func (s *fakeSession) GetAuthURL() (string, error) {
+	return s.AuthURL, nil
+}
+
+func (s *fakeSession) Marshal() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func (s *fakeSession) Authorize(_ goth.Provider, params goth.Params) (string, error) {
+	s.Code = params.Get("code")
+	return s.Code, nil
+}
+
+// addAccount creates an account at the fake provider, reporting whether the provider verified its email
+func (api *testAPI) addAccount(subject, email string, emailVerified bool) {
+	api.idp.accounts[subject] = goth.User{
+		UserID:    subject,
+		Email:     email,
+		Name:      "Octo Cat",
+		NickName:  subject,
+		AvatarURL: "https://idp.example.com/" + subject + ".png",
+		RawData:   map[string]any{"email_verified": emailVerified},
+	}
+}
+
+// signInWith runs the provider's sign-in as the account and returns the frontend URL it ends on
+func (c *testClient) signInWith(subject string) *url.URL {
+	c.api.t.Helper()
+
+	start := c.do(http.MethodGet, "/auth/start?provider="+fakeProviderName, nil).expect(http.StatusTemporaryRedirect)
+	return c.completeOAuth(start.header.Get("Location"), subject)
+}
+
+// completeOAuth consents at the provider's auth URL as the account and follows the provider back to the callback
+func (c *testClient) completeOAuth(authURL, subject string) *url.URL {
+	c.api.t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		c.api.t.Fatal(err)
+	}
+	query := url.Values{"provider": {fakeProviderName}, "state": {parsed.Query().Get("state")}, "code": {subject}}
+	callback := c.do(http.MethodGet, "/auth/callback?"+query.Encode(), nil).expect(http.StatusFound)
+
+	location, err := url.Parse(callback.header.Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), frontendURL) {
+		c.api.t.Fatalf("callback redirected to %q", callback.header.Get("Location"))
+	}
+	return location
+}
+
+// link links the account at the provider to the signed-in user and returns the frontend URL the flow ends on
+func (c *testClient) link(subject string) *url.URL {
+	c.api.t.Helper()
+
+	var started map[string]string
+	c.do(http.MethodPost, "/api/users/me/identities/"+fakeProviderName, nil).expect(http.StatusOK).decode(&started)
+	return c.completeOAuth(started["auth_url"], subject)
+}
+
+func TestOAuthSignInBootstrapsAdmin(t *testing.T) {
+	// A provider that does not vouch for the email cannot claim the bootstrap admin
+	api := newTestAPI(t)
+	api.addAccount("unverified", adminEmail, false)
+	browser := api.anonymous()
+	browser.signInWith("unverified")
+	if me := browser.me(); me.Role != domain.RoleUser || me.EmailVerified {
+		t.Errorf("unverified bootstrap sign-in = %+v", me)
+	}
+
+	api = newTestAPI(t)
+	api.addAccount("verified", strings.ToUpper(adminEmail), true)
+	browser = api.anonymous()
+	browser.signInWith("verified")
+	if role := browser.me().Role; role != domain.RoleAdmin {
+		t.Errorf("verified bootstrap sign-in has role %q", role)
+	}
+}
+
+func TestOAuthSignIn(t *testing.T) {
+	api := newTestAPI(t)
+	api.addAccount("octocat", "octo@example.com", true)
+
+	browser := api.anonymous()
+	if location := browser.signInWith("octocat"); location.RawQuery != "" {
+		t.Errorf("first sign-in redirected to %s", location)
+	}
+	octo := browser.me()
+	if octo.Email != "octo@example.com" || !octo.EmailVerified || octo.FirstName != "Octo" || octo.LastName != "Cat" ||
+		octo.PictureURL != "https://idp.example.com/octocat.png" {
+		t.Errorf("user created on sign-in = %+v", octo)
+	}
+
+	// The account signs in to the same user from then on
+	again := api.anonymous()
+	again.signInWith("octocat")
+	if me := again.me(); me.ID != octo.ID {
+		t.Errorf("second sign-in as user %d, want %d", me.ID, octo.ID)
+	}
+
+	// An email of a password account does not sign in to it
+	api.signUp("ada@example.com")
+	api.addAccount("ada-at-idp", "ada@example.com", true)
+	stranger := api.anonymous()
+	if location := stranger.signInWith("ada-at-idp"); location.Query().Get("error") != "account_exists" {
+		t.Errorf("sign-in with a password account's email redirected to %s", location)
+	}
+	stranger.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+
+	api.addAccount("anonymous", "", false)
+	if location := api.anonymous().signInWith("anonymous"); location.Query().Get("error") != "email_required" {
+		t.Errorf("sign-in without an email redirected to %s", location)
+	}
+}
+
+func TestOAuthSignInToLegacyAccount(t *testing.T) {
+	api := newTestAPI(t)
+
+	// Signed up with Google before identities were recorded: no password and nothing linked
+	legacy, err := api.repos.Users.CreateUser(context.Background(), "Old", "Timer", "old@example.com", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api.addAccount("unverified", "old@example.com", false)
+	if location := api.anonymous().signInWith("unverified"); location.Query().Get("error") != "account_exists" {
+		t.Errorf("sign-in with an unverified email redirected to %s", location)
+	}
+
+	// A verified email links the first account to sign in, and only it
+	api.addAccount("verified", "old@example.com", true)
+	browser := api.anonymous()
+	browser.signInWith("verified")
+	if me := browser.me(); me.ID != int(legacy.ID) || !me.EmailVerified {
+		t.Errorf("legacy sign-in = %+v", me)
+	}
+	api.addAccount("another", "old@example.com", true)
+	if location := api.anonymous().signInWith("another"); location.Query().Get("error") != "account_exists" {
+		t.Errorf("second account with the legacy email redirected to %s", location)
+	}
+}
+
+func TestLinkIdentities(t *testing.T) {
+	api := newTestAPI(t)
+	api.addAccount("ada-at-idp", "ada@example.com", true)
+	api.addAccount("ada-second", "ada@work.example.com", true)
+
+	ada := api.signUp("ada@example.com")
+	if location := ada.link("ada-at-idp"); location.Query().Get("linked") != fakeProviderName {
+		t.Errorf("link redirected to %s", location)
+	}
+	me := ada.me()
+	if !me.EmailVerified {
+		t.Error("linking an account that verified the user's email did not verify it")
+	}
+
+	var identities []domain.Identity
+	ada.do(http.MethodGet, "/api/users/me/identities", nil).expect(http.StatusOK).decode(&identities)
+	if len(identities) != 1 || identities[0].Provider != fakeProviderName || identities[0].Email != "ada@example.com" {
+		t.Errorf("GET /api/users/me/identities = %+v", identities)
+	}
+
+	// The linked account signs in to the password account now
+	browser := api.anonymous()
+	browser.signInWith("ada-at-idp")
+	if signedIn := browser.me(); signedIn.ID != me.ID {
+		t.Errorf("sign-in with the linked account as user %d, want %d", signedIn.ID, me.ID)
+	}
+
+	if location := ada.link("ada-second"); location.Query().Get("link_error") != "provider_already_linked" {
+		t.Errorf("second link at the provider redirected to %s", location)
+	}
+	grace := api.signUp("grace@example.com")
+	if location := grace.link("ada-at-idp"); location.Query().Get("link_error") != "identity_in_use" {
+		t.Errorf("linking another user's account redirected to %s", location)
+	}
+	grace.me()
+	grace.do(http.MethodPost, "/api/users/me/identities/unknown", nil).expectError(http.StatusNotFound, "Unknown provider")
+
+	// A callback without a started link is a sign-in, even in a signed-in browser
+	api.addAccount("grace-at-idp", "grace@example.com", true)
+	if location := grace.signInWith("grace-at-idp"); location.Query().Get("error") != "account_exists" {
+		t.Errorf("sign-in from a signed-in browser redirected to %s", location)
+	}
+
+	path := "/api/users/me/identities/" + fakeProviderName
+	ada = api.anonymous()
+	ada.login("ada@example.com", testPassword, "").expect(http.StatusOK)
+	ada.do(http.MethodDelete, path, nil).expect(http.StatusNoContent)
+	ada.do(http.MethodDelete, path, nil).expectError(http.StatusNotFound, "no account at this provider is linked")
+	if location := api.anonymous().signInWith("ada-at-idp"); location.Query().Get("error") != "account_exists" {
+		t.Errorf("sign-in with an unlinked account redirected to %s", location)
+	}
+
+	// An account without a password keeps its last provider
+	api.addAccount("octocat", "octo@example.com", true)
+	octo := api.anonymous()
+	octo.signInWith("octocat")
+	octo.do(http.MethodDelete, path, nil).
+		expectError(http.StatusConflict, "set a password or link another provider before unlinking the last one")
+}
+
+func TestOAuthSignInWithTwoFactor(t *testing.T) {
+	api := newTestAPI(t)
+	api.addAccount("ada-at-idp", "ada@example.com", true)
+
+	ada := api.signUp("ada@example.com")
+	app, _ := ada.enableTwoFactor()
+	ada.link("ada-at-idp")
+
+	browser := api.anonymous()
+	if location := browser.signInWith("ada-at-idp"); location.Query().Get("two_factor") != "required" {
+		t.Errorf("sign-in to a two-factor account redirected to %s", location)
+	}
+	browser.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+	browser.do(http.MethodPost, "/auth/2fa/verify", map[string]string{"code": app.code()}).expect(http.StatusOK)
+	browser.me()
+}
+
+func TestUpdateProfile(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.signUp("ada@example.com")
+
+	me := ada.me()
+	if !me.HasPassword || string(me.Preferences) != "{}" {
+		t.Errorf("new user = %+v", me)
+	}
+
+	var updated domain.User
+	ada.do(http.MethodPatch, "/api/users/me", map[string]any{
+		"first_name":  "  Augusta ",
+		"preferences": map[string]any{"theme": "dark", "autoplay": false},
+	}).expect(http.StatusOK).decode(&updated)
+	if updated.FirstName != "Augusta" || updated.LastName != "User" || updated.Email != "ada@example.com" {
+		t.Errorf("PATCH /api/users/me = %+v", updated)
+	}
+
+	// Fields left out keep their values
+	ada.do(http.MethodPatch, "/api/users/me", map[string]string{"picture_url": "https://example.com/ada.png"}).
+		expect(http.StatusOK)
+	me = ada.me()
+	var preferences map[string]any
+	if err := json.Unmarshal(me.Preferences, &preferences); err != nil || preferences["theme"] != "dark" {
+		t.Errorf("preferences = %s, %v", me.Preferences, err)
+	}
+	if me.FirstName != "Augusta" || me.PictureURL != "https://example.com/ada.png" {
+		t.Errorf("GET /api/users/me after updates = %+v", me)
+	}
+
+	for _, tt := range []struct {
+		body    any
+		message string
+	}{
+		{map[string]string{"first_name": " "}, "names must be between 1 and 50 characters"},
+		{map[string]string{"last_name": strings.Repeat("é", 51)}, "names must be between 1 and 50 characters"},
+		{map[string]string{"picture_url": "javascript:alert(1)"}, "picture URL must be an http or https URL"},
+		{map[string]string{"picture_url": "/relative.png"}, "picture URL must be an http or https URL"},
+		{map[string]any{"preferences": []int{1}}, "preferences must be a JSON object of at most 4096 bytes"},
+		{map[string]any{"preferences": map[string]string{"notes": strings.Repeat("x", 4096)}},
+			"preferences must be a JSON object of at most 4096 bytes"},
+		{"not json", "Invalid request"},
+	} {
+		ada.do(http.MethodPatch, "/api/users/me", tt.body).expectError(http.StatusBadRequest, tt.message)
+	}
+
+	// An empty picture URL removes the picture
+	ada.do(http.MethodPatch, "/api/users/me", map[string]string{"picture_url": ""}).expect(http.StatusOK)
+	if me := ada.me(); me.PictureURL != "" {
+		t.Errorf("picture after removing it = %q", me.PictureURL)
+	}
+
+	// Account settings take the session, not an API key
+	key := ada.createAPIKey("Scripts", domain.APIKeyScopeAdmin)
+	api.withAPIKey(key.Key).do(http.MethodPatch, "/api/users/me", map[string]string{"first_name": "Bot"}).
+		expect(http.StatusUnauthorized)
+}
+
+func TestChangePassword(t *testing.T) {
+	api := newTestAPI(t)
+	laptop := api.signUp("ada@example.com")
+	phone := api.anonymous()
+	phone.login("ada@example.com", testPassword, "198.51.100.1").expect(http.StatusOK)
+
+	path := "/api/users/me/password"
+	newPassword := "a brand new passphrase"
+	laptop.do(http.MethodPut, path, map[string]string{"current_password": "wrong", "new_password": newPassword}).
+		expectError(http.StatusForbidden, "current password is incorrect")
+	laptop.do(http.MethodPut, path, map[string]string{"current_password": testPassword, "new_password": "short"}).
+		expectError(http.StatusBadRequest, "password must be between 8 and 72 characters")
+
+	laptop.do(http.MethodPut, path, map[string]string{"current_password": testPassword, "new_password": newPassword}).
+		expect(http.StatusOK)
+
+	// Only the session that changed the password stays signed in
+	laptop.me()
+	phone.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+	phone.login("ada@example.com", testPassword, "198.51.100.1").expectError(http.StatusUnauthorized, "Invalid credentials")
+	phone.login("ada@example.com", newPassword, "198.51.100.1").expect(http.StatusOK)
+
+	// An account that signs in with a provider sets its first password without a current one
+	api.addAccount("octocat", "octo@example.com", true)
+	octo := api.anonymous()
+	octo.signInWith("octocat")
+	if octo.me().HasPassword {
+		t.Error("provider account has a password")
+	}
+	api.anonymous().login("octo@example.com", newPassword, "198.51.100.2").
+		expectError(http.StatusUnauthorized, "This account signs in with an external provider. Please log in with it.")
+	identity := "/api/users/me/identities/" + fakeProviderName
+	octo.do(http.MethodDelete, identity, nil).expect(http.StatusConflict)
+
+	octo.do(http.MethodPut, path, map[string]string{"new_password": newPassword}).expect(http.StatusOK)
+	if !octo.me().HasPassword {
+		t.Error("setting a password did not give the account one")
+	}
+	octo.do(http.MethodPut, path, map[string]string{"new_password": testPassword}).
+		expectError(http.StatusForbidden, "current password is incorrect")
+
+	// With a password the provider can go
+	octo.do(http.MethodDelete, identity, nil).expect(http.StatusNoContent)
+	api.anonymous().login("octo@example.com", newPassword, "198.51.100.2").expect(http.StatusOK)
+}
+
+func TestChangePasswordLockout(t *testing.T) {
+	api := newTestAPI(t)
+	ada := api.signUp("ada@example.com")
+
+	path := "/api/users/me/password"
+	wrong := map[string]string{"current_password": "wrong", "new_password": "a brand new passphrase"}
+	for range maxLoginFailures - 1 {
+		ada.do(http.MethodPut, path, wrong).expectError(http.StatusForbidden, "current password is incorrect")
+	}
+	ada.do(http.MethodPut, path, wrong).expectLockedOut()
+	ada.do(http.MethodPut, path, map[string]string{"current_password": testPassword, "new_password": "a brand new passphrase"}).
+		expectLockedOut()
+
+	// Signing in is counted apart, so guessing through a session does not lock the owner out
+	api.anonymous().login("ada@example.com", testPassword, "198.51.100.1").expect(http.StatusOK)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	api := newTestAPI(t)
+	laptop := api.signUp("ada@example.com")
+	phone := api.anonymous()
+	phone.login("ada@example.com", testPassword, "198.51.100.1").expect(http.StatusOK)
+	key := laptop.createAPIKey("Scripts", domain.APIKeyScopeRead)
+
+	laptop.do(http.MethodDelete, "/api/users/me", map[string]string{"password": "wrong"}).
+		expectError(http.StatusForbidden, "password is incorrect")
+
+	var deleted struct {
+		DeletesAt time.Time `json:"deletes_at"`
+	}
+	laptop.do(http.MethodDelete, "/api/users/me", map[string]string{"password": testPassword}).
+		expect(http.StatusAccepted).decode(&deleted)
+	if until := time.Until(deleted.DeletesAt); until < 29*24*time.Hour || until > 31*24*time.Hour {
+		t.Errorf("deletes_at = %v, want in 30 days", deleted.DeletesAt)
+	}
+
+	// The account is signed out everywhere and its keys stop working
+	laptop.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+	phone.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+	api.withAPIKey(key.Key).do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+	api.anonymous().do(http.MethodPost, "/auth/signup", map[string]string{
+		"first_name": "Ada", "last_name": "Again", "email": "ada@example.com", "password": testPassword,
+	}).expectError(http.StatusConflict, "User already exists")
+
+	// Signing in within the grace period restores it
+	laptop.login("ada@example.com", testPassword, "198.51.100.1").expect(http.StatusOK)
+	laptop.me()
+	api.withAPIKey(key.Key).do(http.MethodGet, "/api/users/me", nil).expect(http.StatusOK)
+
+	// Once purged, nothing of the account is left
+	laptop.do(http.MethodDelete, "/api/users/me", map[string]string{"password": testPassword}).expect(http.StatusAccepted)
+	if purged, _, err := api.repos.Users.PurgeDeletedUsers(context.Background(), 0); err != nil || purged != 1 {
+		t.Fatalf("PurgeDeletedUsers = %d, %v; want 1", purged, err)
+	}
+	laptop.login("ada@example.com", testPassword, "198.51.100.1").expectError(http.StatusUnauthorized, "Invalid credentials")
+	api.signUp("ada@example.com").me()
+}
+
+func TestDeleteAccountSignedInWithProvider(t *testing.T) {
+	api := newTestAPI(t)
+	api.addAccount("octocat", "octo@example.com", true)
+
+	octo := api.anonymous()
+	octo.signInWith("octocat")
+	before := octo.me()
+	octo.do(http.MethodDelete, "/api/users/me", map[string]string{}).expect(http.StatusAccepted)
+	octo.do(http.MethodGet, "/api/users/me", nil).expect(http.StatusUnauthorized)
+
+	// The provider still signs in to the account, which restores it
+	octo.signInWith("octocat")
+	if me := octo.me(); me.ID != before.ID {
+		t.Errorf("signed in as user %d after restoring, want %d", me.ID, before.ID)
+	}
+}
+
+// waitForMail waits for the background worker to mail the address a message whose subject contains subject,
+// and returns the token in it
+func (api *testAPI) waitForMail(to, subject string) string {
+	api.t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for _, mail := range api.mails(to) {
+			if strings.Contains(mail, "Subject: "+subject) {
+				return api.mailedToken(to, subject)
+			}
+		}
+	}
+	api.t.Fatalf("no %q mail to %s", subject, to)
+	return ""
+}
+
+func TestDataExport(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	user := api.signUp("fan@example.com")
+	other := api.signUp("other@example.com")
+	anonymous := api.anonymous()
+
+	heat := createMovie(admin, testMovie("Heat"))
+	alien := createMovie(admin, testMovie("Alien"))
+	user.do(http.MethodPost, fmt.Sprintf("/api/movies/%d/like", heat.ID), nil).expect(http.StatusOK)
+	user.do(http.MethodPost, fmt.Sprintf("/api/movies/%d/like", alien.ID), nil).expect(http.StatusOK)
+	user.do(http.MethodPut, fmt.Sprintf("/api/movies/%d/rating", alien.ID), map[string]int{"rating": 5}).expect(http.StatusOK)
+	user.do(http.MethodPost, fmt.Sprintf("/api/movies/%d/reviews", heat.ID), map[string]string{"body": "A classic"}).
+		expect(http.StatusCreated)
+	var list domain.UserList
+	user.do(http.MethodPost, "/api/lists/", map[string]string{"name": "Heists"}).expect(http.StatusCreated).decode(&list)
+	user.do(http.MethodPost, fmt.Sprintf("/api/lists/%d/entries", list.ID), map[string]int{"movie_id": heat.ID}).
+		expect(http.StatusOK)
+
+	var export domain.DataExport
+	user.do(http.MethodPost, "/api/users/me/export", nil).expect(http.StatusAccepted).decode(&export)
+	if export.ID == 0 || export.Status != domain.DataExportStatusPending || export.ExpiresAt != nil {
+		t.Errorf("POST export = %+v", export)
+	}
+
+	// The archive is assembled in the background and its link mailed
+	token := api.waitForMail("fan@example.com", "Your Movie Search data export is ready")
+	mails := api.mails("fan@example.com")
+	if link := apiURL + "/api/exports/download?token=" + token; !strings.Contains(mails[len(mails)-1], link) {
+		t.Errorf("export mail does not link to the API at %s", link)
+	}
+
+	exportPath := fmt.Sprintf("/api/users/me/export/%d", export.ID)
+	user.do(http.MethodGet, exportPath, nil).expect(http.StatusOK).decode(&export)
+	if export.Status != domain.DataExportStatusReady || export.CompletedAt == nil || export.ExpiresAt == nil {
+		t.Errorf("GET ready export = %+v", export)
+	}
+	other.do(http.MethodGet, exportPath, nil).expectError(http.StatusNotFound, "Data export not found")
+	user.do(http.MethodGet, "/api/users/me/export/abc", nil).expectError(http.StatusBadRequest, "Invalid export ID")
+
+	// The link works without signing in
+	response := anonymous.do(http.MethodGet, "/api/exports/download?token="+token, nil).expect(http.StatusOK)
+	if contentType := response.header.Get("Content-Type"); contentType != "application/zip" {
+		t.Errorf("download Content-Type = %q, want application/zip", contentType)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(response.body), int64(len(response.body)))
+	if err != nil {
+		t.Fatalf("download is not a ZIP archive: %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name], err = io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{
+		"profile.json", "likes.json", "ratings.json", "reviews.json",
+		"lists.json", "sessions.json", "api_keys.json", "identities.json",
+	} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("archive has no %s; it has %v", name, slices.Collect(maps.Keys(files)))
+		}
+	}
+
+	var profile domain.User
+	var likes []domain.ExportedLike
+	var ratings []domain.ExportedRating
+	var reviews []domain.ExportedReview
+	var lists []domain.UserList
+	var sessions []domain.Session
+	for name, v := range map[string]any{
+		"profile.json": &profile, "likes.json": &likes, "ratings.json": &ratings, "reviews.json": &reviews,
+		"lists.json": &lists, "sessions.json": &sessions,
+	} {
+		if err := json.Unmarshal(files[name], v); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if profile.Email != "fan@example.com" {
+		t.Errorf("profile.json = %+v", profile)
+	}
+	if len(likes) != 2 || likes[0].MovieID != heat.ID || likes[1].MovieID != alien.ID || likes[0].LikedAt.IsZero() {
+		t.Errorf("likes.json = %+v; want Heat then Alien with when they were liked", likes)
+	}
+	if len(ratings) != 1 || ratings[0].MovieID != alien.ID || ratings[0].Rating != 5 {
+		t.Errorf("ratings.json = %+v", ratings)
+	}
+	if len(reviews) != 1 || reviews[0].Body != "A classic" || reviews[0].Status != domain.ReviewStatusPending {
+		t.Errorf("reviews.json = %+v", reviews)
+	}
+	if len(lists) != 1 || len(lists[0].Entries) != 1 || lists[0].Entries[0].MovieID != heat.ID {
+		t.Errorf("lists.json = %+v", lists)
+	}
+	if len(sessions) != 1 {
+		t.Errorf("sessions.json = %+v, want the signed-in session", sessions)
+	}
+
+	anonymous.do(http.MethodGet, "/api/exports/download?token=unknown", nil).
+		expectError(http.StatusNotFound, "Data export not found or expired")
+	anonymous.do(http.MethodGet, "/api/exports/download", nil).expectError(http.StatusBadRequest, "Missing token")
+
+	// Once the export is ready another can be requested
+	user.do(http.MethodPost, "/api/users/me/export", nil).expect(http.StatusAccepted)
+}
+
+// failingMailer rejects messages while failing is set, like an SMTP server that is down
+type failingMailer struct {
+	mailer.Mailer
+	failing atomic.Bool
+}
+
+func (m *failingMailer) Send(ctx context.Context, message mailer.Message) error {
+	if m.failing.Load() {
+		return errors.New("connection refused")
+	}
+	return m.Mailer.Send(ctx, message)
+}
+
+func TestDataExportFailsWhenMailFails(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.signUp("fan@example.com")
+
+	api.mailer.failing.Store(true)
+	var export domain.DataExport
+	user.do(http.MethodPost, "/api/users/me/export", nil).expect(http.StatusAccepted).decode(&export)
+
+	// The link was never mailed, so the export must not be left ready
+	exportPath := fmt.Sprintf("/api/users/me/export/%d", export.ID)
+	for deadline := time.Now().Add(5 * time.Second); export.Status == domain.DataExportStatusPending &&
+		time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		user.do(http.MethodGet, exportPath, nil).expect(http.StatusOK).decode(&export)
+	}
+	if export.Status != domain.DataExportStatusFailed {
+		t.Errorf("GET export after failed mail = %+v", export)
+	}
+
+	// A failed export does not block a new one
+	api.mailer.failing.Store(false)
+	user.do(http.MethodPost, "/api/users/me/export", nil).expect(http.StatusAccepted)
+	api.waitForMail("fan@example.com", "Your Movie Search data export is ready")
+}
+
+func TestUnauthenticatedRequestsAreRejected(t *testing.T) {
+	api := newTestAPI(t)
+	anonymous := api.anonymous()
+
+	routes := []struct{ method, path string }{
+		{http.MethodGet, "/api/users/me"},
+		{http.MethodPatch, "/api/users/me"},
+		{http.MethodDelete, "/api/users/me"},
+		{http.MethodPut, "/api/users/me/password"},
+		{http.MethodPost, "/api/users/me/export"},
+		{http.MethodGet, "/api/users/me/export/1"},
+		{http.MethodPost, "/auth/verify-email/resend"},
+		{http.MethodPost, "/auth/2fa/verify"},
+		{http.MethodGet, "/api/users/me/sessions"},
+		{http.MethodDelete, "/api/users/me/sessions"},
+		{http.MethodDelete, "/api/users/me/sessions/abc"},
+		{http.MethodGet, "/api/users/me/api-keys"},
+		{http.MethodPost, "/api/users/me/api-keys"},
+		{http.MethodDelete, "/api/users/me/api-keys/1"},
+		{http.MethodGet, "/api/users/me/identities"},
+		{http.MethodPost, "/api/users/me/identities/" + fakeProviderName},
+		{http.MethodDelete, "/api/users/me/identities/" + fakeProviderName},
+		{http.MethodPost, "/api/users/me/2fa/enroll"},
+		{http.MethodPost, "/api/users/me/2fa/confirm"},
+		{http.MethodPost, "/api/users/me/2fa/recovery-codes"},
+		{http.MethodDelete, "/api/users/me/2fa"},
+		{http.MethodGet, "/api/movies/"},
+		{http.MethodGet, "/api/movies/1"},
+		{http.MethodPost, "/api/movies/1/like"},
+		{http.MethodDelete, "/api/movies/1/like"},
+		{http.MethodPut, "/api/movies/1/rating"},
+		{http.MethodDelete, "/api/movies/1/rating"},
+		{http.MethodPost, "/api/movies/1/reviews"},
+		{http.MethodGet, "/api/movies/1/reviews"},
+		{http.MethodPut, "/api/movies/1/reviews"},
+		{http.MethodDelete, "/api/movies/1/reviews"},
+		{http.MethodPost, "/api/movies/1/reviews/1/helpful"},
+		{http.MethodDelete, "/api/movies/1/reviews/1/helpful"},
+		{http.MethodGet, "/api/lists/"},
+		{http.MethodPost, "/api/lists/"},
+		{http.MethodGet, "/api/lists/1"},
+		{http.MethodPut, "/api/lists/1"},
+		{http.MethodDelete, "/api/lists/1"},
+		{http.MethodPost, "/api/lists/1/entries"},
+		{http.MethodPut, "/api/lists/1/entries"},
+		{http.MethodDelete, "/api/lists/1/entries/1"},
+		{http.MethodPost, "/api/admin/movies"},
+		{http.MethodPut, "/api/admin/movies/1"},
+		{http.MethodDelete, "/api/admin/movies/1"},
+		{http.MethodPost, "/api/admin/movies/1/credits"},
+		{http.MethodDelete, "/api/admin/movies/1/credits/1"},
+		{http.MethodGet, "/api/admin/people"},
+		{http.MethodPost, "/api/admin/people"},
+		{http.MethodPut, "/api/admin/people/1"},
+		{http.MethodDelete, "/api/admin/people/1"},
+		{http.MethodGet, "/api/admin/reviews"},
+		{http.MethodPut, "/api/admin/reviews/1"},
+		{http.MethodPut, "/api/admin/users/1/role"},
+		{http.MethodDelete, "/api/admin/users/1/2fa"},
+		{http.MethodGet, "/api/admin/audit"},
+		{http.MethodGet, "/metrics"},
+	}
+	for _, route := range routes {
+		anonymous.do(route.method, route.path, nil).expect(http.StatusUnauthorized)
+	}
+}
+
+func TestRolesGuardAdminEndpoints(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	user := api.signUp("user@example.com")
+	editor := api.editor(admin, "editor@example.com")
+
+	editorRoutes := []struct{ method, path string }{
+		{http.MethodPost, "/api/admin/movies"},
+		{http.MethodPut, "/api/admin/movies/1"},
+		{http.MethodPost, "/api/admin/movies/1/credits"},
+		{http.MethodDelete, "/api/admin/movies/1/credits/1"},
+		{http.MethodGet, "/api/admin/people"},
+		{http.MethodPost, "/api/admin/people"},
+		{http.MethodPut, "/api/admin/people/1"},
+		{http.MethodGet, "/api/admin/reviews"},
+		{http.MethodPut, "/api/admin/reviews/1"},
+	}
+	adminRoutes := []struct{ method, path string }{
+		{http.MethodDelete, "/api/admin/movies/1"},
+		{http.MethodDelete, "/api/admin/people/1"},
+		{http.MethodPut, "/api/admin/users/1/role"},
+		{http.MethodDelete, "/api/admin/users/1/2fa"},
+		{http.MethodGet, "/api/admin/audit"},
+	}
+
+	for _, route := range append(editorRoutes, adminRoutes...) {
+		user.do(route.method, route.path, nil).expect(http.StatusForbidden)
+	}
+	for _, route := range adminRoutes {
+		editor.do(route.method, route.path, nil).expect(http.StatusForbidden)
+	}
+	editor.do(http.MethodGet, "/api/admin/people", nil).expect(http.StatusOK)
+
+	// A demotion takes effect on the next request
+	editorID := editor.me().ID
+	admin.do(http.MethodPut, fmt.Sprintf("/api/admin/users/%d/role", editorID), map[string]string{"role": domain.RoleUser}).
+		expect(http.StatusOK)
+	editor.do(http.MethodGet, "/api/admin/people", nil).expect(http.StatusForbidden)
+}
+
+func TestSetUserRole(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	user := api.signUp("user@example.com")
+	userID := user.me().ID
+	path := fmt.Sprintf("/api/admin/users/%d/role", userID)
+
+	var updated domain.User
+	admin.do(http.MethodPut, path, map[string]string{"role": domain.RoleEditor}).expect(http.StatusOK).decode(&updated)
+	if updated.ID != userID || updated.Role != domain.RoleEditor {
+		t.Errorf("PUT %s = %+v", path, updated)
+	}
+	if role := user.me().Role; role != domain.RoleEditor {
+		t.Errorf("promoted user has role %q", role)
+	}
+
+	admin.do(http.MethodPut, path, map[string]string{"role": "owner"}).
+		expectError(http.StatusBadRequest, "role must be user, editor or admin")
+	admin.do(http.MethodPut, path, "{").expectError(http.StatusBadRequest, "Invalid request")
+	admin.do(http.MethodPut, fmt.Sprintf("/api/admin/users/%d/role", admin.me().ID), map[string]string{"role": domain.RoleUser}).
+		expectError(http.StatusBadRequest, "you cannot change your own role")
+	admin.do(http.MethodPut, "/api/admin/users/424242/role", map[string]string{"role": domain.RoleEditor}).
+		expectError(http.StatusNotFound, "User not found")
+	admin.do(http.MethodPut, "/api/admin/users/abc/role", map[string]string{"role": domain.RoleEditor}).
+		expectError(http.StatusBadRequest, "Invalid user ID")
+}
+
+func TestPublicCatalog(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	anonymous := api.anonymous()
+
+	var genres []domain.Genre
+	anonymous.do(http.MethodGet, "/api/public/genres", nil).expect(http.StatusOK).decode(&genres)
+	if len(genres) != 13 {
+		t.Errorf("%d genres, want the 13 seeded ones", len(genres))
+	}
+
+	sciFi := genreID(anonymous, "Sci-Fi")
+	action := genreID(anonymous, "Action")
+	matrix := createMovie(admin, testMovie("The Matrix", sciFi, action))
+	heat := testMovie("Heat", action)
+	heat.ReleaseDate = time.Date(1995, time.December, 15, 0, 0, 0, 0, time.UTC)
+	heat.RunTime = 170
+	heat.Description = "A group of professional bank robbers."
+	createMovie(admin, heat)
+	createMovie(admin, testMovie("Alien"))
+
+	var movie domain.Movie
+	anonymous.do(http.MethodGet, fmt.Sprintf("/api/public/movies/%d", matrix.ID), nil).expect(http.StatusOK).decode(&movie)
+	if movie.Title != "The Matrix" || len(movie.Genres) != 2 || movie.Video != "trailer-id" {
+		t.Errorf("GET movie = %+v", movie)
+	}
+	anonymous.do(http.MethodGet, "/api/public/movies/abc", nil).expectError(http.StatusBadRequest, "Invalid movie ID")
+	anonymous.do(http.MethodGet, "/api/public/movies/424242", nil).expectError(http.StatusNotFound, "Movie not found")
+
+	// Pages follow the cursor until the listing is exhausted
+	var titles []string
+	path := "/api/public/movies?sort=title&order=asc&limit=2"
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("pagination does not terminate")
+		}
+		var page domain.MovieList[*domain.Movie]
+		anonymous.do(http.MethodGet, path, nil).expect(http.StatusOK).decode(&page)
+		if page.Facets == nil {
+			t.Errorf("GET %s has no facets", path)
+		}
+		for _, movie := range page.Movies {
+			titles = append(titles, movie.Title)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		path = "/api/public/movies?sort=title&order=asc&limit=2&cursor=" + page.NextCursor
+	}
+	if strings.Join(titles, ", ") != "Alien, Heat, The Matrix" {
+		t.Errorf("paged titles = %v", titles)
+	}
+
+	var filtered domain.MovieList[*domain.Movie]
+	anonymous.do(http.MethodGet, fmt.Sprintf("/api/public/movies?genre=%d&year_to=1998", action), nil).
+		expect(http.StatusOK).decode(&filtered)
+	if len(filtered.Movies) != 1 || filtered.Movies[0].Title != "Heat" {
+		t.Errorf("filtered movies = %+v", filtered.Movies)
+	}
+
+	badListings := map[string]string{
+		"/api/public/movies?limit=0":                     "invalid limit: must be between 1 and 100",
+		"/api/public/movies?sort=budget":                 `invalid sort: "budget"`,
+		"/api/public/movies?genre=abc":                   `invalid genre: "abc"`,
+		"/api/public/movies?year_from=2000&year_to=1990": "year_from must not be after year_to",
+		"/api/public/movies?cursor=garbage":              domain.ErrInvalidCursor.Error(),
+	}
+	for path, message := range badListings {
+		anonymous.do(http.MethodGet, path, nil).expectError(http.StatusBadRequest, message)
+	}
+
+	// The catalog's facets are cached with its listings and invalidated with them
+	sciFiCount := func() int {
+		var page domain.MovieList[*domain.Movie]
+		anonymous.do(http.MethodGet, "/api/public/movies", nil).expect(http.StatusOK).decode(&page)
+		for _, facet := range page.Facets.Genres {
+			if facet.ID == sciFi {
+				return facet.Count
+			}
+		}
+		return 0
+	}
+	if count := sciFiCount(); count != 1 {
+		t.Errorf("Sci-Fi facet = %d, want 1", count)
+	}
+	createMovie(admin, testMovie("Dune", sciFi))
+	if count := sciFiCount(); count != 2 {
+		t.Errorf("Sci-Fi facet after a new movie = %d, want 2", count)
+	}
+}
+
+func TestSearchAndSuggest(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	anonymous := api.anonymous()
+
+	createMovie(admin, testMovie("The Matrix"))
+	createMovie(admin, testMovie("Heat"))
+
+	var results []domain.MovieSearchResult
+	anonymous.do(http.MethodGet, "/api/public/movies/search?q=matrix", nil).expect(http.StatusOK).decode(&results)
+	if len(results) != 1 || results[0].Title != "The Matrix" || results[0].MatchType != domain.MatchTypeFullText {
+		t.Errorf("search for matrix = %+v", results)
+	}
+
+	// Typos fall back to trigram matching
+	results = nil
+	anonymous.do(http.MethodGet, "/api/public/movies/search?q=matrx", nil).expect(http.StatusOK).decode(&results)
+	if len(results) != 1 || results[0].Title != "The Matrix" || results[0].MatchType != domain.MatchTypeFuzzy {
+		t.Errorf("search for matrx = %+v", results)
+	}
+
+	anonymous.do(http.MethodGet, "/api/public/movies/search", nil).expectError(http.StatusBadRequest, "Missing search query")
+	anonymous.do(http.MethodGet, "/api/public/movies/search?q=matrix&limit=1000", nil).
+		expectError(http.StatusBadRequest, "Invalid limit")
+
+	var suggestions []domain.MovieSuggestion
+	anonymous.do(http.MethodGet, "/api/public/movies/suggest?prefix=mat", nil).expect(http.StatusOK).decode(&suggestions)
+	if len(suggestions) != 1 || suggestions[0].Title != "The Matrix" || suggestions[0].ReleaseYear != 1999 {
+		t.Errorf("suggestions for mat = %+v", suggestions)
+	}
+
+	anonymous.do(http.MethodGet, "/api/public/movies/suggest", nil).expectError(http.StatusBadRequest, "Missing prefix")
+	anonymous.do(http.MethodGet, "/api/public/movies/suggest?prefix=mat&limit=0", nil).
+		expectError(http.StatusBadRequest, "Invalid limit")
+}
+
+func TestCatalogManagement(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	editor := api.editor(admin, "editor@example.com")
+	anonymous := api.anonymous()
+
+	movie := createMovie(editor, testMovie("The Matrx", genreID(editor, "Sci-Fi")))
+	moviePath := fmt.Sprintf("/api/public/movies/%d", movie.ID)
+
+	// Warm the cache so the update has to invalidate it. Updates leave the genres alone.
+	anonymous.do(http.MethodGet, moviePath, nil).expect(http.StatusOK)
+
+	update := testMovie("The Matrix")
+	var message map[string]string
+	editor.do(http.MethodPut, fmt.Sprintf("/api/admin/movies/%d", movie.ID), update).expect(http.StatusOK).decode(&message)
+	if message["message"] != "Movie updated successfully" {
+		t.Errorf("PUT movie = %v", message)
+	}
+
+	var updated domain.Movie
+	anonymous.do(http.MethodGet, moviePath, nil).expect(http.StatusOK).decode(&updated)
+	if updated.Title != "The Matrix" || len(updated.Genres) != 1 || updated.Genres[0].Genre != "Sci-Fi" {
+		t.Errorf("updated movie = %+v", updated)
+	}
+
+	editor.do(http.MethodPost, "/api/admin/movies", "{").expectError(http.StatusBadRequest, "Invalid request")
+	editor.do(http.MethodPut, "/api/admin/movies/abc", update).expectError(http.StatusBadRequest, "Invalid movie ID")
+	editor.do(http.MethodPut, fmt.Sprintf("/api/admin/movies/%d", movie.ID), "{").
+		expectError(http.StatusBadRequest, "Invalid request")
+
+	admin.do(http.MethodDelete, "/api/admin/movies/abc", nil).expectError(http.StatusBadRequest, "Invalid movie ID")
+	admin.do(http.MethodDelete, fmt.Sprintf("/api/admin/movies/%d", movie.ID), nil).expect(http.StatusOK).decode(&message)
+	if message["message"] != "Movie deleted successfully" {
+		t.Errorf("DELETE movie = %v", message)
+	}
+	anonymous.do(http.MethodGet, moviePath, nil).expectError(http.StatusNotFound, "Movie not found")
+	admin.do(http.MethodDelete, fmt.Sprintf("/api/admin/movies/%d", movie.ID), nil).
+		expectError(http.StatusNotFound, "Movie not found")
+	editor.do(http.MethodPut, fmt.Sprintf("/api/admin/movies/%d", movie.ID), update).
+		expectError(http.StatusNotFound, "Movie not found")
+}
+
+func (c *testClient) auditEvents(query string) *domain.AuditEventList {
+	c.api.t.Helper()
+
+	var events domain.AuditEventList
+	c.do(http.MethodGet, "/api/admin/audit"+query, nil).expect(http.StatusOK).decode(&events)
+	return &events
+}
+
+func TestAuditLog(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	editor := api.editor(admin, "editor@example.com")
+	adminID, editorID := admin.me().ID, editor.me().ID
+
+	movie := createMovie(editor, testMovie("The Matrx"))
+	editor.do(http.MethodPut, fmt.Sprintf("/api/admin/movies/%d", movie.ID), testMovie("The Matrix")).
+		expect(http.StatusOK)
+	deleted := admin.doWithHeader(http.MethodDelete, fmt.Sprintf("/api/admin/movies/%d", movie.ID), nil,
+		http.Header{"X-Forwarded-For": {"203.0.113.7"}}).expect(http.StatusOK)
+
+	events := admin.auditEvents(fmt.Sprintf("?entity_type=movie&entity_id=%d", movie.ID)).Events
+	if len(events) != 3 {
+		t.Fatalf("movie audit events = %+v, want 3", events)
+	}
+
+	// Newest first: the delete records who deleted the movie, from which request and address, and what it was
+	deleteEvent, updateEvent, createEvent := events[0], events[1], events[2]
+	if deleteEvent.ActorID != adminID || deleteEvent.Action != domain.AuditActionDelete ||
+		deleteEvent.EntityType != domain.AuditEntityMovie || deleteEvent.EntityID != movie.ID ||
+		deleteEvent.RequestID != deleted.header.Get("X-Request-ID") || deleteEvent.IP != "203.0.113.7" ||
+		deleteEvent.CreatedAt.IsZero() {
+		t.Errorf("delete event = %+v", deleteEvent)
+	}
+	var before domain.Movie
+	if err := json.Unmarshal(deleteEvent.Before, &before); err != nil || before.Title != "The Matrix" || before.ID != movie.ID {
+		t.Errorf("deleted movie = %s, %v", deleteEvent.Before, err)
+	}
+	if string(deleteEvent.After) != "null" {
+		t.Errorf("delete event after = %s, want null", deleteEvent.After)
+	}
+
+	// Updates keep only the changed fields
+	var changedBefore, changedAfter map[string]any
+	json.Unmarshal(updateEvent.Before, &changedBefore)
+	json.Unmarshal(updateEvent.After, &changedAfter)
+	if updateEvent.ActorID != editorID || updateEvent.Action != domain.AuditActionUpdate ||
+		len(changedBefore) != 1 || changedBefore["title"] != "The Matrx" ||
+		len(changedAfter) != 1 || changedAfter["title"] != "The Matrix" {
+		t.Errorf("update event = %+v; before %s, after %s", updateEvent, updateEvent.Before, updateEvent.After)
+	}
+
+	if createEvent.ActorID != editorID || createEvent.Action != domain.AuditActionCreate ||
+		string(createEvent.Before) != "null" {
+		t.Errorf("create event = %+v", createEvent)
+	}
+
+	// Role changes and two-factor resets are recorded against the user
+	roleEvents := admin.auditEvents(fmt.Sprintf("?action=change_role&entity_id=%d", editorID)).Events
+	if len(roleEvents) != 1 || roleEvents[0].ActorID != adminID || roleEvents[0].EntityType != domain.AuditEntityUser ||
+		string(roleEvents[0].Before) != `{"role":"user"}` || string(roleEvents[0].After) != `{"role":"editor"}` {
+		t.Errorf("role change events = %+v", roleEvents)
+	}
+
+	// Paging walks the filtered log newest first
+	page := admin.auditEvents(fmt.Sprintf("?actor_id=%d&limit=1", editorID))
+	if len(page.Events) != 1 || page.Events[0].ID != updateEvent.ID || page.NextCursor == "" {
+		t.Fatalf("first page = %+v", page)
+	}
+	page = admin.auditEvents(fmt.Sprintf("?actor_id=%d&limit=1&cursor=%s", editorID, page.NextCursor))
+	if len(page.Events) != 1 || page.Events[0].ID != createEvent.ID || page.NextCursor != "" {
+		t.Errorf("second page = %+v", page)
+	}
+
+	since := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+	if events := admin.auditEvents("?since=" + since).Events; len(events) != 0 {
+		t.Errorf("events since an hour from now = %+v, want none", events)
+	}
+
+	admin.do(http.MethodGet, "/api/admin/audit?actor_id=abc", nil).expectError(http.StatusBadRequest, `invalid actor_id: "abc"`)
+	admin.do(http.MethodGet, "/api/admin/audit?since=yesterday", nil).
+		expectError(http.StatusBadRequest, "invalid since: must be an RFC 3339 time")
+	admin.do(http.MethodGet, "/api/admin/audit?since=2024-02-01T00:00:00Z&until=2024-01-01T00:00:00Z", nil).
+		expectError(http.StatusBadRequest, "until must be after since")
+	admin.do(http.MethodGet, "/api/admin/audit?cursor=!", nil).expectError(http.StatusBadRequest, "invalid cursor")
+	admin.do(http.MethodGet, "/api/admin/audit?limit=0", nil).
+		expectError(http.StatusBadRequest, "invalid limit: must be between 1 and 100")
+}
+
+// failingAuditStore rejects new audit events while failing is set, like a database that went away mid-request
+type failingAuditStore struct {
+	repository.AuditStore
+	failing atomic.Bool
+}
+
+func (s *failingAuditStore) CreateAuditEvent(ctx context.Context, event db.CreateAuditEventParams) (db.AuditEvent, error) {
+	if s.failing.Load() {
+		return db.AuditEvent{}, errors.New("connection reset")
+	}
+	return s.AuditStore.CreateAuditEvent(ctx, event)
+}
+
+func TestAuditFailureRollsBackWrite(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	editor := api.editor(admin, "editor@example.com")
+	anonymous := api.anonymous()
+	movie := createMovie(editor, testMovie("Heat"))
+	moviePath := fmt.Sprintf("/api/admin/movies/%d", movie.ID)
+
+	// Warm the cache, so a write that is rolled back would show if it invalidated or refilled it
+	titles := func() []string {
+		var page domain.MovieList[*domain.Movie]
+		anonymous.do(http.MethodGet, "/api/public/movies?sort=title&order=asc", nil).expect(http.StatusOK).decode(&page)
+		var titles []string
+		for _, movie := range page.Movies {
+			titles = append(titles, movie.Title)
+		}
+		return titles
+	}
+	titles()
+
+	// A write whose audit event cannot be stored is not saved either
+	api.audit.failing.Store(true)
+	editor.do(http.MethodPost, "/api/admin/movies", testMovie("Alien")).
+		expectError(http.StatusInternalServerError, "Could not create movie")
+	editor.do(http.MethodPut, moviePath, testMovie("Heat 2")).expectError(http.StatusInternalServerError, "Could not update movie")
+	admin.do(http.MethodDelete, moviePath, nil).expectError(http.StatusInternalServerError, "Could not delete movie")
+	admin.do(http.MethodPut, fmt.Sprintf("/api/admin/users/%d/role", editor.me().ID), map[string]string{"role": domain.RoleUser}).
+		expectError(http.StatusInternalServerError, "Could not set user role")
+	api.audit.failing.Store(false)
+
+	if got := titles(); !slices.Equal(got, []string{"Heat"}) {
+		t.Errorf("movies after failed writes = %v, want [Heat]", got)
+	}
+	var unchanged domain.Movie
+	anonymous.do(http.MethodGet, fmt.Sprintf("/api/public/movies/%d", movie.ID), nil).expect(http.StatusOK).decode(&unchanged)
+	if unchanged.Title != "Heat" {
+		t.Errorf("movie after failed update = %+v", unchanged)
+	}
+	if role := editor.me().Role; role != domain.RoleEditor {
+		t.Errorf("role after failed change = %q, want %q", role, domain.RoleEditor)
+	}
+
+	// The delete can be retried once the audit log is back
+	admin.do(http.MethodDelete, moviePath, nil).expect(http.StatusOK)
+	events := admin.auditEvents("?entity_type=movie").Events
+	if len(events) != 2 || events[0].Action != domain.AuditActionDelete || events[1].Action != domain.AuditActionCreate {
+		t.Errorf("movie audit events = %+v, want the delete and the create", events)
+	}
+}
+
+func TestPeopleAndCredits(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	editor := api.editor(admin, "editor@example.com")
+	anonymous := api.anonymous()
+
+	movie := createMovie(editor, testMovie("Heat"))
+
+	var person domain.Person
+	editor.do(http.MethodPost, "/api/admin/people", map[string]string{"name": "  Al Pacino  ", "biography": "Actor."}).
+		expect(http.StatusCreated).decode(&person)
+	if person.ID == 0 || person.Name != "Al Pacino" {
+		t.Errorf("POST person = %+v", person)
+	}
+	editor.do(http.MethodPost, "/api/admin/people", map[string]string{"name": " "}).
+		expectError(http.StatusBadRequest, "name cannot be empty")
+	editor.do(http.MethodPost, "/api/admin/people", "{").expectError(http.StatusBadRequest, "Invalid request")
+
+	var people []domain.Person
+	editor.do(http.MethodGet, "/api/admin/people", nil).expect(http.StatusOK).decode(&people)
+	if len(people) != 1 || people[0].ID != person.ID {
+		t.Errorf("GET people = %+v", people)
+	}
+
+	creditsPath := fmt.Sprintf("/api/admin/movies/%d/credits", movie.ID)
+	credit := map[string]any{"person_id": person.ID, "role": domain.CreditRoleActor, "character_name": "Vincent Hanna", "billing_order": 1}
+	var created domain.Credit
+	editor.do(http.MethodPost, creditsPath, credit).expect(http.StatusCreated).decode(&created)
+	if created.ID == 0 || created.PersonID != person.ID || created.CharacterName != "Vincent Hanna" {
+		t.Errorf("POST credit = %+v", created)
+	}
+
+	editor.do(http.MethodPost, creditsPath, credit).expect(http.StatusConflict)
+	editor.do(http.MethodPost, creditsPath, map[string]any{"person_id": person.ID, "role": "stunt double"}).
+		expect(http.StatusBadRequest)
+	editor.do(http.MethodPost, creditsPath, map[string]any{"person_id": 424242, "role": domain.CreditRoleWriter}).
+		expectError(http.StatusNotFound, "Person not found")
+	editor.do(http.MethodPost, "/api/admin/movies/424242/credits", credit).expectError(http.StatusNotFound, "Movie not found")
+	editor.do(http.MethodPost, "/api/admin/movies/abc/credits", credit).expectError(http.StatusBadRequest, "Invalid movie ID")
+	editor.do(http.MethodPost, creditsPath, "{").expectError(http.StatusBadRequest, "Invalid request")
+
+	// Credits show on the movie and in the filmography
+	var withCredits domain.Movie
+	anonymous.do(http.MethodGet, fmt.Sprintf("/api/public/movies/%d", movie.ID), nil).expect(http.StatusOK).decode(&withCredits)
+	if len(withCredits.Credits) != 1 || withCredits.Credits[0].Name != "Al Pacino" {
+		t.Errorf("movie credits = %+v", withCredits.Credits)
+	}
+
+	personPath := fmt.Sprintf("/api/public/people/%d", person.ID)
+	var filmography domain.Person
+	anonymous.do(http.MethodGet, personPath, nil).expect(http.StatusOK).decode(&filmography)
+	if len(filmography.Filmography) != 1 || filmography.Filmography[0].Title != "Heat" {
+		t.Errorf("filmography = %+v", filmography.Filmography)
+	}
+	anonymous.do(http.MethodGet, "/api/public/people/abc", nil).expectError(http.StatusBadRequest, "Invalid person ID")
+	anonymous.do(http.MethodGet, "/api/public/people/424242", nil).expectError(http.StatusNotFound, "Person not found")
+
+	var renamed domain.Person
+	editor.do(http.MethodPut, fmt.Sprintf("/api/admin/people/%d", person.ID), map[string]string{"name": "Alfredo Pacino"}).
+		expect(http.StatusOK).decode(&renamed)
+	if renamed.Name != "Alfredo Pacino" {
+		t.Errorf("PUT person = %+v", renamed)
+	}
+	editor.do(http.MethodPut, "/api/admin/people/424242", map[string]string{"name": "Nobody"}).
+		expectError(http.StatusNotFound, "Person not found")
+	editor.do(http.MethodPut, "/api/admin/people/abc", map[string]string{"name": "Nobody"}).
+		expectError(http.StatusBadRequest, "Invalid person ID")
+
+	creditPath := fmt.Sprintf("%s/%d", creditsPath, created.ID)
+	editor.do(http.MethodDelete, creditPath, nil).expect(http.StatusOK)
+	editor.do(http.MethodDelete, creditPath, nil).expectError(http.StatusNotFound, "Credit not found")
+	editor.do(http.MethodDelete, creditsPath+"/abc", nil).expectError(http.StatusBadRequest, "Invalid credit ID")
+
+	admin.do(http.MethodDelete, fmt.Sprintf("/api/admin/people/%d", person.ID), nil).expect(http.StatusOK)
+	admin.do(http.MethodDelete, fmt.Sprintf("/api/admin/people/%d", person.ID), nil).
+		expectError(http.StatusNotFound, "Person not found")
+	admin.do(http.MethodDelete, "/api/admin/people/abc", nil).expectError(http.StatusBadRequest, "Invalid person ID")
+	anonymous.do(http.MethodGet, personPath, nil).expectError(http.StatusNotFound, "Person not found")
+}
+
+func TestLikesAndRatings(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	user := api.signUp("fan@example.com")
+
+	// The aggregate rating comes only from users, so one sent on create is ignored
+	heat := testMovie("Heat")
+	heat.UserRating = 4.9
+	movie := createMovie(admin, heat)
+	if movie.UserRating != 0 || movie.RatingCount != 0 {
+		t.Errorf("created movie rating = %v over %d, want none", movie.UserRating, movie.RatingCount)
+	}
+	createMovie(admin, testMovie("Alien"))
+	moviePath := fmt.Sprintf("/api/movies/%d", movie.ID)
+
+	user.do(http.MethodPost, moviePath+"/like", nil).expect(http.StatusOK)
+	user.do(http.MethodPost, moviePath+"/like", nil).expect(http.StatusOK)
+	user.do(http.MethodPost, "/api/movies/424242/like", nil).expectError(http.StatusNotFound, "Movie not found")
+	user.do(http.MethodPost, "/api/movies/abc/like", nil).expectError(http.StatusBadRequest, "Invalid movie ID")
+
+	var rating domain.MovieRating
+	user.do(http.MethodPut, moviePath+"/rating", map[string]int{"rating": 4}).expect(http.StatusOK).decode(&rating)
+	if rating.MovieID != movie.ID || rating.UserRating != 4 || rating.RatingCount != 1 || rating.MyRating != 4 {
+		t.Errorf("PUT rating = %+v", rating)
+	}
+	admin.do(http.MethodPut, moviePath+"/rating", map[string]int{"rating": 5}).expect(http.StatusOK).decode(&rating)
+	if rating.UserRating != 4.5 || rating.RatingCount != 2 {
+		t.Errorf("second rating = %+v", rating)
+	}
+
+	user.do(http.MethodPut, moviePath+"/rating", map[string]int{"rating": 6}).
+		expectError(http.StatusBadRequest, "rating must be between 1 and 5")
+	user.do(http.MethodPut, moviePath+"/rating", "{").expectError(http.StatusBadRequest, "Invalid request")
+	user.do(http.MethodPut, "/api/movies/424242/rating", map[string]int{"rating": 3}).
+		expectError(http.StatusNotFound, "Movie not found")
+	user.do(http.MethodPut, "/api/movies/abc/rating", map[string]int{"rating": 3}).
+		expectError(http.StatusBadRequest, "Invalid movie ID")
+
+	var withLike domain.MovieWithLike
+	user.do(http.MethodGet, moviePath, nil).expect(http.StatusOK).decode(&withLike)
+	if !withLike.IsLiked || withLike.MyRating != 4 || withLike.UserRating != 4.5 {
+		t.Errorf("GET movie with like = %+v", withLike)
+	}
+	user.do(http.MethodGet, "/api/movies/424242", nil).expectError(http.StatusNotFound, "Movie not found")
+	user.do(http.MethodGet, "/api/movies/abc", nil).expectError(http.StatusBadRequest, "Invalid movie ID")
+
+	// The public movie reflects the new aggregate rating
+	var public domain.Movie
+	api.anonymous().do(http.MethodGet, fmt.Sprintf("/api/public/movies/%d", movie.ID), nil).expect(http.StatusOK).decode(&public)
+	if public.UserRating != 4.5 || public.RatingCount != 2 {
+		t.Errorf("public movie rating = %v over %d", public.UserRating, public.RatingCount)
+	}
+
+	var liked domain.MovieList[*domain.Movie]
+	user.do(http.MethodGet, "/api/movies/?only_liked=true", nil).expect(http.StatusOK).decode(&liked)
+	if len(liked.Movies) != 1 || liked.Movies[0].Title != "Heat" {
+		t.Errorf("liked movies = %+v", liked.Movies)
+	}
+	var all domain.MovieList[*domain.MovieWithLike]
+	user.do(http.MethodGet, "/api/movies/", nil).expect(http.StatusOK).decode(&all)
+	if len(all.Movies) != 2 {
+		t.Errorf("%d movies with likes, want 2", len(all.Movies))
+	}
+	user.do(http.MethodGet, "/api/movies/?limit=abc", nil).expect(http.StatusBadRequest)
+
+	user.do(http.MethodDelete, moviePath+"/rating", nil).expect(http.StatusOK).decode(&rating)
+	if rating.UserRating != 5 || rating.RatingCount != 1 || rating.MyRating != 0 {
+		t.Errorf("DELETE rating = %+v", rating)
+	}
+	user.do(http.MethodDelete, "/api/movies/424242/rating", nil).expectError(http.StatusNotFound, "Movie not found")
+
+	user.do(http.MethodDelete, moviePath+"/like", nil).expect(http.StatusOK)
+	user.do(http.MethodDelete, "/api/movies/abc/like", nil).expectError(http.StatusBadRequest, "Invalid movie ID")
+	liked = domain.MovieList[*domain.Movie]{}
+	user.do(http.MethodGet, "/api/movies/?only_liked=true", nil).expect(http.StatusOK).decode(&liked)
+	if len(liked.Movies) != 0 {
+		t.Errorf("liked movies after unlike = %+v", liked.Movies)
+	}
+}
+
+func TestReviews(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	author := api.signUp("critic@example.com")
+	reader := api.signUp("reader@example.com")
+	anonymous := api.anonymous()
+
+	movie := createMovie(admin, testMovie("Heat"))
+	reviewPath := fmt.Sprintf("/api/movies/%d/reviews", movie.ID)
+	publicPath := fmt.Sprintf("/api/public/movies/%d/reviews", movie.ID)
+
+	var review domain.Review
+	author.do(http.MethodPost, reviewPath, map[string]string{"body": " Great heist movie. "}).
+		expect(http.StatusCreated).decode(&review)
+	if review.Body != "Great heist movie." || review.Status != domain.ReviewStatusPending {
+		t.Errorf("POST review = %+v", review)
+	}
+	author.do(http.MethodPost, reviewPath, map[string]string{"body": "Again."}).
+		expectError(http.StatusConflict, "you have already reviewed this movie")
+	reader.do(http.MethodPost, reviewPath, map[string]string{"body": " "}).
+		expectError(http.StatusBadRequest, "review must be between 1 and 5000 characters")
+	reader.do(http.MethodPost, "/api/movies/424242/reviews", map[string]string{"body": "Lost."}).
+		expectError(http.StatusNotFound, "Movie not found")
+	reader.do(http.MethodPost, "/api/movies/abc/reviews", map[string]string{"body": "Lost."}).
+		expectError(http.StatusBadRequest, "Invalid movie ID")
+
+	var own domain.Review
+	author.do(http.MethodGet, reviewPath, nil).expect(http.StatusOK).decode(&own)
+	if own.ID != review.ID {
+		t.Errorf("GET own review = %+v", own)
+	}
+	reader.do(http.MethodGet, reviewPath, nil).expectError(http.StatusNotFound, "Review not found")
+
+	// Pending reviews wait for moderation
+	var reviews domain.ReviewList
+	anonymous.do(http.MethodGet, publicPath, nil).expect(http.StatusOK).decode(&reviews)
+	if len(reviews.Reviews) != 0 {
+		t.Errorf("pending review is public: %+v", reviews.Reviews)
+	}
+	anonymous.do(http.MethodGet, "/api/public/movies/abc/reviews", nil).expectError(http.StatusBadRequest, "Invalid movie ID")
+	anonymous.do(http.MethodGet, publicPath+"?limit=0", nil).expect(http.StatusBadRequest)
+
+	votePath := fmt.Sprintf("%s/%d/helpful", reviewPath, review.ID)
+	reader.do(http.MethodPost, votePath, nil).expectError(http.StatusNotFound, "Review not found")
+
+	var queue domain.ReviewList
+	admin.do(http.MethodGet, "/api/admin/reviews", nil).expect(http.StatusOK).decode(&queue)
+	if len(queue.Reviews) != 1 || queue.Reviews[0].ID != review.ID {
+		t.Errorf("moderation queue = %+v", queue.Reviews)
+	}
+	admin.do(http.MethodGet, "/api/admin/reviews?status=spam", nil).expectError(http.StatusBadRequest, "Invalid status")
+
+	moderatePath := fmt.Sprintf("/api/admin/reviews/%d", review.ID)
+	var moderated domain.Review
+	admin.do(http.MethodPut, moderatePath, map[string]string{"status": domain.ReviewStatusApproved}).
+		expect(http.StatusOK).decode(&moderated)
+	if moderated.Status != domain.ReviewStatusApproved {
+		t.Errorf("PUT moderation = %+v", moderated)
+	}
+	admin.do(http.MethodPut, moderatePath, map[string]string{"status": domain.ReviewStatusPending}).
+		expectError(http.StatusBadRequest, "status must be approved or rejected")
+	admin.do(http.MethodPut, "/api/admin/reviews/424242", map[string]string{"status": domain.ReviewStatusApproved}).
+		expectError(http.StatusNotFound, "Review not found")
+	admin.do(http.MethodPut, "/api/admin/reviews/abc", map[string]string{"status": domain.ReviewStatusApproved}).
+		expectError(http.StatusBadRequest, "Invalid review ID")
+
+	reviews = domain.ReviewList{}
+	anonymous.do(http.MethodGet, publicPath, nil).expect(http.StatusOK).decode(&reviews)
+	if len(reviews.Reviews) != 1 || reviews.Reviews[0].AuthorName == "" {
+		t.Errorf("approved reviews = %+v", reviews.Reviews)
+	}
+
+	var voted domain.Review
+	reader.do(http.MethodPost, votePath, nil).expect(http.StatusOK).decode(&voted)
+	if voted.HelpfulCount != 1 {
+		t.Errorf("helpful count = %d, want 1", voted.HelpfulCount)
+	}
+	author.do(http.MethodPost, votePath, nil).expectError(http.StatusForbidden, "you cannot vote on your own review")
+	reader.do(http.MethodPost, fmt.Sprintf("%s/abc/helpful", reviewPath), nil).
+		expectError(http.StatusBadRequest, "Invalid review ID")
+	reader.do(http.MethodDelete, votePath, nil).expect(http.StatusOK).decode(&voted)
+	if voted.HelpfulCount != 0 {
+		t.Errorf("helpful count after unvote = %d, want 0", voted.HelpfulCount)
+	}
+
+	// Editing sends the review back to the queue
+	var edited domain.Review
+	author.do(http.MethodPut, reviewPath, map[string]string{"body": "Still great."}).expect(http.StatusOK).decode(&edited)
+	if edited.Body != "Still great." || edited.Status != domain.ReviewStatusPending {
+		t.Errorf("PUT review = %+v", edited)
+	}
+	reader.do(http.MethodPut, reviewPath, map[string]string{"body": "Not mine."}).
+		expectError(http.StatusNotFound, "Review not found")
+
+	author.do(http.MethodDelete, reviewPath, nil).expect(http.StatusNoContent)
+	author.do(http.MethodDelete, reviewPath, nil).expectError(http.StatusNotFound, "Review not found")
+	author.do(http.MethodGet, reviewPath, nil).expectError(http.StatusNotFound, "Review not found")
+}
+
+func TestLists(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.admin()
+	owner := api.signUp("owner@example.com")
+	other := api.signUp("other@example.com")
+	anonymous := api.anonymous()
+
+	heat := createMovie(admin, testMovie("Heat"))
+	ronin := createMovie(admin, testMovie("Ronin"))
+
+	var list domain.UserList
+	owner.do(http.MethodPost, "/api/lists/", map[string]string{"name": "Heists"}).expect(http.StatusCreated).decode(&list)
+	if list.ID == 0 || list.Visibility != domain.ListVisibilityPrivate || list.ShareURL != "" {
+		t.Errorf("POST list = %+v", list)
+	}
+	owner.do(http.MethodPost, "/api/lists/", map[string]string{"name": ""}).
+		expectError(http.StatusBadRequest, "list name must be between 1 and 100 characters")
+	owner.do(http.MethodPost, "/api/lists/", map[string]string{"name": "Secret", "visibility": "hidden"}).
+		expectError(http.StatusBadRequest, "visibility must be private, unlisted or public")
+	owner.do(http.MethodPost, "/api/lists/", "{").expectError(http.StatusBadRequest, "Invalid request")
+
+	listPath := fmt.Sprintf("/api/lists/%d", list.ID)
+	entriesPath := listPath + "/entries"
+	for _, movieID := range []int{heat.ID, ronin.ID} {
+		owner.do(http.MethodPost, entriesPath, map[string]int{"movie_id": movieID}).expect(http.StatusOK)
+	}
+	owner.do(http.MethodPost, entriesPath, map[string]int{"movie_id": 424242}).expectError(http.StatusNotFound, "Movie not found")
+	owner.do(http.MethodPost, entriesPath, "{").expectError(http.StatusBadRequest, "Invalid request")
+	other.do(http.MethodPost, entriesPath, map[string]int{"movie_id": heat.ID}).expectError(http.StatusNotFound, "List not found")
+
+	owner.do(http.MethodPut, entriesPath, map[string][]int{"movie_ids": {ronin.ID, heat.ID}}).expect(http.StatusOK)
+	owner.do(http.MethodPut, entriesPath, map[string][]int{"movie_ids": {ronin.ID}}).
+		expectError(http.StatusBadRequest, "movie_ids must contain every movie of the list exactly once")
+
+	var fetched domain.UserList
+	owner.do(http.MethodGet, listPath, nil).expect(http.StatusOK).decode(&fetched)
+	if len(fetched.Entries) != 2 || fetched.Entries[0].Title != "Ronin" || fetched.Entries[1].Title != "Heat" {
+		t.Errorf("GET list entries = %+v", fetched.Entries)
+	}
+	other.do(http.MethodGet, listPath, nil).expectError(http.StatusNotFound, "List not found")
+	owner.do(http.MethodGet, "/api/lists/abc", nil).expectError(http.StatusBadRequest, "Invalid list ID")
+
+	var lists []domain.UserList
+	owner.do(http.MethodGet, "/api/lists/", nil).expect(http.StatusOK).decode(&lists)
+	if len(lists) != 1 || lists[0].EntryCount != 2 {
+		t.Errorf("GET lists = %+v", lists)
+	}
+
+	var updated domain.UserList
+	owner.do(http.MethodPut, listPath, map[string]string{"name": "Best heists", "visibility": domain.ListVisibilityUnlisted}).
+		expect(http.StatusOK).decode(&updated)
+	if updated.Name != "Best heists" || updated.Visibility != domain.ListVisibilityUnlisted || updated.ShareURL == "" {
+		t.Errorf("PUT list = %+v", updated)
+	}
+	other.do(http.MethodPut, listPath, map[string]string{"name": "Mine", "visibility": domain.ListVisibilityPublic}).expectError(http.StatusNotFound, "List not found")
+
+	sharePath := updated.ShareURL[strings.Index(updated.ShareURL, "/api/public/lists/"):]
+	var shared domain.UserList
+	anonymous.do(http.MethodGet, sharePath, nil).expect(http.StatusOK).decode(&shared)
+	if shared.Name != "Best heists" || len(shared.Entries) != 2 {
+		t.Errorf("shared list = %+v", shared)
+	}
+	anonymous.do(http.MethodGet, "/api/public/lists/unknown-token", nil).expectError(http.StatusNotFound, "List not found")
+
+	// Unlisted lists are not discoverable; public ones are listed on the owner's profile
+	ownerListsPath := fmt.Sprintf("/api/public/users/%d/lists", owner.me().ID)
+	var discovered []domain.UserList
+	anonymous.do(http.MethodGet, ownerListsPath, nil).expect(http.StatusOK).decode(&discovered)
+	if len(discovered) != 0 {
+		t.Errorf("public lists with an unlisted list = %+v", discovered)
+	}
+	owner.do(http.MethodPut, listPath, map[string]string{"name": "Best heists", "visibility": domain.ListVisibilityPublic}).
+		expect(http.StatusOK)
+	anonymous.do(http.MethodGet, ownerListsPath, nil).expect(http.StatusOK).decode(&discovered)
+	if len(discovered) != 1 || discovered[0].Name != "Best heists" || discovered[0].EntryCount != 2 ||
+		discovered[0].ShareURL != updated.ShareURL {
+		t.Errorf("public lists = %+v", discovered)
+	}
+	anonymous.do(http.MethodGet, "/api/public/users/abc/lists", nil).expectError(http.StatusBadRequest, "Invalid user ID")
+
+	// Making the list private again hides it from its share URL
+	owner.do(http.MethodPut, listPath, map[string]string{"name": "Best heists", "visibility": domain.ListVisibilityPrivate}).
+		expect(http.StatusOK)
+	anonymous.do(http.MethodGet, sharePath, nil).expectError(http.StatusNotFound, "List not found")
+
+	owner.do(http.MethodDelete, fmt.Sprintf("%s/%d", entriesPath, heat.ID), nil).expect(http.StatusOK)
+	owner.do(http.MethodDelete, fmt.Sprintf("%s/%d", entriesPath, heat.ID), nil).
+		expectError(http.StatusNotFound, "movie is not in the list")
+	owner.do(http.MethodDelete, entriesPath+"/abc", nil).expectError(http.StatusBadRequest, "Invalid movie ID")
+
+	other.do(http.MethodDelete, listPath, nil).expectError(http.StatusNotFound, "List not found")
+	owner.do(http.MethodDelete, listPath, nil).expect(http.StatusNoContent)
+	owner.do(http.MethodGet, listPath, nil).expectError(http.StatusNotFound, "List not found")
+}
+
+func TestMetrics(t *testing.T) {
+	api := newTestAPI(t)
+	client := api.anonymous()
+
+	header := http.Header{}
+	request, _ := http.NewRequest(http.MethodGet, "/", nil)
+	request.SetBasicAuth(alloyUsername, "wrong")
+	header.Set("Authorization", request.Header.Get("Authorization"))
+	client.doWithHeader(http.MethodGet, "/metrics", nil, header).expect(http.StatusUnauthorized)
+
+	request.SetBasicAuth(alloyUsername, alloyPassword)
+	header.Set("Authorization", request.Header.Get("Authorization"))
+	response := client.doWithHeader(http.MethodGet, "/metrics", nil, header).expect(http.StatusOK)
+	if !bytes.Contains(response.body, []byte("http_requests_total")) {
+		t.Errorf("metrics do not include the request metrics")
+	}
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/martishin/movie-search-service/internal/mailer"
+	"github.com/martishin/movie-search-service/internal/model/config"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	accountTokenBytes         = 32
+	passwordResetTokenTTL     = time.Hour
+	emailVerificationTokenTTL = 48 * time.Hour
+	minPasswordLength         = 8
+	maxPasswordLength         = 72 // bcrypt ignores anything longer
+	resetPasswordPath         = "/reset-password"
+	verifyEmailPath           = "/verify-email"
+)
+
+// AccountDeletionGracePeriod is how long a deleted account can still be restored by signing in to it
+const AccountDeletionGracePeriod = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidToken         = errors.New("invalid or expired token")
+	ErrInvalidPassword      = errors.New("password must be between 8 and 72 characters")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	ErrIncorrectPassword    = errors.New("current password is incorrect")
+)
+
+// AccountService runs the account recovery and email verification flows.
+// Both mail a single-use token; only its SHA-256 is stored, so the database never holds a redeemable token.
+type AccountService struct {
+	userRepo       repository.UserStore
+	tokenRepo      repository.UserTokenStore
+	userService    *UserService
+	sessionService *SessionService
+	mailer         mailer.Mailer
+	appURL         string
+}
+
+func NewAccountService(
+	userRepo repository.UserStore,
+	tokenRepo repository.UserTokenStore,
+	userService *UserService,
+	sessionService *SessionService,
+	mailer mailer.Mailer,
+	mailerConfig *config.MailerConfig,
+) *AccountService {
+	return &AccountService{
+		userRepo:       userRepo,
+		tokenRepo:      tokenRepo,
+		userService:    userService,
+		sessionService: sessionService,
+		mailer:         mailer,
+		appURL:         mailerConfig.AppURL,
+	}
+}
+
+// RequestPasswordReset mails a reset link to the account with the email. Unknown emails are ignored without an error,
+// so the endpoint does not reveal which addresses have accounts.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) error {
+	dbUser, err := s.userRepo.GetUserByEmail(ctx, email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := s.issueToken(ctx, int(dbUser.ID), domain.TokenPurposePasswordReset, passwordResetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      dbUser.Email,
+		Subject: "Reset your Movie Search password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your Movie Search account. "+
+				"Follow this link within an hour to choose a new one:\n\n%s\n\n"+
+				"If it was not you, ignore this email and your password stays the same.\n",
+			dbUser.FirstName, s.link(resetPasswordPath, token),
+		),
+	})
+}
+
+// ResetPassword redeems a reset token, sets the new password and signs the user out everywhere. Receiving the token
+// proves the user owns the email, so the address counts as verified from then on.
+func (s *AccountService) ResetPassword(ctx context.Context, token, password string) error {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return ErrInvalidPassword
+	}
+
+	dbToken, err := s.tokenRepo.ConsumeUserToken(ctx, domain.TokenPurposePasswordReset, hashToken(token))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	userID := int(dbToken.UserID)
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if _, err := s.userRepo.SetUserPassword(ctx, userID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	// Links mailed before this reset must not be able to change the password again
+	if err := s.tokenRepo.RevokeUserTokens(ctx, userID, domain.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+
+	// Whoever knew the old password may already be signed in
+	if err := s.sessionService.RevokeAllSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.markEmailVerified(ctx, userID)
+}
+
+// SendEmailVerification mails a verification link to the user, superseding the links sent before
+func (s *AccountService) SendEmailVerification(ctx context.Context, userID int) error {
+	dbUser, err := s.userRepo.GetUserByID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if dbUser.EmailVerifiedAt.Valid {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := s.issueToken(ctx, userID, domain.TokenPurposeEmailVerification, emailVerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      dbUser.Email,
+		Subject: "Verify your Movie Search email",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nConfirm that this is your email address by following this link within 48 hours:\n\n%s\n",
+			dbUser.FirstName, s.link(verifyEmailPath, token),
+		),
+	})
+}
+
+// VerifyEmail redeems a verification token
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) error {
+	dbToken, err := s.tokenRepo.ConsumeUserToken(ctx, domain.TokenPurposeEmailVerification, hashToken(token))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.markEmailVerified(ctx, int(dbToken.UserID))
+}
+
+// MarkEmailVerified verifies the address of a user whose identity provider already verified it
+func (s *AccountService) MarkEmailVerified(ctx context.Context, userID int) error {
+	return s.markEmailVerified(ctx, userID)
+}
+
+// markEmailVerified records the verification, which may make the user the bootstrap admin
+func (s *AccountService) markEmailVerified(ctx context.Context, userID int) error {
+	if err := s.userRepo.MarkEmailVerified(ctx, userID); err != nil {
+		return err
+	}
+	return s.userService.bootstrapVerifiedAdmin(ctx, userID)
+}
+
+// ChangePassword sets the user's password. An account with a password must supply it as currentPassword; an account
+// that signs in with providers only gets its first password. Every session but the one with keepSessionID ends.
+func (s *AccountService) ChangePassword(ctx context.Context, userID int, currentPassword, newPassword, keepSessionID string) error {
+	dbUser, err := s.userRepo.GetUserByID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if dbUser.Password.Valid && bcrypt.CompareHashAndPassword([]byte(dbUser.Password.String), []byte(currentPassword)) != nil {
+		return ErrIncorrectPassword
+	}
+	if len(newPassword) < minPasswordLength || len(newPassword) > maxPasswordLength {
+		return ErrInvalidPassword
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if _, err := s.userRepo.SetUserPassword(ctx, userID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	// A reset link mailed before the change would undo it
+	if err := s.tokenRepo.RevokeUserTokens(ctx, userID, domain.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+
+	// Whoever knew the old password may already be signed in
+	_, err = s.sessionService.RevokeOtherSessions(ctx, userID, keepSessionID)
+	return err
+}
+
+// DeleteAccount schedules the user's account for deletion and signs it out everywhere. An account with a password
+// must supply it. Signing in again within AccountDeletionGracePeriod restores the account; after that it is purged
+// with everything that belongs to it.
+func (s *AccountService) DeleteAccount(ctx context.Context, userID int, password string) error {
+	dbUser, err := s.userRepo.GetUserByID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if dbUser.Password.Valid && bcrypt.CompareHashAndPassword([]byte(dbUser.Password.String), []byte(password)) != nil {
+		return ErrIncorrectPassword
+	}
+
+	scheduled, err := s.userRepo.ScheduleDeletion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !scheduled {
+		return ErrUserNotFound
+	}
+
+	return s.sessionService.RevokeAllSessions(ctx, userID)
+}
+
+// RestoreAccount cancels the deletion of the user's account and reports whether it was scheduled for deletion
+func (s *AccountService) RestoreAccount(ctx context.Context, userID int) (bool, error) {
+	return s.userRepo.RestoreUser(ctx, userID)
+}
+
+// issueToken revokes the user's outstanding tokens for the purpose and stores a new one, so only the latest link works
+func (s *AccountService) issueToken(ctx context.Context, userID int, purpose string, ttl time.Duration) (string, error) {
+	if err := s.tokenRepo.RevokeUserTokens(ctx, userID, purpose); err != nil {
+		return "", err
+	}
+
+	raw := make([]byte, accountTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	if _, err := s.tokenRepo.CreateUserToken(ctx, userID, purpose, hashToken(token), ttl); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *AccountService) link(path, token string) string {
+	return s.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
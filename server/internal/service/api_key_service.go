@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+const (
+	apiKeyBytes         = 32
+	apiKeyPrefix        = "msk_"
+	apiKeyDisplayLength = len(apiKeyPrefix) + 8
+	maxAPIKeyNameLength = 100
+)
+
+var (
+	ErrAPIKeyNotFound     = errors.New("API key not found")
+	ErrInvalidAPIKeyName  = errors.New("API key name must be between 1 and 100 characters")
+	ErrInvalidAPIKeyScope = errors.New("scopes must be one or more of read, likes and admin")
+)
+
+// APIKeyService manages personal API keys. Like the mailed tokens, only a key's SHA-256 is stored;
+// the key is returned once, when it is created.
+type APIKeyService struct {
+	apiKeyRepo repository.APIKeyStore
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyStore) *APIKeyService {
+	return &APIKeyService{apiKeyRepo: apiKeyRepo}
+}
+
+// CreateAPIKey issues a key with the scopes to the user
+func (s *APIKeyService) CreateAPIKey(
+	ctx context.Context,
+	userID int,
+	name string,
+	scopes []string,
+) (*domain.CreatedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		return nil, ErrInvalidAPIKeyName
+	}
+
+	scopes = slices.Compact(slices.Sorted(slices.Values(scopes)))
+	if len(scopes) == 0 {
+		return nil, ErrInvalidAPIKeyScope
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(domain.APIKeyScopes, scope) {
+			return nil, ErrInvalidAPIKeyScope
+		}
+	}
+
+	raw := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(raw)
+
+	dbKey, err := s.apiKeyRepo.CreateAPIKey(ctx, userID, name, key[:apiKeyDisplayLength], hashToken(key), scopes)
+	if isPgError(err, pgForeignKeyViolation) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.CreatedAPIKey{APIKey: *mapDBAPIKeyToDomainAPIKey(&dbKey), Key: key}, nil
+}
+
+// ListAPIKeys returns the user's keys, newest first
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, userID int) ([]*domain.APIKey, error) {
+	dbKeys, err := s.apiKeyRepo.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*domain.APIKey, 0, len(dbKeys))
+	for i := range dbKeys {
+		keys = append(keys, mapDBAPIKeyToDomainAPIKey(&dbKeys[i]))
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey deletes one of the user's keys
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, userID, keyID int) error {
+	deleted, err := s.apiKeyRepo.DeleteAPIKey(ctx, userID, keyID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey returns the owner and scopes of the key, recording that it was used.
+// Unknown keys yield middleware.ErrInvalidAPIKey.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (int, []string, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return 0, nil, middleware.ErrInvalidAPIKey
+	}
+
+	dbKey, err := s.apiKeyRepo.UseAPIKey(ctx, hashToken(key))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil, middleware.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return int(dbKey.UserID), dbKey.Scopes, nil
+}
+
+func mapDBAPIKeyToDomainAPIKey(dbKey *db.UserApiKey) *domain.APIKey {
+	key := &domain.APIKey{
+		ID:        int(dbKey.ID),
+		Name:      dbKey.Name,
+		Prefix:    dbKey.Prefix,
+		Scopes:    dbKey.Scopes,
+		CreatedAt: dbKey.CreatedAt.Time,
+	}
+	if dbKey.LastUsedAt.Valid {
+		key.LastUsedAt = &dbKey.LastUsedAt.Time
+	}
+	return key
+}
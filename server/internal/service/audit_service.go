@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var ErrInvalidAuditFilter = errors.New("until must be after since")
+
+// AuditEntry describes one admin write. Before and After are the entity as the API returns it, nil when the write
+// created or deleted it.
+type AuditEntry struct {
+	ActorID    int
+	Action     string
+	EntityType string
+	EntityID   int
+	RequestID  string
+	IP         string
+	Before     any
+	After      any
+}
+
+// AuditService keeps the append-only log of admin writes. Each event stores only the top-level fields the write
+// changed, so the log shows who changed what without copying whole entities on every edit.
+type AuditService struct {
+	auditRepo  repository.AuditStore
+	transactor repository.Transactor
+}
+
+func NewAuditService(auditRepo repository.AuditStore, transactor repository.Transactor) *AuditService {
+	return &AuditService{auditRepo: auditRepo, transactor: transactor}
+}
+
+// InTransaction runs write in a transaction. Events recorded with the context it receives are committed together
+// with the write, or not at all.
+func (s *AuditService) InTransaction(ctx context.Context, write func(ctx context.Context) error) error {
+	return s.transactor.InTransaction(ctx, write)
+}
+
+// Record appends an event for the write
+func (s *AuditService) Record(ctx context.Context, entry AuditEntry) (*domain.AuditEvent, error) {
+	before, after, err := diffAuditStates(entry.Before, entry.After)
+	if err != nil {
+		return nil, err
+	}
+
+	dbEvent, err := s.auditRepo.CreateAuditEvent(ctx, db.CreateAuditEventParams{
+		ActorID:    int32(entry.ActorID),
+		Action:     entry.Action,
+		EntityType: entry.EntityType,
+		EntityID:   int32(entry.EntityID),
+		RequestID:  entry.RequestID,
+		Ip:         entry.IP,
+		Before:     before,
+		After:      after,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mapDBAuditEventToDomainAuditEvent(&dbEvent), nil
+}
+
+// ListEvents returns one page of the events matching filter, newest first
+func (s *AuditService) ListEvents(
+	ctx context.Context,
+	filter domain.AuditFilter,
+	page domain.AuditPageRequest,
+) (*domain.AuditEventList, error) {
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Until.After(filter.Since) {
+		return nil, ErrInvalidAuditFilter
+	}
+
+	// Fetch one extra event to find out whether another page follows
+	dbEvents, err := s.auditRepo.ListAuditEvents(ctx, filter, page.BeforeID, page.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*domain.AuditEvent, 0, len(dbEvents))
+	for i := range dbEvents {
+		events = append(events, mapDBAuditEventToDomainAuditEvent(&dbEvents[i]))
+	}
+
+	list := &domain.AuditEventList{Events: events}
+	if len(events) > page.Limit {
+		list.Events = events[:page.Limit]
+		list.NextCursor = domain.EncodeAuditCursor(events[page.Limit-1].ID)
+	}
+	return list, nil
+}
+
+// diffAuditStates encodes both states and drops the top-level fields they share. A missing state stays NULL.
+func diffAuditStates(before, after any) ([]byte, []byte, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		for name, value := range beforeFields {
+			if otherValue, ok := afterFields[name]; ok && bytes.Equal(value, otherValue) {
+				delete(beforeFields, name)
+				delete(afterFields, name)
+			}
+		}
+	}
+
+	beforeJSON, err := encodeAuditFields(beforeFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterJSON, err := encodeAuditFields(afterFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+func auditFields(state any) (map[string]json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func encodeAuditFields(fields map[string]json.RawMessage) ([]byte, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	return json.Marshal(fields)
+}
+
+func mapDBAuditEventToDomainAuditEvent(dbEvent *db.AuditEvent) *domain.AuditEvent {
+	return &domain.AuditEvent{
+		ID:         int(dbEvent.ID),
+		ActorID:    int(dbEvent.ActorID),
+		Action:     dbEvent.Action,
+		EntityType: dbEvent.EntityType,
+		EntityID:   int(dbEvent.EntityID),
+		RequestID:  dbEvent.RequestID,
+		IP:         dbEvent.Ip,
+		Before:     dbEvent.Before,
+		After:      dbEvent.After,
+		CreatedAt:  dbEvent.CreatedAt.Time,
+	}
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/mailer"
+	"github.com/martishin/movie-search-service/internal/model/config"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+const (
+	dataExportTokenBytes   = 32
+	dataExportTTL          = 24 * time.Hour
+	dataExportClaimTimeout = 10 * time.Minute
+	dataExportPollPeriod   = time.Minute
+	dataExportPath         = "/api/exports/download"
+)
+
+var (
+	ErrExportNotFound   = errors.New("data export not found")
+	ErrExportInProgress = errors.New("a data export is already being prepared")
+)
+
+// ExportService assembles archives of everything stored about a user. Requests only queue an export; a background
+// worker builds the archive and mails a download link that expires with it. Like the account tokens, only the
+// SHA-256 of the link's token is stored.
+type ExportService struct {
+	exportRepo      repository.DataExportStore
+	transactor      repository.Transactor
+	userService     *UserService
+	listService     *ListService
+	apiKeyService   *APIKeyService
+	identityService *IdentityService
+	sessionService  *SessionService
+	mailer          mailer.Mailer
+	apiURL          string
+	// wake nudges the worker when an export is requested, so it does not wait for the next poll
+	wake chan struct{}
+}
+
+func NewExportService(
+	exportRepo repository.DataExportStore,
+	transactor repository.Transactor,
+	userService *UserService,
+	listService *ListService,
+	apiKeyService *APIKeyService,
+	identityService *IdentityService,
+	sessionService *SessionService,
+	mailer mailer.Mailer,
+	mailerConfig *config.MailerConfig,
+) *ExportService {
+	return &ExportService{
+		exportRepo:      exportRepo,
+		transactor:      transactor,
+		userService:     userService,
+		listService:     listService,
+		apiKeyService:   apiKeyService,
+		identityService: identityService,
+		sessionService:  sessionService,
+		mailer:          mailer,
+		apiURL:          mailerConfig.APIURL,
+		wake:            make(chan struct{}, 1),
+	}
+}
+
+// RequestExport queues an export of the user's data. A user has at most one export pending at a time.
+func (s *ExportService) RequestExport(ctx context.Context, userID int) (*domain.DataExport, error) {
+	dbExport, err := s.exportRepo.CreateExport(ctx, userID)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation && pgErr.ConstraintName == "unique_user_data_export_pending":
+		return nil, ErrExportInProgress
+	case isPgError(err, pgForeignKeyViolation):
+		return nil, ErrUserNotFound
+	case err != nil:
+		return nil, err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return mapDBExportToDomainExport(&dbExport), nil
+}
+
+// GetExport returns the state of one of the user's exports
+func (s *ExportService) GetExport(ctx context.Context, userID, id int) (*domain.DataExport, error) {
+	dbExport, err := s.exportRepo.GetExport(ctx, userID, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapDBExportToDomainExport(&dbExport), nil
+}
+
+// DownloadExport returns the unexpired export with the mailed download token together with its archive
+func (s *ExportService) DownloadExport(ctx context.Context, token string) (*domain.DataExport, []byte, error) {
+	dbExport, err := s.exportRepo.GetExportByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return mapDBExportToDomainExport(&dbExport), dbExport.Archive, nil
+}
+
+// Run assembles pending exports and deletes expired ones, when an export is requested and every minute,
+// until ctx is cancelled
+func (s *ExportService) Run(ctx context.Context, logger *slog.Logger) {
+	ticker := time.NewTicker(dataExportPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		if deleted, err := s.exportRepo.DeleteExpiredExports(ctx); err != nil {
+			logger.Error("Failed to delete expired data exports", slog.Any("error", err))
+		} else if deleted > 0 {
+			logger.Info("Expired data exports deleted", slog.Int("count", deleted))
+		}
+		s.processExports(ctx, logger)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// processExports assembles pending exports until none is left
+func (s *ExportService) processExports(ctx context.Context, logger *slog.Logger) {
+	for {
+		dbExport, err := s.exportRepo.ClaimExport(ctx, dataExportClaimTimeout)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to claim data export", slog.Any("error", err))
+			return
+		}
+
+		exportID, userID := int(dbExport.ID), int(dbExport.UserID)
+		if err := s.completeExport(ctx, exportID, userID); err != nil {
+			logger.Error("Failed to complete data export", slog.Any("error", err),
+				slog.Int("export_id", exportID), slog.Int("user_id", userID))
+			if _, err := s.exportRepo.FailExport(ctx, exportID, dataExportTTL); err != nil {
+				logger.Error("Failed to mark data export as failed", slog.Any("error", err), slog.Int("export_id", exportID))
+			}
+			continue
+		}
+		logger.Info("Data export ready", slog.Int("export_id", exportID), slog.Int("user_id", userID))
+	}
+}
+
+// completeExport builds and stores the export's archive and mails the user a link to download it
+func (s *ExportService) completeExport(ctx context.Context, exportID, userID int) error {
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	archive, err := s.buildArchive(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	raw := make([]byte, dataExportTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+
+	// The mail holds the only copy of the token, so the export is ready only if it was sent; otherwise it stays
+	// pending and is marked failed
+	return s.transactor.InTransaction(ctx, func(ctx context.Context) error {
+		// Not completed when another worker took the export over after the claim timed out and finished first
+		completed, err := s.exportRepo.CompleteExport(ctx, exportID, hashToken(token), archive, dataExportTTL)
+		if err != nil || !completed {
+			return err
+		}
+
+		return s.mailer.Send(ctx, mailer.Message{
+			To:      user.Email,
+			Subject: "Your Movie Search data export is ready",
+			Body: fmt.Sprintf(
+				"Hi %s,\n\nThe archive of your Movie Search data you asked for is ready. "+
+					"Download it within 24 hours from this link:\n\n%s\n\n"+
+					"If it was not you, change your password and sign out your other devices.\n",
+				user.FirstName, s.downloadURL(token),
+			),
+		})
+	})
+}
+
+// buildArchive writes the user's data to a ZIP archive, one JSON file per kind
+func (s *ExportService) buildArchive(ctx context.Context, user *domain.User) ([]byte, error) {
+	files, err := s.collectData(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type exportFile struct {
+	name string
+	data any
+}
+
+func (s *ExportService) collectData(ctx context.Context, user *domain.User) ([]exportFile, error) {
+	likeRows, err := s.exportRepo.ListUserLikes(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	likes := make([]*domain.ExportedLike, 0, len(likeRows))
+	for _, row := range likeRows {
+		likes = append(likes, &domain.ExportedLike{
+			MovieID: int(row.MovieID),
+			Title:   row.Title,
+			LikedAt: row.LikedAt.Time,
+		})
+	}
+
+	ratingRows, err := s.exportRepo.ListUserRatings(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	ratings := make([]*domain.ExportedRating, 0, len(ratingRows))
+	for _, row := range ratingRows {
+		ratings = append(ratings, &domain.ExportedRating{
+			MovieID:   int(row.MovieID),
+			Title:     row.Title,
+			Rating:    int(row.Rating),
+			CreatedAt: row.CreatedAt.Time,
+			UpdatedAt: row.UpdatedAt.Time,
+		})
+	}
+
+	reviewRows, err := s.exportRepo.ListUserReviews(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	reviews := make([]*domain.ExportedReview, 0, len(reviewRows))
+	for _, row := range reviewRows {
+		reviews = append(reviews, &domain.ExportedReview{
+			ID:        int(row.ID),
+			MovieID:   int(row.MovieID),
+			Title:     row.Title,
+			Body:      row.Body,
+			Status:    row.Status,
+			CreatedAt: row.CreatedAt.Time,
+			UpdatedAt: row.UpdatedAt.Time,
+		})
+	}
+
+	summaries, err := s.listService.ListUserLists(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	lists := make([]*domain.UserList, 0, len(summaries))
+	for _, summary := range summaries {
+		list, err := s.listService.GetList(ctx, user.ID, summary.ID)
+		if errors.Is(err, ErrListNotFound) {
+			continue // Deleted since it was listed
+		}
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+
+	sessions, err := s.sessionService.ListSessions(ctx, user.ID, "")
+	if err != nil {
+		return nil, err
+	}
+	apiKeys, err := s.apiKeyService.ListAPIKeys(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.identityService.ListIdentities(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return []exportFile{
+		{"profile.json", user},
+		{"likes.json", likes},
+		{"ratings.json", ratings},
+		{"reviews.json", reviews},
+		{"lists.json", lists},
+		{"sessions.json", sessions},
+		{"api_keys.json", apiKeys},
+		{"identities.json", identities},
+	}, nil
+}
+
+func (s *ExportService) downloadURL(token string) string {
+	return s.apiURL + dataExportPath + "?token=" + url.QueryEscape(token)
+}
+
+func mapDBExportToDomainExport(dbExport *db.UserDataExport) *domain.DataExport {
+	export := &domain.DataExport{
+		ID:        int(dbExport.ID),
+		Status:    dbExport.Status,
+		CreatedAt: dbExport.CreatedAt.Time,
+	}
+	if dbExport.CompletedAt.Valid {
+		export.CompletedAt = &dbExport.CompletedAt.Time
+	}
+	if dbExport.ExpiresAt.Valid {
+		export.ExpiresAt = &dbExport.ExpiresAt.Time
+	}
+	return export
+}
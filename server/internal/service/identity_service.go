@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var (
+	ErrIdentityEmailTaken  = errors.New("an account with this email already exists; sign in to it and link the provider from your settings")
+	ErrIdentityEmailNeeded = errors.New("the provider did not share an email address")
+	ErrIdentityInUse       = errors.New("this provider account is linked to another user")
+	ErrIdentityLinked      = errors.New("an account at this provider is already linked")
+	ErrIdentityNotFound    = errors.New("no account at this provider is linked")
+	ErrLastSignInMethod    = errors.New("set a password or link another provider before unlinking the last one")
+)
+
+// IdentityService signs users in with OAuth and OpenID Connect providers and manages the provider accounts linked to
+// them. A provider account is matched by the provider's ID for it, never by email: an email that already belongs to
+// a user only signs in to that user through an explicit link.
+type IdentityService struct {
+	userService  *UserService
+	userRepo     repository.UserStore
+	identityRepo repository.IdentityStore
+}
+
+func NewIdentityService(
+	userService *UserService,
+	userRepo repository.UserStore,
+	identityRepo repository.IdentityStore,
+) *IdentityService {
+	return &IdentityService{userService: userService, userRepo: userRepo, identityRepo: identityRepo}
+}
+
+// SignIn returns the user the provider account is linked to, creating a user for an account seen for the first time.
+//
+// Users from before identities were recorded have neither a password nor a linked identity. Their provider email,
+// when verified, links the account to them once; every other email collision is refused with ErrIdentityEmailTaken.
+func (s *IdentityService) SignIn(ctx context.Context, profile domain.OAuthProfile) (*domain.User, error) {
+	identity, err := s.identityRepo.GetUserIdentity(ctx, profile.Provider, profile.Subject)
+	switch {
+	case err == nil:
+		return s.signedInUser(ctx, int(identity.UserID), profile)
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+
+	if profile.Email == "" {
+		return nil, ErrIdentityEmailNeeded
+	}
+
+	existing, err := s.userRepo.GetUserByEmail(ctx, profile.Email)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return s.createUser(ctx, profile)
+	case err != nil:
+		return nil, err
+	}
+
+	legacy, err := s.isLegacyOAuthUser(ctx, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if !legacy || !profile.EmailVerified {
+		return nil, ErrIdentityEmailTaken
+	}
+
+	if _, err := s.identityRepo.CreateUserIdentity(ctx, int(existing.ID), profile.Provider, profile.Subject, profile.Email); err != nil {
+		return nil, identityError(err)
+	}
+	return s.signedInUser(ctx, int(existing.ID), profile)
+}
+
+// LinkIdentity links the provider account to the signed-in user
+func (s *IdentityService) LinkIdentity(ctx context.Context, userID int, profile domain.OAuthProfile) error {
+	_, err := s.identityRepo.CreateUserIdentity(ctx, userID, profile.Provider, profile.Subject, profile.Email)
+	if err != nil {
+		return identityError(err)
+	}
+
+	dbUser, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !dbUser.EmailVerifiedAt.Valid && vouchesForEmail(&dbUser, profile) {
+		if err := s.userRepo.MarkEmailVerified(ctx, userID); err != nil {
+			return err
+		}
+		return s.userService.bootstrapVerifiedAdmin(ctx, userID)
+	}
+	return nil
+}
+
+// ListIdentities returns the provider accounts linked to the user, in the order they were linked
+func (s *IdentityService) ListIdentities(ctx context.Context, userID int) ([]*domain.Identity, error) {
+	dbIdentities, err := s.identityRepo.ListUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make([]*domain.Identity, 0, len(dbIdentities))
+	for _, dbIdentity := range dbIdentities {
+		identities = append(identities, &domain.Identity{
+			Provider:  dbIdentity.Provider,
+			Email:     dbIdentity.Email,
+			CreatedAt: dbIdentity.CreatedAt.Time,
+		})
+	}
+	return identities, nil
+}
+
+// UnlinkIdentity unlinks the user's account at the provider, unless it is the only way left to sign in
+func (s *IdentityService) UnlinkIdentity(ctx context.Context, userID int, provider string) error {
+	dbUser, err := s.userRepo.GetUserByID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	identities, err := s.identityRepo.ListUserIdentities(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !dbUser.Password.Valid && len(identities) == 1 && identities[0].Provider == provider {
+		return ErrLastSignInMethod
+	}
+
+	deleted, err := s.identityRepo.DeleteUserIdentity(ctx, userID, provider)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
+
+func (s *IdentityService) createUser(ctx context.Context, profile domain.OAuthProfile) (*domain.User, error) {
+	dbUser, err := s.identityRepo.CreateUserWithIdentity(
+		ctx,
+		truncateName(profile.FirstName),
+		truncateName(profile.LastName),
+		profile.Email,
+		profile.PictureURL,
+		profile.EmailVerified,
+		profile.Provider,
+		profile.Subject,
+	)
+	if err != nil {
+		// Another request signed the same account or email up first
+		if isPgError(err, pgUniqueViolation) {
+			return nil, ErrIdentityEmailTaken
+		}
+		return nil, err
+	}
+
+	// Only an email the provider verified may claim the bootstrap admin
+	if profile.EmailVerified {
+		if err := s.userService.bootstrapAdmin(ctx, &dbUser); err != nil {
+			return nil, err
+		}
+	}
+	return mapDBUserToDomainUser(&dbUser), nil
+}
+
+func (s *IdentityService) signedInUser(ctx context.Context, userID int, profile domain.OAuthProfile) (*domain.User, error) {
+	dbUser, err := s.userRepo.GetUserByID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !vouchesForEmail(&dbUser, profile) {
+		return mapDBUserToDomainUser(&dbUser), nil
+	}
+
+	if !dbUser.EmailVerifiedAt.Valid {
+		if err := s.userRepo.MarkEmailVerified(ctx, userID); err != nil {
+			return nil, err
+		}
+		dbUser.EmailVerifiedAt = pgtype.Timestamp{Time: time.Now(), Valid: true}
+	}
+
+	// Only a sign-in in which the provider verified the account's email may claim the bootstrap admin
+	if err := s.userService.bootstrapAdmin(ctx, &dbUser); err != nil {
+		return nil, err
+	}
+	return mapDBUserToDomainUser(&dbUser), nil
+}
+
+// isLegacyOAuthUser reports whether the user signed up with Google before identities were recorded
+func (s *IdentityService) isLegacyOAuthUser(ctx context.Context, dbUser *db.User) (bool, error) {
+	if dbUser.Password.Valid {
+		return false, nil
+	}
+	identities, err := s.identityRepo.ListUserIdentities(ctx, int(dbUser.ID))
+	return len(identities) == 0, err
+}
+
+// vouchesForEmail reports whether the provider verified the user's own address
+func vouchesForEmail(dbUser *db.User, profile domain.OAuthProfile) bool {
+	return profile.EmailVerified && profile.Email != "" && strings.EqualFold(dbUser.Email, profile.Email)
+}
+
+// identityError maps the constraint a new identity violated to the error to report
+func identityError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch {
+	case pgErr.Code == pgUniqueViolation && pgErr.ConstraintName == "unique_user_identity":
+		return ErrIdentityInUse
+	case pgErr.Code == pgUniqueViolation && pgErr.ConstraintName == "unique_user_identity_provider":
+		return ErrIdentityLinked
+	case pgErr.Code == pgForeignKeyViolation:
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// truncateName fits a provider's name into the users table
+func truncateName(name string) string {
+	runes := []rune(strings.TrimSpace(name))
+	if len(runes) > maxUserNameLength {
+		return string(runes[:maxUserNameLength])
+	}
+	return string(runes)
+}
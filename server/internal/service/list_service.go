@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+const (
+	maxListNameLength        = 100
+	maxListDescriptionLength = 1000
+	shareTokenBytes          = 16
+	sharedListPath           = "/api/public/lists/"
+)
+
+var (
+	ErrListNotFound          = errors.New("list not found")
+	ErrListEntryNotFound     = errors.New("movie is not in the list")
+	ErrInvalidListName       = errors.New("list name must be between 1 and 100 characters")
+	ErrInvalidListDesc       = errors.New("list description must be at most 1000 characters")
+	ErrInvalidListVisibility = errors.New("visibility must be private, unlisted or public")
+	ErrInvalidListOrder      = errors.New("movie_ids must contain every movie of the list exactly once")
+)
+
+type ListService struct {
+	listRepo repository.ListStore
+}
+
+func NewListService(listRepo repository.ListStore) *ListService {
+	return &ListService{listRepo: listRepo}
+}
+
+func (s *ListService) CreateList(ctx context.Context, userID int, name, description, visibility string) (*domain.UserList, error) {
+	if visibility == "" {
+		visibility = domain.ListVisibilityPrivate
+	}
+	name, description, err := validateList(name, description, visibility)
+	if err != nil {
+		return nil, err
+	}
+
+	shareToken, err := generateShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	dbList, err := s.listRepo.CreateUserList(ctx, userID, name, description, visibility, shareToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapDBListToDomainList(&dbList, nil), nil
+}
+
+func (s *ListService) ListUserLists(ctx context.Context, userID int) ([]*domain.UserList, error) {
+	rows, err := s.listRepo.ListUserListsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	lists := make([]*domain.UserList, 0, len(rows))
+	for i := range rows {
+		lists = append(lists, mapDBListSummaryToDomainList(&rows[i]))
+	}
+
+	return lists, nil
+}
+
+// ListPublicLists returns the user's public lists without their movies, for anyone to discover
+func (s *ListService) ListPublicLists(ctx context.Context, userID int) ([]*domain.UserList, error) {
+	rows, err := s.listRepo.ListPublicUserListsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	lists := make([]*domain.UserList, 0, len(rows))
+	for _, row := range rows {
+		summary := db.ListUserListsByUserRow(row)
+		lists = append(lists, mapDBListSummaryToDomainList(&summary))
+	}
+
+	return lists, nil
+}
+
+// GetList returns one of the user's lists together with its movies
+func (s *ListService) GetList(ctx context.Context, userID, listID int) (*domain.UserList, error) {
+	dbList, err := s.listRepo.GetUserListByID(ctx, userID, listID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.withEntries(ctx, &dbList)
+}
+
+// GetSharedList returns an unlisted or public list by its share token
+func (s *ListService) GetSharedList(ctx context.Context, shareToken string) (*domain.UserList, error) {
+	dbList, err := s.listRepo.GetUserListByShareToken(ctx, shareToken)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.withEntries(ctx, &dbList)
+}
+
+func (s *ListService) UpdateList(ctx context.Context, userID, listID int, name, description, visibility string) (*domain.UserList, error) {
+	name, description, err := validateList(name, description, visibility)
+	if err != nil {
+		return nil, err
+	}
+
+	dbList, err := s.listRepo.UpdateUserList(ctx, userID, listID, name, description, visibility)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.withEntries(ctx, &dbList)
+}
+
+func (s *ListService) DeleteList(ctx context.Context, userID, listID int) error {
+	deleted, err := s.listRepo.DeleteUserList(ctx, userID, listID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrListNotFound
+	}
+	return nil
+}
+
+func (s *ListService) AddEntry(ctx context.Context, userID, listID, movieID int) (*domain.UserList, error) {
+	err := s.listRepo.AddUserListEntry(ctx, userID, listID, movieID)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, ErrListNotFound
+	case isPgError(err, pgForeignKeyViolation):
+		return nil, ErrMovieNotFound
+	case err != nil:
+		return nil, err
+	}
+
+	return s.GetList(ctx, userID, listID)
+}
+
+func (s *ListService) RemoveEntry(ctx context.Context, userID, listID, movieID int) (*domain.UserList, error) {
+	removed, err := s.listRepo.RemoveUserListEntry(ctx, userID, listID, movieID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, ErrListEntryNotFound
+	}
+
+	return s.GetList(ctx, userID, listID)
+}
+
+// ReorderEntries stores a new order for the list; movieIDs must be a permutation of the movies in the list
+func (s *ListService) ReorderEntries(ctx context.Context, userID, listID int, movieIDs []int) (*domain.UserList, error) {
+	err := s.listRepo.ReorderUserListEntries(ctx, userID, listID, func(current []int32) ([]int32, error) {
+		if len(movieIDs) != len(current) {
+			return nil, ErrInvalidListOrder
+		}
+
+		remaining := make(map[int32]bool, len(current))
+		for _, movieID := range current {
+			remaining[movieID] = true
+		}
+
+		ordered := make([]int32, 0, len(movieIDs))
+		for _, movieID := range movieIDs {
+			if !remaining[int32(movieID)] {
+				return nil, ErrInvalidListOrder
+			}
+			delete(remaining, int32(movieID))
+			ordered = append(ordered, int32(movieID))
+		}
+		return ordered, nil
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetList(ctx, userID, listID)
+}
+
+func (s *ListService) withEntries(ctx context.Context, dbList *db.UserList) (*domain.UserList, error) {
+	rows, err := s.listRepo.ListUserListEntries(ctx, int(dbList.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*domain.UserListEntry, 0, len(rows))
+	for i, row := range rows {
+		userRating, _ := row.UserRating.Float64Value()
+		entries = append(entries, &domain.UserListEntry{
+			MovieID:     int(row.MovieID),
+			Title:       row.Title,
+			ReleaseDate: row.ReleaseDate.Time,
+			Image:       row.Image.String,
+			UserRating:  userRating.Float64,
+			// Removals leave gaps in the stored positions, so report the rank instead
+			Position: i + 1,
+			AddedAt:  row.AddedAt.Time,
+		})
+	}
+
+	return mapDBListToDomainList(dbList, entries), nil
+}
+
+func validateList(name, description, visibility string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxListNameLength {
+		return "", "", ErrInvalidListName
+	}
+
+	description = strings.TrimSpace(description)
+	if utf8.RuneCountInString(description) > maxListDescriptionLength {
+		return "", "", ErrInvalidListDesc
+	}
+
+	if !domain.IsValidListVisibility(visibility) {
+		return "", "", ErrInvalidListVisibility
+	}
+
+	return name, description, nil
+}
+
+func generateShareToken() (string, error) {
+	token := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+func mapDBListToDomainList(dbList *db.UserList, entries []*domain.UserListEntry) *domain.UserList {
+	list := &domain.UserList{
+		ID:          int(dbList.ID),
+		Name:        dbList.Name,
+		Description: dbList.Description,
+		Visibility:  dbList.Visibility,
+		EntryCount:  len(entries),
+		Entries:     entries,
+		CreatedAt:   dbList.CreatedAt.Time,
+		UpdatedAt:   dbList.UpdatedAt.Time,
+	}
+
+	// Private lists have no share URL even though a token is reserved for them
+	if dbList.Visibility != domain.ListVisibilityPrivate {
+		list.ShareURL = sharedListPath + dbList.ShareToken
+	}
+
+	return list
+}
+
+func mapDBListSummaryToDomainList(row *db.ListUserListsByUserRow) *domain.UserList {
+	list := mapDBListToDomainList(&db.UserList{
+		ID:          row.ID,
+		UserID:      row.UserID,
+		Name:        row.Name,
+		Description: row.Description,
+		Visibility:  row.Visibility,
+		ShareToken:  row.ShareToken,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+	}, nil)
+	list.EntryCount = int(row.EntryCount)
+	return list
+}
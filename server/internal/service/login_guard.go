@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/martishin/movie-search-service/internal/model/config"
+	"github.com/martishin/movie-search-service/internal/throttle"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Failed login reasons, used as metric labels
+const (
+	LoginFailureUnknownAccount   = "unknown_account"
+	LoginFailureOAuthAccount     = "oauth_account"
+	LoginFailureInvalidPassword  = "invalid_password"
+	LoginFailureInvalidTwoFactor = "invalid_two_factor_code"
+)
+
+// Lockout scopes
+const (
+	LockoutScopeAccount = "account"
+	LockoutScopeIP      = "ip"
+)
+
+var ErrLoginLocked = errors.New("too many failed login attempts, try again later")
+
+var (
+	loginFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "login_failures_total",
+			Help: "Total number of failed login attempts",
+		},
+		[]string{"reason"},
+	)
+
+	loginLockouts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "login_lockouts_total",
+			Help: "Total number of lockouts triggered by repeated failed logins",
+		},
+		[]string{"scope"},
+	)
+
+	loginRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "login_locked_rejections_total",
+			Help: "Total number of login attempts rejected because the account or IP was locked out",
+		},
+		[]string{"scope"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(loginFailures, loginLockouts, loginRejected)
+}
+
+// LoginLockout describes a lockout triggered by a failed login
+type LoginLockout struct {
+	Scope    string
+	Failures int
+	Duration time.Duration
+}
+
+// LoginGuard throttles password guessing. Failed logins are counted in sliding windows per account and per client IP;
+// every recent failure of an account doubles the wait before its next attempt is checked, and too many failures lock
+// the account or IP out for a while.
+type LoginGuard struct {
+	store  throttle.Store
+	config *config.LoginProtectionConfig
+}
+
+func NewLoginGuard(store throttle.Store, loginConfig *config.LoginProtectionConfig) *LoginGuard {
+	return &LoginGuard{store: store, config: loginConfig}
+}
+
+// Check returns how long an attempt on the account from ip must wait before its credentials are checked.
+// If the account or IP is locked out it returns ErrLoginLocked and how long the lockout lasts.
+func (g *LoginGuard) Check(ctx context.Context, account, ip string) (time.Duration, error) {
+	for _, scope := range []struct{ name, key string }{
+		{LockoutScopeAccount, accountKey(account)},
+		{LockoutScopeIP, ipKey(ip)},
+	} {
+		lockedFor, err := g.store.LockedFor(ctx, scope.key)
+		if err != nil {
+			return 0, err
+		}
+		if lockedFor > 0 {
+			loginRejected.WithLabelValues(scope.name).Inc()
+			return lockedFor, ErrLoginLocked
+		}
+	}
+
+	failures, err := g.store.Count(ctx, failuresKey(accountKey(account)), time.Now(), g.config.FailureWindow)
+	if err != nil {
+		return 0, err
+	}
+	return g.delay(failures), nil
+}
+
+// RecordFailure counts a failed attempt against both the account and the IP. It returns the lockout the failure
+// triggered, preferring the account's when both were locked.
+func (g *LoginGuard) RecordFailure(ctx context.Context, account, ip, reason string) (*LoginLockout, error) {
+	loginFailures.WithLabelValues(reason).Inc()
+
+	now := time.Now()
+	var lockout *LoginLockout
+	for _, scope := range []struct {
+		name, key   string
+		maxFailures int
+	}{
+		{LockoutScopeAccount, accountKey(account), g.config.MaxFailuresPerAccount},
+		{LockoutScopeIP, ipKey(ip), g.config.MaxFailuresPerIP},
+	} {
+		failures, err := g.store.Hit(ctx, failuresKey(scope.key), now, g.config.FailureWindow)
+		if err != nil {
+			return nil, err
+		}
+		if failures < scope.maxFailures {
+			continue
+		}
+
+		if err := g.store.Lock(ctx, scope.key, g.config.LockoutDuration); err != nil {
+			return nil, err
+		}
+		// The lockout replaces the failures; after it ends the account starts again without delays
+		if err := g.store.Reset(ctx, failuresKey(scope.key)); err != nil {
+			return nil, err
+		}
+
+		loginLockouts.WithLabelValues(scope.name).Inc()
+		if lockout == nil {
+			lockout = &LoginLockout{Scope: scope.name, Failures: failures, Duration: g.config.LockoutDuration}
+		}
+	}
+	return lockout, nil
+}
+
+// RecordSuccess clears the account's failures. Failures from the IP still count, so one valid account cannot be used
+// to reset the budget of an address that guesses others.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, account string) error {
+	return g.store.Reset(ctx, failuresKey(accountKey(account)))
+}
+
+// delay is DelayBase after one failure, doubling with each further failure up to DelayMax
+func (g *LoginGuard) delay(failures int) time.Duration {
+	if failures == 0 || g.config.DelayBase == 0 {
+		return 0
+	}
+
+	delay := g.config.DelayBase
+	for i := 1; i < failures && delay < g.config.DelayMax; i++ {
+		delay *= 2
+	}
+	return min(delay, g.config.DelayMax)
+}
+
+// accountKey normalises emails, so changing their case does not buy more guesses
+func accountKey(account string) string {
+	return "login:account:" + strings.ToLower(strings.TrimSpace(account))
+}
+
+func ipKey(ip string) string {
+	return "login:ip:" + ip
+}
+
+func failuresKey(key string) string {
+	return key + ":failures"
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/martishin/movie-search-service/internal/cache"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+const (
+	// Tag versions are embedded in every cache key. Bumping a version orphans the keys built with the old one,
+	// so readers that raced a write can only ever store stale data under a key nobody reads again.
+	catalogVersionKey    = "cache:version:catalog"
+	movieListVersionKey  = "cache:version:movie_lists"
+	movieVersionKeyFmt   = "cache:version:movie:%d"
+	movieInvalidationsCh = "cache:invalidate:movies"
+
+	// Versions are also kept in process so that warm reads skip the cache backend entirely. Broadcast invalidations drop them
+	// at once; the TTL bounds staleness if a broadcast is missed.
+	localVersionsSize = 10000
+	localVersionsTTL  = time.Second
+)
+
+// errVersionsDegraded skips the version lookup while the backend is bypassed after a failure
+var errVersionsDegraded = errors.New("cache versions unavailable")
+
+// MovieInvalidation is broadcast to every instance when cached movie data changes.
+// MovieID is 0 when the whole catalog was invalidated.
+type MovieInvalidation struct {
+	MovieID int `json:"movie_id,omitempty"`
+}
+
+// MovieCache builds versioned cache keys for movies and movie listings and broadcasts invalidations
+type MovieCache struct {
+	backend       cache.Backend
+	localVersions *cache.LRU[int64]
+	degradedUntil atomic.Int64
+
+	mu        sync.RWMutex
+	listeners []func(MovieInvalidation)
+}
+
+func NewMovieCache(backend cache.Backend) *MovieCache {
+	return &MovieCache{
+		backend:       backend,
+		localVersions: cache.NewLRU[int64]("movie_versions", localVersionsSize, localVersionsTTL),
+	}
+}
+
+// MovieKey returns the current cache key of a single movie, or "" when caching is disabled. Reads inside a
+// transaction bypass the cache, since they may see writes that are not committed yet.
+func (c *MovieCache) MovieKey(ctx context.Context, movieID int) (string, error) {
+	if repository.InTransaction(ctx) {
+		return "", nil
+	}
+	versions, err := c.versions(ctx, catalogVersionKey, fmt.Sprintf(movieVersionKeyFmt, movieID))
+	if errors.Is(err, cache.ErrDisabled) || errors.Is(err, errVersionsDegraded) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("movie:v%d.%d:%d", versions[0], versions[1], movieID), nil
+}
+
+// ListKey returns the current cache key of a movie listing identified by suffix, or "" when caching is disabled or
+// ctx belongs to a transaction
+func (c *MovieCache) ListKey(ctx context.Context, suffix string) (string, error) {
+	if repository.InTransaction(ctx) {
+		return "", nil
+	}
+	versions, err := c.versions(ctx, catalogVersionKey, movieListVersionKey)
+	if errors.Is(err, cache.ErrDisabled) || errors.Is(err, errVersionsDegraded) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("movies:v%d.%d:%s", versions[0], versions[1], suffix), nil
+}
+
+// InvalidateMovie drops the cached copy of the movie and every cached listing once the transaction of ctx, if any,
+// commits. Errors are logged rather than returned because the write that caused the invalidation has already been
+// committed.
+func (c *MovieCache) InvalidateMovie(ctx context.Context, movieID int) {
+	repository.AfterCommit(ctx, func(ctx context.Context) {
+		c.invalidateMovie(ctx, movieID)
+	})
+}
+
+func (c *MovieCache) invalidateMovie(ctx context.Context, movieID int) {
+	err := c.backend.Incr(ctx, fmt.Sprintf(movieVersionKeyFmt, movieID), movieListVersionKey)
+	if err != nil {
+		middleware.GetLogger(ctx).Error("Failed to invalidate cached movie", slog.Any("error", err), slog.Int("movie_id", movieID))
+		return
+	}
+
+	// Drop local versions now so this instance reads its own write without waiting for the broadcast
+	c.dropLocalVersions(MovieInvalidation{MovieID: movieID})
+	c.publish(ctx, MovieInvalidation{MovieID: movieID})
+}
+
+// InvalidateAll drops every cached movie and listing at once, after the transaction of ctx commits
+func (c *MovieCache) InvalidateAll(ctx context.Context) {
+	repository.AfterCommit(ctx, c.invalidateAll)
+}
+
+func (c *MovieCache) invalidateAll(ctx context.Context) {
+	if err := c.backend.Incr(ctx, catalogVersionKey); err != nil {
+		middleware.GetLogger(ctx).Error("Failed to invalidate movie cache", slog.Any("error", err))
+		return
+	}
+
+	c.dropLocalVersions(MovieInvalidation{})
+	c.publish(ctx, MovieInvalidation{})
+}
+
+// OnInvalidate registers a listener for invalidations from any instance, including this one.
+// Listeners drop in-process copies of movie data and must not block.
+func (c *MovieCache) OnInvalidate(listener func(MovieInvalidation)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, listener)
+}
+
+// Listen delivers broadcast invalidations to the registered listeners until ctx is cancelled
+func (c *MovieCache) Listen(ctx context.Context, logger *slog.Logger) {
+	messages, err := c.backend.Subscribe(ctx, movieInvalidationsCh)
+	if err != nil {
+		logger.Error("Failed to subscribe to movie cache invalidations", slog.Any("error", err))
+		return
+	}
+
+	for message := range messages {
+		var invalidation MovieInvalidation
+		if err := json.Unmarshal([]byte(message), &invalidation); err != nil {
+			logger.Error("Invalid movie cache invalidation message", slog.Any("error", err), slog.String("payload", message))
+			continue
+		}
+
+		c.dropLocalVersions(invalidation)
+
+		c.mu.RLock()
+		listeners := c.listeners
+		c.mu.RUnlock()
+
+		for _, listener := range listeners {
+			listener(invalidation)
+		}
+	}
+}
+
+func (c *MovieCache) publish(ctx context.Context, invalidation MovieInvalidation) {
+	payload, _ := json.Marshal(invalidation)
+	if err := c.backend.Publish(ctx, movieInvalidationsCh, string(payload)); err != nil {
+		middleware.GetLogger(ctx).Error("Failed to broadcast movie cache invalidation", slog.Any("error", err))
+	}
+}
+
+func (c *MovieCache) dropLocalVersions(invalidation MovieInvalidation) {
+	if invalidation.MovieID == 0 {
+		c.localVersions.Purge()
+		return
+	}
+	c.localVersions.Remove(fmt.Sprintf(movieVersionKeyFmt, invalidation.MovieID))
+	c.localVersions.Remove(movieListVersionKey)
+}
+
+// versions reads the tag versions, fetching those not held in process in one round trip.
+// Tags that were never bumped are at version 0. Like the caches themselves, the lookup bypasses the backend for
+// movieCacheDegradedFor after it fails, so an outage costs one timeout rather than one per request.
+func (c *MovieCache) versions(ctx context.Context, keys ...string) ([]int64, error) {
+	versions := make([]int64, len(keys))
+
+	var missing []string
+	var missingIdx []int
+	for i, key := range keys {
+		version, ok := c.localVersions.Get(key)
+		if !ok {
+			missing = append(missing, key)
+			missingIdx = append(missingIdx, i)
+			continue
+		}
+		versions[i] = version
+	}
+	if len(missing) == 0 {
+		return versions, nil
+	}
+
+	if time.Now().UnixNano() < c.degradedUntil.Load() {
+		return nil, errVersionsDegraded
+	}
+
+	values, err := c.backend.MGet(ctx, missing...)
+	if err != nil {
+		if !errors.Is(err, cache.ErrDisabled) {
+			c.degradedUntil.Store(time.Now().Add(movieCacheDegradedFor).UnixNano())
+		}
+		return nil, err
+	}
+
+	for i, value := range values {
+		version, _ := strconv.ParseInt(value, 10, 64)
+		versions[missingIdx[i]] = version
+		c.localVersions.Set(missing[i], version)
+	}
+	return versions, nil
+}
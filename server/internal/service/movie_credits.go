@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+)
+
+var (
+	ErrCreditNotFound    = errors.New("credit not found")
+	ErrCreditExists      = errors.New("the person already holds this role on the movie")
+	ErrInvalidCreditRole = errors.New("role must be actor, director, writer or composer")
+)
+
+// AddMovieCredit attaches a person to the movie in the given role
+func (s *MovieService) AddMovieCredit(ctx context.Context, movieID int, credit domain.Credit) (*domain.Credit, error) {
+	if !domain.IsValidCreditRole(credit.Role) {
+		return nil, ErrInvalidCreditRole
+	}
+
+	// Only the cast plays characters and is billed
+	credit.CharacterName = strings.TrimSpace(credit.CharacterName)
+	if credit.Role != domain.CreditRoleActor {
+		credit.CharacterName = ""
+		credit.BillingOrder = 0
+	}
+
+	dbCredit, err := s.movieRepo.CreateMovieCredit(ctx, movieID, credit)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case isPgError(err, pgUniqueViolation):
+			return nil, ErrCreditExists
+		case errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation && pgErr.ConstraintName == "fk_people":
+			return nil, ErrPersonNotFound
+		case isPgError(err, pgForeignKeyViolation):
+			return nil, ErrMovieNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	s.movieCache.InvalidateMovie(ctx, movieID)
+
+	return &domain.Credit{
+		ID:            int(dbCredit.ID),
+		PersonID:      int(dbCredit.PersonID),
+		Role:          dbCredit.Role,
+		CharacterName: dbCredit.CharacterName.String,
+		BillingOrder:  int(dbCredit.BillingOrder.Int32),
+	}, nil
+}
+
+func (s *MovieService) RemoveMovieCredit(ctx context.Context, movieID, creditID int) error {
+	deleted, err := s.movieRepo.DeleteMovieCredit(ctx, movieID, creditID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrCreditNotFound
+	}
+
+	s.movieCache.InvalidateMovie(ctx, movieID)
+
+	return nil
+}
+
+func mapDBCreditsToDomainCredits(rows []db.ListCreditsByMovieIDRow) []*domain.Credit {
+	var credits []*domain.Credit
+	for _, row := range rows {
+		credits = append(credits, &domain.Credit{
+			ID:            int(row.ID),
+			PersonID:      int(row.PersonID),
+			Name:          row.Name,
+			Image:         row.Image.String,
+			Role:          row.Role,
+			CharacterName: row.CharacterName.String,
+			BillingOrder:  int(row.BillingOrder.Int32),
+		})
+	}
+	return credits
+}
@@ -2,25 +2,84 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/martishin/movie-search-service/internal/cache"
 	db "github.com/martishin/movie-search-service/internal/db/generated"
 	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/model/config"
 	"github.com/martishin/movie-search-service/internal/model/domain"
 	"github.com/martishin/movie-search-service/internal/repository"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	movieCacheTTL         = 10 * time.Minute
+	movieCacheJitter      = 0.1
+	missingMovieCacheTTL  = time.Minute
+	movieCacheDegradedFor = 5 * time.Second
+
+	// The in-process copies sit in front of Redis and are keyed by version, so writes make them unreachable
+	localMoviesSize     = 1000
+	localMovieListsSize = 200
+	localFacetsSize     = 10
+	localMovieCacheTTL  = time.Minute
+)
+
 type MovieService struct {
-	movieRepo   *repository.MovieRepository
-	redisClient *redis.Client
+	movieRepo repository.MovieStore
+	// redisClient holds the title suggestion index. It is nil when Redis is not configured,
+	// in which case suggestions are served from PostgreSQL.
+	redisClient  *redis.Client
+	movieCache   *MovieCache
+	movies       *cache.Aside[*domain.Movie]
+	movieLists   *cache.Aside[*domain.MovieList[*domain.Movie]]
+	movieFacets  *cache.Aside[*domain.MovieFacets]
+	searchConfig *config.SearchConfig
 }
 
-func NewMovieService(movieRepo *repository.MovieRepository, redisClient *redis.Client) *MovieService {
-	return &MovieService{movieRepo: movieRepo, redisClient: redisClient}
+func NewMovieService(
+	movieRepo repository.MovieStore,
+	redisClient *redis.Client,
+	cacheBackend cache.Backend,
+	movieCache *MovieCache,
+	searchConfig *config.SearchConfig,
+) *MovieService {
+	return &MovieService{
+		movieRepo:   movieRepo,
+		redisClient: redisClient,
+		movieCache:  movieCache,
+		movies: cache.NewAside[*domain.Movie](cacheBackend, cache.Options{
+			Family:      "movie",
+			TTL:         movieCacheTTL,
+			Jitter:      movieCacheJitter,
+			NotFound:    pgx.ErrNoRows,
+			NegativeTTL: missingMovieCacheTTL,
+			DegradedFor: movieCacheDegradedFor,
+			LocalSize:   localMoviesSize,
+			LocalTTL:    localMovieCacheTTL,
+		}),
+		movieLists: cache.NewAside[*domain.MovieList[*domain.Movie]](cacheBackend, cache.Options{
+			Family:      "movie_list",
+			TTL:         movieCacheTTL,
+			Jitter:      movieCacheJitter,
+			DegradedFor: movieCacheDegradedFor,
+			LocalSize:   localMovieListsSize,
+			LocalTTL:    localMovieCacheTTL,
+		}),
+		movieFacets: cache.NewAside[*domain.MovieFacets](cacheBackend, cache.Options{
+			Family:      "movie_facets",
+			TTL:         movieCacheTTL,
+			Jitter:      movieCacheJitter,
+			DegradedFor: movieCacheDegradedFor,
+			LocalSize:   localFacetsSize,
+			LocalTTL:    localMovieCacheTTL,
+		}),
+		searchConfig: searchConfig,
+	}
 }
 
 func (s *MovieService) CreateMovie(ctx context.Context, movie domain.Movie) (*domain.Movie, error) {
@@ -34,25 +93,25 @@ func (s *MovieService) CreateMovie(ctx context.Context, movie domain.Movie) (*do
 		return nil, err
 	}
 	createdMovie.Genres = mapDBGenresToDomainGenres(genres)
+
+	repository.AfterCommit(ctx, func(ctx context.Context) { s.indexMovieSuggestion(ctx, createdMovie.ID) })
+	s.movieCache.InvalidateMovie(ctx, createdMovie.ID)
+
 	return createdMovie, nil
 }
 
 func (s *MovieService) GetMovieByIDWithGenres(ctx context.Context, id int) (*domain.Movie, error) {
-	logger := middleware.GetLogger(ctx)
-
-	// Check Redis cache
-	cacheKey := fmt.Sprintf("movie:%d", id)
-
-	cachedMovie, err := s.redisClient.Get(ctx, cacheKey).Result()
-	if err == nil && cachedMovie != "" {
-		var movie domain.Movie
-		if err := json.Unmarshal([]byte(cachedMovie), &movie); err == nil {
-			logger.Info("Fetched movie from Redis", slog.Int("movie_id", id))
-			return &movie, nil
-		}
+	cacheKey, err := s.movieCache.MovieKey(ctx, id)
+	if err != nil {
+		middleware.GetLogger(ctx).Error("Failed to read movie cache version", slog.Any("error", err))
 	}
 
-	// Fetch from database
+	return s.movies.Get(ctx, cacheKey, func(ctx context.Context) (*domain.Movie, error) {
+		return s.loadMovieWithGenres(ctx, id)
+	})
+}
+
+func (s *MovieService) loadMovieWithGenres(ctx context.Context, id int) (*domain.Movie, error) {
 	dbMovie, err := s.movieRepo.GetMovieByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -63,16 +122,15 @@ func (s *MovieService) GetMovieByIDWithGenres(ctx context.Context, id int) (*dom
 		return nil, err
 	}
 
-	movie := mapDBMovieToDomainMovie(&dbMovie)
-	movie.Genres = mapDBGenresToDomainGenres(genres)
-
-	// Store in Redis with a TTL of 10 minutes
-	movieJSON, _ := json.Marshal(movie)
-	err = s.redisClient.Set(ctx, cacheKey, string(movieJSON), 10*time.Minute).Err()
+	credits, err := s.movieRepo.ListCreditsByMovieID(ctx, id)
 	if err != nil {
-		logger.Error("Failed to store movie in Redis", slog.Any("error", err))
+		return nil, err
 	}
 
+	movie := mapDBMovieToDomainMovie(&dbMovie)
+	movie.Genres = mapDBGenresToDomainGenres(genres)
+	movie.Credits = mapDBCreditsToDomainCredits(credits)
+
 	return movie, nil
 }
 
@@ -88,41 +146,107 @@ func (s *MovieService) GetMovieByIDWithGenresAndLike(ctx context.Context, movieI
 	}
 
 	isLiked, err := s.movieRepo.IsMovieLikedByUser(ctx, movieID, userID)
-	movie := mapDBMovieToDomainMovieWithLike(&dbMovie, isLiked)
+	if err != nil {
+		return nil, err
+	}
+
+	myRating, err := s.movieRepo.GetUserMovieRating(ctx, movieID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credits, err := s.movieRepo.ListCreditsByMovieID(ctx, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	movie := mapDBMovieToDomainMovieWithLike(&dbMovie, isLiked, myRating)
 	movie.Genres = mapDBGenresToDomainGenres(genres)
+	movie.Credits = mapDBCreditsToDomainCredits(credits)
 
 	return movie, nil
 }
 
-func (s *MovieService) ListMoviesWithGenres(ctx context.Context) ([]*domain.Movie, error) {
-	logger := middleware.GetLogger(ctx)
-
-	// Check Redis cache
-	cacheKey := "movies"
+func (s *MovieService) ListMoviesWithGenres(
+	ctx context.Context,
+	filter domain.MovieFilter,
+	page domain.MoviePageRequest,
+) (*domain.MovieList[*domain.Movie], error) {
+	// Only unfiltered pages of the catalog are cached
+	cacheKey := ""
+	if filter.IsEmpty() {
+		var err error
+		cacheKey, err = s.movieCache.ListKey(
+			ctx,
+			fmt.Sprintf("%s:%s:%d:%s", page.Sort.Field, page.Sort.Direction, page.Limit, page.After.Encode()),
+		)
+		if err != nil {
+			middleware.GetLogger(ctx).Error("Failed to read movie cache version", slog.Any("error", err))
+		}
+	}
 
-	cachedMovies, err := s.redisClient.Get(ctx, cacheKey).Result()
-	if err == nil && cachedMovies != "" {
-		var movies []*domain.Movie
-		if err := json.Unmarshal([]byte(cachedMovies), &movies); err == nil {
-			logger.Info("Fetched movies from Redis")
-			return movies, nil
+	return s.movieLists.Get(ctx, cacheKey, func(ctx context.Context) (*domain.MovieList[*domain.Movie], error) {
+		rows, nextCursor, err := s.movieRepo.ListFilteredMoviesWithGenres(ctx, filter, page, 0, false)
+		if err != nil {
+			return nil, err
 		}
+
+		return &domain.MovieList[*domain.Movie]{
+			Movies:     mapFilteredRowsToDomainMovies(rows),
+			NextCursor: nextCursor.Encode(),
+		}, nil
+	})
+}
+
+// SearchMovies runs a full-text search over titles and descriptions, best matches first.
+// When nothing matches lexically it falls back to typo-tolerant title matching.
+func (s *MovieService) SearchMovies(ctx context.Context, query string, limit int) ([]*domain.MovieSearchResult, error) {
+	rows, err := s.movieRepo.SearchMoviesWithGenres(ctx, query, limit)
+	if err != nil {
+		return nil, err
 	}
 
-	// Fetch from database
-	rows, err := s.movieRepo.ListMoviesWithGenres(ctx)
+	if len(rows) > 0 {
+		return mapSearchRowsToDomainResults(rows, domain.MatchTypeFullText), nil
+	}
+
+	fuzzyRows, err := s.movieRepo.FuzzySearchMoviesWithGenres(ctx, query, s.searchConfig.SimilarityThreshold, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	movieMap := make(map[int]*domain.Movie)
+	rows = make([]db.SearchMoviesWithGenresRow, len(fuzzyRows))
+	for i, row := range fuzzyRows {
+		rows[i] = db.SearchMoviesWithGenresRow{
+			MovieID:     row.MovieID,
+			Title:       row.Title,
+			ReleaseDate: row.ReleaseDate,
+			Runtime:     row.Runtime,
+			MpaaRating:  row.MpaaRating,
+			Description: row.Description,
+			Image:       row.Image,
+			UserRating:  row.UserRating,
+			Video:       row.Video,
+			Rank:        row.Similarity,
+			GenreID:     row.GenreID,
+			Genre:       row.Genre,
+		}
+	}
+
+	return mapSearchRowsToDomainResults(rows, domain.MatchTypeFuzzy), nil
+}
+
+func mapSearchRowsToDomainResults(rows []db.SearchMoviesWithGenresRow, matchType string) []*domain.MovieSearchResult {
+	// Rows come back ordered by score, so keep the first-seen order while grouping genres
+	results := make([]*domain.MovieSearchResult, 0)
+	resultMap := make(map[int]*domain.MovieSearchResult)
 
 	for _, row := range rows {
 		movieID := int(row.MovieID)
-		movie, exists := movieMap[movieID]
-		userRating, _ := row.UserRating.Float64Value()
+		result, exists := resultMap[movieID]
 		if !exists {
-			movie = &domain.Movie{
+			userRating, _ := row.UserRating.Float64Value()
+			result = &domain.MovieSearchResult{
 				ID:          movieID,
 				Title:       row.Title,
 				ReleaseDate: row.ReleaseDate.Time,
@@ -133,40 +257,44 @@ func (s *MovieService) ListMoviesWithGenres(ctx context.Context) ([]*domain.Movi
 				Video:       row.Video.String,
 				Genres:      []*domain.Genre{},
 				UserRating:  userRating.Float64,
+				Rank:        float64(row.Rank),
+				MatchType:   matchType,
 			}
+			resultMap[movieID] = result
+			results = append(results, result)
 		}
 
 		if row.GenreID.Valid {
-			movie.Genres = append(movie.Genres, &domain.Genre{
+			result.Genres = append(result.Genres, &domain.Genre{
 				ID:    int(row.GenreID.Int32),
 				Genre: row.Genre.String,
 			})
 		}
-
-		movieMap[movieID] = movie
 	}
 
-	movies := make([]*domain.Movie, 0, len(movieMap))
-	for _, movie := range movieMap {
-		movies = append(movies, movie)
-	}
+	return results
+}
 
-	// Store in Redis with a TTL of 10 minutes
-	movieJSON, _ := json.Marshal(movies)
-	err = s.redisClient.Set(ctx, cacheKey, string(movieJSON), 10*time.Minute).Err()
-	if err != nil {
-		logger.Error("Failed to store movie in Redis", slog.Any("error", err))
+func (s *MovieService) UpdateMovie(ctx context.Context, movie domain.Movie) error {
+	if err := s.movieRepo.UpdateMovie(ctx, movie); err != nil {
+		return err
 	}
 
-	return movies, nil
-}
+	repository.AfterCommit(ctx, func(ctx context.Context) { s.indexMovieSuggestion(ctx, movie.ID) })
+	s.movieCache.InvalidateMovie(ctx, movie.ID)
 
-func (s *MovieService) UpdateMovie(ctx context.Context, movie domain.Movie) error {
-	return s.movieRepo.UpdateMovie(ctx, movie)
+	return nil
 }
 
 func (s *MovieService) DeleteMovie(ctx context.Context, id int) error {
-	return s.movieRepo.DeleteMovie(ctx, id)
+	if err := s.movieRepo.DeleteMovie(ctx, id); err != nil {
+		return err
+	}
+
+	repository.AfterCommit(ctx, func(ctx context.Context) { s.removeMovieSuggestion(ctx, id) })
+	s.movieCache.InvalidateMovie(ctx, id)
+
+	return nil
 }
 
 func (s *MovieService) UpdateMovieGenres(ctx context.Context, movieID int, genreIDs []int) error {
@@ -181,6 +309,9 @@ func (s *MovieService) UpdateMovieGenres(ctx context.Context, movieID int, genre
 			return err
 		}
 	}
+
+	s.movieCache.InvalidateMovie(ctx, movieID)
+
 	return nil
 }
 
@@ -197,10 +328,11 @@ func mapDBMovieToDomainMovie(dbMovie *db.Movie) *domain.Movie {
 		Image:       dbMovie.Image.String,
 		Video:       dbMovie.Video.String,
 		UserRating:  userRating.Float64,
+		RatingCount: int(dbMovie.RatingCount),
 	}
 }
 
-func mapDBMovieToDomainMovieWithLike(dbMovie *db.Movie, isLiked bool) *domain.MovieWithLike {
+func mapDBMovieToDomainMovieWithLike(dbMovie *db.Movie, isLiked bool, myRating int) *domain.MovieWithLike {
 	userRating, _ := dbMovie.UserRating.Float64Value()
 
 	return &domain.MovieWithLike{
@@ -213,7 +345,9 @@ func mapDBMovieToDomainMovieWithLike(dbMovie *db.Movie, isLiked bool) *domain.Mo
 		Image:       dbMovie.Image.String,
 		Video:       dbMovie.Video.String,
 		UserRating:  userRating.Float64,
+		RatingCount: int(dbMovie.RatingCount),
 		IsLiked:     isLiked,
+		MyRating:    myRating,
 	}
 }
 
@@ -257,69 +391,94 @@ func (s *MovieService) ListGenres(ctx context.Context) ([]*domain.Genre, error)
 	return genres, nil
 }
 
-func (s *MovieService) ListMoviesWithGenresAndLikes(ctx context.Context, userID int) ([]*domain.MovieWithLike, error) {
-	dbMovies, err := s.movieRepo.ListMoviesWithGenresAndLikes(ctx, userID)
+func (s *MovieService) ListMoviesWithGenresAndLikes(
+	ctx context.Context,
+	userID int,
+	filter domain.MovieFilter,
+	page domain.MoviePageRequest,
+) (*domain.MovieList[*domain.MovieWithLike], error) {
+	rows, nextCursor, err := s.movieRepo.ListFilteredMoviesWithGenres(ctx, filter, page, userID, false)
 	if err != nil {
 		return nil, err
 	}
 
-	movieMap := make(map[int]*domain.MovieWithLike)
+	return &domain.MovieList[*domain.MovieWithLike]{
+		Movies:     mapFilteredRowsToDomainMoviesWithLike(rows),
+		NextCursor: nextCursor.Encode(),
+	}, nil
+}
 
-	for _, row := range dbMovies {
-		movieID := int(row.MovieID)
-		movie, exists := movieMap[movieID]
-		userRating, _ := row.UserRating.Float64Value()
+func (s *MovieService) GetLikedMovies(
+	ctx context.Context,
+	userID int,
+	filter domain.MovieFilter,
+	page domain.MoviePageRequest,
+) (*domain.MovieList[*domain.Movie], error) {
+	rows, nextCursor, err := s.movieRepo.ListFilteredMoviesWithGenres(ctx, filter, page, userID, true)
+	if err != nil {
+		return nil, err
+	}
 
-		if !exists {
-			movie = &domain.MovieWithLike{
-				ID:          movieID,
-				Title:       row.Title,
-				ReleaseDate: row.ReleaseDate.Time,
-				RunTime:     int(row.Runtime.Int32),
-				MPAARating:  row.MpaaRating.String,
-				Description: row.Description.String,
-				Image:       row.Image.String,
-				Video:       row.Video.String,
-				Genres:      []*domain.Genre{},
-				UserRating:  userRating.Float64,
-				IsLiked:     row.IsLiked,
-			}
-		}
+	return &domain.MovieList[*domain.Movie]{
+		Movies:     mapFilteredRowsToDomainMovies(rows),
+		NextCursor: nextCursor.Encode(),
+	}, nil
+}
 
-		if row.GenreID.Valid {
-			movie.Genres = append(movie.Genres, &domain.Genre{
-				ID:    int(row.GenreID.Int32),
-				Genre: row.Genre.String,
-			})
+// ListMovieFacets returns per-genre and per-MPAA-rating counts for the listing described by the arguments
+func (s *MovieService) ListMovieFacets(
+	ctx context.Context,
+	filter domain.MovieFilter,
+	userID int,
+	likedOnly bool,
+) (*domain.MovieFacets, error) {
+	// Like the listings, the facets of the unfiltered catalog are cached; they are the same for every user
+	cacheKey := ""
+	if filter.IsEmpty() && !likedOnly {
+		var err error
+		cacheKey, err = s.movieCache.ListKey(ctx, "facets")
+		if err != nil {
+			middleware.GetLogger(ctx).Error("Failed to read movie cache version", slog.Any("error", err))
 		}
-
-		movieMap[movieID] = movie
-	}
-
-	// Convert map to slice
-	movies := make([]*domain.MovieWithLike, 0, len(movieMap))
-	for _, movie := range movieMap {
-		movies = append(movies, movie)
 	}
 
-	return movies, nil
+	return s.movieFacets.Get(ctx, cacheKey, func(ctx context.Context) (*domain.MovieFacets, error) {
+		return s.movieRepo.ListMovieFacets(ctx, filter, userID, likedOnly)
+	})
 }
 
-func (s *MovieService) GetLikedMovies(ctx context.Context, userID int) ([]*domain.Movie, error) {
-	dbMovies, err := s.movieRepo.GetLikedMovies(ctx, userID)
-	if err != nil {
-		return nil, err
+// mapFilteredRowsToDomainMovies groups movie/genre rows into movies, keeping the query order
+func mapFilteredRowsToDomainMovies(rows []db.ListMoviesWithGenresAndLikeStatusRow) []*domain.Movie {
+	movies := make([]*domain.Movie, 0)
+	for _, movie := range mapFilteredRowsToDomainMoviesWithLike(rows) {
+		movies = append(movies, &domain.Movie{
+			ID:          movie.ID,
+			Title:       movie.Title,
+			ReleaseDate: movie.ReleaseDate,
+			RunTime:     movie.RunTime,
+			MPAARating:  movie.MPAARating,
+			Description: movie.Description,
+			Image:       movie.Image,
+			Video:       movie.Video,
+			Genres:      movie.Genres,
+			UserRating:  movie.UserRating,
+			RatingCount: movie.RatingCount,
+		})
 	}
+	return movies
+}
 
-	movieMap := make(map[int]*domain.Movie)
+// mapFilteredRowsToDomainMoviesWithLike groups movie/genre rows into movies, keeping the query order
+func mapFilteredRowsToDomainMoviesWithLike(rows []db.ListMoviesWithGenresAndLikeStatusRow) []*domain.MovieWithLike {
+	movies := make([]*domain.MovieWithLike, 0)
+	movieMap := make(map[int]*domain.MovieWithLike)
 
-	for _, row := range dbMovies {
+	for _, row := range rows {
 		movieID := int(row.MovieID)
 		movie, exists := movieMap[movieID]
-		userRating, _ := row.UserRating.Float64Value()
-
 		if !exists {
-			movie = &domain.Movie{
+			userRating, _ := row.UserRating.Float64Value()
+			movie = &domain.MovieWithLike{
 				ID:          movieID,
 				Title:       row.Title,
 				ReleaseDate: row.ReleaseDate.Time,
@@ -327,10 +486,15 @@ func (s *MovieService) GetLikedMovies(ctx context.Context, userID int) ([]*domai
 				MPAARating:  row.MpaaRating.String,
 				Description: row.Description.String,
 				Image:       row.Image.String,
-				UserRating:  userRating.Float64,
 				Video:       row.Video.String,
 				Genres:      []*domain.Genre{},
+				UserRating:  userRating.Float64,
+				RatingCount: int(row.RatingCount),
+				IsLiked:     row.IsLiked,
+				MyRating:    int(row.MyRating.Int16),
 			}
+			movieMap[movieID] = movie
+			movies = append(movies, movie)
 		}
 
 		if row.GenreID.Valid {
@@ -339,14 +503,7 @@ func (s *MovieService) GetLikedMovies(ctx context.Context, userID int) ([]*domai
 				Genre: row.Genre.String,
 			})
 		}
-
-		movieMap[movieID] = movie
 	}
 
-	movies := make([]*domain.Movie, 0)
-	for _, movie := range movieMap {
-		movies = append(movies, movie)
-	}
-
-	return movies, nil
+	return movies
 }
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/middleware"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Sorted set of "<normalized title suffix>\x00<movie id>" members, all with score 0 so they are ordered lexicographically
+	suggestIndexKey = "movies:suggest"
+	// Hash of movie id to the JSON-encoded suggestion returned to clients
+	suggestDataKey = "movies:suggest:data"
+
+	suggestMemberSeparator = "\x00"
+)
+
+// likeEscaper escapes the wildcards of a LIKE pattern so that a prefix is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// RebuildSuggestionIndex replaces the autocomplete index with the current contents of the movies table
+func (s *MovieService) RebuildSuggestionIndex(ctx context.Context) error {
+	if s.redisClient == nil {
+		return nil
+	}
+
+	dbMovies, err := s.movieRepo.ListMovies(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, suggestIndexKey, suggestDataKey)
+		for i := range dbMovies {
+			if err := addSuggestionToPipeline(ctx, pipe, mapDBMovieToSuggestion(&dbMovies[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// SuggestMovies returns up to limit movies with a title word starting with prefix
+func (s *MovieService) SuggestMovies(ctx context.Context, prefix string, limit int) ([]*domain.MovieSuggestion, error) {
+	normalizedPrefix := normalizeSuggestionText(prefix)
+	suggestions := make([]*domain.MovieSuggestion, 0, limit)
+	if normalizedPrefix == "" {
+		return suggestions, nil
+	}
+
+	if s.redisClient == nil {
+		return s.suggestMoviesFromDB(ctx, normalizedPrefix, limit)
+	}
+
+	// A title is indexed once per word, so over-fetch to leave room for duplicates
+	members, err := s.redisClient.ZRangeByLex(ctx, suggestIndexKey, &redis.ZRangeBy{
+		Min:   "[" + normalizedPrefix,
+		Max:   "[" + normalizedPrefix + "\xff",
+		Count: int64(limit * 4), //nolint:mnd
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var movieIDs []string
+	seen := make(map[string]bool)
+	for _, member := range members {
+		separatorIndex := strings.LastIndex(member, suggestMemberSeparator)
+		if separatorIndex < 0 {
+			continue
+		}
+		movieID := member[separatorIndex+1:]
+		if seen[movieID] {
+			continue
+		}
+		seen[movieID] = true
+		movieIDs = append(movieIDs, movieID)
+		if len(movieIDs) == limit {
+			break
+		}
+	}
+
+	if len(movieIDs) == 0 {
+		return suggestions, nil
+	}
+
+	values, err := s.redisClient.HMGet(ctx, suggestDataKey, movieIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		encoded, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var suggestion domain.MovieSuggestion
+		if err := json.Unmarshal([]byte(encoded), &suggestion); err != nil {
+			continue
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	return suggestions, nil
+}
+
+// suggestMoviesFromDB answers suggestions with a title scan when there is no Redis index
+func (s *MovieService) suggestMoviesFromDB(ctx context.Context, normalizedPrefix string, limit int) ([]*domain.MovieSuggestion, error) {
+	rows, err := s.movieRepo.SuggestMoviesByTitle(ctx, likeEscaper.Replace(normalizedPrefix), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]*domain.MovieSuggestion, 0, len(rows))
+	for i := range rows {
+		suggestions = append(suggestions, mapDBMovieToSuggestion(&db.Movie{
+			ID:          rows[i].ID,
+			Title:       rows[i].Title,
+			ReleaseDate: rows[i].ReleaseDate,
+			Image:       rows[i].Image,
+		}))
+	}
+	return suggestions, nil
+}
+
+// indexMovieSuggestion adds or refreshes a movie in the autocomplete index.
+// Failures are logged rather than returned so that catalog writes never fail because of Redis.
+func (s *MovieService) indexMovieSuggestion(ctx context.Context, movieID int) {
+	if s.redisClient == nil {
+		return
+	}
+
+	logger := middleware.GetLogger(ctx)
+
+	dbMovie, err := s.movieRepo.GetMovieByID(ctx, movieID)
+	if err != nil {
+		logger.Error("Failed to load movie for suggestion index", slog.Any("error", err), slog.Int("movie_id", movieID))
+		return
+	}
+
+	suggestion := mapDBMovieToSuggestion(&dbMovie)
+	staleMembers, err := s.suggestionMembers(ctx, movieID)
+	if err != nil {
+		logger.Error("Failed to read suggestion index", slog.Any("error", err), slog.Int("movie_id", movieID))
+		return
+	}
+
+	_, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(staleMembers) > 0 {
+			pipe.ZRem(ctx, suggestIndexKey, staleMembers...)
+		}
+		return addSuggestionToPipeline(ctx, pipe, suggestion)
+	})
+	if err != nil {
+		logger.Error("Failed to update suggestion index", slog.Any("error", err), slog.Int("movie_id", movieID))
+	}
+}
+
+// removeMovieSuggestion drops a movie from the autocomplete index, logging any failure
+func (s *MovieService) removeMovieSuggestion(ctx context.Context, movieID int) {
+	if s.redisClient == nil {
+		return
+	}
+
+	logger := middleware.GetLogger(ctx)
+
+	staleMembers, err := s.suggestionMembers(ctx, movieID)
+	if err != nil {
+		logger.Error("Failed to read suggestion index", slog.Any("error", err), slog.Int("movie_id", movieID))
+		return
+	}
+
+	_, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(staleMembers) > 0 {
+			pipe.ZRem(ctx, suggestIndexKey, staleMembers...)
+		}
+		pipe.HDel(ctx, suggestDataKey, strconv.Itoa(movieID))
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to update suggestion index", slog.Any("error", err), slog.Int("movie_id", movieID))
+	}
+}
+
+// suggestionMembers returns the sorted set members currently indexed for a movie
+func (s *MovieService) suggestionMembers(ctx context.Context, movieID int) ([]interface{}, error) {
+	encoded, err := s.redisClient.HGet(ctx, suggestDataKey, strconv.Itoa(movieID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestion domain.MovieSuggestion
+	if err := json.Unmarshal([]byte(encoded), &suggestion); err != nil {
+		return nil, err
+	}
+
+	var members []interface{}
+	for _, member := range buildSuggestionMembers(&suggestion) {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func addSuggestionToPipeline(ctx context.Context, pipe redis.Pipeliner, suggestion *domain.MovieSuggestion) error {
+	encoded, err := json.Marshal(suggestion)
+	if err != nil {
+		return err
+	}
+
+	members := buildSuggestionMembers(suggestion)
+	entries := make([]redis.Z, len(members))
+	for i, member := range members {
+		entries[i] = redis.Z{Score: 0, Member: member}
+	}
+
+	if len(entries) > 0 {
+		pipe.ZAdd(ctx, suggestIndexKey, entries...)
+	}
+	pipe.HSet(ctx, suggestDataKey, strconv.Itoa(suggestion.ID), string(encoded))
+	return nil
+}
+
+// buildSuggestionMembers indexes every word-suffix of the title so "god" matches "The Godfather"
+func buildSuggestionMembers(suggestion *domain.MovieSuggestion) []string {
+	words := strings.Fields(normalizeSuggestionText(suggestion.Title))
+	movieID := strconv.Itoa(suggestion.ID)
+
+	members := make([]string, 0, len(words))
+	for i := range words {
+		members = append(members, strings.Join(words[i:], " ")+suggestMemberSeparator+movieID)
+	}
+	return members
+}
+
+func normalizeSuggestionText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+func mapDBMovieToSuggestion(dbMovie *db.Movie) *domain.MovieSuggestion {
+	suggestion := &domain.MovieSuggestion{
+		ID:    int(dbMovie.ID),
+		Title: dbMovie.Title,
+		Image: dbMovie.Image.String,
+	}
+	if dbMovie.ReleaseDate.Valid {
+		suggestion.ReleaseYear = dbMovie.ReleaseDate.Time.Year()
+	}
+	return suggestion
+}
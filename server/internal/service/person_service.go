@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+var (
+	ErrPersonNotFound    = errors.New("person not found")
+	ErrInvalidPersonName = errors.New("name cannot be empty")
+)
+
+type PersonService struct {
+	personRepo repository.PersonStore
+	movieCache *MovieCache
+}
+
+func NewPersonService(personRepo repository.PersonStore, movieCache *MovieCache) *PersonService {
+	return &PersonService{personRepo: personRepo, movieCache: movieCache}
+}
+
+func (s *PersonService) CreatePerson(ctx context.Context, person domain.Person) (*domain.Person, error) {
+	person.Name = strings.TrimSpace(person.Name)
+	if person.Name == "" {
+		return nil, ErrInvalidPersonName
+	}
+
+	dbPerson, err := s.personRepo.CreatePerson(ctx, person)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapDBPersonToDomainPerson(&dbPerson), nil
+}
+
+func (s *PersonService) ListPeople(ctx context.Context) ([]*domain.Person, error) {
+	dbPeople, err := s.personRepo.ListPeople(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	people := make([]*domain.Person, 0, len(dbPeople))
+	for i := range dbPeople {
+		people = append(people, mapDBPersonToDomainPerson(&dbPeople[i]))
+	}
+	return people, nil
+}
+
+// GetPersonWithFilmography returns the person together with every movie they are credited on, newest first
+func (s *PersonService) GetPersonWithFilmography(ctx context.Context, id int) (*domain.Person, error) {
+	dbPerson, err := s.personRepo.GetPersonByID(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPersonNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.personRepo.ListCreditsByPersonID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	person := mapDBPersonToDomainPerson(&dbPerson)
+	for _, row := range rows {
+		person.Filmography = append(person.Filmography, &domain.PersonCredit{
+			ID:            int(row.ID),
+			MovieID:       int(row.MovieID),
+			Title:         row.Title,
+			ReleaseDate:   row.ReleaseDate.Time,
+			Image:         row.Image.String,
+			Role:          row.Role,
+			CharacterName: row.CharacterName.String,
+			BillingOrder:  int(row.BillingOrder.Int32),
+		})
+	}
+
+	return person, nil
+}
+
+func (s *PersonService) UpdatePerson(ctx context.Context, person domain.Person) (*domain.Person, error) {
+	person.Name = strings.TrimSpace(person.Name)
+	if person.Name == "" {
+		return nil, ErrInvalidPersonName
+	}
+
+	dbPerson, err := s.personRepo.UpdatePerson(ctx, person)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPersonNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Cached movies embed the person's credits
+	s.movieCache.InvalidateAll(ctx)
+
+	return mapDBPersonToDomainPerson(&dbPerson), nil
+}
+
+func (s *PersonService) DeletePerson(ctx context.Context, id int) error {
+	deleted, err := s.personRepo.DeletePerson(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPersonNotFound
+	}
+
+	s.movieCache.InvalidateAll(ctx)
+
+	return nil
+}
+
+func mapDBPersonToDomainPerson(dbPerson *db.Person) *domain.Person {
+	person := &domain.Person{
+		ID:        int(dbPerson.ID),
+		Name:      dbPerson.Name,
+		Biography: dbPerson.Biography.String,
+		Image:     dbPerson.Image.String,
+	}
+	if dbPerson.BirthDate.Valid {
+		birthDate := dbPerson.BirthDate.Time
+		person.BirthDate = &birthDate
+	}
+	return person
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+)
+
+const maxReviewLength = 5000
+
+// Postgres error codes returned when a review references a missing movie or already exists
+const (
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+)
+
+var (
+	ErrReviewNotFound      = errors.New("review not found")
+	ErrReviewExists        = errors.New("you have already reviewed this movie")
+	ErrInvalidReviewBody   = errors.New("review must be between 1 and 5000 characters")
+	ErrInvalidReviewStatus = errors.New("status must be approved or rejected")
+	ErrOwnReviewVote       = errors.New("you cannot vote on your own review")
+)
+
+type ReviewService struct {
+	reviewRepo repository.ReviewStore
+}
+
+func NewReviewService(reviewRepo repository.ReviewStore) *ReviewService {
+	return &ReviewService{reviewRepo: reviewRepo}
+}
+
+func (s *ReviewService) CreateReview(ctx context.Context, userID, movieID int, body string) (*domain.Review, error) {
+	body, err := normalizeReviewBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	dbReview, err := s.reviewRepo.CreateReview(ctx, userID, movieID, body)
+	switch {
+	case isPgError(err, pgUniqueViolation):
+		return nil, ErrReviewExists
+	case isPgError(err, pgForeignKeyViolation):
+		return nil, ErrMovieNotFound
+	case err != nil:
+		return nil, err
+	}
+
+	return mapDBReviewToDomainReview(&dbReview, 0), nil
+}
+
+// GetUserReview returns the user's own review of the movie in whatever moderation state it is
+func (s *ReviewService) GetUserReview(ctx context.Context, userID, movieID int) (*domain.Review, error) {
+	dbReview, err := s.reviewRepo.GetReviewByUserAndMovie(ctx, userID, movieID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	helpfulCount, err := s.reviewRepo.CountReviewHelpfulVotes(ctx, int(dbReview.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	return mapDBReviewToDomainReview(&dbReview, int(helpfulCount)), nil
+}
+
+// GetReview returns any review in whatever moderation state it is, for moderators
+func (s *ReviewService) GetReview(ctx context.Context, reviewID int) (*domain.Review, error) {
+	dbReview, err := s.reviewRepo.GetReviewByID(ctx, reviewID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	helpfulCount, err := s.reviewRepo.CountReviewHelpfulVotes(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapDBReviewToDomainReview(&dbReview, int(helpfulCount)), nil
+}
+
+// UpdateReview replaces the review text and sends the review back to the moderation queue
+func (s *ReviewService) UpdateReview(ctx context.Context, userID, movieID int, body string) (*domain.Review, error) {
+	body, err := normalizeReviewBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	dbReview, err := s.reviewRepo.UpdateReviewBody(ctx, userID, movieID, body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	helpfulCount, err := s.reviewRepo.CountReviewHelpfulVotes(ctx, int(dbReview.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	return mapDBReviewToDomainReview(&dbReview, int(helpfulCount)), nil
+}
+
+func (s *ReviewService) DeleteReview(ctx context.Context, userID, movieID int) error {
+	deleted, err := s.reviewRepo.DeleteReview(ctx, userID, movieID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrReviewNotFound
+	}
+	return nil
+}
+
+// ListMovieReviews returns one page of a movie's approved reviews, newest first
+func (s *ReviewService) ListMovieReviews(ctx context.Context, movieID int, page domain.ReviewPageRequest) (*domain.ReviewList, error) {
+	// Fetch one extra review to find out whether another page follows
+	rows, err := s.reviewRepo.ListApprovedReviewsByMovie(ctx, movieID, page.AfterID, page.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := make([]*domain.Review, 0, len(rows))
+	for _, row := range rows {
+		reviews = append(reviews, &domain.Review{
+			ID:           int(row.ID),
+			MovieID:      int(row.MovieID),
+			UserID:       int(row.UserID),
+			AuthorName:   formatAuthorName(row.FirstName, row.LastName),
+			Body:         row.Body,
+			Status:       row.Status,
+			HelpfulCount: int(row.HelpfulCount),
+			CreatedAt:    row.CreatedAt.Time,
+			UpdatedAt:    row.UpdatedAt.Time,
+		})
+	}
+
+	return paginateReviews(reviews, page.Limit), nil
+}
+
+// ListReviewsForModeration returns one page of reviews in the given state, oldest first
+func (s *ReviewService) ListReviewsForModeration(ctx context.Context, status string, page domain.ReviewPageRequest) (*domain.ReviewList, error) {
+	switch status {
+	case domain.ReviewStatusPending, domain.ReviewStatusApproved, domain.ReviewStatusRejected:
+	default:
+		return nil, ErrInvalidReviewStatus
+	}
+
+	rows, err := s.reviewRepo.ListReviewsByStatus(ctx, status, page.AfterID, page.Limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := make([]*domain.Review, 0, len(rows))
+	for _, row := range rows {
+		reviews = append(reviews, &domain.Review{
+			ID:           int(row.ID),
+			MovieID:      int(row.MovieID),
+			UserID:       int(row.UserID),
+			AuthorName:   formatAuthorName(row.FirstName, row.LastName),
+			Body:         row.Body,
+			Status:       row.Status,
+			HelpfulCount: int(row.HelpfulCount),
+			CreatedAt:    row.CreatedAt.Time,
+			UpdatedAt:    row.UpdatedAt.Time,
+		})
+	}
+
+	return paginateReviews(reviews, page.Limit), nil
+}
+
+// ModerateReview approves or rejects a review on behalf of the moderator
+func (s *ReviewService) ModerateReview(ctx context.Context, reviewID int, status string, moderatorID int) (*domain.Review, error) {
+	if status != domain.ReviewStatusApproved && status != domain.ReviewStatusRejected {
+		return nil, ErrInvalidReviewStatus
+	}
+
+	dbReview, err := s.reviewRepo.SetReviewStatus(ctx, reviewID, status, moderatorID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	helpfulCount, err := s.reviewRepo.CountReviewHelpfulVotes(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapDBReviewToDomainReview(&dbReview, int(helpfulCount)), nil
+}
+
+// MarkReviewHelpful records the user's helpful vote on an approved review of the movie
+func (s *ReviewService) MarkReviewHelpful(ctx context.Context, userID, movieID, reviewID int) (*domain.Review, error) {
+	dbReview, err := s.getVotableReview(ctx, userID, movieID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.reviewRepo.AddReviewHelpfulVote(ctx, userID, reviewID); err != nil {
+		return nil, err
+	}
+
+	helpfulCount, err := s.reviewRepo.CountReviewHelpfulVotes(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapDBReviewToDomainReview(dbReview, int(helpfulCount)), nil
+}
+
+func (s *ReviewService) UnmarkReviewHelpful(ctx context.Context, userID, movieID, reviewID int) (*domain.Review, error) {
+	dbReview, err := s.getVotableReview(ctx, userID, movieID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.reviewRepo.RemoveReviewHelpfulVote(ctx, userID, reviewID); err != nil {
+		return nil, err
+	}
+
+	helpfulCount, err := s.reviewRepo.CountReviewHelpfulVotes(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapDBReviewToDomainReview(dbReview, int(helpfulCount)), nil
+}
+
+// getVotableReview loads an approved review of the movie that was written by someone other than the voter
+func (s *ReviewService) getVotableReview(ctx context.Context, userID, movieID, reviewID int) (*db.Review, error) {
+	dbReview, err := s.reviewRepo.GetReviewByID(ctx, reviewID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Reviews that are not visible publicly cannot be voted on
+	if int(dbReview.MovieID) != movieID || dbReview.Status != domain.ReviewStatusApproved {
+		return nil, ErrReviewNotFound
+	}
+	if int(dbReview.UserID) == userID {
+		return nil, ErrOwnReviewVote
+	}
+
+	return &dbReview, nil
+}
+
+func normalizeReviewBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > maxReviewLength {
+		return "", ErrInvalidReviewBody
+	}
+	return body, nil
+}
+
+func paginateReviews(reviews []*domain.Review, limit int) *domain.ReviewList {
+	list := &domain.ReviewList{Reviews: reviews}
+	if len(reviews) > limit {
+		list.Reviews = reviews[:limit]
+		list.NextCursor = domain.EncodeReviewCursor(reviews[limit-1].ID)
+	}
+	return list
+}
+
+func formatAuthorName(firstName, lastName string) string {
+	return strings.TrimSpace(firstName + " " + lastName)
+}
+
+func isPgError(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
+
+func mapDBReviewToDomainReview(dbReview *db.Review, helpfulCount int) *domain.Review {
+	return &domain.Review{
+		ID:           int(dbReview.ID),
+		MovieID:      int(dbReview.MovieID),
+		UserID:       int(dbReview.UserID),
+		Body:         dbReview.Body,
+		Status:       dbReview.Status,
+		HelpfulCount: helpfulCount,
+		CreatedAt:    dbReview.CreatedAt.Time,
+		UpdatedAt:    dbReview.UpdatedAt.Time,
+	}
+}
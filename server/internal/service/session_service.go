@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/sessionstore"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionService lists and revokes a user's sessions. Sessions are identified to clients by a hash of their ID,
+// as the ID itself is what the signed cookie carries.
+type SessionService struct {
+	backend sessionstore.Backend
+}
+
+func NewSessionService(backend sessionstore.Backend) *SessionService {
+	return &SessionService{backend: backend}
+}
+
+// ListSessions returns the user's sessions, most recently seen first, marking the one with currentID
+func (s *SessionService) ListSessions(ctx context.Context, userID int, currentID string) ([]*domain.Session, error) {
+	records, err := s.backend.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*domain.Session, 0, len(records))
+	for _, record := range records {
+		sessions = append(sessions, &domain.Session{
+			ID:         publicSessionID(record.ID),
+			Device:     describeDevice(record.UserAgent),
+			UserAgent:  record.UserAgent,
+			IP:         record.IP,
+			CreatedAt:  record.CreatedAt,
+			LastSeenAt: record.LastSeenAt,
+			Current:    record.ID == currentID,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+// RevokeSession ends the user's session with the public ID
+func (s *SessionService) RevokeSession(ctx context.Context, userID int, id string) error {
+	records, err := s.backend.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if publicSessionID(record.ID) == id {
+			return s.backend.Delete(ctx, record.ID)
+		}
+	}
+	return ErrSessionNotFound
+}
+
+// RevokeOtherSessions ends every session of the user except the one with currentID and returns how many it ended
+func (s *SessionService) RevokeOtherSessions(ctx context.Context, userID int, currentID string) (int, error) {
+	records, err := s.backend.ListByUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, record := range records {
+		if record.ID == currentID {
+			continue
+		}
+		if err := s.backend.Delete(ctx, record.ID); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// RevokeAllSessions signs the user out everywhere
+func (s *SessionService) RevokeAllSessions(ctx context.Context, userID int) error {
+	_, err := s.RevokeOtherSessions(ctx, userID, "")
+	return err
+}
+
+func publicSessionID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
+// describeDevice names the browser and operating system in a user agent, like "Firefox on Windows"
+func describeDevice(userAgent string) string {
+	browsers := []struct{ token, name string }{
+		// Edge and Opera also claim to be Chrome, and Chrome claims to be Safari, so they come first
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	systems := []struct{ token, name string }{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+
+	browser := ""
+	for _, candidate := range browsers {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+	system := ""
+	for _, candidate := range systems {
+		if strings.Contains(userAgent, candidate.token) {
+			system = candidate.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return "Unknown device"
+	}
+}
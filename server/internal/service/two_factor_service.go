@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/config"
+	"github.com/martishin/movie-search-service/internal/model/domain"
+	"github.com/martishin/movie-search-service/internal/repository"
+	"github.com/martishin/movie-search-service/internal/totp"
+)
+
+const (
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	// recoveryCodeAlphabet leaves out characters that are easy to confuse, like 0 and o or 1 and l
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	ErrTwoFactorRequiresPassword = errors.New("two-factor authentication is only available for password accounts")
+	ErrTwoFactorEnabled          = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotPending       = errors.New("start two-factor enrollment first")
+	ErrInvalidTwoFactorCode      = errors.New("invalid two-factor code")
+)
+
+// TwoFactorService manages TOTP enrollment and checks codes at login.
+// Secrets are sealed with AES-GCM before they reach the database; recovery codes are stored as SHA-256 hashes.
+type TwoFactorService struct {
+	userRepo      repository.UserStore
+	twoFactorRepo repository.TwoFactorStore
+	encryptionKey []byte
+	issuer        string
+}
+
+func NewTwoFactorService(
+	userRepo repository.UserStore,
+	twoFactorRepo repository.TwoFactorStore,
+	twoFactorConfig *config.TwoFactorConfig,
+) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:      userRepo,
+		twoFactorRepo: twoFactorRepo,
+		encryptionKey: twoFactorConfig.EncryptionKey,
+		issuer:        twoFactorConfig.Issuer,
+	}
+}
+
+// BeginEnrollment creates a new pending secret. Two-factor stays off until ConfirmEnrollment receives a code from it.
+func (s *TwoFactorService) BeginEnrollment(ctx context.Context, userID int) (*domain.TwoFactorEnrollment, error) {
+	dbUser, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !dbUser.Password.Valid {
+		return nil, ErrTwoFactorRequiresPassword
+	}
+	if dbUser.TotpEnabledAt.Valid {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.seal(secret, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := s.twoFactorRepo.SetUserTOTPSecret(ctx, userID, sealed)
+	if err != nil {
+		return nil, err
+	}
+	if !set {
+		// Enrollment completed concurrently
+		return nil, ErrTwoFactorEnabled
+	}
+
+	return &domain.TwoFactorEnrollment{
+		Secret:     totp.EncodeSecret(secret),
+		OTPAuthURI: totp.URI(s.issuer, dbUser.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables two-factor once the user proves their app produces codes, and returns the recovery codes
+func (s *TwoFactorService) ConfirmEnrollment(ctx context.Context, userID int, code string) ([]string, error) {
+	dbUser, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if dbUser.TotpEnabledAt.Valid {
+		return nil, ErrTwoFactorEnabled
+	}
+	if dbUser.TotpSecret == nil {
+		return nil, ErrTwoFactorNotPending
+	}
+
+	if err := s.checkTOTP(ctx, &dbUser, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := s.twoFactorRepo.EnableUserTOTP(ctx, userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	return codes, nil
+}
+
+// Verify checks a TOTP code or an unused recovery code of a user with two-factor enabled
+func (s *TwoFactorService) Verify(ctx context.Context, userID int, code string) error {
+	dbUser, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !dbUser.TotpEnabledAt.Valid {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return s.checkTOTP(ctx, &dbUser, code)
+	}
+
+	consumed, err := s.twoFactorRepo.ConsumeUserRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// Disable turns two-factor off after checking a current code, so a hijacked session alone cannot remove it
+func (s *TwoFactorService) Disable(ctx context.Context, userID int, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+
+	_, err := s.twoFactorRepo.DisableUserTOTP(ctx, userID)
+	return err
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a current code
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID int, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.ReplaceUserRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Reset turns off two-factor for a user who lost both their authenticator and recovery codes. Only admins may call it.
+func (s *TwoFactorService) Reset(ctx context.Context, userID int) error {
+	reset, err := s.twoFactorRepo.DisableUserTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !reset {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *TwoFactorService) getUser(ctx context.Context, userID int) (db.User, error) {
+	dbUser, err := s.userRepo.GetUserByID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.User{}, ErrUserNotFound
+	}
+	return dbUser, err
+}
+
+// checkTOTP validates code against the user's secret and burns its time step, so the same code cannot be used twice
+func (s *TwoFactorService) checkTOTP(ctx context.Context, dbUser *db.User, code string) error {
+	secret, err := s.open(dbUser.TotpSecret, int(dbUser.ID))
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	fresh, err := s.twoFactorRepo.MarkUserTOTPStepUsed(ctx, int(dbUser.ID), step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// seal encrypts a secret with a random nonce, which it prepends. The user ID is authenticated alongside,
+// so a sealed secret copied to another user's row does not open.
+func (s *TwoFactorService) seal(secret []byte, userID int) ([]byte, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, secret, secretAssociatedData(userID)), nil
+}
+
+func (s *TwoFactorService) open(sealed []byte, userID int) ([]byte, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed TOTP secret is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, secretAssociatedData(userID))
+}
+
+func (s *TwoFactorService) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func secretAssociatedData(userID int) []byte {
+	return []byte("totp-secret:" + strconv.Itoa(userID))
+}
+
+// generateRecoveryCodes returns new codes formatted as xxxxx-xxxxx, and their hashes
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	random := make([]byte, recoveryCodeLength)
+	for i := range codes {
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+
+		var code strings.Builder
+		for j, b := range random {
+			if j == recoveryCodeLength/2 {
+				code.WriteByte('-')
+			}
+			// The modulo bias over a 31 letter alphabet is negligible for single-use codes
+			code.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+		}
+
+		codes[i] = code.String()
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes, so codes can be typed loosely
+func hashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
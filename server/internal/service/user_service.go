@@ -1,20 +1,56 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/jackc/pgx/v5"
 	db "github.com/martishin/movie-search-service/internal/db/generated"
+	"github.com/martishin/movie-search-service/internal/model/config"
 	"github.com/martishin/movie-search-service/internal/model/domain"
 	"github.com/martishin/movie-search-service/internal/repository"
 )
 
+const (
+	minMovieRating     = 1
+	maxMovieRating     = 5
+	maxUserNameLength  = 50 // users.first_name and last_name are VARCHAR(50)
+	maxPictureURLLen   = 2048
+	maxPreferencesSize = 4096
+	accountPurgePeriod = time.Hour
+)
+
+var (
+	ErrMovieNotFound = errors.New("movie not found")
+	ErrInvalidRating = errors.New("rating must be between 1 and 5")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrInvalidRole   = errors.New("role must be user, editor or admin")
+	ErrOwnRoleChange = errors.New("you cannot change your own role")
+
+	ErrInvalidName        = errors.New("names must be between 1 and 50 characters")
+	ErrInvalidPictureURL  = errors.New("picture URL must be an http or https URL")
+	ErrInvalidPreferences = errors.New("preferences must be a JSON object of at most 4096 bytes")
+)
+
 type UserService struct {
-	userRepo *repository.UserRepository
+	userRepo            repository.UserStore
+	movieCache          *MovieCache
+	bootstrapAdminEmail string
 }
 
-func NewUserService(userRepo *repository.UserRepository) *UserService {
-	return &UserService{userRepo: userRepo}
+func NewUserService(userRepo repository.UserStore, movieCache *MovieCache, rbacConfig *config.RBACConfig) *UserService {
+	return &UserService{
+		userRepo:            userRepo,
+		movieCache:          movieCache,
+		bootstrapAdminEmail: rbacConfig.BootstrapAdminEmail,
+	}
 }
 
 func (s *UserService) CreateUser(ctx context.Context, firstName, lastName, email, pictureURL string, password string) (*domain.User, error) {
@@ -30,18 +66,174 @@ func (s *UserService) CreateUser(ctx context.Context, firstName, lastName, email
 	return mapDBUserToDomainUser(&dbUser), nil
 }
 
-func (s *UserService) FindOrCreateUser(ctx context.Context, firstName, lastName, email, pictureURL string, password string) (*domain.User, error) {
-	dbUser, err := s.userRepo.GetUserByEmail(ctx, email)
-	if err == nil {
-		return mapDBUserToDomainUser(&dbUser), nil
+// BootstrapAdmin promotes the configured bootstrap account to admin if it exists, its email is verified and no admin
+// has been appointed yet
+func (s *UserService) BootstrapAdmin(ctx context.Context) (bool, error) {
+	if s.bootstrapAdminEmail == "" {
+		return false, nil
+	}
+	return s.userRepo.PromoteFirstAdmin(ctx, s.bootstrapAdminEmail)
+}
+
+// bootstrapAdmin promotes the bootstrap account once it proves it owns the email, so the first admin does not have to
+// wait for a restart. Anyone can sign up with the address, so an unverified account is never promoted.
+func (s *UserService) bootstrapAdmin(ctx context.Context, dbUser *db.User) error {
+	if s.bootstrapAdminEmail == "" || dbUser.Role == domain.RoleAdmin || !dbUser.EmailVerifiedAt.Valid ||
+		!strings.EqualFold(dbUser.Email, s.bootstrapAdminEmail) {
+		return nil
+	}
+
+	promoted, err := s.userRepo.PromoteFirstAdmin(ctx, dbUser.Email)
+	if err != nil {
+		return err
+	}
+	if promoted {
+		dbUser.Role = domain.RoleAdmin
+	}
+	return nil
+}
+
+// bootstrapVerifiedAdmin runs the bootstrap for a user whose email was just verified
+func (s *UserService) bootstrapVerifiedAdmin(ctx context.Context, userID int) error {
+	dbUser, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.bootstrapAdmin(ctx, &dbUser)
+}
+
+// SetUserRole changes another user's role. Admins cannot change their own role so that the last admin cannot lock everyone out.
+func (s *UserService) SetUserRole(ctx context.Context, actorID, id int, role string) (*domain.User, error) {
+	if !domain.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	if actorID == id {
+		return nil, ErrOwnRoleChange
+	}
+
+	dbUser, err := s.userRepo.SetUserRole(ctx, id, role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	createdUser, err := s.userRepo.CreateUser(ctx, firstName, lastName, email, pictureURL, password)
+	return mapDBUserToDomainUser(&dbUser), nil
+}
+
+// UpdateProfile changes the user's names, picture and preferences
+func (s *UserService) UpdateProfile(ctx context.Context, id int, update domain.ProfileUpdate) (*domain.User, error) {
+	dbUser, err := s.userRepo.GetUserByID(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return mapDBUserToDomainUser(&createdUser), nil
+	firstName, lastName, pictureURL, preferences := dbUser.FirstName, dbUser.LastName, dbUser.PictureUrl.String, dbUser.Preferences
+	if update.FirstName != nil {
+		if firstName, err = validName(*update.FirstName); err != nil {
+			return nil, err
+		}
+	}
+	if update.LastName != nil {
+		if lastName, err = validName(*update.LastName); err != nil {
+			return nil, err
+		}
+	}
+	if update.PictureURL != nil {
+		pictureURL = strings.TrimSpace(*update.PictureURL)
+		if !isValidPictureURL(pictureURL) {
+			return nil, ErrInvalidPictureURL
+		}
+	}
+	if update.Preferences != nil {
+		if preferences, err = validPreferences(update.Preferences); err != nil {
+			return nil, err
+		}
+	}
+
+	dbUser, err = s.userRepo.UpdateUserProfile(ctx, id, firstName, lastName, pictureURL, preferences)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return mapDBUserToDomainUser(&dbUser), nil
+}
+
+func validName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxUserNameLength {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+// isValidPictureURL accepts an absolute http or https URL, or nothing to remove the picture
+func isValidPictureURL(pictureURL string) bool {
+	if pictureURL == "" {
+		return true
+	}
+	if len(pictureURL) > maxPictureURLLen {
+		return false
+	}
+
+	parsed, err := url.Parse(pictureURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// validPreferences returns the preferences compacted, if they are a JSON object that fits the limit
+func validPreferences(preferences json.RawMessage) ([]byte, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(preferences, &object); err != nil || object == nil {
+		return nil, ErrInvalidPreferences
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, preferences); err != nil || compacted.Len() > maxPreferencesSize {
+		return nil, ErrInvalidPreferences
+	}
+	return compacted.Bytes(), nil
+}
+
+// PurgeDeletedUsers removes the accounts whose deletion grace period is over and returns how many it removed
+func (s *UserService) PurgeDeletedUsers(ctx context.Context) (int, error) {
+	purged, movieIDs, err := s.userRepo.PurgeDeletedUsers(ctx, AccountDeletionGracePeriod)
+	if err != nil {
+		return 0, err
+	}
+
+	// The purged users' ratings are gone from the aggregates
+	for _, movieID := range movieIDs {
+		s.movieCache.InvalidateMovie(ctx, movieID)
+	}
+	return purged, nil
+}
+
+// RunAccountPurge purges deleted accounts now and then every hour until ctx is cancelled
+func (s *UserService) RunAccountPurge(ctx context.Context, logger *slog.Logger) {
+	ticker := time.NewTicker(accountPurgePeriod)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeDeletedUsers(ctx)
+		if err != nil {
+			logger.Error("Failed to purge deleted accounts", slog.Any("error", err))
+		} else if purged > 0 {
+			logger.Info("Deleted accounts purged", slog.Int("count", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 func (s *UserService) GetUserByID(ctx context.Context, id int) (*domain.User, error) {
@@ -69,19 +261,77 @@ func (s *UserService) GetUserIDAndPasswordByEmail(ctx context.Context, email str
 }
 
 func mapDBUserToDomainUser(dbUser *db.User) *domain.User {
+	preferences := json.RawMessage(dbUser.Preferences)
+	if len(preferences) == 0 {
+		preferences = json.RawMessage("{}")
+	}
+
 	return &domain.User{
-		ID:         int(dbUser.ID),
-		FirstName:  dbUser.FirstName,
-		LastName:   dbUser.LastName,
-		Email:      dbUser.Email,
-		PictureURL: dbUser.PictureUrl.String,
+		ID:               int(dbUser.ID),
+		FirstName:        dbUser.FirstName,
+		LastName:         dbUser.LastName,
+		Email:            dbUser.Email,
+		PictureURL:       dbUser.PictureUrl.String,
+		Role:             dbUser.Role,
+		EmailVerified:    dbUser.EmailVerifiedAt.Valid,
+		TwoFactorEnabled: dbUser.TotpEnabledAt.Valid,
+		HasPassword:      dbUser.Password.Valid,
+		Preferences:      preferences,
 	}
 }
 
 func (s *UserService) LikeMovie(ctx context.Context, userID, movieID int) error {
-	return s.userRepo.LikeMovie(ctx, userID, movieID)
+	err := s.userRepo.LikeMovie(ctx, userID, movieID)
+	if isPgError(err, pgForeignKeyViolation) {
+		return ErrMovieNotFound
+	}
+	return err
 }
 
 func (s *UserService) UnlikeMovie(ctx context.Context, userID, movieID int) error {
 	return s.userRepo.UnlikeMovie(ctx, userID, movieID)
 }
+
+func (s *UserService) RateMovie(ctx context.Context, userID, movieID, rating int) (*domain.MovieRating, error) {
+	if rating < minMovieRating || rating > maxMovieRating {
+		return nil, ErrInvalidRating
+	}
+
+	stats, err := s.userRepo.RateMovie(ctx, userID, movieID, rating)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMovieNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The aggregate rating is part of the cached movie and listings
+	s.movieCache.InvalidateMovie(ctx, movieID)
+
+	return mapDBRatingStatsToDomainRating(movieID, &stats, rating), nil
+}
+
+func (s *UserService) UnrateMovie(ctx context.Context, userID, movieID int) (*domain.MovieRating, error) {
+	stats, err := s.userRepo.UnrateMovie(ctx, userID, movieID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrMovieNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.movieCache.InvalidateMovie(ctx, movieID)
+
+	return mapDBRatingStatsToDomainRating(movieID, &stats, 0), nil
+}
+
+func mapDBRatingStatsToDomainRating(movieID int, stats *db.RecomputeMovieRatingRow, myRating int) *domain.MovieRating {
+	userRating, _ := stats.UserRating.Float64Value()
+
+	return &domain.MovieRating{
+		MovieID:     movieID,
+		UserRating:  userRating.Float64,
+		RatingCount: int(stats.RatingCount),
+		MyRating:    myRating,
+	}
+}
@@ -0,0 +1,38 @@
+// Package sessionstore keeps sessions on the server, so they can be listed and revoked.
+// The cookie carries only a signed session ID.
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned for sessions that expired, were revoked or never existed
+var ErrNotFound = errors.New("session not found")
+
+// Record is a stored session
+type Record struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"user_id"` // 0 until someone signs in
+	Data       string    `json:"data"`    // encoded session values
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// Backend holds the records. Redis shares them between instances; the in-memory backend is private to the process
+// and suits a single instance or tests.
+type Backend interface {
+	Get(ctx context.Context, id string) (*Record, error)
+
+	// Save stores the record for ttl, indexing it under its user
+	Save(ctx context.Context, record *Record, ttl time.Duration) error
+
+	// Delete removes the record. Deleting a missing record is not an error.
+	Delete(ctx context.Context, id string) error
+
+	// ListByUser returns the user's live sessions in no particular order
+	ListByUser(ctx context.Context, userID int) ([]*Record, error)
+}
@@ -0,0 +1,70 @@
+package sessionstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type MemoryBackend struct {
+	mu      sync.Mutex
+	records map[string]memoryRecord
+}
+
+type memoryRecord struct {
+	record    Record
+	expiresAt time.Time
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{records: make(map[string]memoryRecord)}
+}
+
+func (b *MemoryBackend) Get(_ context.Context, id string) (*Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.records[id]
+	if !ok || !entry.expiresAt.After(time.Now()) {
+		delete(b.records, id)
+		return nil, ErrNotFound
+	}
+	record := entry.record
+	return &record, nil
+}
+
+func (b *MemoryBackend) Save(_ context.Context, record *Record, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.records[record.ID] = memoryRecord{record: *record, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (b *MemoryBackend) Delete(_ context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.records, id)
+	return nil
+}
+
+// ListByUser scans every session, which also drops the expired ones
+func (b *MemoryBackend) ListByUser(_ context.Context, userID int) ([]*Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	var records []*Record
+	for id, entry := range b.records {
+		if !entry.expiresAt.After(now) {
+			delete(b.records, id)
+			continue
+		}
+		if entry.record.UserID == userID {
+			record := entry.record
+			records = append(records, &record)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,130 @@
+package sessionstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	sessionKeyPrefix     = "session:"
+	userSessionKeyPrefix = "user_sessions:"
+)
+
+// RedisBackend stores each record as JSON under its own key, expiring with the session, and indexes a user's
+// sessions in a set. Set members are not expired individually; listing drops the ones whose record is gone.
+type RedisBackend struct {
+	redisClient *redis.Client
+}
+
+func NewRedisBackend(redisClient *redis.Client) *RedisBackend {
+	return &RedisBackend{redisClient: redisClient}
+}
+
+func (b *RedisBackend) Get(ctx context.Context, id string) (*Record, error) {
+	data, err := b.redisClient.Get(ctx, sessionKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (b *RedisBackend) Save(ctx context.Context, record *Record, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, sessionKeyPrefix+record.ID, data, ttl)
+		if record.UserID != 0 {
+			indexKey := userIndexKey(record.UserID)
+			pipe.SAdd(ctx, indexKey, record.ID)
+			// The index lives as long as its longest-lived session: NX sets the TTL of a new index, GT only extends it
+			pipe.ExpireNX(ctx, indexKey, ttl)
+			pipe.ExpireGT(ctx, indexKey, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (b *RedisBackend) Delete(ctx context.Context, id string) error {
+	record, err := b.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = b.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKeyPrefix+id)
+		if record.UserID != 0 {
+			pipe.SRem(ctx, userIndexKey(record.UserID), id)
+		}
+		return nil
+	})
+	return err
+}
+
+func (b *RedisBackend) ListByUser(ctx context.Context, userID int) ([]*Record, error) {
+	indexKey := userIndexKey(userID)
+	ids, err := b.redisClient.SMembers(ctx, indexKey).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionKeyPrefix + id
+	}
+	values, err := b.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	var stale []any
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			stale = append(stale, ids[i])
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return nil, err
+		}
+		// A session that changed hands stays in the previous user's index until now
+		if record.UserID != userID {
+			stale = append(stale, ids[i])
+			continue
+		}
+		records = append(records, &record)
+	}
+
+	if len(stale) > 0 {
+		if err := b.redisClient.SRem(ctx, indexKey, stale...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+func userIndexKey(userID int) string {
+	return userSessionKeyPrefix + strconv.Itoa(userID)
+}
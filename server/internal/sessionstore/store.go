@@ -0,0 +1,169 @@
+package sessionstore
+
+import (
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// AnonymousTTL caps how long sessions nobody signed in to are kept, such as those of abandoned OAuth flows
+const AnonymousTTL = time.Hour
+
+var sessionIDEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Store is a sessions.Store that keeps session values in a Backend. The cookie holds only the signed session ID,
+// so deleting the record ends the session wherever the cookie is.
+type Store struct {
+	Options *sessions.Options
+
+	backend      Backend
+	cookieCodecs []securecookie.Codec
+	valueCodec   securecookie.Codec
+	userID       func(*sessions.Session) int
+	clientIP     func(*http.Request) string
+}
+
+// NewStore returns a store signing cookies with secret. userID tells which user a session belongs to, 0 for none,
+// and clientIP where a request comes from.
+func NewStore(
+	backend Backend,
+	secret []byte,
+	options *sessions.Options,
+	userID func(*sessions.Session) int,
+	clientIP func(*http.Request) string,
+) *Store {
+	cookieCodecs := securecookie.CodecsFromPairs(secret)
+	for _, codec := range cookieCodecs {
+		if cookieCodec, ok := codec.(*securecookie.SecureCookie); ok {
+			cookieCodec.MaxAge(options.MaxAge)
+		}
+	}
+
+	// Values never leave the server, so their size is not limited by the cookie's and their age is the record's TTL
+	valueCodec := securecookie.New(secret, nil)
+	valueCodec.MaxLength(0)
+	valueCodec.MaxAge(0)
+
+	return &Store{
+		Options:      options,
+		backend:      backend,
+		cookieCodecs: cookieCodecs,
+		valueCodec:   valueCodec,
+		userID:       userID,
+		clientIP:     clientIP,
+	}
+}
+
+// Get returns the request's session, loading it once per request
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request's cookie. A session that expired or was revoked comes back new and empty.
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	options := *s.Options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var id string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &id, s.cookieCodecs...); err != nil {
+		return session, err
+	}
+
+	record, err := s.backend.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+
+	if err := securecookie.DecodeMulti(name, record.Data, &session.Values, s.valueCodec); err != nil {
+		return session, err
+	}
+	session.ID = id
+	session.IsNew = false
+	return session, nil
+}
+
+// Save stores the session and sets its cookie. A MaxAge of 0 or less deletes it.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	ctx := r.Context()
+
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			if err := s.backend.Delete(ctx, session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	now := time.Now()
+	userID := s.userID(session)
+	createdAt := now
+
+	// The session was loaded in this request, so it existed then
+	if session.ID != "" {
+		existing, err := s.backend.Get(ctx, session.ID)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			// Revoked while the request ran; saving would bring it back
+			http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+			return nil
+		case err != nil:
+			return err
+		case existing.UserID != userID:
+			// Signing in gets a new ID, so an ID planted in the browser before the login is worthless after it
+			if err := s.backend.Delete(ctx, session.ID); err != nil {
+				return err
+			}
+			session.ID = ""
+		default:
+			createdAt = existing.CreatedAt
+		}
+	}
+	if session.ID == "" {
+		session.ID = sessionIDEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+	}
+
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.valueCodec)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Duration(session.Options.MaxAge) * time.Second
+	if userID == 0 {
+		ttl = min(ttl, AnonymousTTL)
+	}
+	record := &Record{
+		ID:         session.ID,
+		UserID:     userID,
+		Data:       data,
+		UserAgent:  r.UserAgent(),
+		IP:         s.clientIP(r),
+		CreatedAt:  createdAt,
+		LastSeenAt: now,
+	}
+	if err := s.backend.Save(ctx, record, ttl); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.cookieCodecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
@@ -0,0 +1,116 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySweepEvery is how many hits pass between sweeps of idle keys
+const memorySweepEvery = 1024
+
+// MemoryStore keeps windows and locks in the process
+type MemoryStore struct {
+	mu     sync.Mutex
+	events map[string]*memoryWindow
+	locks  map[string]time.Time
+	hits   int
+}
+
+type memoryWindow struct {
+	events []time.Time // oldest first
+	window time.Duration
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		events: make(map[string]*memoryWindow),
+		locks:  make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryStore) Hit(_ context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := append(s.trim(key, now, window), now)
+	s.events[key] = &memoryWindow{events: events, window: window}
+
+	s.hits++
+	if s.hits%memorySweepEvery == 0 {
+		s.sweep(now)
+	}
+	return len(events), nil
+}
+
+func (s *MemoryStore) Count(_ context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.trim(key, now, window)), nil
+}
+
+func (s *MemoryStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.events, key)
+	return nil
+}
+
+func (s *MemoryStore) Lock(_ context.Context, key string, duration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.locks[key] = time.Now().Add(duration)
+	return nil
+}
+
+func (s *MemoryStore) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.locks[key]
+	if !ok {
+		return 0, nil
+	}
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		delete(s.locks, key)
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// trim drops the key's events that left the window and returns the rest
+func (s *MemoryStore) trim(key string, now time.Time, window time.Duration) []time.Time {
+	entry, ok := s.events[key]
+	if !ok {
+		return nil
+	}
+
+	start := now.Add(-window)
+	i := 0
+	for i < len(entry.events) && !entry.events[i].After(start) {
+		i++
+	}
+	entry.events = entry.events[i:]
+
+	if len(entry.events) == 0 {
+		delete(s.events, key)
+		return nil
+	}
+	return entry.events
+}
+
+// sweep drops idle keys and expired locks, so addresses that stop guessing do not stay in memory
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, entry := range s.events {
+		s.trim(key, now, entry.window)
+	}
+	for key, until := range s.locks {
+		if !until.After(now) {
+			delete(s.locks, key)
+		}
+	}
+}
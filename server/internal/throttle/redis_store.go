@@ -0,0 +1,71 @@
+package throttle
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const lockSuffix = ":lock"
+
+// RedisStore keeps each window in a sorted set of event timestamps, trimmed to the window on every write
+type RedisStore struct {
+	redisClient *redis.Client
+}
+
+func NewRedisStore(redisClient *redis.Client) *RedisStore {
+	return &RedisStore{redisClient: redisClient}
+}
+
+func (s *RedisStore) Hit(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	// Events in the same nanosecond from different instances must not collapse into one member
+	nonce := make([]byte, 4)
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+	member := strconv.FormatInt(now.UnixNano(), 10) + "-" + hex.EncodeToString(nonce)
+
+	var count *redis.IntCmd
+	_, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", windowStart(now, window))
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.UnixNano()), Member: member})
+		count = pipe.ZCard(ctx, key)
+		pipe.PExpire(ctx, key, window)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(count.Val()), nil
+}
+
+func (s *RedisStore) Count(ctx context.Context, key string, now time.Time, window time.Duration) (int, error) {
+	count, err := s.redisClient.ZCount(ctx, key, "("+windowStart(now, window), "+inf").Result()
+	return int(count), err
+}
+
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	return s.redisClient.Del(ctx, key).Err()
+}
+
+func (s *RedisStore) Lock(ctx context.Context, key string, duration time.Duration) error {
+	return s.redisClient.Set(ctx, key+lockSuffix, "1", duration).Err()
+}
+
+func (s *RedisStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.redisClient.PTTL(ctx, key+lockSuffix).Result()
+	if err != nil {
+		return 0, err
+	}
+	// PTTL reports missing keys and keys without expiry as negative durations
+	return max(ttl, 0), nil
+}
+
+// windowStart is the score at or before which events fall out of the window
+func windowStart(now time.Time, window time.Duration) string {
+	return strconv.FormatInt(now.Add(-window).UnixNano(), 10)
+}
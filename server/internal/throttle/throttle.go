@@ -0,0 +1,27 @@
+// Package throttle counts events in sliding time windows and blocks keys for a while, to slow down guessing.
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// Store holds the windows and locks. Redis shares them between instances; the in-memory store is private to the
+// process and suits a single instance or tests.
+type Store interface {
+	// Hit records an event for key at now and returns the number of events within the window ending at now,
+	// including this one
+	Hit(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+
+	// Count returns the number of events within the window ending at now without recording one
+	Count(ctx context.Context, key string, now time.Time, window time.Duration) (int, error)
+
+	// Reset forgets the events of key
+	Reset(ctx context.Context, key string) error
+
+	// Lock blocks key for the duration
+	Lock(ctx context.Context, key string, duration time.Duration) error
+
+	// LockedFor returns how long key stays blocked, or 0 when it is not
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+}
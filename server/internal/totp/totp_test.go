@@ -0,0 +1,66 @@
+package totp_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martishin/movie-search-service/internal/totp"
+)
+
+// The SHA-1 test vectors of RFC 6238 appendix B, truncated to six digits
+func TestCodeMatchesRFC6238(t *testing.T) {
+	secret := []byte("12345678901234567890")
+
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, vector := range vectors {
+		if code := totp.Code(secret, totp.Step(time.Unix(vector.unix, 0))); code != vector.code {
+			t.Errorf("code at %d = %s, want %s", vector.unix, code, vector.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1111111109, 0)
+	step := totp.Step(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		if matched, ok := totp.Validate(secret, totp.Code(secret, step+offset), now); !ok || matched != step+offset {
+			t.Errorf("code of step %+d = %d, %v", offset, matched, ok)
+		}
+	}
+	for _, offset := range []int64{-2, 2} {
+		if _, ok := totp.Validate(secret, totp.Code(secret, step+offset), now); ok {
+			t.Errorf("code of step %+d was accepted", offset)
+		}
+	}
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := totp.Validate(secret, code, now); ok {
+			t.Errorf("code %q was accepted", code)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := totp.URI("Movie Search", "ada@example.com", []byte("12345678901234567890"))
+
+	if !strings.HasPrefix(uri, "otpauth://totp/Movie%20Search:ada@example.com?") {
+		t.Errorf("URI label: %s", uri)
+	}
+	for _, param := range []string{"secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "issuer=Movie+Search", "digits=6", "period=30"} {
+		if !strings.Contains(uri, param) {
+			t.Errorf("URI %s lacks %s", uri, param)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_movies_search_vector;
+
+DROP TRIGGER IF EXISTS set_search_vector_movies ON movies;
+
+DROP FUNCTION IF EXISTS update_movies_search_vector;
+
+ALTER TABLE movies
+    DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,28 @@
+ALTER TABLE movies
+    ADD COLUMN search_vector TSVECTOR;
+
+-- Title matches rank above description matches
+CREATE FUNCTION update_movies_search_vector()
+    RETURNS TRIGGER
+AS $$
+BEGIN
+    new.search_vector =
+            setweight(to_tsvector('english', coalesce(new.title, '')), 'A') ||
+            setweight(to_tsvector('english', coalesce(new.description, '')), 'B');
+    RETURN new;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER set_search_vector_movies
+    BEFORE INSERT OR UPDATE OF title, description
+    ON movies
+    FOR EACH ROW
+EXECUTE FUNCTION update_movies_search_vector();
+
+-- Backfill existing movies
+UPDATE movies
+SET search_vector =
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B');
+
+CREATE INDEX idx_movies_search_vector ON movies USING GIN (search_vector);
@@ -0,0 +1,3 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_movies_title_trgm ON movies USING GIN (title gin_trgm_ops);
@@ -0,0 +1,8 @@
+ALTER TABLE movies
+    DROP COLUMN IF EXISTS editorial_rating;
+ALTER TABLE movies
+    DROP COLUMN IF EXISTS rating_count;
+
+DROP TRIGGER IF EXISTS set_timestamp_users_rate_movies ON users_rate_movies;
+
+DROP TABLE IF EXISTS users_rate_movies;
@@ -0,0 +1,31 @@
+CREATE TABLE users_rate_movies (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER                             NOT NULL,
+    movie_id   INTEGER                             NOT NULL,
+    rating     SMALLINT                            NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT fk_movies FOREIGN KEY (movie_id) REFERENCES movies (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT unique_user_movie_rating UNIQUE (user_id, movie_id) -- Ensures a user can rate a movie only once
+);
+
+CREATE INDEX idx_users_rate_movies_movie_id ON users_rate_movies (movie_id);
+
+CREATE TRIGGER set_timestamp_users_rate_movies
+    BEFORE UPDATE
+    ON users_rate_movies
+    FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+-- Number of user ratings behind movies.user_rating
+ALTER TABLE movies
+    ADD COLUMN rating_count INTEGER DEFAULT 0 NOT NULL;
+
+-- The catalog's own rating, which user_rating falls back to while nobody has rated the movie
+ALTER TABLE movies
+    ADD COLUMN editorial_rating DECIMAL(2, 1) CHECK (editorial_rating BETWEEN 0 AND 5);
+
+UPDATE movies
+SET editorial_rating = user_rating;
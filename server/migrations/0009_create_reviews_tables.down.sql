@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS reviews_helpful_votes;
+
+DROP TRIGGER IF EXISTS set_timestamp_reviews ON reviews;
+
+DROP TABLE IF EXISTS reviews;
@@ -0,0 +1,38 @@
+CREATE TABLE reviews (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER                                 NOT NULL,
+    movie_id     INTEGER                                 NOT NULL,
+    body         TEXT                                    NOT NULL,
+    status       VARCHAR(20) DEFAULT 'pending'           NOT NULL CHECK (status IN ('pending', 'approved', 'rejected')),
+    moderated_by INTEGER,
+    moderated_at TIMESTAMP,
+    created_at   TIMESTAMP   DEFAULT CURRENT_TIMESTAMP   NOT NULL,
+    updated_at   TIMESTAMP   DEFAULT CURRENT_TIMESTAMP   NOT NULL,
+
+    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT fk_movies FOREIGN KEY (movie_id) REFERENCES movies (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT fk_moderators FOREIGN KEY (moderated_by) REFERENCES users (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT unique_user_movie_review UNIQUE (user_id, movie_id) -- Ensures a user can review a movie only once
+);
+
+CREATE INDEX idx_reviews_movie_id_status ON reviews (movie_id, status, id);
+CREATE INDEX idx_reviews_status ON reviews (status, id);
+
+CREATE TRIGGER set_timestamp_reviews
+    BEFORE UPDATE
+    ON reviews
+    FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE reviews_helpful_votes (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER                             NOT NULL,
+    review_id  INTEGER                             NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT fk_reviews FOREIGN KEY (review_id) REFERENCES reviews (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT unique_user_review_vote UNIQUE (user_id, review_id) -- Ensures a user can vote for a review only once
+);
+
+CREATE INDEX idx_reviews_helpful_votes_review_id ON reviews_helpful_votes (review_id);
@@ -0,0 +1,4 @@
+-- Access level for /api/admin: editors manage the catalog and reviews, admins also manage users
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(20) DEFAULT 'user' NOT NULL
+        CONSTRAINT check_users_role CHECK (role IN ('user', 'editor', 'admin'));
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS user_list_entries;
+
+DROP TRIGGER IF EXISTS set_timestamp_user_lists ON user_lists;
+
+DROP TABLE IF EXISTS user_lists;
@@ -0,0 +1,35 @@
+CREATE TABLE user_lists (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER                                 NOT NULL,
+    name        VARCHAR(100)                            NOT NULL,
+    description TEXT        DEFAULT ''                  NOT NULL,
+    visibility  VARCHAR(20) DEFAULT 'private'           NOT NULL CHECK (visibility IN ('private', 'unlisted', 'public')),
+    share_token VARCHAR(32)                             NOT NULL,
+    created_at  TIMESTAMP   DEFAULT CURRENT_TIMESTAMP   NOT NULL,
+    updated_at  TIMESTAMP   DEFAULT CURRENT_TIMESTAMP   NOT NULL,
+
+    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT unique_user_list_share_token UNIQUE (share_token)
+);
+
+CREATE INDEX idx_user_lists_user_id ON user_lists (user_id);
+
+CREATE TRIGGER set_timestamp_user_lists
+    BEFORE UPDATE
+    ON user_lists
+    FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE user_list_entries (
+    id         SERIAL PRIMARY KEY,
+    list_id    INTEGER                             NOT NULL,
+    movie_id   INTEGER                             NOT NULL,
+    position   INTEGER                             NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_user_lists FOREIGN KEY (list_id) REFERENCES user_lists (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT fk_movies FOREIGN KEY (movie_id) REFERENCES movies (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT unique_list_movie UNIQUE (list_id, movie_id) -- A movie appears in a list at most once
+);
+
+CREATE INDEX idx_user_list_entries_list_id_position ON user_list_entries (list_id, position);
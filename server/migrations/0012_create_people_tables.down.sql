@@ -0,0 +1,32 @@
+DROP TRIGGER IF EXISTS refresh_search_vector_people ON people;
+
+DROP FUNCTION IF EXISTS refresh_movie_search_vector_from_people;
+
+DROP TRIGGER IF EXISTS refresh_search_vector_movie_credits ON movie_credits;
+
+DROP FUNCTION IF EXISTS refresh_movie_search_vector_from_credits;
+
+-- Restore the title and description only search vector
+CREATE OR REPLACE FUNCTION update_movies_search_vector()
+    RETURNS TRIGGER
+AS $$
+BEGIN
+    new.search_vector =
+            setweight(to_tsvector('english', coalesce(new.title, '')), 'A') ||
+            setweight(to_tsvector('english', coalesce(new.description, '')), 'B');
+    RETURN new;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS build_movie_search_vector;
+
+DROP TABLE IF EXISTS movie_credits;
+
+DROP TRIGGER IF EXISTS set_timestamp_people ON people;
+
+DROP TABLE IF EXISTS people;
+
+UPDATE movies
+SET search_vector =
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B');
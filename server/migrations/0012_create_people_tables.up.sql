@@ -0,0 +1,108 @@
+CREATE TABLE people (
+    id         SERIAL PRIMARY KEY,
+    name       VARCHAR(255)                        NOT NULL,
+    biography  TEXT,
+    birth_date DATE,
+    image      VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_people_name ON people (name);
+
+CREATE TRIGGER set_timestamp_people
+    BEFORE UPDATE
+    ON people
+    FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE movie_credits (
+    id             SERIAL PRIMARY KEY,
+    movie_id       INTEGER                             NOT NULL,
+    person_id      INTEGER                             NOT NULL,
+    role           VARCHAR(20)                         NOT NULL CHECK (role IN ('actor', 'director', 'writer', 'composer')),
+    character_name VARCHAR(255), -- Only set for actors
+    billing_order  INTEGER,      -- Only set for actors, lower is billed first
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_movies FOREIGN KEY (movie_id) REFERENCES movies (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT fk_people FOREIGN KEY (person_id) REFERENCES people (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT unique_movie_person_role UNIQUE (movie_id, person_id, role) -- A person holds each role on a movie once
+);
+
+CREATE INDEX idx_movie_credits_person_id ON movie_credits (person_id);
+
+-- Title matches rank above description matches, which rank above cast and crew names
+CREATE FUNCTION build_movie_search_vector(movie_id INTEGER, title TEXT, description TEXT)
+    RETURNS TSVECTOR
+AS $$
+SELECT
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce((
+                                                  SELECT
+                                                      string_agg(p.name, ' ')
+                                                  FROM
+                                                      movie_credits mc
+                                                          JOIN people p ON mc.person_id = p.id
+                                                  WHERE
+                                                      mc.movie_id = build_movie_search_vector.movie_id
+                                              ), '')), 'C');
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION update_movies_search_vector()
+    RETURNS TRIGGER
+AS $$
+BEGIN
+    new.search_vector = build_movie_search_vector(new.id, new.title, new.description);
+    RETURN new;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Keep the movie's search vector in sync when its credits change
+CREATE FUNCTION refresh_movie_search_vector_from_credits()
+    RETURNS TRIGGER
+AS $$
+BEGIN
+    UPDATE movies m
+    SET search_vector = build_movie_search_vector(m.id, m.title, m.description)
+    WHERE
+        m.id IN (
+                 CASE WHEN tg_op <> 'INSERT' THEN old.movie_id END,
+                 CASE WHEN tg_op <> 'DELETE' THEN new.movie_id END
+            );
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER refresh_search_vector_movie_credits
+    AFTER INSERT OR UPDATE OR DELETE
+    ON movie_credits
+    FOR EACH ROW
+EXECUTE FUNCTION refresh_movie_search_vector_from_credits();
+
+-- Keep the search vectors of a person's movies in sync when the person is renamed
+CREATE FUNCTION refresh_movie_search_vector_from_people()
+    RETURNS TRIGGER
+AS $$
+BEGIN
+    UPDATE movies m
+    SET search_vector = build_movie_search_vector(m.id, m.title, m.description)
+    WHERE
+        m.id IN (
+                    SELECT
+                        mc.movie_id
+                    FROM
+                        movie_credits mc
+                    WHERE
+                        mc.person_id = new.id
+                );
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER refresh_search_vector_people
+    AFTER UPDATE OF name
+    ON people
+    FOR EACH ROW
+EXECUTE FUNCTION refresh_movie_search_vector_from_people();
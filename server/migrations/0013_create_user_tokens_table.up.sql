@@ -0,0 +1,19 @@
+-- Set once the user proves they own the email address; NULL until then
+ALTER TABLE users
+    ADD COLUMN email_verified_at TIMESTAMP;
+
+-- Single-use tokens sent by email. Only their SHA-256 is stored, so a database leak does not leak usable tokens.
+CREATE TABLE user_tokens (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER                             NOT NULL,
+    purpose    VARCHAR(30)                         NOT NULL CHECK (purpose IN ('password_reset', 'email_verification')),
+    token_hash VARCHAR(64)                         NOT NULL,
+    expires_at TIMESTAMP                           NOT NULL,
+    used_at    TIMESTAMP, -- Set when the token is redeemed or superseded
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT unique_user_token_hash UNIQUE (token_hash)
+);
+
+CREATE INDEX idx_user_tokens_user_id_purpose ON user_tokens (user_id, purpose);
@@ -0,0 +1,18 @@
+-- TOTP two-factor authentication. The secret is encrypted by the application; it is set but not yet enabled
+-- while enrollment waits for the first code.
+ALTER TABLE users
+    ADD COLUMN totp_secret         BYTEA,
+    ADD COLUMN totp_enabled_at     TIMESTAMP,
+    ADD COLUMN totp_last_used_step BIGINT; -- Rejects replays of a code within its time step
+
+-- One-time codes that stand in for a TOTP code when the authenticator is lost. Only their SHA-256 is stored.
+CREATE TABLE user_recovery_codes (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER                             NOT NULL,
+    code_hash  VARCHAR(64)                         NOT NULL,
+    used_at    TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT unique_user_recovery_code UNIQUE (user_id, code_hash)
+);
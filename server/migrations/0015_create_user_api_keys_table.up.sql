@@ -0,0 +1,19 @@
+-- Personal API keys for scripted access. Only their SHA-256 is stored; the prefix identifies a key in listings.
+CREATE TABLE user_api_keys (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER                             NOT NULL,
+    name         VARCHAR(100)                        NOT NULL,
+    prefix       VARCHAR(16)                         NOT NULL,
+    key_hash     VARCHAR(64)                         NOT NULL,
+    scopes       TEXT[]                              NOT NULL,
+    last_used_at TIMESTAMP,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT unique_user_api_key_hash UNIQUE (key_hash),
+    CONSTRAINT check_user_api_key_scopes CHECK (
+        CARDINALITY(scopes) > 0 AND scopes <@ ARRAY ['read', 'likes', 'admin']::TEXT[]
+    )
+);
+
+CREATE INDEX idx_user_api_keys_user_id ON user_api_keys (user_id);
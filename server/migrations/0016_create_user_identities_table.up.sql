@@ -0,0 +1,14 @@
+-- Accounts at OAuth and OpenID Connect providers that sign in to a user, one per provider and user.
+-- subject is the provider's stable user ID; email is what the provider reported, for display.
+CREATE TABLE user_identities (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER                             NOT NULL,
+    provider   VARCHAR(50)                         NOT NULL,
+    subject    VARCHAR(255)                        NOT NULL,
+    email      VARCHAR(255)                        NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT unique_user_identity UNIQUE (provider, subject),
+    CONSTRAINT unique_user_identity_provider UNIQUE (user_id, provider)
+);
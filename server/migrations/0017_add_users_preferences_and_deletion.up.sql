@@ -0,0 +1,6 @@
+-- Settings the frontend keeps for the user, stored as the JSON object it sends
+ALTER TABLE users
+    ADD COLUMN preferences JSONB DEFAULT '{}' NOT NULL,
+    ADD COLUMN deleted_at  TIMESTAMP; -- Set while the account waits out its deletion grace period
+
+CREATE INDEX idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
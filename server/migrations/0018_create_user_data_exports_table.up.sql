@@ -0,0 +1,20 @@
+-- Archives of everything stored about a user, assembled in the background on request. The archive is downloaded
+-- through a mailed link whose token is stored only as its SHA-256; exports are deleted once expires_at passes.
+CREATE TABLE user_data_exports (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER                             NOT NULL,
+    status       VARCHAR(20) DEFAULT 'pending'       NOT NULL CHECK (status IN ('pending', 'ready', 'failed')),
+    token_hash   VARCHAR(64), -- Set with the archive, once the export is ready
+    archive      BYTEA,
+    claimed_at   TIMESTAMP, -- Set when a worker starts assembling the archive
+    completed_at TIMESTAMP,
+    expires_at   TIMESTAMP,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT unique_user_data_export_token_hash UNIQUE (token_hash)
+);
+
+-- A user has at most one export in progress
+CREATE UNIQUE INDEX unique_user_data_export_pending ON user_data_exports (user_id) WHERE status = 'pending';
+CREATE INDEX idx_user_data_exports_expires_at ON user_data_exports (expires_at) WHERE expires_at IS NOT NULL;
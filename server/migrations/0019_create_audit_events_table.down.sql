@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS append_only_audit_events ON audit_events;
+
+DROP TABLE IF EXISTS audit_events;
+
+DROP FUNCTION IF EXISTS reject_audit_event_change;
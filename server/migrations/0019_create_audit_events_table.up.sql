@@ -0,0 +1,31 @@
+-- Append-only record of admin writes: who changed which entity, from which request and address. before and after
+-- hold only the fields the write changed. actor_id has no foreign key, so events outlive the actor's account.
+CREATE TABLE audit_events (
+    id          SERIAL PRIMARY KEY,
+    actor_id    INTEGER                             NOT NULL,
+    action      VARCHAR(50)                         NOT NULL,
+    entity_type VARCHAR(50)                         NOT NULL,
+    entity_id   INTEGER                             NOT NULL,
+    request_id  VARCHAR(64)                         NOT NULL,
+    ip          TEXT                                NOT NULL,
+    before      JSONB,
+    after       JSONB,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX idx_audit_events_entity ON audit_events (entity_type, entity_id);
+
+CREATE FUNCTION reject_audit_event_change()
+    RETURNS TRIGGER
+AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER append_only_audit_events
+    BEFORE UPDATE OR DELETE
+    ON audit_events
+    FOR EACH ROW
+EXECUTE FUNCTION reject_audit_event_change();